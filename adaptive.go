@@ -0,0 +1,153 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 自适应并发的过载判断，Load 不为nil 时只使用Load
+type ConcurrencyTarget struct {
+	// 单条数据执行耗时的目标，超过时视为过载
+	Latency time.Duration
+	// 注入的负载信号，例如CPU 使用率与目标的比值，返回值大于1 视为过载
+	Load func() float64
+}
+
+// 按AIMD 调整批量执行（HandleBatchGrouped）和流式执行（HandleStream）的并发数，范围为[min, max]，
+// 初始为min：每执行完一条数据检查一次是否过载，过载时并发数减半，之前已经开始执行的数据不再重复减半；
+// 连续当前并发数条数据没有过载时并发数加1
+// 启动的goroutine 数量仍然由 WithBatchConcurrency、WithStreamConcurrency 决定，自适应的并发数只限制其中同时执行的数量
+// 开启 WithStageParallelism 时，同一次执行中同时执行的节点数也按同样的方法调整，按节点（工作节点链）的执行耗时判断过载，
+// 与批量、流式执行的并发数分别计算，实际不超过 WithStageParallelism 的槽位数
+// 耗时由 WithClock 的时间来源测量，调整只发生在数据执行完时，不依赖定时器
+func WithAdaptiveConcurrency(min, max int, target ConcurrencyTarget) Option {
+	return func(m *Manager) {
+		if min < 1 {
+			min = 1
+		}
+		if max < min {
+			max = min
+		}
+		m.adaptive = &adaptiveLimiter{min: min, max: max, limit: min, target: target, wake: make(chan struct{})}
+		m.adaptiveBranches = &adaptiveLimiter{min: min, max: max, limit: min, target: target, wake: make(chan struct{})}
+	}
+}
+
+// 当前自适应的并发数，没有开启 WithAdaptiveConcurrency 时返回0
+func (m *Manager) ConcurrencyLimit() int {
+	if m.adaptive == nil {
+		return 0
+	}
+	return m.adaptive.current()
+}
+
+// 开启 WithStageParallelism 时一次执行中同时执行的节点数，没有开启 WithAdaptiveConcurrency 时返回0
+func (m *Manager) BranchConcurrencyLimit() int {
+	if m.adaptive == nil || m.parallelism <= 0 {
+		return 0
+	}
+	return m.branchLimit()
+}
+
+// 并行执行时同时执行的节点数，开启 WithAdaptiveConcurrency 时不超过自适应的并发数
+func (m *Manager) branchLimit() int {
+	if m.adaptiveBranches == nil {
+		return m.parallelism
+	}
+	if n := m.adaptiveBranches.current(); n < m.parallelism {
+		return n
+	}
+	return m.parallelism
+}
+
+type adaptiveLimiter struct {
+	mu       sync.Mutex
+	min, max int
+	target   ConcurrencyTarget
+	// 当前的并发数，以及正在执行的数量
+	limit, active int
+	// 连续没有过载的条数，以及减半后仍要忽略过载的条数
+	good, cooldown int
+	// 有空闲名额时关闭并替换
+	wake chan struct{}
+}
+
+func (a *adaptiveLimiter) acquire(ctx context.Context) error {
+	for {
+		a.mu.Lock()
+		if a.active < a.limit {
+			a.active++
+			a.mu.Unlock()
+			return nil
+		}
+		wake := a.wake
+		a.mu.Unlock()
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// 不等待名额直接开始执行，由调用方保证不超过 current，用于并行执行的节点
+func (a *adaptiveLimiter) start() {
+	a.mu.Lock()
+	a.active++
+	a.mu.Unlock()
+}
+
+func (a *adaptiveLimiter) current() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.limit
+}
+
+// 记录一条数据的执行耗时并释放名额
+func (a *adaptiveLimiter) release(latency time.Duration) {
+	var overloaded bool
+	if a.target.Load != nil {
+		overloaded = a.target.Load() > 1
+	} else {
+		overloaded = a.target.Latency > 0 && latency > a.target.Latency
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.active--
+	switch {
+	case overloaded && a.cooldown > 0:
+		a.cooldown--
+	case overloaded:
+		a.good = 0
+		if a.limit /= 2; a.limit < a.min {
+			a.limit = a.min
+		}
+		// 正在执行的数据是在减半之前开始的
+		a.cooldown = a.active
+	default:
+		if a.cooldown > 0 {
+			a.cooldown--
+		}
+		if a.good++; a.good >= a.limit && a.limit < a.max {
+			a.good = 0
+			a.limit++
+		}
+	}
+	close(a.wake)
+	a.wake = make(chan struct{})
+}
+
+// 开启 WithAdaptiveConcurrency 时在自适应的并发数内执行
+func (m *Manager) handleAdaptive(ctx context.Context, in *rawData) (*rawData, error) {
+	if m.adaptive == nil {
+		return m.HandleContext(ctx, in)
+	}
+	if err := m.adaptive.acquire(ctx); err != nil {
+		return nil, err
+	}
+	start := m.clock.Now()
+	out, err := m.HandleContext(ctx, in)
+	m.adaptive.release(m.clock.Now().Sub(start))
+	return out, err
+}
@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestManager_AdaptiveConcurrency(t *testing.T) {
+	clock := newFakeClock()
+	latency := 10 * time.Millisecond
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		clock.Advance(latency)
+		return in, nil
+	}, WithClock(clock), WithAdaptiveConcurrency(1, 8, ConcurrencyTarget{Latency: 100 * time.Millisecond}))
+	// 每次执行一条数据，返回执行后的并发数
+	step := func() int {
+		if _, err := m.HandleBatchGrouped(context.Background(), map[string][]*rawData{"g": {{}}}, WithBatchConcurrency(1)); err != nil {
+			t.Fatal(err)
+		}
+		return m.ConcurrencyLimit()
+	}
+	// 加性增加：并发数为n 时连续n 条没有过载才加1
+	var ramp []int
+	for i := 0; i < 10; i++ {
+		ramp = append(ramp, step())
+	}
+	if want := []int{2, 2, 3, 3, 3, 4, 4, 4, 4, 5}; !reflect.DeepEqual(ramp, want) {
+		t.Errorf("ramp up = %v, want %v", ramp, want)
+	}
+	for i := 0; i < 30; i++ {
+		step()
+	}
+	if got := m.ConcurrencyLimit(); got != 8 {
+		t.Errorf("limit should be clamped to max 8, got %d", got)
+	}
+
+	// 乘性减少，最低为min
+	latency = 200 * time.Millisecond
+	var backoff []int
+	for i := 0; i < 5; i++ {
+		backoff = append(backoff, step())
+	}
+	if want := []int{4, 2, 1, 1, 1}; !reflect.DeepEqual(backoff, want) {
+		t.Errorf("back off = %v, want %v", backoff, want)
+	}
+
+	latency = 10 * time.Millisecond
+	if got := step(); got != 2 {
+		t.Errorf("limit should ramp up again after recovery, got %d", got)
+	}
+	if got := m.Capabilities().ConcurrencyLimit; got != 2 {
+		t.Errorf("capabilities should report the current limit, got %d", got)
+	}
+}
+
+func TestManager_AdaptiveConcurrencyLoadSignal(t *testing.T) {
+	var load int64 = 2
+	m := newSingleWorkerManager(t, passWorker, WithAdaptiveConcurrency(2, 4, ConcurrencyTarget{
+		Latency: time.Nanosecond,
+		Load:    func() float64 { return float64(atomic.LoadInt64(&load)) },
+	}))
+	items := map[string][]*rawData{"g": {{}, {}, {}, {}}}
+	if _, err := m.HandleBatchGrouped(context.Background(), items, WithBatchConcurrency(1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.ConcurrencyLimit(); got != 2 {
+		t.Errorf("overloaded signal should keep the limit at min, got %d", got)
+	}
+	atomic.StoreInt64(&load, 0)
+	// 2 -> 3 需要2 条，3 -> 4 需要3 条
+	items["g"] = append(items["g"], &rawData{})
+	if _, err := m.HandleBatchGrouped(context.Background(), items, WithBatchConcurrency(1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.ConcurrencyLimit(); got != 4 {
+		t.Errorf("load signal should override latency, want 4, got %d", got)
+	}
+}
+
+func TestManager_AdaptiveConcurrencyLimitsWorkers(t *testing.T) {
+	var active, peak int64
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		n := atomic.AddInt64(&active, 1)
+		for {
+			p := atomic.LoadInt64(&peak)
+			if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt64(&active, -1)
+		return in, nil
+	}, WithAdaptiveConcurrency(2, 2, ConcurrencyTarget{}))
+	var items []*rawData
+	for i := 0; i < 40; i++ {
+		items = append(items, &rawData{})
+	}
+	if _, err := m.HandleBatchGrouped(context.Background(), map[string][]*rawData{"g": items}, WithBatchConcurrency(8)); err != nil {
+		t.Fatal(err)
+	}
+	if peak > 2 {
+		t.Errorf("at most 2 executions should run at once, got %d", peak)
+	}
+}
+
+// 测试并行执行时同时执行的节点数按负载调整：过载时各分支依次执行，恢复后逐步增加到槽位数
+func TestManager_AdaptiveBranchConcurrency(t *testing.T) {
+	var load int64 = 2
+	var running, peak int64
+	work := func(string) {
+		n := atomic.AddInt64(&running, 1)
+		for {
+			p := atomic.LoadInt64(&peak)
+			if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt64(&running, -1)
+	}
+	m := newMultiDiamondManager(t, 1, 4, work, WithStageParallelism(), WithAdaptiveConcurrency(1, 8, ConcurrencyTarget{
+		Load: func() float64 { return float64(atomic.LoadInt64(&load)) },
+	}))
+	m.parallelism = 3
+	for i := 0; i < 3; i++ {
+		if _, err := m.Handle(&rawData{Data: "in"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := m.BranchConcurrencyLimit(); got != 1 || atomic.LoadInt64(&peak) != 1 {
+		t.Errorf("limit=%d peak=%d, want overloaded branches to run one at a time", got, peak)
+	}
+	atomic.StoreInt64(&load, 0)
+	for i := 0; i < 10; i++ {
+		if _, err := m.Handle(&rawData{Data: "in"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 自适应的并发数继续增加，实际不超过槽位数
+	if got := m.Capabilities().BranchConcurrencyLimit; got != 3 {
+		t.Errorf("branch limit=%d, want the slot count 3 after recovery", got)
+	}
+	if got := m.ConcurrencyLimit(); got != 1 {
+		t.Errorf("batch limit=%d, want it untouched by single executions", got)
+	}
+}
@@ -0,0 +1,46 @@
+package pipeline
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// 设置复制节点数据的方法，保存数据（死信、血缘）时用它代替引用
+// 死信保存执行开始时输入的副本，工作节点返回自己的输入时后续节点和血缘使用它的副本
+func WithCloner(f func(in *rawData) *rawData) Option {
+	return func(m *Manager) {
+		m.cloner = f
+	}
+}
+
+// 工作节点返回了自己的输入，并且本次执行会保存数据（死信、血缘）时，设置了 WithCloner 就返回输出的副本，
+// 否则通过 Env 的日志提醒一次：死信保存的会是修改后的输入，血缘也会写入调用方传入的对象的Meta 中
+func (e *execution) checkAliasing(ctx context.Context, node *Node, in, out *rawData) *rawData {
+	if out != in || in == nil {
+		return out
+	}
+	var option string
+	switch {
+	case e.lineage:
+		option = "WithLineage"
+	case e.m.deadLetters != nil && !e.noDeadLetter:
+		option = "WithDeadLetterStore"
+	default:
+		return out
+	}
+	if e.m.cloner != nil {
+		return e.m.cloner(out)
+	}
+	if !atomic.CompareAndSwapInt32(&node.aliasWarned, 0, 1) {
+		return out
+	}
+	finding := LintFinding{
+		Node:    node.nodeName,
+		Option:  option,
+		Message: "worker returned its input pointer; stored data will reflect in-place mutations, see WithCloner",
+	}
+	if l := EnvFrom(ctx).Logger; l != nil {
+		l.Printf("pipeline: %s", finding)
+	}
+	return out
+}
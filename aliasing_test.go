@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/caigoumiao/pipeline/store"
+)
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+// 测试工作节点返回自己的输入时，保存死信、血缘的执行通过日志提醒一次
+func TestManager_ReturnedInputWarning(t *testing.T) {
+	inPlace := func(ctx context.Context, in *rawData) (*rawData, error) {
+		in.Data = in.Data.(string) + "!"
+		return in, nil
+	}
+	errDown := errors.New("downstream unavailable")
+	fail := func(ctx context.Context, in *rawData) (*rawData, error) { return nil, errDown }
+	newManager := func(logger Logger, opts ...Option) *Manager {
+		m := NewManager(append(opts, WithEnv(Env{Logger: logger}))...)
+		_ = m.AddWorkerNode("w1", inPlace)
+		_ = m.AddWorkerNode("w2", fail)
+		if err := m.BuildPipeline([][]string{{Head, "w1"}, {"w1", "w2"}, {"w2", Tail}}); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	logger := &recordingLogger{}
+	m := newManager(logger, WithDeadLetterStore(store.NewMemory()))
+	for i := 0; i < 2; i++ {
+		if _, err := m.HandleContext(context.Background(), &rawData{Data: "a"}); !errors.Is(err, errDown) {
+			t.Fatalf("err=%v, want errDown", err)
+		}
+	}
+	want := "pipeline: node[w1] WithDeadLetterStore: worker returned its input pointer; stored data will reflect in-place mutations, see WithCloner"
+	if len(logger.lines) != 1 || logger.lines[0] != want {
+		t.Errorf("want one warning %q, got %q", want, logger.lines)
+	}
+
+	// 不保存数据时不提醒
+	logger = &recordingLogger{}
+	m = newManager(logger)
+	_, _ = m.HandleContext(context.Background(), &rawData{Data: "a"})
+	_, _ = m.HandleContext(context.Background(), &rawData{Data: "a"}, WithLineage())
+	if len(logger.lines) != 1 || logger.lines[0] != "pipeline: node[w1] WithLineage: worker returned its input pointer; stored data will reflect in-place mutations, see WithCloner" {
+		t.Errorf("want one lineage warning, got %q", logger.lines)
+	}
+}
+
+// 测试设置了 WithCloner 时死信保存执行开始时的输入，血缘不写入调用方传入的对象
+func TestManager_Cloner(t *testing.T) {
+	inPlace := func(ctx context.Context, in *rawData) (*rawData, error) {
+		in.Data = in.Data.(string) + "!"
+		return in, nil
+	}
+	errDown := errors.New("downstream unavailable")
+	fail := func(ctx context.Context, in *rawData) (*rawData, error) {
+		if in.Data == "stop!" {
+			return nil, errDown
+		}
+		return in, nil
+	}
+	clone := func(in *rawData) *rawData {
+		c := *in
+		c.Meta = make(map[string]interface{}, len(in.Meta))
+		for k, v := range in.Meta {
+			c.Meta[k] = v
+		}
+		return &c
+	}
+	logger := &recordingLogger{}
+	s := store.NewMemory()
+	m := NewManager(WithEnv(Env{Logger: logger}), WithDeadLetterStore(s), WithCloner(clone))
+	_ = m.AddWorkerNode("w1", inPlace)
+	_ = m.AddWorkerNode("w2", fail)
+	if err := m.BuildPipeline([][]string{{Head, "w1"}, {"w1", "w2"}, {"w2", Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.HandleContext(context.Background(), &rawData{Data: "stop"}); !errors.Is(err, errDown) {
+		t.Fatalf("err=%v, want errDown", err)
+	}
+	dls, err := DeadLetters(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dls) != 1 || dls[0].Input.Data != "stop" {
+		t.Errorf("want dead letter input %q, got %+v", "stop", dls)
+	}
+
+	in := &rawData{Data: "a"}
+	out, err := m.HandleContext(context.Background(), in, WithLineage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out == in || out.Data != "a!" || out.Meta[LineageMetaKey] == nil {
+		t.Errorf("want a cloned output with lineage, got %+v", out)
+	}
+	if _, ok := in.Meta[LineageMetaKey]; ok {
+		t.Errorf("lineage written into the caller's input: %+v", in.Meta)
+	}
+	if len(logger.lines) != 0 {
+		t.Errorf("want no warnings with a cloner, got %q", logger.lines)
+	}
+}
@@ -0,0 +1,259 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 判断节点可以把分支直接连到尾节点，选中该分支时判断节点的输入就是最终输出
+// 但如果判断节点位于分裂节点的某个分支内，直接结束会让后面的合并节点永远收不齐输入，构建时报错
+func (m *Manager) validateJudgerTailBranches() error {
+	// 每个节点所在的分裂层数，分裂节点之后加一，合并节点之后减一
+	level := map[*Node]int{m.nodes[m.headName]: 0}
+	queue := []*Node{m.nodes[m.headName]}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		l := level[node]
+		switch node.Typ {
+		case NodeTypDivider:
+			l++
+		case NodeTypMerger:
+			l--
+		}
+		for i, next := range node.Next {
+			if node.Typ == NodeTypJudger && next.Typ == NodeTypTail && level[node] > 0 {
+				return invalidNode(CodeJudgerTailInBranch, node.nodeName, fmt.Errorf("judger node[%s] routes branch[%s] to tail inside a divider branch, downstream merger would never be fed",
+					node.nodeName, node.branchName(i)))
+			}
+			if _, ok := level[next]; !ok {
+				level[next] = l
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// 返回从头节点到尾节点的最长路径的节点数以及该路径上的节点（不含虚拟头、尾节点）
+// 最长路径在BuildPipeline 时计算
+func (m *Manager) LongestPath() (int, []string, error) {
+	if !m.built {
+		return 0, nil, ErrorsPipelineNotBuilt
+	}
+	path := make([]string, len(m.depthPath))
+	copy(path, m.depthPath)
+	return m.depth, path, nil
+}
+
+// 计算最长路径，同时检查图中是否有环
+func (m *Manager) calLongestPath() error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[*Node]int)
+	depth := make(map[*Node]int)
+	next := make(map[*Node]*Node)
+	var dfs func(node *Node) error
+	dfs = func(node *Node) error {
+		switch state[node] {
+		case visiting:
+			return invalidNode(CodeCycle, node.nodeName, fmt.Errorf("%w: node[%s]", ErrorsPipelineHasCycle, node.nodeName))
+		case visited:
+			return nil
+		}
+		state[node] = visiting
+		for _, n := range node.Next {
+			if err := dfs(n); err != nil {
+				return err
+			}
+			if next[node] == nil || depth[n] > depth[next[node]] {
+				next[node] = n
+			}
+		}
+		if next[node] != nil {
+			depth[node] = depth[next[node]]
+		}
+		if node.Typ != NodeTypHead && node.Typ != NodeTypTail {
+			depth[node]++
+		}
+		state[node] = visited
+		return nil
+	}
+	head := m.nodes[m.headName]
+	if err := dfs(head); err != nil {
+		return err
+	}
+	var path []string
+	for p := next[head]; p != nil && p.Typ != NodeTypTail; p = next[p] {
+		path = append(path, p.nodeName)
+	}
+	m.depth, m.depthPath = depth[head], path
+	if m.maxDepth > 0 && m.depth > m.maxDepth {
+		return invalid(CodeTooDeep, fmt.Errorf("%w: depth=%d max=%d path=[%s]", ErrorsPipelineTooDeep, m.depth, m.maxDepth, strings.Join(path, "->")))
+	}
+	return nil
+}
+
+// MaxCostPath 最多枚举的判断节点决策组合数
+const maxCostCombinations = 4096
+
+var ErrorsTooManyCombinations = errors.New("too many judger decision combinations")
+
+// 没有给出判断节点的决策
+type missingDecisionError struct {
+	node *Node
+}
+
+func (e *missingDecisionError) Error() string {
+	return fmt.Sprintf("judger node[%s] has no decision", e.node.nodeName)
+}
+
+// 按给定的判断节点决策遍历流程，返回会执行的节点（按广度优先的顺序，不含虚拟头、尾节点）
+// 判断节点只走决策的分支，分裂节点走所有分支
+func (m *Manager) walkDecisions(decisions map[string]int) ([]*Node, error) {
+	if !m.built {
+		return nil, ErrorsPipelineNotBuilt
+	}
+	var nodes []*Node
+	vis := make(map[*Node]bool)
+	queue := []*Node{m.nodes[m.headName]}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if vis[node] {
+			continue
+		}
+		vis[node] = true
+		next := node.Next
+		switch node.Typ {
+		case NodeTypHead, NodeTypTail:
+		case NodeTypJudger:
+			i, ok := decisions[node.nodeName]
+			if !ok {
+				return nil, &missingDecisionError{node: node}
+			}
+			if i < 0 || i >= len(node.Next) {
+				return nil, fmt.Errorf("judger node[%s] decision %d outbound, valid branches %s", node.nodeName, i, node.branchList())
+			}
+			next = node.Next[i : i+1]
+			nodes = append(nodes, node)
+		default:
+			nodes = append(nodes, node)
+		}
+		queue = append(queue, next...)
+	}
+	return nodes, nil
+}
+
+// 估算按给定的判断节点决策执行一次的成本，返回总成本以及会执行的节点
+func (m *Manager) EstimateCost(decisions map[string]int) (float64, []string, error) {
+	nodes, err := m.walkDecisions(decisions)
+	if err != nil {
+		return 0, nil, err
+	}
+	var total float64
+	for _, node := range nodes {
+		total += node.opts.cost
+	}
+	return total, nodeNames(nodes), nil
+}
+
+// 在所有判断节点决策的组合中找出成本最高的一种，返回总成本以及会执行的节点
+// 组合数超过上限时返回ErrorsTooManyCombinations
+func (m *Manager) MaxCostPath() (float64, []string, error) {
+	var best float64
+	var bestNames []string
+	err := m.eachDecisionPath(map[string]int{}, maxCostCombinations, func(nodes []*Node, decisions map[string]int) {
+		var total float64
+		for _, node := range nodes {
+			total += node.opts.cost
+		}
+		if bestNames == nil || total > best {
+			best, bestNames = total, nodeNames(nodes)
+		}
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return best, bestNames, nil
+}
+
+// 返回流程中所有不同的执行路径，每条路径是按执行顺序排列的节点名
+// 判断节点的每个分支各自形成路径，分裂节点的所有分支都会执行，合在同一条路径里
+// 路径数超过limit 时返回ErrorsTooManyCombinations
+func (m *Manager) Paths(limit int, opts ...PathOption) ([][]string, error) {
+	var o pathOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var paths [][]string
+	err := m.eachDecisionPath(map[string]int{}, limit, func(nodes []*Node, decisions map[string]int) {
+		path := nodeNames(nodes)
+		if o.virtualNodes {
+			path = append(append([]string{m.headName}, path...), m.tailName)
+		}
+		paths = append(paths, path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+type pathOptions struct {
+	virtualNodes bool
+}
+
+type PathOption func(o *pathOptions)
+
+// 路径中包含虚拟头、尾节点
+func WithVirtualNodes() PathOption {
+	return func(o *pathOptions) {
+		o.virtualNodes = true
+	}
+}
+
+// 对缺少决策的判断节点逐个尝试每个分支，每得到一组完整的决策调用一次f，decisions 为路径上判断节点的决策
+// 组合数超过limit 时返回ErrorsTooManyCombinations
+func (m *Manager) eachDecisionPath(decisions map[string]int, limit int, f func(nodes []*Node, decisions map[string]int)) error {
+	count := 0
+	var walk func(decisions map[string]int) error
+	walk = func(decisions map[string]int) error {
+		nodes, err := m.walkDecisions(decisions)
+		var missing *missingDecisionError
+		if !errors.As(err, &missing) {
+			if err != nil {
+				return err
+			}
+			if count++; count > limit {
+				return fmt.Errorf("%w: more than %d", ErrorsTooManyCombinations, limit)
+			}
+			f(nodes, decisions)
+			return nil
+		}
+		for i := range missing.node.Next {
+			d := make(map[string]int, len(decisions)+1)
+			for k, v := range decisions {
+				d[k] = v
+			}
+			d[missing.node.nodeName] = i
+			if err := walk(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(decisions)
+}
+
+func nodeNames(nodes []*Node) []string {
+	names := make([]string, len(nodes))
+	for i, node := range nodes {
+		names[i] = node.nodeName
+	}
+	return names
+}
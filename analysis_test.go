@@ -0,0 +1,256 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func passWorker(ctx context.Context, in *rawData) (out *rawData, err error) {
+	return in, nil
+}
+
+// 测试最长路径的上限：12个节点的直线流程，上限为10
+func TestManager_MaxDepth(t *testing.T) {
+	m := NewManager(WithMaxDepth(10))
+	edges := [][]string{{"head000", "w1"}}
+	for i := 1; i <= 12; i++ {
+		if err := m.AddWorkerNode(fmt.Sprintf("w%d", i), passWorker); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if i < 12 {
+			edges = append(edges, []string{fmt.Sprintf("w%d", i), fmt.Sprintf("w%d", i+1)})
+		}
+	}
+	edges = append(edges, []string{"w12", "tail111"})
+	err := m.BuildPipeline(edges)
+	if !errors.Is(err, ErrorsPipelineTooDeep) {
+		t.Errorf("err=%v, want ErrorsPipelineTooDeep", err)
+		t.FailNow()
+	}
+	if !strings.Contains(err.Error(), "w1->w2->w3") || !strings.Contains(err.Error(), "w12") {
+		t.Errorf("err=%v should name the witness path", err)
+	}
+}
+
+// 测试菱形结构的最长路径由较长的分支决定
+func TestManager_LongestPath(t *testing.T) {
+	m := NewManager()
+	if err := m.AddDividerNode("d", func(ctx context.Context, in *rawData) (out []*rawData, err error) {
+		return []*rawData{in, {Data: in.Data}}, nil
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	for _, name := range []string{"a1", "a2", "b1"} {
+		if err := m.AddWorkerNode(name, passWorker); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+	if err := m.AddMergerNode("m", func(ctx context.Context, in []*rawData) (out *rawData, err error) {
+		return in[0], nil
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if _, _, err := m.LongestPath(); err != ErrorsPipelineNotBuilt {
+		t.Errorf("err=%v, want ErrorsPipelineNotBuilt", err)
+	}
+	if err := m.BuildPipeline([][]string{
+		{"head000", "d"},
+		{"d", "b1"},
+		{"d", "a1"},
+		{"a1", "a2"},
+		{"a2", "m"},
+		{"b1", "m"},
+		{"m", "tail111"},
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	depth, path, err := m.LongestPath()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if depth != 4 || !reflect.DeepEqual(path, []string{"d", "a1", "a2", "m"}) {
+		t.Errorf("depth=%d path=%v, want 4 [d a1 a2 m]", depth, path)
+	}
+}
+
+// 测试判断节点不同决策下的成本
+func TestManager_EstimateCost(t *testing.T) {
+	m := NewManager()
+	if err := m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) (pipeIndex int) {
+		return 0
+	}, WithCost(0.5)); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := m.AddWorkerNode("cheap", passWorker, WithCost(1)); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := m.AddWorkerNode("remote", passWorker, WithCost(5)); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := m.AddWorkerNode("gpu", passWorker, WithCost(10)); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := m.BuildPipeline([][]string{
+		{"head000", "j1"},
+		{"j1", "cheap"},
+		{"j1", "remote"},
+		{"cheap", "tail111"},
+		{"remote", "gpu"},
+		{"gpu", "tail111"},
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	cases := []struct {
+		decision int
+		total    float64
+		nodes    []string
+	}{
+		{0, 1.5, []string{"j1", "cheap"}},
+		{1, 15.5, []string{"j1", "remote", "gpu"}},
+	}
+	for _, c := range cases {
+		total, nodes, err := m.EstimateCost(map[string]int{"j1": c.decision})
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if total != c.total || !reflect.DeepEqual(nodes, c.nodes) {
+			t.Errorf("decision %d: total=%v nodes=%v, want %v %v", c.decision, total, nodes, c.total, c.nodes)
+		}
+	}
+	if _, _, err := m.EstimateCost(nil); err == nil || !strings.Contains(err.Error(), "judger node[j1] has no decision") {
+		t.Errorf("err=%v, want missing decision", err)
+	}
+	total, nodes, err := m.MaxCostPath()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if total != 15.5 || !reflect.DeepEqual(nodes, []string{"j1", "remote", "gpu"}) {
+		t.Errorf("max total=%v nodes=%v", total, nodes)
+	}
+}
+
+// 测试决策组合数超过上限时报错
+func TestManager_MaxCostPathTooMany(t *testing.T) {
+	m := NewManager()
+	if err := m.AddDividerNode("d1", func(ctx context.Context, in *rawData) (out []*rawData, err error) {
+		return nil, nil
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	edges := [][]string{{"head000", "d1"}}
+	for i := 0; i < 13; i++ {
+		j := fmt.Sprintf("j%d", i)
+		if err := m.AddJudgerNode(j, func(ctx context.Context, in *rawData) (pipeIndex int) {
+			return 0
+		}); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		edges = append(edges, []string{"d1", j})
+		for _, w := range []string{j + "a", j + "b"} {
+			if err := m.AddWorkerNode(w, passWorker); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			edges = append(edges, []string{j, w}, []string{w, "tail111"})
+		}
+	}
+	if err := m.BuildPipeline(edges); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if _, _, err := m.MaxCostPath(); !errors.Is(err, ErrorsTooManyCombinations) {
+		t.Errorf("err=%v, want ErrorsTooManyCombinations", err)
+	}
+}
+
+// 构建分裂节点之后并列n 个判断节点的流程，每个判断节点有两个分支，共2^n 条路径
+func newJudgerFanoutManager(t *testing.T, n int) *Manager {
+	m := NewManager()
+	if err := m.AddDividerNode("d1", func(ctx context.Context, in *rawData) (out []*rawData, err error) {
+		return nil, nil
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	edges := [][]string{{Head, "d1"}}
+	for i := 1; i <= n; i++ {
+		j := fmt.Sprintf("j%d", i)
+		if err := m.AddJudgerNode(j, func(ctx context.Context, in *rawData) (pipeIndex int) {
+			return 0
+		}); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		edges = append(edges, []string{"d1", j})
+		for _, w := range []string{j + "a", j + "b"} {
+			if err := m.AddWorkerNode(w, passWorker); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			edges = append(edges, []string{j, w}, []string{w, Tail})
+		}
+	}
+	if err := m.BuildPipeline(edges); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	return m
+}
+
+// 测试枚举执行路径：两个判断节点共4 条路径，分裂节点的分支合在同一条路径里
+func TestManager_Paths(t *testing.T) {
+	m := newJudgerFanoutManager(t, 2)
+	paths, err := m.Paths(10)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	want := [][]string{
+		{"d1", "j1", "j2", "j1a", "j2a"},
+		{"d1", "j1", "j2", "j1a", "j2b"},
+		{"d1", "j1", "j2", "j1b", "j2a"},
+		{"d1", "j1", "j2", "j1b", "j2b"},
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths=%v, want=%v", paths, want)
+	}
+	paths, err = m.Paths(10, WithVirtualNodes())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if got := paths[0]; got[0] != Head || got[len(got)-1] != Tail {
+		t.Errorf("path=%v should include virtual nodes", got)
+	}
+}
+
+// 测试路径数超过上限时报错
+func TestManager_PathsTooMany(t *testing.T) {
+	m := newJudgerFanoutManager(t, 12)
+	if _, err := m.Paths(1000); !errors.Is(err, ErrorsTooManyCombinations) {
+		t.Errorf("err=%v, want ErrorsTooManyCombinations", err)
+	}
+	paths, err := m.Paths(1 << 12)
+	if err != nil || len(paths) != 1<<12 {
+		t.Errorf("len(paths)=%d err=%v", len(paths), err)
+	}
+}
@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+)
+
+// 工作节点直接调用处理方法，不经过中间件，不产生监听事件、健康统计和执行轨迹，
+// 也不计入 WithStallDetection 的进度，用于散射等调用次数很多、单次很轻的节点
+// 节点对可观测性是不可见的，BuildPipeline 会在 Lint 的结果中列出这样的节点；
+// 不能和需要拦截调用的配置同时使用（重试、超时、剩余时间跳过、输入大小、版本、有预算的阶段、
+// 临界区的入口和出口、检查点），同时使用时构建报错；默认的重试和超时不用于这样的节点
+func WithBareExecution() NodeOption {
+	return func(o *nodeOptions) {
+		o.bare = true
+		o.use("WithBareExecution", NodeTypWorker)
+	}
+}
+
+// 直接执行的节点不能使用需要拦截调用的配置
+func (m *Manager) validateBareNodes() error {
+	for _, node := range m.nodes {
+		if !node.opts.bare || node.Typ != NodeTypWorker {
+			continue
+		}
+		if conflict := m.bareConflict(node); conflict != "" {
+			return invalidNode(CodeBadOption, node.nodeName, fmt.Errorf("node[%s] WithBareExecution cannot be used with %s", node.nodeName, conflict))
+		}
+	}
+	return nil
+}
+
+// 返回和直接执行冲突的配置，没有冲突时返回空
+func (m *Manager) bareConflict(node *Node) string {
+	o := &node.opts
+	switch {
+	case o.retry != nil:
+		return "WithRetry"
+	case o.timeout > 0:
+		return "WithTimeout"
+	case o.skipIfRemaining > 0:
+		return "WithSkipIfRemaining"
+	case o.maxInputSize != nil:
+		return "WithMaxInputSize"
+	case len(node.variants) > 0:
+		return "AddWorkerVariant"
+	case o.checkpoint:
+		return "WithCheckpoint"
+	case node.stage != "" && m.stageTimeouts[node.stage] > 0:
+		return fmt.Sprintf("stage[%s] timeout", node.stage)
+	}
+	if s := node.section; s != nil && (s.first == node || s.last == node) {
+		return fmt.Sprintf("critical section[%s] boundary", s.name)
+	}
+	return ""
+}
+
+// 列出直接执行的节点，提醒它们不会出现在监听事件、健康统计和执行轨迹中
+func lintBareNodes(order []*Node) []LintFinding {
+	var findings []LintFinding
+	for _, node := range order {
+		if !node.opts.bare || node.Typ != NodeTypWorker {
+			continue
+		}
+		findings = append(findings, LintFinding{
+			Node:    node.nodeName,
+			Option:  "WithBareExecution",
+			Message: "called without middleware, listener events, health stats or trace entries",
+		})
+	}
+	return findings
+}
+
+// 直接执行节点的处理方法
+func (e *execution) callBare(ctx context.Context, node *Node, action WorkerFunc, in *rawData) (*rawData, error) {
+	var out *rawData
+	err := e.call(e.nodeContext(ctx, node), func(ctx context.Context) (err error) {
+		out, err = action(ctx, in)
+		return
+	})
+	e.last = callInfo{branch: -1, attempts: 1}
+	if err != nil {
+		return nil, newNodeError(node, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,141 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// 中间件只记录经过的节点
+func recordingMiddleware(seen map[string]int) MiddlewareSet {
+	return MiddlewareSet{Name: "record", Worker: func(node NodeInfo, next NodeInvoker) NodeInvoker {
+		return func(ctx context.Context, in *rawData) (*rawData, error) {
+			seen[node.Name]++
+			return next(ctx, in)
+		}
+	}}
+}
+
+// 测试直接执行的节点不出现在监听事件、中间件、执行轨迹和健康状况中，前后的节点正常出现
+func TestManager_BareExecution(t *testing.T) {
+	var events []string
+	seen := make(map[string]int)
+	m := NewManager(WithListener(func(ev NodeEvent) {
+		events = append(events, ev.Node)
+	}), WithMiddleware(recordingMiddleware(seen)))
+	_ = m.AddWorkerNode("pre", passWorker)
+	_ = m.AddWorkerNode("hot", func(ctx context.Context, in *rawData) (*rawData, error) {
+		if in.Data == "fail" {
+			return nil, errors.New("hot failed")
+		}
+		return &rawData{Data: in.Data.(int) + 1}, nil
+	}, WithBareExecution())
+	_ = m.AddWorkerNode("post", passWorker)
+	if err := m.BuildPipeline([][]string{{Head, "pre"}, {"pre", "hot"}, {"hot", "post"}, {"post", Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	trace := &Trace{}
+	out, err := m.HandleContext(context.Background(), &rawData{Data: 1}, WithTrace(trace))
+	if err != nil || out.Data != 2 {
+		t.Fatalf("out=%v err=%v", out, err)
+	}
+	var traced []string
+	for _, entry := range trace.Entries() {
+		traced = append(traced, entry.Node)
+	}
+	if fmt.Sprint(traced) != "[pre post]" || fmt.Sprint(events) != "[pre post]" {
+		t.Errorf("trace %v events %v, want only pre and post", traced, events)
+	}
+	if seen["hot"] != 0 || seen["pre"] != 1 || seen["post"] != 1 {
+		t.Errorf("middleware saw %v, want pre and post once", seen)
+	}
+
+	// 失败时仍然返回节点的错误，但健康状况中没有该节点
+	_, err = m.Handle(&rawData{Data: "fail"})
+	var ne *NodeError
+	if !errors.As(err, &ne) || ne.Node != "hot" {
+		t.Fatalf("err=%v, want NodeError of hot", err)
+	}
+	if report := m.Health(context.Background()); len(report.FailingNodes) != 0 {
+		t.Errorf("failing nodes %v, want bare node hidden from health", report.FailingNodes)
+	}
+}
+
+// 测试直接执行的节点出现在 Lint 的结果中，并且不使用默认的重试和超时
+func TestManager_BareExecutionLint(t *testing.T) {
+	m := NewManager(WithDefaultRetry(3, nil), WithDefaultNodeTimeout(time.Second))
+	_ = m.AddWorkerNode("hot", passWorker, WithBareExecution())
+	if err := m.BuildPipeline([][]string{{Head, "hot"}, {"hot", Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, f := range m.Lint() {
+		found = found || f.Node == "hot" && f.Option == "WithBareExecution"
+	}
+	if !found {
+		t.Errorf("lint %v, want a finding for the bare node", m.Lint())
+	}
+	if e := m.nodes["hot"].effective; e.retry != nil || e.timeout != 0 {
+		t.Errorf("bare node got defaults retry=%v timeout=%v", e.retry, e.timeout)
+	}
+}
+
+// 测试直接执行和需要拦截调用的配置一起使用时构建报错
+func TestManager_BareExecutionConflicts(t *testing.T) {
+	cases := map[string]NodeOption{
+		"WithRetry":           WithRetry(2),
+		"WithTimeout":         WithTimeout(time.Second),
+		"WithSkipIfRemaining": WithSkipIfRemaining(time.Second),
+	}
+	for name, opt := range cases {
+		m := NewManager()
+		_ = m.AddWorkerNode("hot", passWorker, WithBareExecution(), opt)
+		err := m.BuildPipeline([][]string{{Head, "hot"}, {"hot", Tail}})
+		var ve *ValidationError
+		if !errors.As(err, &ve) || ve.Code != CodeBadOption || ve.Node != "hot" {
+			t.Errorf("%s: err=%v, want bad option of hot", name, err)
+		}
+	}
+}
+
+func benchmarkScatter(b *testing.B, opts ...NodeOption) {
+	const n = 64
+	m := NewManager(WithListener(func(ev NodeEvent) {}), WithMiddleware(MiddlewareSet{Name: "noop", Worker: func(node NodeInfo, next NodeInvoker) NodeInvoker {
+		return next
+	}}))
+	_ = m.AddDividerNode("scatter", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		outs := make([]*rawData, n)
+		for i := range outs {
+			outs[i] = in
+		}
+		return outs, nil
+	})
+	_ = m.AddMergerNode("gather", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		return in[0], nil
+	})
+	edges := [][]string{{Head, "scatter"}, {"gather", Tail}}
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("w%d", i)
+		_ = m.AddWorkerNode(name, passWorker, opts...)
+		edges = append(edges, []string{"scatter", name}, []string{name, "gather"})
+	}
+	if err := m.BuildPipeline(edges); err != nil {
+		b.Fatal(err)
+	}
+	in := &rawData{Data: 1}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.Handle(in); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// 64 路散射，比较直接执行和正常执行每次调用的开销
+func BenchmarkHandle_Scatter(b *testing.B) {
+	b.Run("normal", func(b *testing.B) { benchmarkScatter(b) })
+	b.Run("bare", func(b *testing.B) { benchmarkScatter(b, WithBareExecution()) })
+}
@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// 批量执行的可选配置
+type BatchOption func(o *batchOptions)
+
+type batchOptions struct {
+	concurrency int
+}
+
+// 设置批量执行的并发数，默认为GOMAXPROCS
+func WithBatchConcurrency(n int) BatchOption {
+	return func(o *batchOptions) {
+		o.concurrency = n
+	}
+}
+
+// 批量执行中单条数据的结果
+type BatchResult struct {
+	Out *rawData
+	Err error
+}
+
+// 按组批量执行流水线
+// 各组的数据按组名排序后轮流调度，数据量大的组不会让其他组饿死
+// 返回的结果按组名索引，且与输入的位置一一对应；单条数据失败只记录在对应的结果中
+// ctx 结束后未开始执行的数据的结果为ctx 的错误，同时返回ctx 的错误
+// 设置了 WithRunner 时每条数据在 Runner 上执行
+func (m *Manager) HandleBatchGrouped(ctx context.Context, groups map[string][]*rawData, opts ...BatchOption) (map[string][]BatchResult, error) {
+	o := batchOptions{concurrency: runtime.GOMAXPROCS(0)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.concurrency <= 0 {
+		o.concurrency = 1
+	}
+	keys := make([]string, 0, len(groups))
+	results := make(map[string][]BatchResult, len(groups))
+	maxLen := 0
+	for key, items := range groups {
+		keys = append(keys, key)
+		results[key] = make([]BatchResult, len(items))
+		if len(items) > maxLen {
+			maxLen = len(items)
+		}
+	}
+	sort.Strings(keys)
+
+	type task struct {
+		key   string
+		index int
+	}
+	run := func(t task) {
+		out, err := m.handleAdaptive(ctx, groups[t.key][t.index])
+		results[t.key][t.index] = BatchResult{Out: out, Err: err}
+	}
+	// 调度一条数据，ctx 结束前没有调度出去时返回ctx 的错误
+	var dispatch func(t task) error
+	var wait func()
+	if m.runner != nil {
+		// 每条数据直接交给 Runner 执行，Runner 拒绝执行的数据结果为 Runner 的错误
+		g := m.newRunnerGroup(o.concurrency)
+		dispatch = func(t task) error {
+			if err := g.acquire(ctx); err != nil {
+				return err
+			}
+			if err := g.run(ctx, func() { run(t) }); err != nil {
+				results[t.key][t.index].Err = err
+			}
+			return nil
+		}
+		wait = g.wait
+	} else {
+		tasks := make(chan task)
+		var wg sync.WaitGroup
+		for i := 0; i < o.concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for t := range tasks {
+					run(t)
+				}
+			}()
+		}
+		dispatch = func(t task) error {
+			select {
+			case tasks <- t:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		wait = func() {
+			close(tasks)
+			wg.Wait()
+		}
+	}
+	// 轮流从每个组中取出一条数据调度
+	var err error
+	sent := make(map[string]int, len(keys))
+dispatch:
+	for i := 0; i < maxLen; i++ {
+		for _, key := range keys {
+			if i >= len(groups[key]) {
+				continue
+			}
+			if err = dispatch(task{key: key, index: i}); err != nil {
+				break dispatch
+			}
+			sent[key]++
+		}
+	}
+	wait()
+	if err != nil {
+		// 未被调度的数据记为ctx 的错误
+		for _, key := range keys {
+			for i := sent[key]; i < len(results[key]); i++ {
+				results[key][i].Err = err
+			}
+		}
+	}
+	return results, err
+}
@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 测试按组批量执行时各组轮流执行，小组不会被大组饿死；结果按组和位置对应输入
+func TestManager_HandleBatchGrouped(t *testing.T) {
+	for _, concurrency := range []int{1, 4} {
+		var mu sync.Mutex
+		var seen []string
+		m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (out *rawData, err error) {
+			mu.Lock()
+			seen = append(seen, in.Data.(string))
+			mu.Unlock()
+			return &rawData{Data: in.Data.(string) + "!"}, nil
+		})
+		groups := map[string][]*rawData{}
+		for i := 0; i < 1000; i++ {
+			groups["big"] = append(groups["big"], &rawData{Data: fmt.Sprintf("big/%d", i)})
+		}
+		for i := 0; i < 10; i++ {
+			groups["small"] = append(groups["small"], &rawData{Data: fmt.Sprintf("small/%d", i)})
+		}
+		results, err := m.HandleBatchGrouped(context.Background(), groups, WithBatchConcurrency(concurrency))
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		for key, items := range groups {
+			if len(results[key]) != len(items) {
+				t.Errorf("group %s: %d results, want %d", key, len(results[key]), len(items))
+				t.FailNow()
+			}
+			for i, r := range results[key] {
+				if want := fmt.Sprintf("%s/%d!", key, i); r.Err != nil || r.Out.Data != want {
+					t.Errorf("group %s result %d = %+v, want %s", key, i, r, want)
+				}
+			}
+		}
+		if len(seen) != 1010 {
+			t.Errorf("concurrency %d: node saw %d items, want 1010", concurrency, len(seen))
+			t.FailNow()
+		}
+		if concurrency == 1 {
+			// 依次执行时按组名轮流取一条
+			var want []string
+			for i := 0; i < 1000; i++ {
+				want = append(want, fmt.Sprintf("big/%d", i))
+				if i < 10 {
+					want = append(want, fmt.Sprintf("small/%d", i))
+				}
+			}
+			if fmt.Sprint(seen) != fmt.Sprint(want) {
+				t.Errorf("node saw %v..., want %v...", seen[:24], want[:24])
+			}
+		}
+		last := -1
+		for i, data := range seen {
+			if strings.HasPrefix(data, "small/") {
+				last = i
+			}
+		}
+		if last >= 30 {
+			t.Errorf("concurrency %d: small group finished at item %d, want within the first 30", concurrency, last+1)
+		}
+	}
+}
+
+// 测试ctx 已结束时未执行的数据记为ctx 的错误
+func TestManager_HandleBatchGroupedCancel(t *testing.T) {
+	m := newSingleWorkerManager(t, passWorker)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := m.HandleBatchGrouped(ctx, map[string][]*rawData{
+		"a": {{Data: 1}, {Data: 2}},
+	}, WithBatchConcurrency(1))
+	if err != context.Canceled {
+		t.Errorf("err=%v, want context.Canceled", err)
+	}
+	for i, r := range results["a"] {
+		if r.Err == nil {
+			t.Errorf("result %d should fail", i)
+		}
+	}
+}
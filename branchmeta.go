@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// 合并节点的一份输入所在分支的执行情况，只提供给 AddBranchMergerNode 添加的合并节点
+type BranchMeta struct {
+	// 分裂节点的分支名，没有设置分支名时为分支的序号
+	Branch string
+	// 分支的完整标识，从最外层的分裂节点开始，每层为 "<分裂节点名>:<分支序号>"，以 "/" 连接，
+	// 例如 "fan:0/enrich:1"；同一个上游节点的多条路径汇合到同一个合并节点时也各不相同
+	Path string
+	// 从分裂节点产出数据到数据到达合并节点的时间
+	Duration time.Duration
+	// 分支上的节点没有真正执行时（例如被跳过）为对应的结果，否则为 OutcomeExecuted
+	Outcome Outcome
+	// 分支上所有节点调用处理方法的次数之和，包括重试
+	Attempts int
+}
+
+// 合并节点的一份输入以及所在分支的执行情况
+type Contribution struct {
+	Data *rawData
+	Meta BranchMeta
+}
+
+// 添加一个合并节点，每份输入带有所在分支的执行情况，例如可以按分支的耗时、重试次数调整输入的权重
+// 流水线中有这种合并节点时才记录分支的执行情况
+func (m *Manager) AddBranchMergerNode(name string, f func(ctx context.Context, in []Contribution) (out *rawData, err error), opts ...NodeOption) error {
+	return m.addNode(name, NodeTypMerger, BranchMergerFunc(f), opts, callSite(1))
+}
+
+// 执行中一个分支的累计情况，分支上的节点依次累加
+type branchAcc struct {
+	BranchMeta
+	start time.Time
+	// 外层分支，合并之后继续累加到外层分支
+	parent *branchAcc
+}
+
+// 分裂节点的第i 个分支开始，流水线中没有 BranchMergerFunc 时返回nil
+func (e *execution) startBranchMeta(divider *Node, i int, parent *branchAcc, at time.Time) *branchAcc {
+	if !e.m.branchMeta {
+		return nil
+	}
+	meta := BranchMeta{Branch: divider.branchName(i), Path: divider.nodeName + ":" + strconv.Itoa(i)}
+	if parent != nil {
+		meta.Path = parent.Path + "/" + meta.Path
+	}
+	return &branchAcc{BranchMeta: meta, start: at, parent: parent}
+}
+
+// 累加分支上一个节点的执行情况
+func (a *branchAcc) add(info callInfo) {
+	if a == nil {
+		return
+	}
+	a.Attempts += info.attempts
+	if info.outcome != OutcomeExecuted {
+		a.Outcome = info.outcome
+	}
+}
+
+// 分支的数据在at 到达合并节点
+func (a *branchAcc) arrive(at time.Time) {
+	if a != nil {
+		a.Duration = at.Sub(a.start)
+	}
+}
+
+// 合并之后回到外层分支，内层分支的处理次数计入外层分支
+func mergeBranchMeta(accs []*branchAcc) *branchAcc {
+	if len(accs) == 0 || accs[0] == nil {
+		return nil
+	}
+	parent := accs[0].parent
+	if parent != nil {
+		for _, a := range accs {
+			parent.Attempts += a.Attempts
+		}
+	}
+	return parent
+}
+
+func contributions(in []*rawData, accs []*branchAcc) []Contribution {
+	cs := make([]Contribution, len(in))
+	for i := range in {
+		cs[i].Data = in[i]
+		if i < len(accs) && accs[i] != nil {
+			cs[i].Meta = accs[i].BranchMeta
+		}
+	}
+	return cs
+}
@@ -0,0 +1,123 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// 重试过的分支、被跳过的分支以及耗时较长的分支交给同一个合并节点
+func TestManager_BranchMerger(t *testing.T) {
+	m := NewManager()
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{{Data: "retry"}, {Data: "skip"}, {Data: "slow"}}, nil
+	}, WithBranches("retry", "skip", "slow"))
+	failures := 2
+	_ = m.AddWorkerNode("flaky", func(ctx context.Context, in *rawData) (*rawData, error) {
+		if failures > 0 {
+			failures--
+			return nil, errors.New("boom")
+		}
+		return in, nil
+	}, WithRetry(3))
+	_ = m.AddWorkerNode("optional", passWorker, WithSkipIfRemaining(time.Hour))
+	_ = m.AddWorkerNode("slow", func(ctx context.Context, in *rawData) (*rawData, error) {
+		time.Sleep(20 * time.Millisecond)
+		return in, nil
+	})
+	var got []Contribution
+	_ = m.AddBranchMergerNode("m1", func(ctx context.Context, in []Contribution) (*rawData, error) {
+		got = in
+		return &rawData{Data: len(in)}, nil
+	})
+	if err := m.BuildPipeline([][]string{
+		{Head, "d1"}, {"d1", "flaky"}, {"d1", "optional"}, {"d1", "slow"},
+		{"flaky", "m1"}, {"optional", "m1"}, {"slow", "m1"}, {"m1", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := m.HandleContext(ctx, &rawData{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("want 3 contributions, got %d", len(got))
+	}
+	metas := make(map[string]BranchMeta)
+	for _, c := range got {
+		if c.Data.Data != c.Meta.Branch {
+			t.Errorf("data %v delivered with meta of branch %s", c.Data.Data, c.Meta.Branch)
+		}
+		metas[c.Meta.Branch] = c.Meta
+	}
+	if meta := metas["retry"]; meta.Attempts != 3 || meta.Outcome != OutcomeExecuted {
+		t.Errorf("retry branch: %+v", meta)
+	}
+	if meta := metas["skip"]; meta.Attempts != 0 || meta.Outcome != OutcomeSkipped {
+		t.Errorf("skip branch: %+v", meta)
+	}
+	if meta := metas["slow"]; meta.Attempts != 1 || meta.Duration < 20*time.Millisecond {
+		t.Errorf("slow branch: %+v", meta)
+	}
+	if metas["retry"].Duration >= 20*time.Millisecond {
+		t.Errorf("retry branch should not include the slow branch: %+v", metas["retry"])
+	}
+}
+
+// 只有普通合并节点时不记录分支的执行情况
+func TestManager_BranchMetaDisabled(t *testing.T) {
+	m := newDiamondManager(t)
+	if m.branchMeta {
+		t.Error("branch meta should only be collected for BranchMergerFunc")
+	}
+	if out, err := m.Handle(&rawData{Data: 1}); err != nil || out.Data != 2 {
+		t.Errorf("got %v %v", out, err)
+	}
+}
+
+// 同一个分裂节点的两个分支直接汇合到合并节点时，前驱节点相同，用分支的完整标识区分
+func TestManager_BranchMergerPath(t *testing.T) {
+	m := NewManager()
+	_ = m.AddDividerNode("o", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{{Data: "fan"}, {Data: "side"}}, nil
+	})
+	_ = m.AddDividerNode("fan", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{{Data: "copy0"}, {Data: "copy1"}}, nil
+	})
+	_ = m.AddWorkerNode("side", passWorker)
+	paths := make(map[string][]string)
+	merger := func(name string) func(ctx context.Context, in []Contribution) (*rawData, error) {
+		return func(ctx context.Context, in []Contribution) (*rawData, error) {
+			for _, c := range in {
+				paths[name] = append(paths[name], fmt.Sprintf("%s=%v", c.Meta.Path, c.Data.Data))
+			}
+			return &rawData{Data: name}, nil
+		}
+	}
+	_ = m.AddBranchMergerNode("inner", merger("inner"))
+	_ = m.AddBranchMergerNode("outer", merger("outer"))
+	if err := m.BuildPipeline([][]string{
+		{Head, "o"}, {"o", "fan"}, {"o", "side"},
+		{"fan", "inner"}, {"fan", "inner"}, {"inner", "outer"}, {"side", "outer"}, {"outer", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.HandleContext(context.Background(), &rawData{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, ps := range paths {
+		sort.Strings(ps)
+	}
+	want := map[string][]string{
+		"inner": {"o:0/fan:0=copy0", "o:0/fan:1=copy1"},
+		"outer": {"o:0=inner", "o:1=side"},
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("want %v, got %v", want, paths)
+	}
+}
@@ -0,0 +1,212 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+type branchTimeoutConfig struct {
+	divider, successor string
+	d                  time.Duration
+}
+
+// 分裂节点一个分支的超时，在构建时解析
+type branchTimeout struct {
+	d time.Duration
+	// 分支结束的合并节点
+	merger *Node
+}
+
+// 执行中的一个限时分支
+type activeBranch struct {
+	merger *Node
+	ctx    context.Context
+	// 分裂之前的ctx
+	parent context.Context
+	outer  []context.Context
+	// 分裂节点所在的限时分支
+	prev *activeBranch
+	// 合并节点设置了 WithMergeTimeout 时，等待超时后取消分支，见 startMergeWait
+	cancel  context.CancelFunc
+	timer   Timer
+	started time.Time
+	expired int32
+}
+
+// 到达合并节点node 之后所在的限时分支
+func (b *activeBranch) endAt(node *Node) *activeBranch {
+	for b != nil && b.merger == node {
+		b = b.prev
+	}
+	return b
+}
+
+// 限制分裂节点divider 到successor 的分支（从successor 直到对应的合并节点）的总耗时
+// 分支上的节点收到带有该截止时间的ctx；分支因超时失败时，如果合并节点设置了
+// WithMergeTimeout(..., ProceedWithPartial)，合并节点缺少该分支的输入继续执行，否则执行失败
+func (m *Manager) SetBranchTimeout(dividerName, successorName string, d time.Duration) {
+	m.branchTimeouts = append(m.branchTimeouts, branchTimeoutConfig{divider: dividerName, successor: successorName, d: d})
+}
+
+// 检查分支超时的设置，并找出每个分支结束的合并节点
+func (m *Manager) validateBranchTimeouts() error {
+	for _, node := range m.nodes {
+		node.branchTimeouts, node.mergeWait = nil, nil
+		if node.Typ != NodeTypDivider || len(node.Next) == 0 {
+			continue
+		}
+		if merger := branchMerger(node.Next[0]); merger != nil && merger.opts.mergeTimeout != nil {
+			node.mergeWait = merger
+		}
+	}
+	for _, c := range m.branchTimeouts {
+		divider, ok := m.nodes[c.divider]
+		if !ok || divider.Typ != NodeTypDivider {
+			return fmt.Errorf("branch timeout node[%s] is not a divider", c.divider)
+		}
+		i := divider.nextIndex(c.successor)
+		if i < 0 {
+			return fmt.Errorf("branch timeout node[%s] is not a successor of divider node[%s]", c.successor, c.divider)
+		}
+		merger := branchMerger(divider.Next[i])
+		if merger == nil {
+			return fmt.Errorf("branch timeout divider node[%s] branch[%s] does not end at a merger", c.divider, c.successor)
+		}
+		if divider.branchTimeouts == nil {
+			divider.branchTimeouts = make([]*branchTimeout, len(divider.Next))
+		}
+		divider.branchTimeouts[i] = &branchTimeout{d: c.d, merger: merger}
+	}
+	return nil
+}
+
+// 返回后继中名为name 的节点的下标，不存在时返回-1
+func (n *Node) nextIndex(name string) int {
+	for i, next := range n.Next {
+		if next.nodeName == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// 从分支的第一个节点出发，找到与分裂节点对应的合并节点
+func branchMerger(p *Node) *Node {
+	level := 0
+	for p != nil && p.Typ != NodeTypTail {
+		switch p.Typ {
+		case NodeTypDivider:
+			level++
+		case NodeTypMerger:
+			if level == 0 {
+				return p
+			}
+			level--
+		}
+		if len(p.Next) == 0 {
+			return nil
+		}
+		p = p.Next[0]
+	}
+	return nil
+}
+
+// 为分裂节点的第i 个分支设置超时，没有设置时返回nil
+func (e *execution) startBranch(divider *Node, i int, ctx context.Context, outer []context.Context, prev *activeBranch) (context.Context, *activeBranch) {
+	if divider.branchTimeouts == nil || divider.branchTimeouts[i] == nil {
+		return ctx, nil
+	}
+	bt := divider.branchTimeouts[i]
+	bctx, cancel := context.WithTimeout(ctx, bt.d)
+	e.cancels = append(e.cancels, cancel)
+	return bctx, &activeBranch{merger: bt.merger, ctx: bctx, parent: ctx, outer: outer, prev: prev}
+}
+
+// 分裂节点的合并节点设置了 WithMergeTimeout 时，所有分支共用一个可以取消的ctx，
+// 合并节点的第一份输入到达时开始计时，超时后取消还没有结束的分支
+func (e *execution) startMergeWait(divider *Node, ctx context.Context, outer []context.Context, prev *activeBranch) (context.Context, *activeBranch) {
+	if divider.mergeWait == nil {
+		return ctx, prev
+	}
+	wctx, cancel := context.WithCancel(ctx)
+	e.cancels = append(e.cancels, cancel)
+	return wctx, &activeBranch{merger: divider.mergeWait, ctx: wctx, parent: ctx, outer: outer, prev: prev, cancel: cancel}
+}
+
+// 输入加入队列时调用，到达合并节点的第一份输入开始合并节点的等待计时
+func (e *execution) arrive(nw *nodeDataWrapper) *nodeDataWrapper {
+	if nw.node.Typ != NodeTypMerger {
+		return nw
+	}
+	if b := nw.branch.mergeWait(nw.node); b != nil && b.timer == nil {
+		b.started = nw.at
+		b.timer = e.m.clock.AfterFunc(nw.node.opts.mergeTimeout.d, b.expire)
+		e.cancels = append(e.cancels, b.stop)
+	}
+	return nw
+}
+
+// 到达合并节点node 的输入所在的等待计时的分支
+func (b *activeBranch) mergeWait(node *Node) *activeBranch {
+	for ; b != nil && b.merger == node; b = b.prev {
+		if b.cancel != nil {
+			return b
+		}
+	}
+	return nil
+}
+
+// 合并节点等待超时，取消还没有结束的分支
+func (b *activeBranch) expire() {
+	atomic.StoreInt32(&b.expired, 1)
+	b.cancel()
+}
+
+// 合并节点等待超时后取消的分支
+func (b *activeBranch) waitExpired() bool {
+	return b != nil && atomic.LoadInt32(&b.expired) == 1
+}
+
+// 停止合并节点的等待计时
+func (b *activeBranch) stop() {
+	if b != nil && b.timer != nil {
+		b.timer.Stop()
+	}
+}
+
+// 限时分支b 中的节点node 失败时，如果是分支超时并且合并节点允许缺少输入，或者合并节点等待超时取消了分支，
+// 返回通知合并节点缺少该分支的数据。外层的分支超时时，通知外层的合并节点
+func (e *execution) abandonBranch(b *activeBranch, node *Node) *nodeDataWrapper {
+	for b != nil && b.ctx.Err() != nil && b.parent.Err() != nil {
+		b = b.prev
+	}
+	if b == nil || b.ctx.Err() == nil {
+		return nil
+	}
+	if !b.waitExpired() {
+		if b.ctx.Err() != context.DeadlineExceeded {
+			return nil
+		}
+		if opt := b.merger.opts.mergeTimeout; opt == nil || opt.policy != ProceedWithPartial {
+			return nil
+		}
+	}
+	return &nodeDataWrapper{
+		node:    b.merger,
+		from:    node,
+		at:      e.m.clock.Now(),
+		ctx:     b.ctx,
+		outer:   b.outer,
+		branch:  b,
+		missing: true,
+	}
+}
+
+// 执行结束时取消还没有结束的分支，并停止合并等待的计时器
+func (e *execution) cancelBranches() {
+	for _, cancel := range e.cancels {
+		cancel()
+	}
+}
@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// d1 -> slow, fast -> m1，slow 分支限时50ms
+func newBranchTimeoutManager(t *testing.T, mergeOpts ...NodeOption) (*Manager, *bool) {
+	fastHasDeadline := new(bool)
+	m := NewManager()
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{{Data: "slow"}, {Data: "fast"}}, nil
+	})
+	_ = m.AddWorkerNode("slow", func(ctx context.Context, in *rawData) (*rawData, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return in, nil
+		}
+	})
+	_ = m.AddWorkerNode("fast", func(ctx context.Context, in *rawData) (*rawData, error) {
+		_, *fastHasDeadline = ctx.Deadline()
+		return in, nil
+	})
+	_ = m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		parts := make([]string, len(in))
+		for i, d := range in {
+			parts[i] = d.Data.(string)
+		}
+		return &rawData{Data: strings.Join(parts, ",")}, nil
+	}, mergeOpts...)
+	m.SetBranchTimeout("d1", "slow", 50*time.Millisecond)
+	if err := m.BuildPipeline([][]string{
+		{Head, "d1"},
+		{"d1", "slow"}, {"d1", "fast"},
+		{"slow", "m1"}, {"fast", "m1"},
+		{"m1", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return m, fastHasDeadline
+}
+
+// 测试限时分支超时后，合并节点允许缺少输入时继续执行
+func TestManager_BranchTimeoutPartial(t *testing.T) {
+	m, fastHasDeadline := newBranchTimeoutManager(t, WithMergeTimeout(time.Hour, ProceedWithPartial))
+	start := time.Now()
+	out, err := m.Handle(&rawData{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Data != "fast" {
+		t.Errorf("out=%v, want only the fast branch", out.Data)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("branch timeout not applied, took %v", elapsed)
+	}
+	if *fastHasDeadline {
+		t.Errorf("the other branch should not be capped")
+	}
+}
+
+// 测试限时分支超时后，合并节点不允许缺少输入时执行失败
+func TestManager_BranchTimeoutFail(t *testing.T) {
+	for _, opts := range [][]NodeOption{nil, {WithMergeTimeout(time.Hour, FailOnMergeTimeout)}} {
+		m, _ := newBranchTimeoutManager(t, opts...)
+		_, err := m.Handle(&rawData{})
+		var nodeErr *NodeError
+		if !errors.Is(err, context.DeadlineExceeded) || !errors.As(err, &nodeErr) || nodeErr.Node != "slow" {
+			t.Errorf("err=%v, want slow node deadline exceeded", err)
+		}
+	}
+}
+
+// 测试分支超时只能设置在分裂节点的后继上
+func TestManager_BranchTimeoutValidate(t *testing.T) {
+	for _, c := range []struct {
+		divider, successor string
+		want               string
+	}{
+		{"a", "m1", "node[a] is not a divider"},
+		{"d1", "m1", "node[m1] is not a successor of divider node[d1]"},
+	} {
+		m := newDiamondManager(t)
+		m.SetBranchTimeout(c.divider, c.successor, time.Second)
+		if err := m.BuildPipeline(m.edges); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s->%s: err=%v, want %q", c.divider, c.successor, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package pipeline
+
+import "fmt"
+
+// 以链式调用的方式添加节点并连接节点，例如：
+// m.Connect().From(pipeline.Head).Then(parse).ThenNamed("enrich", enrich).To(pipeline.Tail).Build()
+// 未命名的步骤会自动生成形如 anon-worker-3 的节点名
+type Builder struct {
+	m     *Manager
+	edges [][]string
+	// 每条边的声明位置
+	sources []string
+	cur     string
+	err     error
+}
+
+// 创建一个Builder
+func (m *Manager) Connect() *Builder {
+	return &Builder{m: m}
+}
+
+// 设置当前节点，后续添加的节点连接在它之后
+func (b *Builder) From(name string) *Builder {
+	b.cur = name
+	return b
+}
+
+// 添加一个匿名工作节点并连接到当前节点之后
+func (b *Builder) Then(f WorkerFunc) *Builder {
+	return b.then(b.m.anonName(NodeTypWorker), f, callSite(1))
+}
+
+// 添加一个工作节点并连接到当前节点之后
+func (b *Builder) ThenNamed(name string, f WorkerFunc) *Builder {
+	return b.then(name, f, callSite(1))
+}
+
+func (b *Builder) then(name string, f WorkerFunc, source string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if err := b.m.addNode(name, NodeTypWorker, f, nil, source); err != nil {
+		b.err = fmt.Errorf("node[%s]: %w", name, err)
+		return b
+	}
+	return b.to(name, source)
+}
+
+// 将当前节点连接到一个已存在的节点，并将其设置为当前节点
+func (b *Builder) To(name string) *Builder {
+	return b.to(name, callSite(1))
+}
+
+func (b *Builder) to(name string, source string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if b.cur == "" {
+		b.err = fmt.Errorf("node[%s] has no front node, call From first", name)
+		return b
+	}
+	b.edges = append(b.edges, []string{b.cur, name})
+	b.sources = append(b.sources, source)
+	b.cur = name
+	return b
+}
+
+// 返回目前为止添加的节点间关系
+func (b *Builder) Edges() [][]string {
+	edges := make([][]string, len(b.edges))
+	copy(edges, b.edges)
+	return edges
+}
+
+// 构建pipeline
+func (b *Builder) Build() error {
+	if b.err != nil {
+		return b.err
+	}
+	return b.m.buildPipeline(b.Edges(), b.sources, callSite(1))
+}
+
+// 生成匿名节点的名字，序号按添加顺序递增，同一次构建内是稳定的
+func (m *Manager) anonName(typ NodeTyp) string {
+	for {
+		m.anonSeq++
+		name := fmt.Sprintf("anon-%s-%d", typ, m.anonSeq)
+		if _, ok := m.nodes[name]; !ok {
+			return name
+		}
+	}
+}
+
+// 带名字的工作节点，见 Linear
+type NamedWorker struct {
+	Name string
+	F    WorkerFunc
+}
+
+// 创建并构建 head -> stages[0] -> ... -> stages[n-1] -> tail 的直线流水线，用于脚本和测试
+// 返回的是普通的Manager，可以继续使用其他功能
+func Linear(stages ...NamedWorker) (*Manager, error) {
+	m := NewManager()
+	b := m.Connect().From(headNodeName)
+	for _, s := range stages {
+		b.then(s.Name, s.F, callSite(1))
+	}
+	return m.buildLinear(b.to(tailNodeName, callSite(1)))
+}
+
+// 与 Linear 相同，节点名按 Builder.Then 的规则自动生成（anon-worker-1、anon-worker-2 ...）
+func LinearFunc(fs ...WorkerFunc) (*Manager, error) {
+	m := NewManager()
+	b := m.Connect().From(headNodeName)
+	for _, f := range fs {
+		b.then(m.anonName(NodeTypWorker), f, callSite(1))
+	}
+	return m.buildLinear(b.to(tailNodeName, callSite(1)))
+}
+
+func (m *Manager) buildLinear(b *Builder) (*Manager, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if err := m.buildPipeline(b.Edges(), b.sources, callSite(2)); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
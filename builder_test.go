@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func addWorker(n int) WorkerFunc {
+	return func(ctx context.Context, in *rawData) (out *rawData, err error) {
+		in.Data = in.Data.(int) + n
+		return in, nil
+	}
+}
+
+// 测试链式构建一个五步的流程，匿名与具名节点混用
+func TestBuilder_Then(t *testing.T) {
+	m := NewManager()
+	b := m.Connect().
+		From("head000").
+		Then(addWorker(1)).
+		Then(addWorker(2)).
+		ThenNamed("enrich", addWorker(3)).
+		Then(addWorker(4)).
+		Then(addWorker(5)).
+		To("tail111")
+	if err := b.Build(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	want := [][]string{
+		{"head000", "anon-worker-1"},
+		{"anon-worker-1", "anon-worker-2"},
+		{"anon-worker-2", "enrich"},
+		{"enrich", "anon-worker-3"},
+		{"anon-worker-3", "anon-worker-4"},
+		{"anon-worker-4", "tail111"},
+	}
+	if !reflect.DeepEqual(b.Edges(), want) {
+		t.Errorf("edges=%v, want=%v", b.Edges(), want)
+	}
+	out, err := m.Handle(&rawData{Data: 0})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if out.Data.(int) != 15 {
+		t.Errorf("out=%d not eq 15", out.Data.(int))
+	}
+}
+
+// 测试重名的节点在错误中带上节点名
+func TestBuilder_DuplicateName(t *testing.T) {
+	m := NewManager()
+	err := m.Connect().
+		From("head000").
+		Then(addWorker(1)).
+		ThenNamed("anon-worker-1", addWorker(2)).
+		To("tail111").
+		Build()
+	if !errors.Is(err, ErrorsNodeNameDuplicate) || !strings.Contains(err.Error(), "anon-worker-1") {
+		t.Errorf("err=%v, want duplicate error naming anon-worker-1", err)
+	}
+}
+
+// 测试一次调用构建直线流水线，自动生成的节点名出现在轨迹和DOT 中
+func TestLinear(t *testing.T) {
+	appendWorker := func(s string) WorkerFunc {
+		return func(ctx context.Context, in *rawData) (*rawData, error) {
+			return &rawData{Data: in.Data.(string) + s}, nil
+		}
+	}
+	named, err := Linear(
+		NamedWorker{Name: "parse", F: appendWorker("p")},
+		NamedWorker{Name: "enrich", F: appendWorker("e")},
+		NamedWorker{Name: "emit", F: appendWorker("o")},
+	)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	auto, err := LinearFunc(appendWorker("p"), appendWorker("e"), appendWorker("o"))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	cases := []struct {
+		m     *Manager
+		names []string
+	}{
+		{named, []string{"parse", "enrich", "emit"}},
+		{auto, []string{"anon-worker-1", "anon-worker-2", "anon-worker-3"}},
+	}
+	for _, c := range cases {
+		trace := &Trace{}
+		out, err := c.m.HandleContext(context.Background(), &rawData{Data: ""}, WithTrace(trace))
+		if err != nil || out.Data != "peo" {
+			t.Errorf("out=%v err=%v", out, err)
+			t.FailNow()
+		}
+		var got []string
+		for _, entry := range trace.Entries() {
+			got = append(got, entry.Node)
+		}
+		if !reflect.DeepEqual(got, c.names) {
+			t.Errorf("trace nodes %v, want %v", got, c.names)
+		}
+		dot := c.m.ToDOT()
+		for _, name := range c.names {
+			if !strings.Contains(dot, `"`+name+`"`) {
+				t.Errorf("DOT output missing node %s:\n%s", name, dot)
+			}
+		}
+	}
+
+	if _, err := Linear(NamedWorker{Name: "a", F: passWorker}, NamedWorker{Name: "a", F: passWorker}); !errors.Is(err, ErrorsNodeNameDuplicate) {
+		t.Errorf("want ErrorsNodeNameDuplicate, got %v", err)
+	}
+}
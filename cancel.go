@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 执行因为ctx 被取消或超时而失败时返回的错误，带有执行停止时的状态
+// Unwrap 返回原来的错误，errors.Is(err, context.DeadlineExceeded) 等判断仍然有效
+type CancelledError struct {
+	ExecID string
+	// 从开始执行到失败经过的时间
+	Elapsed time.Duration
+	// 正在执行的节点，开启 WithStageParallelism 时为所有正在执行的节点，按名字排序
+	Running []string
+	// 已经加入队列、还没有开始执行的节点
+	Queued []string
+	// 还在等待输入的合并节点
+	Mergers []MergerWait
+	Err     error
+}
+
+// 等待输入的合并节点，Received 为已经到达的输入数，Expected 为入度
+type MergerWait struct {
+	Node     string
+	Received int
+	Expected int
+}
+
+func (e *CancelledError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "execution[%s] cancelled after %v: %v", e.ExecID, e.Elapsed, e.Err)
+	if len(e.Running) > 0 {
+		fmt.Fprintf(&b, ", running [%s]", strings.Join(e.Running, ", "))
+	}
+	if len(e.Queued) > 0 {
+		fmt.Fprintf(&b, ", queued [%s]", strings.Join(e.Queued, ", "))
+	}
+	for _, w := range e.Mergers {
+		fmt.Fprintf(&b, ", merger[%s] %d/%d", w.Node, w.Received, w.Expected)
+	}
+	return b.String()
+}
+
+func (e *CancelledError) Unwrap() error {
+	return e.Err
+}
+
+// 执行停止时队列和合并节点的状态，只在ctx 结束导致失败时记录
+type cancelSnapshot struct {
+	// 并行执行时所有正在执行的节点，依次执行时为nil，使用 execution.current
+	running []string
+	queued  []string
+	mergers []MergerWait
+}
+
+// 记录队列中的节点以及还在等待输入的合并节点
+func (e *execution) snapshotQueue(queue []*nodeDataWrapper, mergers map[*Node]*mergerState) {
+	e.snapshot = queueState(queue, mergers)
+}
+
+// 队列中的节点以及还在等待输入的合并节点，队列中发给合并节点的数据算作合并节点已经到达的输入
+func queueState(queue []*nodeDataWrapper, mergers map[*Node]*mergerState) *cancelSnapshot {
+	s := &cancelSnapshot{}
+	received := make(map[*Node]int)
+	for node, st := range mergers {
+		if !st.done {
+			received[node] += st.received + st.missing
+		}
+	}
+	for _, nw := range queue {
+		if nw.node.Typ == NodeTypMerger {
+			if st := mergers[nw.node]; st == nil || !st.done {
+				received[nw.node]++
+			}
+			continue
+		}
+		s.queued = append(s.queued, nw.node.nodeName)
+	}
+	for node, n := range received {
+		s.mergers = append(s.mergers, MergerWait{Node: node.nodeName, Received: n, Expected: node.inEdges})
+	}
+	sort.Slice(s.mergers, func(i, j int) bool {
+		return s.mergers[i].Node < s.mergers[j].Node
+	})
+	return s
+}
+
+// ctx 结束导致执行失败时，将错误转换为 CancelledError
+func (e *execution) cancelled(err error) error {
+	if _, ok := err.(*CancelledError); ok || e.ctx.Err() == nil {
+		return err
+	}
+	ce := &CancelledError{ExecID: e.id(), Elapsed: e.m.clock.Now().Sub(e.start), Err: err}
+	if e.current != nil {
+		ce.Running = []string{e.current.nodeName}
+	}
+	if e.snapshot != nil {
+		if e.snapshot.running != nil {
+			ce.Running = e.snapshot.running
+		}
+		ce.Queued, ce.Mergers = e.snapshot.queued, e.snapshot.mergers
+	}
+	return ce
+}
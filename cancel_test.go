@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// d1 分出a、b、c 三个分支后在m1 合并，a 阻塞到ctx 结束
+func newCancelFanoutManager(t *testing.T, started chan<- struct{}) *Manager {
+	m := NewManager()
+	if err := m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{in, in, in}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddWorkerNode("c", passWorker); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddWorkerNode("a", func(ctx context.Context, in *rawData) (*rawData, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddWorkerNode("b", passWorker); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		return in[0], nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.BuildPipeline([][]string{
+		{Head, "d1"},
+		{"d1", "c"},
+		{"d1", "a"},
+		{"d1", "b"},
+		{"c", "m1"},
+		{"a", "m1"},
+		{"b", "m1"},
+		{"m1", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestManager_CancelledError(t *testing.T) {
+	started := make(chan struct{})
+	m := newCancelFanoutManager(t, started)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, err := m.HandleContext(ctx, &rawData{Data: 1})
+	var ce *CancelledError
+	if !errors.As(err, &ce) {
+		t.Fatalf("want CancelledError, got %v", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("CancelledError should unwrap to context.Canceled: %v", err)
+	}
+	if ce.ExecID == "" || ce.Elapsed <= 0 {
+		t.Errorf("missing exec id or elapsed: %+v", ce)
+	}
+	// 分支依次执行：c 已经完成，a 正在执行，b 还在队列中
+	if strings.Join(ce.Running, ",") != "a" || strings.Join(ce.Queued, ",") != "b" {
+		t.Errorf("running=%v queued=%v, want [a] [b]", ce.Running, ce.Queued)
+	}
+	if len(ce.Mergers) != 1 || ce.Mergers[0] != (MergerWait{Node: "m1", Received: 1, Expected: 3}) {
+		t.Errorf("mergers=%v, want m1 1/3", ce.Mergers)
+	}
+	for _, want := range []string{"running [a]", "queued [b]", "merger[m1] 1/3"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should contain %q: %v", want, err)
+		}
+	}
+}
+
+// 测试并行执行时报告所有正在执行的节点
+func TestManager_CancelledErrorParallel(t *testing.T) {
+	var r rendezvous
+	m := NewManager(WithStageParallelism())
+	m.parallelism = 2
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{in, in}, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pair := map[string]string{"a": "b", "b": "a"}
+	for _, name := range []string{"a", "b"} {
+		name := name
+		_ = m.AddWorkerNode(name, func(c context.Context, in *rawData) (*rawData, error) {
+			if err := r.wait(name, pair[name]); err != nil {
+				return nil, err
+			}
+			cancel()
+			<-c.Done()
+			return nil, c.Err()
+		})
+	}
+	_ = m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		return in[0], nil
+	})
+	if err := m.BuildPipeline([][]string{
+		{Head, "d1"}, {"d1", "a"}, {"d1", "b"}, {"a", "m1"}, {"b", "m1"}, {"m1", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := m.HandleContext(ctx, &rawData{Data: 1})
+	var ce *CancelledError
+	if !errors.As(err, &ce) {
+		t.Fatalf("want CancelledError, got %v", err)
+	}
+	if strings.Join(ce.Running, ",") != "a,b" || len(ce.Queued) != 0 {
+		t.Errorf("running=%v queued=%v, want [a b] []", ce.Running, ce.Queued)
+	}
+	if !strings.Contains(err.Error(), "running [a, b]") {
+		t.Errorf("error should list both running nodes: %v", err)
+	}
+}
+
+// 测试不是ctx 结束导致的错误保持不变
+func TestManager_CancelledErrorOnlyOnCancel(t *testing.T) {
+	m := newLinearManager(t, 3, 2)
+	_, err := m.Handle(&rawData{Data: 1})
+	var ce *CancelledError
+	if err == nil || errors.As(err, &ce) {
+		t.Errorf("err=%v, want plain node error", err)
+	}
+}
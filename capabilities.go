@@ -0,0 +1,138 @@
+package pipeline
+
+import "sort"
+
+// Manager 的功能摘要，可以序列化后提供给外部工具
+type CapabilityReport struct {
+	Built bool `json:"built"`
+	// 包支持的配置格式版本，以及从配置加载节点时配置的版本，没有加载过配置时为空
+	FormatVersion string `json:"formatVersion"`
+	ConfigVersion string `json:"configVersion,omitempty"`
+	// 流水线的指纹，只在构建后给出
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// 开启的Manager 配置，按名字排序，例如 "WithListener"
+	Options []string `json:"options"`
+	// 各类型的节点数，不含虚拟头、尾节点
+	NodesByType map[NodeTyp]int `json:"nodesByType"`
+	// 使用了各项节点配置的节点数，例如 "WithRetry"，没有节点使用的配置不出现
+	NodeOptions map[string]int `json:"nodeOptions"`
+	// 当前版本总是支持的功能：流式处理、子图执行、执行轨迹
+	Streaming bool `json:"streaming"`
+	Subgraph  bool `json:"subgraph"`
+	Tracing   bool `json:"tracing"`
+	// 开启 WithAdaptiveConcurrency 时当前的并发数
+	ConcurrencyLimit int `json:"concurrencyLimit,omitempty"`
+	// 同时开启 WithStageParallelism 时一次执行中同时执行的节点数
+	BranchConcurrencyLimit int `json:"branchConcurrencyLimit,omitempty"`
+	// 构建后流水线是否为纯工作节点组成的直线流程
+	LinearFastPath bool `json:"linearFastPath"`
+	// 开启 WithSLO 时执行耗时目标的状态
+	SLO *SLOStatus `json:"slo,omitempty"`
+}
+
+// 返回Manager 的功能摘要，构建前后都可以调用，不执行流水线
+func (m *Manager) Capabilities() CapabilityReport {
+	r := CapabilityReport{
+		Built:                  m.built,
+		FormatVersion:          FormatVersion,
+		ConfigVersion:          m.configVersion,
+		Options:                m.enabledOptions(),
+		ConcurrencyLimit:       m.ConcurrencyLimit(),
+		BranchConcurrencyLimit: m.BranchConcurrencyLimit(),
+		NodesByType:            make(map[NodeTyp]int),
+		NodeOptions:            make(map[string]int),
+		Streaming:              true,
+		Subgraph:               true,
+		Tracing:                true,
+		LinearFastPath:         m.built && m.linear != nil,
+		SLO:                    m.slo.status(),
+	}
+	if m.built {
+		r.Fingerprint = m.Fingerprint()
+	}
+	for _, node := range m.userNodes() {
+		r.NodesByType[node.Typ]++
+		o := &node.opts
+		for name, set := range map[string]bool{
+			"WithRetry":           o.retry != nil,
+			"WithMergeTimeout":    o.mergeTimeout != nil,
+			"WithBranches":        len(o.branches) > 0,
+			"WithCost":            o.cost != 0,
+			"WithSkipIfRemaining": o.skipIfRemaining > 0,
+			"WithDefaultBranch":   o.defaultBranch != nil,
+			"WithTimeout":         o.timeout > 0,
+			"WithNoTimeout":       o.noTimeout,
+			"AddWorkerVariant":    len(node.variants) > 0,
+			"DefineStage":         node.stage != "",
+			"WithArrivalOrder":    o.arrivalOrder,
+			"WithBareExecution":   o.bare,
+		} {
+			if set {
+				r.NodeOptions[name]++
+			}
+		}
+	}
+	// 同一个分裂节点可以设置多个分支的超时，按节点计数
+	dividers := make(map[string]bool)
+	for _, bt := range m.branchTimeouts {
+		if !dividers[bt.divider] {
+			dividers[bt.divider] = true
+			r.NodeOptions["SetBranchTimeout"]++
+		}
+	}
+	return r
+}
+
+// 开启的Manager 配置的名字，按名字排序
+func (m *Manager) enabledOptions() []string {
+	_, defaultClock := m.clock.(realClock)
+	options := make([]string, 0, 8)
+	for name, set := range map[string]bool{
+		"WithClock":                 !defaultClock,
+		"WithMaxDepth":              m.maxDepth > 0,
+		"WithMaxInflightExecutions": m.inflight.sem != nil,
+		"WithFinalizer":             m.finalizer != nil,
+		"WithVariantSelector":       m.variantSelector != nil,
+		"WithYieldEvery":            m.yieldEvery != defaultYieldEvery,
+		"SetErrorHandlerEntry":      m.errorHandler != nil,
+		"DefineCriticalSection":     len(m.sections) > 0,
+		"WithSoftDeadline":          m.softDeadline != nil,
+		"WithStageTimeout":          len(m.stageTimeouts) > 0,
+		"WithListener":              m.listener != nil,
+		"WithStartListener":         m.nodeStart != nil,
+		"WithCloner":                m.cloner != nil,
+		"WithPayloadRedactor":       m.redactor != nil,
+		"WithDiagnostics":           m.diagnostics != nil,
+		"WithTraversal":             m.traversal != BFS,
+		"WithDeadLetterStore":       m.deadLetters != nil,
+		"WithSnapshotStore":         m.snapshots != nil,
+		"WithEnv":                   m.env != nil,
+		"WithMergerOrderShuffling":  m.mergerShuffle != nil,
+		"WithMaxRecursionDepth":     m.maxRecursion > 0,
+		"WithRuntimeAssertions":     m.runtimeAssertions,
+		"WithRequireContext":        m.requireContext,
+		"WithDeadlineAssertions":    m.deadlineAssertions,
+		"WithStrictOptions":         m.strictOptions,
+		"WithExecutionRand":         m.executionRand,
+		"WithContextValues":         m.contextValues,
+		"WithExecutionHistory":      m.history != nil,
+		"WithAdaptiveConcurrency":   m.adaptive != nil,
+		"WithDefaultNodeTimeout":    m.defaults.timeout > 0,
+		"WithDefaultRetry":          m.defaults.retry != nil,
+		"WithDefaultSizer":          m.defaults.sizer != nil,
+		"WithTraceSampling":         m.sampling != nil && m.sampling.trace < 1,
+		"WithRecordingSampling":     m.sampling != nil && m.sampling.recording < 1,
+		"WithStageParallelism":      m.parallelism > 0,
+		"WithAllowNilData":          m.allowNilData,
+		"WithSLO":                   m.slo != nil,
+		"WithMiddleware":            len(m.middlewares) > 0,
+		"WithStallDetection":        m.stall != nil,
+		"WithRunner":                m.runner != nil,
+	} {
+		if set {
+			options = append(options, name)
+		}
+	}
+	sort.Strings(options)
+	return options
+}
@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManager_Capabilities(t *testing.T) {
+	m := NewManager(
+		WithListener(func(ev NodeEvent) {}),
+		WithMaxInflightExecutions(2, OverflowReject),
+		WithContextValues(),
+		WithStrictOptions(),
+	)
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{in, in}, nil
+	}, WithBranches("left", "right"), WithRetry(2))
+	_ = m.AddWorkerNode("a", passWorker, WithRetry(3), WithCost(2))
+	_ = m.AddWorkerNode("b", passWorker, WithSkipIfRemaining(time.Second))
+	_ = m.AddMergerNode("m1", func(ctx context.Context, ins []*rawData) (*rawData, error) {
+		return ins[0], nil
+	}, WithMergeTimeout(time.Second, ProceedWithPartial))
+	m.SetBranchTimeout("d1", "a", time.Second)
+	m.SetBranchTimeout("d1", "b", time.Second)
+	if err := m.DefineStage("fetch", []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	before := m.Capabilities()
+	if before.Built || before.Fingerprint != "" || before.LinearFastPath {
+		t.Errorf("unbuilt manager should not report build results: %+v", before)
+	}
+	if err := m.BuildPipeline([][]string{
+		{Head, "d1"},
+		{"d1", "a"},
+		{"d1", "b"},
+		{"a", "m1"},
+		{"b", "m1"},
+		{"m1", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := m.Capabilities()
+	if !r.Built || r.Fingerprint != m.Fingerprint() || r.FormatVersion != FormatVersion {
+		t.Errorf("unexpected build info: %+v", r)
+	}
+	wantOptions := []string{"WithContextValues", "WithListener", "WithMaxInflightExecutions", "WithStrictOptions"}
+	if !reflect.DeepEqual(r.Options, wantOptions) {
+		t.Errorf("options = %v, want %v", r.Options, wantOptions)
+	}
+	wantNodes := map[NodeTyp]int{NodeTypDivider: 1, NodeTypWorker: 2, NodeTypMerger: 1}
+	if !reflect.DeepEqual(r.NodesByType, wantNodes) {
+		t.Errorf("nodes = %v, want %v", r.NodesByType, wantNodes)
+	}
+	wantNodeOptions := map[string]int{
+		"WithRetry":           2,
+		"WithBranches":        1,
+		"WithCost":            1,
+		"WithSkipIfRemaining": 1,
+		"WithMergeTimeout":    1,
+		"SetBranchTimeout":    1,
+		"DefineStage":         2,
+	}
+	if !reflect.DeepEqual(r.NodeOptions, wantNodeOptions) {
+		t.Errorf("node options = %v, want %v", r.NodeOptions, wantNodeOptions)
+	}
+	if r.LinearFastPath || r.ConfigVersion != "" {
+		t.Errorf("unexpected report: %+v", r)
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded CapabilityReport
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, r) {
+		t.Errorf("report should survive json round trip:\n%+v\n%+v", decoded, r)
+	}
+}
+
+func TestManager_CapabilitiesFromConfig(t *testing.T) {
+	m, err := LoadJSON(strings.NewReader(fetchConfig), newTeamRegistry(t), WithNamespace("team-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := m.Capabilities()
+	if r.ConfigVersion != FormatVersion {
+		t.Errorf("config version = %q, want %q", r.ConfigVersion, FormatVersion)
+	}
+	if !r.LinearFastPath || r.NodesByType[NodeTypWorker] != 1 || len(r.Options) != 0 {
+		t.Errorf("unexpected report: %+v", r)
+	}
+}
@@ -0,0 +1,137 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 节点的说明，出现在 NodeInfo、Export、DOT/Mermaid 的提示以及 WriteCatalog 的输出中
+func WithDescription(s string) NodeOption {
+	return func(o *nodeOptions) {
+		o.description = s
+	}
+}
+
+// 节点的负责人
+func WithOwner(s string) NodeOption {
+	return func(o *nodeOptions) {
+		o.owner = s
+	}
+}
+
+// 没有 WithDescription 的节点记录到 Lint 的结果中，不影响构建
+func WithStrictDocumentation() Option {
+	return func(m *Manager) {
+		m.strictDocs = true
+	}
+}
+
+// 节点目录的格式
+type CatalogFormat int
+
+const (
+	// Markdown 表格
+	CatalogMarkdownTable CatalogFormat = iota
+	// CatalogEntry 的JSON 数组
+	CatalogJSON
+)
+
+// 节点目录中的一项
+type CatalogEntry struct {
+	Name        string   `json:"name"`
+	Typ         NodeTyp  `json:"type"`
+	Description string   `json:"description,omitempty"`
+	Owner       string   `json:"owner,omitempty"`
+	Options     []string `json:"options,omitempty"`
+	// 直接相连的上下游节点，不含虚拟头、尾节点
+	Upstream   []string `json:"upstream,omitempty"`
+	Downstream []string `json:"downstream,omitempty"`
+}
+
+// 返回节点目录，按 ToDOT 的节点顺序排列
+func (m *Manager) Catalog() []CatalogEntry {
+	upstream := make(map[*Node][]string)
+	for _, edge := range m.edgeList {
+		from := m.nodes[edge.from]
+		if from == nil || from.Typ == NodeTypHead {
+			continue
+		}
+		if to := m.nodes[edge.to]; to != nil {
+			upstream[to] = append(upstream[to], from.nodeName)
+		}
+	}
+	var entries []CatalogEntry
+	for _, node := range m.exportOrder() {
+		if node.Typ == NodeTypHead || node.Typ == NodeTypTail {
+			continue
+		}
+		entry := CatalogEntry{
+			Name:        node.nodeName,
+			Typ:         node.Typ,
+			Description: node.opts.description,
+			Owner:       node.opts.owner,
+			Options:     node.opts.summary(),
+			Upstream:    upstream[node],
+		}
+		for _, next := range node.Next {
+			if next.Typ != NodeTypTail {
+				entry.Downstream = append(entry.Downstream, next.nodeName)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// 将节点目录以format 格式写入w
+func (m *Manager) WriteCatalog(w io.Writer, format CatalogFormat) error {
+	entries := m.Catalog()
+	switch format {
+	case CatalogJSON:
+		if entries == nil {
+			entries = []CatalogEntry{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case CatalogMarkdownTable:
+		var b strings.Builder
+		b.WriteString("| Node | Type | Description | Owner | Options | Upstream | Downstream |\n")
+		b.WriteString("| --- | --- | --- | --- | --- | --- | --- |\n")
+		for _, e := range entries {
+			cells := []string{e.Name, string(e.Typ), e.Description, e.Owner,
+				strings.Join(e.Options, ", "), strings.Join(e.Upstream, ", "), strings.Join(e.Downstream, ", ")}
+			for i, c := range cells {
+				cells[i] = markdownCell(c)
+			}
+			fmt.Fprintf(&b, "| %s |\n", strings.Join(cells, " | "))
+		}
+		_, err := io.WriteString(w, b.String())
+		return err
+	default:
+		return fmt.Errorf("unknown catalog format %d", format)
+	}
+}
+
+// 转义表格中的竖线和换行
+func markdownCell(s string) string {
+	s = strings.Replace(s, "|", "\\|", -1)
+	return strings.Replace(s, "\n", "<br>", -1)
+}
+
+// 找出没有说明的节点
+func lintDocumentation(order []*Node) []LintFinding {
+	var findings []LintFinding
+	for _, node := range order {
+		if node.opts.description == "" && node.Typ != NodeTypHead && node.Typ != NodeTypTail {
+			findings = append(findings, LintFinding{
+				Node:    node.nodeName,
+				Option:  "WithDescription",
+				Message: "node has no description",
+			})
+		}
+	}
+	return findings
+}
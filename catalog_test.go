@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func newCatalogManager(t *testing.T, opts ...Option) *Manager {
+	m := NewManager(opts...)
+	_ = m.AddWorkerNode("fetch", passWorker, WithDescription("Load the order"), WithOwner("orders"), WithRetry(2))
+	_ = m.AddJudgerNode("route", func(ctx context.Context, in *rawData) int { return 0 },
+		WithDescription("Route by size: small | large"), WithBranches("small", "large"))
+	_ = m.AddWorkerNode("small", passWorker, WithDescription("Handle small orders"), WithOwner("orders"))
+	_ = m.AddWorkerNode("large", passWorker)
+	if err := m.BuildPipeline([][]string{
+		{Head, "fetch"},
+		{"fetch", "route"},
+		{"route", "small"},
+		{"route", "large"},
+		{"small", Tail},
+		{"large", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestManager_WriteCatalogMarkdown(t *testing.T) {
+	m := newCatalogManager(t)
+	var buf bytes.Buffer
+	if err := m.WriteCatalog(&buf, CatalogMarkdownTable); err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile("testdata/catalog.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(want) {
+		t.Errorf("catalog mismatch, got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestManager_WriteCatalogJSON(t *testing.T) {
+	m := newCatalogManager(t)
+	var buf bytes.Buffer
+	if err := m.WriteCatalog(&buf, CatalogJSON); err != nil {
+		t.Fatal(err)
+	}
+	var entries []CatalogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 || entries[1].Name != "route" || entries[1].Upstream[0] != "fetch" {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestManager_NodeDescription(t *testing.T) {
+	m := newCatalogManager(t)
+	info, _ := m.NodeInfo("fetch")
+	if info.Description != "Load the order" || info.Owner != "orders" {
+		t.Errorf("unexpected node info: %+v", info)
+	}
+	if dot := m.ToDOT(); !strings.Contains(dot, `"fetch" [shape=box, tooltip="Load the order"];`) {
+		t.Errorf("dot should carry the description:\n%s", dot)
+	}
+	if mermaid := m.ToMermaid(); !strings.Contains(mermaid, `"#" "Load the order"`) {
+		t.Errorf("mermaid should carry the description:\n%s", mermaid)
+	}
+	// 说明不影响指纹
+	plain := NewManager()
+	_ = plain.AddWorkerNode("fetch", passWorker, WithRetry(2))
+	_ = plain.AddJudgerNode("route", func(ctx context.Context, in *rawData) int { return 0 }, WithBranches("small", "large"))
+	_ = plain.AddWorkerNode("small", passWorker)
+	_ = plain.AddWorkerNode("large", passWorker)
+	if err := plain.BuildPipeline([][]string{
+		{Head, "fetch"},
+		{"fetch", "route"},
+		{"route", "small"},
+		{"route", "large"},
+		{"small", Tail},
+		{"large", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if plain.Fingerprint() != m.Fingerprint() {
+		t.Error("descriptions should not change the fingerprint")
+	}
+}
+
+func TestManager_StrictDocumentation(t *testing.T) {
+	if findings := newCatalogManager(t).Lint(); len(findings) != 0 {
+		t.Errorf("missing descriptions should only be flagged in strict documentation mode, got %v", findings)
+	}
+	findings := newCatalogManager(t, WithStrictDocumentation(), WithStrictOptions()).Lint()
+	if len(findings) != 1 || findings[0].Node != "large" || findings[0].Option != "WithDescription" {
+		t.Errorf("want a finding for node large, got %v", findings)
+	}
+}
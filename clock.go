@@ -0,0 +1,38 @@
+package pipeline
+
+import "time"
+
+// 时间来源，默认为系统时间，测试时可以通过 WithClock 替换
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	// d 之后在另一个goroutine 中调用f，返回的 Timer 可以取消
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// 可以取消的定时器
+type Timer interface {
+	// 取消定时器，定时器已经触发或已经取消时返回false
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// 设置Manager 使用的时间来源
+func WithClock(c Clock) Option {
+	return func(m *Manager) {
+		m.clock = c
+	}
+}
@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+)
+
+// 测试用的时间来源，只有调用Advance 时时间才会前进
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+	// AfterFunc 注册的回调，ch 为nil
+	f     func()
+	timer *fakeTimer
+}
+
+type fakeTimer struct {
+	c *fakeClock
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	for i, w := range t.c.waiters {
+		if w.timer == t {
+			t.c.waiters = append(t.c.waiters[:i], t.c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{c: c}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), f: f, timer: t})
+	return t
+}
+
+// 还没有触发的等待者数量
+func (c *fakeClock) pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// 时间前进d，到期的等待者会收到通知，到期的回调在锁外同步调用
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var fired []func()
+	waiters := make([]fakeWaiter, 0, len(c.waiters))
+	for _, w := range c.waiters {
+		switch {
+		case w.at.After(c.now):
+			waiters = append(waiters, w)
+		case w.f != nil:
+			fired = append(fired, w.f)
+		default:
+			w.ch <- c.now
+		}
+	}
+	c.waiters = waiters
+	c.mu.Unlock()
+	for _, f := range fired {
+		f()
+	}
+}
+
+// 阻塞直到有n 个等待者，用于在Advance 之前确认被测代码已经开始等待
+func (c *fakeClock) blockUntilWaiters(n int) {
+	for {
+		c.mu.Lock()
+		count := len(c.waiters)
+		c.mu.Unlock()
+		if count >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,126 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// Manager 已经关闭，不再接受新的执行
+	ErrClosed = errors.New("pipeline is closed")
+	// 关闭时没有等到所有执行结束
+	ErrCloseTimedOut = errors.New("pipeline close timed out")
+)
+
+// 记录正在执行的流水线的分片数，执行按序号分散到各个分片，减少并发执行之间的锁竞争
+const closeShards = 32
+
+// 正在执行的流水线，用于 Close 等待
+// 执行数和是否关闭用原子操作读写，执行的标识分片记录，开始和结束一次执行不经过全局的锁
+type closeState struct {
+	closed int32
+	// 正在执行的数量，包括还没有分配序号、即将被拒绝的执行
+	active int64
+	shards [closeShards]closeShard
+	// 保护idle：关闭后所有执行结束时关闭
+	mu   sync.Mutex
+	idle chan struct{}
+}
+
+type closeShard struct {
+	mu sync.Mutex
+	// 执行的序号 -> 开始时间，只记录最外层的执行
+	running map[uint64]time.Time
+}
+
+// 关闭Manager：先不再接受新的执行，之后的 Handle、HandleSubgraph 返回ErrClosed；
+// 然后等待正在执行的流水线结束，ctx 结束时不再等待，返回包装了ErrCloseTimedOut 的错误并列出没有结束的执行的标识
+// 已经在等待执行名额的调用仍然会执行，Close 同样等待这些执行；可以多次调用
+func (m *Manager) Close(ctx context.Context) error {
+	c := &m.closing
+	atomic.StoreInt32(&c.closed, 1)
+	c.mu.Lock()
+	if atomic.LoadInt64(&c.active) == 0 {
+		c.mu.Unlock()
+		return nil
+	}
+	if c.idle == nil {
+		c.idle = make(chan struct{})
+	}
+	idle := c.idle
+	c.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+	}
+	var seqs []uint64
+	starts := make(map[uint64]time.Time)
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.mu.Lock()
+		for seq, start := range shard.running {
+			seqs = append(seqs, seq)
+			starts[seq] = start
+		}
+		shard.mu.Unlock()
+	}
+	if len(seqs) == 0 {
+		return nil
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	ids := make([]string, len(seqs))
+	for i, seq := range seqs {
+		ids[i] = formatExecID(starts[seq], seq)
+	}
+	return fmt.Errorf("%w: %v, abandoned executions [%s]", ErrCloseTimedOut, ctx.Err(), strings.Join(ids, " "))
+}
+
+// 记录一次最外层的执行并分配序号，Manager 已经关闭时返回ErrClosed
+// 先增加执行数再检查是否关闭，与 Close 的顺序相反，两者同时发生时要么执行被拒绝，要么 Close 等待该执行
+func (m *Manager) track(start time.Time) (uint64, error) {
+	c := &m.closing
+	atomic.AddInt64(&c.active, 1)
+	if atomic.LoadInt32(&c.closed) != 0 {
+		c.leave()
+		return 0, ErrClosed
+	}
+	seq := atomic.AddUint64(&m.execSeq, 1)
+	shard := &c.shards[seq%closeShards]
+	shard.mu.Lock()
+	if shard.running == nil {
+		shard.running = make(map[uint64]time.Time)
+	}
+	shard.running[seq] = start
+	shard.mu.Unlock()
+	return seq, nil
+}
+
+// 执行结束
+func (m *Manager) untrack(seq uint64) {
+	c := &m.closing
+	shard := &c.shards[seq%closeShards]
+	shard.mu.Lock()
+	delete(shard.running, seq)
+	shard.mu.Unlock()
+	c.leave()
+}
+
+// 执行数减一，关闭后最后一个执行结束时通知 Close
+func (c *closeState) leave() {
+	if atomic.AddInt64(&c.active, -1) != 0 || atomic.LoadInt32(&c.closed) == 0 {
+		return
+	}
+	c.mu.Lock()
+	if c.idle != nil {
+		close(c.idle)
+		c.idle = nil
+	}
+	c.mu.Unlock()
+}
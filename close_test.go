@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 工作节点在started 中发送执行的标识，然后等待release
+func newBlockingManager(t *testing.T, started chan string, release chan struct{}) *Manager {
+	m := NewManager(WithContextValues())
+	_ = m.AddWorkerNode("block", func(ctx context.Context, in *rawData) (*rawData, error) {
+		id, _ := ExecutionIDFrom(ctx)
+		started <- id
+		<-release
+		return in, nil
+	})
+	if err := m.BuildPipeline([][]string{{Head, "block"}, {"block", Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// Close 等待正在执行的流水线结束后返回
+func TestManager_Close(t *testing.T) {
+	started, release := make(chan string, 1), make(chan struct{})
+	m := newBlockingManager(t, started, release)
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Handle(&rawData{Data: 1})
+		done <- err
+	}()
+	<-started
+	closed := make(chan error, 1)
+	go func() {
+		closed <- m.Close(context.Background())
+	}()
+	select {
+	case err := <-closed:
+		t.Fatalf("Close returned before the execution finished: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("in-flight execution: %v", err)
+	}
+	if err := <-closed; err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if err := m.Close(context.Background()); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+// ctx 结束时还没有结束的执行在错误中列出
+func TestManager_CloseTimedOut(t *testing.T) {
+	started, release := make(chan string, 1), make(chan struct{})
+	defer close(release)
+	m := newBlockingManager(t, started, release)
+	go func() {
+		_, _ = m.Handle(&rawData{Data: 1})
+	}()
+	id := <-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := m.Close(ctx)
+	if !errors.Is(err, ErrCloseTimedOut) || !strings.Contains(err.Error(), id) {
+		t.Errorf("want ErrCloseTimedOut listing %s, got %v", id, err)
+	}
+}
+
+// 关闭之后不再接受新的执行
+func TestManager_HandleAfterClose(t *testing.T) {
+	m := newSingleWorkerManager(t, passWorker)
+	if err := m.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Handle(&rawData{Data: 1}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Handle: want ErrClosed, got %v", err)
+	}
+	if _, err := m.HandleSubgraph(context.Background(), "w1", "w1", &rawData{Data: 1}); !errors.Is(err, ErrClosed) {
+		t.Errorf("HandleSubgraph: want ErrClosed, got %v", err)
+	}
+}
+
+// 测试并发执行的同时关闭：每个执行要么返回ErrClosed，要么在 Close 返回之前结束
+func TestManager_CloseConcurrent(t *testing.T) {
+	var finished int32
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&finished, 1)
+		return in, nil
+	})
+	var wg sync.WaitGroup
+	var accepted int32
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.Handle(&rawData{}); err == nil {
+				atomic.AddInt32(&accepted, 1)
+			} else if !errors.Is(err, ErrClosed) {
+				t.Error(err)
+			}
+		}()
+	}
+	time.Sleep(time.Millisecond)
+	if err := m.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Close 返回时被接受的执行都已经执行完工作节点
+	done := atomic.LoadInt32(&finished)
+	wg.Wait()
+	if done != atomic.LoadInt32(&accepted) {
+		t.Errorf("%d executions finished before Close returned, want all %d accepted ones", done, accepted)
+	}
+}
@@ -0,0 +1,189 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// 配置文件描述的流水线，也是 ExportJSON 导出的格式
+type PipelineConfig struct {
+	// 格式版本，为空时视为1.0
+	Version string       `json:"version" yaml:"version"`
+	Nodes   []NodeConfig `json:"nodes" yaml:"nodes"`
+	Edges   [][]string   `json:"edges" yaml:"edges"`
+}
+
+// 配置文件中的一个节点
+type NodeConfig struct {
+	Name string  `json:"name" yaml:"name"`
+	Typ  NodeTyp `json:"type" yaml:"type"`
+	// 引用的处理方法，为空时与节点名相同
+	// 可以写成 namespace/name 的形式，但命名空间必须是加载时指定的命名空间
+	Action string `json:"action,omitempty" yaml:"action,omitempty"`
+}
+
+// 加载配置的可选配置
+type LoadOption func(o *loadOptions)
+
+type loadOptions struct {
+	namespace string
+	// 节点名的前缀，以及配置的来源
+	prefix string
+	source string
+}
+
+// 只允许引用注册表中该命名空间下的处理方法，默认为默认命名空间
+func WithNamespace(namespace string) LoadOption {
+	return func(o *loadOptions) {
+		o.namespace = namespace
+	}
+}
+
+// 加载时给配置中的节点名加上前缀，例如 "productA/"，避免多个配置的节点重名
+func WithNodePrefix(prefix string) LoadOption {
+	return func(o *loadOptions) {
+		o.prefix = prefix
+	}
+}
+
+// 配置的来源（例如文件路径），节点重名时出现在报错中
+func WithConfigSource(source string) LoadOption {
+	return func(o *loadOptions) {
+		o.source = fmt.Sprintf("config[%s]", source)
+	}
+}
+
+// 从JSON 配置加载并构建流水线
+func LoadJSON(r io.Reader, reg *Registry, opts ...LoadOption) (*Manager, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := decodeConfig(data, json.Unmarshal)
+	if err != nil {
+		return nil, fmt.Errorf("decode json config: %w", err)
+	}
+	return LoadConfig(cfg, reg, opts...)
+}
+
+// 从YAML 配置加载并构建流水线
+func LoadYAML(r io.Reader, reg *Registry, opts ...LoadOption) (*Manager, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := decodeConfig(data, yaml.Unmarshal)
+	if err != nil {
+		return nil, fmt.Errorf("decode yaml config: %w", err)
+	}
+	return LoadConfig(cfg, reg, opts...)
+}
+
+// 导出为JSON 配置，可以通过 LoadJSON 重新加载
+// 节点的处理方法以加载时引用的名字导出，直接通过AddXxxNode 添加的节点以节点名导出
+func (m *Manager) ExportJSON() ([]byte, error) {
+	cfg := PipelineConfig{
+		Version: FormatVersion,
+		Edges:   edgePairs(m.edgeList),
+	}
+	for _, node := range m.exportOrder() {
+		if node.Typ == NodeTypHead || node.Typ == NodeTypTail {
+			continue
+		}
+		cfg.Nodes = append(cfg.Nodes, NodeConfig{
+			Name:   node.nodeName,
+			Typ:    node.Typ,
+			Action: node.actionName,
+		})
+	}
+	return json.MarshalIndent(cfg, "", "  ")
+}
+
+// 按配置添加节点并构建流水线
+func LoadConfig(cfg *PipelineConfig, reg *Registry, opts ...LoadOption) (*Manager, error) {
+	m := NewManager()
+	edges, err := LoadConfigInto(m, cfg, reg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err = m.BuildPipeline(edges); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// 将JSON 配置中的节点添加到m 中，不构建流水线，返回配置中的边
+// 用于将多个配置加载到同一个Manager：合并各个配置返回的边之后再调用BuildPipeline
+func LoadJSONInto(m *Manager, r io.Reader, reg *Registry, opts ...LoadOption) ([][]string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := decodeConfig(data, json.Unmarshal)
+	if err != nil {
+		return nil, fmt.Errorf("decode json config: %w", err)
+	}
+	return LoadConfigInto(m, cfg, reg, opts...)
+}
+
+// 将配置中的节点添加到m 中，不构建流水线，返回配置中的边
+// 设置了 WithNodePrefix 时节点名加上前缀，边中引用本配置节点的名字也一起转换，
+// 虚拟头、尾节点以及其他配置中的节点名保持不变
+func LoadConfigInto(m *Manager, cfg *PipelineConfig, reg *Registry, opts ...LoadOption) ([][]string, error) {
+	o := loadOptions{source: "config"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	local := make(map[string]bool, len(cfg.Nodes))
+	for _, nc := range cfg.Nodes {
+		action, err := o.resolve(reg, nc)
+		if err != nil {
+			return nil, err
+		}
+		name := o.prefix + nc.Name
+		if err = m.addNode(name, nc.Typ, action, nil, o.source); err != nil {
+			return nil, err
+		}
+		m.nodes[name].actionName = nc.Action
+		local[nc.Name] = true
+	}
+	m.configVersion = cfg.Version
+	edges := make([][]string, len(cfg.Edges))
+	for i, edge := range cfg.Edges {
+		edges[i] = make([]string, len(edge))
+		for j, name := range edge {
+			if local[name] {
+				name = o.prefix + name
+			}
+			edges[i][j] = name
+		}
+	}
+	return edges, nil
+}
+
+// 在指定的命名空间中查找节点引用的处理方法
+func (o *loadOptions) resolve(reg *Registry, nc NodeConfig) (interface{}, error) {
+	name := nc.Action
+	if name == "" {
+		name = nc.Name
+	}
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		if name[:i] != o.namespace {
+			return nil, fmt.Errorf("node[%s] action[%s] is outside the allowed namespace[%s]", nc.Name, name, o.namespace)
+		}
+		name = name[i+1:]
+	}
+	a, ok := reg.lookup(o.namespace, name)
+	if !ok {
+		return nil, fmt.Errorf("node[%s] action[%s] is not registered in namespace[%s]", nc.Name, name, o.namespace)
+	}
+	if a.typ != nc.Typ {
+		return nil, fmt.Errorf("node[%s] type[%s] does not match action[%s] type[%s]", nc.Name, nc.Typ, name, a.typ)
+	}
+	return a.action, nil
+}
@@ -0,0 +1,211 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 注册两个团队的处理方法，fetch 同名但行为不同
+func newTeamRegistry(t *testing.T) *Registry {
+	reg := NewRegistry()
+	if err := reg.Namespace("team-a").RegisterWorker("fetch", func(ctx context.Context, in *rawData) (out *rawData, err error) {
+		return &rawData{Data: "a"}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Namespace("team-b").RegisterWorker("fetch", func(ctx context.Context, in *rawData) (out *rawData, err error) {
+		return &rawData{Data: "b"}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Namespace("team-b").RegisterJudger("route", func(ctx context.Context, in *rawData) (pipeIndex int) {
+		return 0
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return reg
+}
+
+const fetchConfig = `{
+	"nodes": [{"name": "f1", "type": "worker", "action": "fetch"}],
+	"edges": [["head000", "f1"], ["f1", "tail111"]]
+}`
+
+// 测试同名的处理方法在不同命名空间中相互独立
+func TestLoadJSON_Namespace(t *testing.T) {
+	reg := newTeamRegistry(t)
+	for _, ns := range []string{"team-a", "team-b"} {
+		m, err := LoadJSON(strings.NewReader(fetchConfig), reg, WithNamespace(ns))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := m.Handle(&rawData{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := strings.TrimPrefix(ns, "team-"); out.Data != want {
+			t.Errorf("namespace %s: out=%v, want %s", ns, out.Data, want)
+		}
+	}
+}
+
+// 测试引用其他命名空间的处理方法会报错
+func TestLoadJSON_CrossNamespace(t *testing.T) {
+	reg := newTeamRegistry(t)
+	cfg := strings.Replace(fetchConfig, `"fetch"`, `"team-b/fetch"`, 1)
+	_, err := LoadJSON(strings.NewReader(cfg), reg, WithNamespace("team-a"))
+	if err == nil || !strings.Contains(err.Error(), "outside the allowed namespace[team-a]") {
+		t.Errorf("err=%v, want cross namespace error", err)
+	}
+	// 显式写出自己的命名空间是允许的
+	cfg = strings.Replace(fetchConfig, `"fetch"`, `"team-a/fetch"`, 1)
+	if _, err = LoadJSON(strings.NewReader(cfg), reg, WithNamespace("team-a")); err != nil {
+		t.Error(err)
+	}
+	// 只注册在其他命名空间中的处理方法找不到
+	if _, err = LoadJSON(strings.NewReader(fetchConfig), reg); err == nil {
+		t.Errorf("predict error occurs, but not")
+	}
+}
+
+// 测试从YAML 加载
+func TestLoadYAML(t *testing.T) {
+	reg := newTeamRegistry(t)
+	cfg := `
+nodes:
+  - name: fetch
+    type: worker
+edges:
+  - [head000, fetch]
+  - [fetch, tail111]
+`
+	m, err := LoadYAML(strings.NewReader(cfg), reg, WithNamespace("team-b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := m.Handle(&rawData{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Data != "b" {
+		t.Errorf("out=%v, want b", out.Data)
+	}
+}
+
+// 测试列出命名空间中的处理方法
+func TestRegistry_List(t *testing.T) {
+	reg := newTeamRegistry(t)
+	want := []ActionInfo{
+		{Namespace: "team-b", Name: "fetch", Typ: NodeTypWorker},
+		{Namespace: "team-b", Name: "route", Typ: NodeTypJudger},
+	}
+	if got := reg.List("team-b"); !reflect.DeepEqual(got, want) {
+		t.Errorf("list=%v, want %v", got, want)
+	}
+	if got := reg.List("team-c"); len(got) != 0 {
+		t.Errorf("list=%v, want empty", got)
+	}
+}
+
+// 共享的打分子图，按数据分给两个产品的前端
+const sharedScoringConfig = `{
+	"nodes": [{"name": "score", "type": "worker"}, {"name": "route", "type": "judger"}],
+	"edges": [["head", "score"], ["score", "route"], ["route", "productA/parse"], ["route", "productB/parse"]]
+}`
+
+// 每个产品的前端使用同一份配置
+const productConfig = `{
+	"nodes": [{"name": "parse", "type": "worker"}],
+	"edges": [["parse", "tail"]]
+}`
+
+func newProductRegistry(t *testing.T) *Registry {
+	reg := NewRegistry()
+	if err := reg.RegisterWorker("score", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: in.Data.(string) + "+score"}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.RegisterWorker("parse", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: in.Data.(string) + "+parse"}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.RegisterJudger("route", func(ctx context.Context, in *rawData) int {
+		if strings.HasPrefix(in.Data.(string), "a") {
+			return 0
+		}
+		return 1
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return reg
+}
+
+// 测试多个配置中的节点重名时报错中带有两个来源
+func TestLoadJSONInto_Collision(t *testing.T) {
+	reg := newProductRegistry(t)
+	m := NewManager()
+	if _, err := LoadJSONInto(m, strings.NewReader(productConfig), reg, WithConfigSource("product-a.json")); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadJSONInto(m, strings.NewReader(productConfig), reg, WithConfigSource("product-b.json"))
+	if !errors.Is(err, ErrorsNodeNameDuplicate) {
+		t.Fatalf("want ErrorsNodeNameDuplicate, got %v", err)
+	}
+	for _, want := range []string{"node[parse]", "config[product-b.json]", "config[product-a.json]"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should contain %s: %v", want, err)
+		}
+	}
+	// 直接添加的节点报错中带有添加节点的代码位置
+	err = m.AddWorkerNode("parse", passWorker)
+	if err == nil || !strings.Contains(err.Error(), "config_test.go:") {
+		t.Errorf("error should contain the call site: %v", err)
+	}
+}
+
+// 测试加上前缀后多个配置可以加载到同一个Manager 中构建并执行
+func TestLoadJSONInto_Prefix(t *testing.T) {
+	reg := newProductRegistry(t)
+	m := NewManager()
+	var edges [][]string
+	for _, load := range []struct {
+		cfg  string
+		opts []LoadOption
+	}{
+		{sharedScoringConfig, nil},
+		{productConfig, []LoadOption{WithNodePrefix("productA/")}},
+		{productConfig, []LoadOption{WithNodePrefix("productB/")}},
+	} {
+		e, err := LoadJSONInto(m, strings.NewReader(load.cfg), reg, load.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		edges = append(edges, e...)
+	}
+	if err := m.BuildPipeline(edges); err != nil {
+		t.Fatal(err)
+	}
+	for in, want := range map[string]string{"a": "a+score+parse", "b": "b+score+parse"} {
+		trace := &Trace{}
+		out, err := m.HandleContext(context.Background(), &rawData{Data: in}, WithTrace(trace))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Data != want {
+			t.Errorf("out=%v, want %s", out.Data, want)
+		}
+		entries := trace.Entries()
+		wantNode := "productA/parse"
+		if in == "b" {
+			wantNode = "productB/parse"
+		}
+		if last := entries[len(entries)-1].Node; last != wantNode {
+			t.Errorf("input %s ended at %s, want %s", in, last, wantNode)
+		}
+	}
+}
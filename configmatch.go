@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 配置文件的格式
+type ConfigFormat int
+
+const (
+	ConfigJSON ConfigFormat = iota
+	ConfigYAML
+)
+
+// Manager 与配置之间的一处结构差异，Kind 为 "added_node"、"removed_node"、"renamed_node"、
+// "changed_node"、"added_edge"、"removed_edge"、"reordered_successors" 之一
+// added 表示配置中有而Manager 中没有，removed 相反
+type Difference struct {
+	Kind   string
+	Node   string
+	Edge   [2]string
+	Detail string
+}
+
+// MatchesConfig 的可选配置
+type MatchOption func(o *matchOptions)
+
+type matchOptions struct {
+	labels bool
+}
+
+// 同时比较分支名和节点说明，默认不比较
+func MatchLabels() MatchOption {
+	return func(o *matchOptions) {
+		o.labels = true
+	}
+}
+
+// 检查m 与配置描述的流水线结构是否一致，用于启动时发现代码和配置不同步
+// 配置加载到一个临时的Manager 中，只比较配置能够表达的节点名、类型以及边，不比较处理方法、节点配置和阶段
+// 配置无法加载或构建时返回错误；m 必须已经构建
+func (m *Manager) MatchesConfig(r io.Reader, reg *Registry, format ConfigFormat, opts ...MatchOption) (bool, []Difference, error) {
+	if !m.built {
+		return false, nil, ErrorsPipelineNotBuilt
+	}
+	var o matchOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var cfg *Manager
+	var err error
+	switch format {
+	case ConfigJSON:
+		cfg, err = LoadJSON(r, reg)
+	case ConfigYAML:
+		cfg, err = LoadYAML(r, reg)
+	default:
+		err = fmt.Errorf("unknown config format %d", format)
+	}
+	if err != nil {
+		return false, nil, err
+	}
+	d := diffViews(m.structureViews(o), cfg.structureViews(o), edgePairs(m.edgeList), edgePairs(cfg.edgeList))
+	diffs := d.differences()
+	return len(diffs) == 0, diffs, nil
+}
+
+// 只包含配置能够表达的节点信息：节点名和类型；配置中没有节点配置和阶段，代码中的重试、超时等配置不参与比较
+// 开启 MatchLabels 时再比较分支名和节点说明
+func (m *Manager) structureViews(o matchOptions) map[string]nodeView {
+	views := m.nodeViews()
+	for name, v := range views {
+		var opts []string
+		if o.labels {
+			for _, kv := range v.opts {
+				if strings.HasPrefix(kv, "branches=") {
+					opts = append(opts, kv)
+				}
+			}
+			if desc := m.nodes[name].opts.description; desc != "" {
+				opts = append(opts, "description="+desc)
+			}
+		}
+		v.action, v.stage, v.opts = "", "", opts
+		v.signature = fmt.Sprintf("%s|%s", v.typ, strings.Join(opts, ","))
+		views[name] = v
+	}
+	return views
+}
+
+// 按 String 中的顺序展开为 Difference
+func (d PipelineDiff) differences() []Difference {
+	var diffs []Difference
+	for _, n := range d.RemovedNodes {
+		diffs = append(diffs, Difference{Kind: "removed_node", Node: n})
+	}
+	for _, n := range d.AddedNodes {
+		diffs = append(diffs, Difference{Kind: "added_node", Node: n})
+	}
+	for _, r := range d.RenamedNodes {
+		diffs = append(diffs, Difference{Kind: "renamed_node", Node: r.From, Detail: "renamed to " + r.To})
+	}
+	for _, c := range d.ChangedNodes {
+		for _, change := range c.Changes {
+			diffs = append(diffs, Difference{Kind: "changed_node", Node: c.Node, Detail: change})
+		}
+	}
+	for _, e := range d.RemovedEdges {
+		diffs = append(diffs, Difference{Kind: "removed_edge", Edge: e})
+	}
+	for _, e := range d.AddedEdges {
+		diffs = append(diffs, Difference{Kind: "added_edge", Edge: e})
+	}
+	for _, r := range d.ReorderedSuccessors {
+		diffs = append(diffs, Difference{Kind: "reordered_successors", Node: r.Node,
+			Detail: fmt.Sprintf("[%s] -> [%s]", strings.Join(r.Before, " "), strings.Join(r.After, " "))})
+	}
+	return diffs
+}
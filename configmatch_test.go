@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const matchConfig = `{
+	"nodes": [
+		{"name": "fetch", "type": "worker"},
+		{"name": "route", "type": "judger"},
+		{"name": "a", "type": "worker", "action": "fetch"},
+		{"name": "b", "type": "worker", "action": "fetch"}
+	],
+	"edges": [["head000", "fetch"], ["fetch", "route"], ["route", "a"], ["route", "b"], ["a", "tail111"], ["b", "tail111"]]
+}`
+
+func newMatchRegistry(t *testing.T) *Registry {
+	reg := NewRegistry()
+	if err := reg.RegisterWorker("fetch", passWorker); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.RegisterJudger("route", func(ctx context.Context, in *rawData) int { return 0 }); err != nil {
+		t.Fatal(err)
+	}
+	return reg
+}
+
+// 代码中的流水线，处理方法是与注册表不同的闭包
+func newMatchManager(t *testing.T, edges [][]string, opts ...NodeOption) *Manager {
+	m := NewManager()
+	worker := func(ctx context.Context, in *rawData) (*rawData, error) { return in, nil }
+	_ = m.AddWorkerNode("fetch", worker, opts...)
+	_ = m.AddJudgerNode("route", func(ctx context.Context, in *rawData) int { return 1 }, WithBranches("left", "right"))
+	_ = m.AddWorkerNode("a", worker)
+	_ = m.AddWorkerNode("b", worker)
+	if err := m.BuildPipeline(edges); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+var matchEdges = [][]string{{Head, "fetch"}, {"fetch", "route"}, {"route", "a"}, {"route", "b"}, {"a", Tail}, {"b", Tail}}
+
+// 测试代码与配置的结构比较：处理方法和分支名不参与比较，边和节点配置的差异逐项返回
+func TestManager_MatchesConfig(t *testing.T) {
+	reg := newMatchRegistry(t)
+	ok, diffs, err := newMatchManager(t, matchEdges).MatchesConfig(strings.NewReader(matchConfig), reg, ConfigJSON)
+	if err != nil || !ok || len(diffs) != 0 {
+		t.Errorf("want match, got %v %v %v", ok, diffs, err)
+	}
+	ok, diffs, _ = newMatchManager(t, matchEdges).MatchesConfig(strings.NewReader(matchConfig), reg, ConfigJSON, MatchLabels())
+	want := []Difference{{Kind: "changed_node", Node: "route", Detail: "branches: left,right -> none"}}
+	if ok || !reflect.DeepEqual(diffs, want) {
+		t.Errorf("with labels want %v, got %v", want, diffs)
+	}
+
+	// 代码把 a 移到了判断节点之前
+	moved := [][]string{{Head, "fetch"}, {"fetch", "a"}, {"a", "route"}, {"route", "b"}, {"route", Tail}, {"b", Tail}}
+	ok, diffs, _ = newMatchManager(t, moved).MatchesConfig(strings.NewReader(matchConfig), reg, ConfigJSON)
+	want = []Difference{
+		{Kind: "removed_edge", Edge: [2]string{"fetch", "a"}},
+		{Kind: "removed_edge", Edge: [2]string{"a", "route"}},
+		{Kind: "removed_edge", Edge: [2]string{"route", "tail"}},
+		{Kind: "added_edge", Edge: [2]string{"fetch", "route"}},
+		{Kind: "added_edge", Edge: [2]string{"route", "a"}},
+		{Kind: "added_edge", Edge: [2]string{"a", "tail"}},
+	}
+	if ok || !reflect.DeepEqual(diffs, want) {
+		t.Errorf("edge drift want %v, got %v", want, diffs)
+	}
+
+	// 配置格式中没有节点配置和阶段，代码中设置的这些不算作差异
+	withOptions := newMatchManager(t, matchEdges, WithRetry(3), WithTimeout(time.Second), WithDescription("fetch the order"))
+	if err := withOptions.DefineStage("load", []string{"fetch"}); err != nil {
+		t.Fatal(err)
+	}
+	ok, diffs, err = withOptions.MatchesConfig(strings.NewReader(matchConfig), reg, ConfigJSON)
+	if err != nil || !ok || len(diffs) != 0 {
+		t.Errorf("node options should not count as drift, got %v %v %v", ok, diffs, err)
+	}
+	// 节点类型仍然参与比较：配置中route 是判断节点，代码中是分裂节点
+	typeChanged := NewManager()
+	_ = typeChanged.AddWorkerNode("fetch", passWorker)
+	_ = typeChanged.AddDividerNode("route", func(ctx context.Context, in *rawData) ([]*rawData, error) { return []*rawData{in, in}, nil })
+	_ = typeChanged.AddWorkerNode("a", passWorker)
+	_ = typeChanged.AddWorkerNode("b", passWorker)
+	if err := typeChanged.BuildPipeline(matchEdges); err != nil {
+		t.Fatal(err)
+	}
+	ok, diffs, _ = typeChanged.MatchesConfig(strings.NewReader(matchConfig), reg, ConfigJSON)
+	want = []Difference{{Kind: "changed_node", Node: "route", Detail: "type: divider -> judger"}}
+	if ok || !reflect.DeepEqual(diffs, want) {
+		t.Errorf("type drift want %v, got %v", want, diffs)
+	}
+
+	if _, _, err := NewManager().MatchesConfig(strings.NewReader(matchConfig), reg, ConfigJSON); err != ErrorsPipelineNotBuilt {
+		t.Errorf("want ErrorsPipelineNotBuilt, got %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrContextRequired = errors.New("context required, use HandleContext")
+	ErrContextIgnored  = errors.New("node action ignored context deadline")
+)
+
+// 要求调用方传入ctx：调用不带ctx 的 Handle 或传入nil ctx 时返回ErrContextRequired
+func WithRequireContext() Option {
+	return func(m *Manager) {
+		m.requireContext = true
+	}
+}
+
+// 调试用：节点的处理方法在ctx 的截止时间之后才返回，并且返回的不是ctx 的错误时，
+// 说明它没有检查ctx，本次调用返回ErrContextIgnored
+func WithDeadlineAssertions() Option {
+	return func(m *Manager) {
+		m.deadlineAssertions = true
+	}
+}
+
+// 检查处理方法是否在截止时间之后才返回
+func (e *execution) checkDeadline(ctx context.Context, node *Node, err error) error {
+	if !e.m.deadlineAssertions || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok || !e.m.clock.Now().After(deadline) {
+		return err
+	}
+	return runtimeError(node, ErrContextIgnored, "action ignored context deadline",
+		fmt.Sprintf("returned %v after deadline", e.m.clock.Now().Sub(deadline)))
+}
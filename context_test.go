@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 测试要求ctx 时拒绝不带ctx 的调用
+func TestManager_RequireContext(t *testing.T) {
+	m := newSingleWorkerManager(t, passWorker, WithRequireContext())
+	if _, err := m.Handle(&rawData{}); !errors.Is(err, ErrContextRequired) {
+		t.Errorf("err=%v, want ErrContextRequired", err)
+	}
+	if _, err := m.HandleContext(nil, &rawData{}); !errors.Is(err, ErrContextRequired) {
+		t.Errorf("err=%v, want ErrContextRequired", err)
+	}
+	if _, err := m.HandleContext(context.Background(), &rawData{}); err != nil {
+		t.Errorf("err=%v", err)
+	}
+}
+
+type ctxKey struct{}
+
+// 测试每种节点收到的ctx 都来自调用方
+func TestManager_RequireContextPropagation(t *testing.T) {
+	seen := map[string]bool{}
+	check := func(ctx context.Context, node string) {
+		seen[node] = ctx.Value(ctxKey{}) == "caller"
+	}
+	m := NewManager(WithRequireContext())
+	_ = m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) int {
+		check(ctx, "j1")
+		return 1
+	})
+	_ = m.AddWorkerNode("skip", passWorker)
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		check(ctx, "d1")
+		return []*rawData{in, {Data: in.Data}}, nil
+	})
+	for _, name := range []string{"a", "b"} {
+		name := name
+		_ = m.AddWorkerNode(name, func(ctx context.Context, in *rawData) (*rawData, error) {
+			check(ctx, name)
+			return in, nil
+		})
+	}
+	_ = m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		check(ctx, "m1")
+		return in[0], nil
+	})
+	if err := m.BuildPipeline([][]string{
+		{Head, "j1"},
+		{"j1", "skip"}, {"j1", "d1"},
+		{"skip", Tail},
+		{"d1", "a"}, {"d1", "b"},
+		{"a", "m1"}, {"b", "m1"},
+		{"m1", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "caller")
+	if _, err := m.HandleContext(ctx, &rawData{Data: 1}); err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range []string{"j1", "d1", "a", "b", "m1"} {
+		if !seen[node] {
+			t.Errorf("node[%s] did not receive the caller's context", node)
+		}
+	}
+}
+
+// 测试处理方法在截止时间之后才返回时报错
+func TestManager_DeadlineAssertions(t *testing.T) {
+	clock := newFakeClock()
+	slow := func(ctx context.Context, in *rawData) (*rawData, error) {
+		clock.Advance(time.Second)
+		return in, nil
+	}
+	ctx := fakeDeadlineCtx{Context: context.Background(), deadline: clock.Now().Add(100 * time.Millisecond)}
+	m := newSingleWorkerManager(t, slow, WithClock(clock))
+	if _, err := m.HandleContext(ctx, &rawData{}); err != nil {
+		t.Errorf("without assertions: err=%v", err)
+	}
+	ctx.deadline = clock.Now().Add(100 * time.Millisecond)
+	m = newSingleWorkerManager(t, slow, WithClock(clock), WithDeadlineAssertions())
+	if _, err := m.HandleContext(ctx, &rawData{}); !errors.Is(err, ErrContextIgnored) {
+		t.Errorf("err=%v, want ErrContextIgnored", err)
+	}
+}
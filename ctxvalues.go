@@ -0,0 +1,83 @@
+package pipeline
+
+import "context"
+
+// 引擎向节点处理方法的ctx 中注入的值，通过下面的 XxxFrom 方法取得，key 都是不导出的类型，不会与用户的key 冲突
+//
+// 开启 WithContextValues 后，所有类型节点（工作、分裂、合并、判断节点）的处理方法中一定存在：
+//   - ExecutionIDFrom：执行的标识，与死信、检查点、CancelledError 中的标识相同
+//   - NodeInfoFrom：当前节点的信息
+// 只在部分节点中存在：
+//   - BranchFrom：节点位于分裂节点或判断节点的分支中时存在，合并之后恢复为外层的分支
+// 由其他配置决定是否存在：
+//   - EnvFrom：设置了 WithEnv 或 WithCallEnv，否则返回默认值
+//   - RandFrom：设置了 WithExecutionRand 或 WithSeed，否则返回共享的随机数来源
+//   - Flag：设置了 WithFlags，否则都为false
+//   - Manager.RecursionDepth：设置了 WithMaxRecursionDepth 或 WithMaxInflightExecutions
+// 用户传入的ctx 中的值原样传给每个节点
+func WithContextValues() Option {
+	return func(m *Manager) {
+		m.contextValues = true
+	}
+}
+
+type (
+	execIDKey struct{}
+	nodeKey   struct{}
+	branchKey struct{}
+)
+
+// 节点所在的分支
+type BranchInfo struct {
+	// 分出该分支的分裂节点或判断节点
+	Node string
+	// 分支的索引，以及分支名（没有命名时为索引）
+	Index int
+	Name  string
+}
+
+// 返回执行的标识
+func ExecutionIDFrom(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(execIDKey{}).(string)
+	return id, ok
+}
+
+// 返回当前节点的信息
+func NodeInfoFrom(ctx context.Context) (NodeInfo, bool) {
+	node, ok := ctx.Value(nodeKey{}).(*Node)
+	if !ok {
+		return NodeInfo{}, false
+	}
+	return node.info(), true
+}
+
+// 返回节点所在的最内层的分支
+func BranchFrom(ctx context.Context) (BranchInfo, bool) {
+	b, ok := ctx.Value(branchKey{}).(*BranchInfo)
+	if !ok {
+		return BranchInfo{}, false
+	}
+	return *b, true
+}
+
+// 返回在节点中嵌套调用m 的深度，最外层的调用为1；没有记录深度时ok 为false，见 WithMaxRecursionDepth
+func (m *Manager) RecursionDepth(ctx context.Context) (int, bool) {
+	depth, ok := ctx.Value(recursionKey{m}).(int)
+	return depth, ok
+}
+
+// 调用节点处理方法的ctx
+func (e *execution) nodeContext(ctx context.Context, node *Node) context.Context {
+	if !e.m.contextValues {
+		return ctx
+	}
+	return context.WithValue(ctx, nodeKey{}, node)
+}
+
+// 分裂节点、判断节点第i 个分支上的节点使用的ctx
+func (e *execution) branchContext(ctx context.Context, node *Node, i int) context.Context {
+	if !e.m.contextValues {
+		return ctx
+	}
+	return context.WithValue(ctx, branchKey{}, &BranchInfo{Node: node.nodeName, Index: i, Name: node.branchName(i)})
+}
@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+type userKey struct{}
+
+// 节点处理方法中取得的ctx 值
+type ctxSnapshot struct {
+	execID   string
+	info     NodeInfo
+	infoOK   bool
+	branch   BranchInfo
+	branchOK bool
+	user     interface{}
+}
+
+// head -> j1 -> (d1 -> (a, b) -> m1 | w2) -> tail，每个节点记录ctx 中的值
+func newCtxValuesManager(t *testing.T, seen map[string]ctxSnapshot, opts ...Option) *Manager {
+	var mu sync.Mutex
+	snap := func(ctx context.Context) {
+		s := ctxSnapshot{user: ctx.Value(userKey{})}
+		s.execID, _ = ExecutionIDFrom(ctx)
+		s.info, s.infoOK = NodeInfoFrom(ctx)
+		s.branch, s.branchOK = BranchFrom(ctx)
+		mu.Lock()
+		seen[s.info.Name] = s
+		mu.Unlock()
+	}
+	m := NewManager(opts...)
+	if err := m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) int {
+		snap(ctx)
+		return 0
+	}, WithBranches("fanout", "single")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		snap(ctx)
+		return []*rawData{in, in}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "w2"} {
+		if err := m.AddWorkerNode(name, func(ctx context.Context, in *rawData) (*rawData, error) {
+			snap(ctx)
+			return in, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		snap(ctx)
+		return in[0], nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.BuildPipeline([][]string{
+		{Head, "j1"},
+		{"j1", "d1"},
+		{"j1", "w2"},
+		{"d1", "a"},
+		{"d1", "b"},
+		{"a", "m1"},
+		{"b", "m1"},
+		{"m1", Tail},
+		{"w2", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestManager_ContextValues(t *testing.T) {
+	seen := make(map[string]ctxSnapshot)
+	m := newCtxValuesManager(t, seen, WithContextValues())
+	ctx := context.WithValue(context.Background(), userKey{}, "mine")
+	if _, err := m.HandleContext(ctx, &rawData{}); err != nil {
+		t.Fatal(err)
+	}
+	fanout := BranchInfo{Node: "j1", Index: 0, Name: "fanout"}
+	want := map[string]struct {
+		typ    NodeTyp
+		branch *BranchInfo
+	}{
+		"j1": {NodeTypJudger, nil},
+		"d1": {NodeTypDivider, &fanout},
+		"a":  {NodeTypWorker, &BranchInfo{Node: "d1", Index: 0, Name: "0"}},
+		"b":  {NodeTypWorker, &BranchInfo{Node: "d1", Index: 1, Name: "1"}},
+		// 合并之后恢复为外层的分支
+		"m1": {NodeTypMerger, &fanout},
+	}
+	if len(seen) != len(want) {
+		t.Fatalf("executed %v", seen)
+	}
+	execID := seen["j1"].execID
+	if execID == "" {
+		t.Fatal("missing execution id")
+	}
+	for name, w := range want {
+		s := seen[name]
+		if !s.infoOK || s.info.Typ != w.typ || s.execID != execID || s.user != "mine" {
+			t.Errorf("node %s: %+v", name, s)
+		}
+		if s.branchOK != (w.branch != nil) || (w.branch != nil && s.branch != *w.branch) {
+			t.Errorf("node %s branch=%+v %v, want %+v", name, s.branch, s.branchOK, w.branch)
+		}
+	}
+	if _, err := m.HandleContext(ctx, &rawData{}); err != nil {
+		t.Fatal(err)
+	}
+	if seen["j1"].execID == execID {
+		t.Errorf("executions should have different ids")
+	}
+}
+
+// 测试不开启时不注入任何值，用户的值仍然原样传递
+func TestManager_ContextValuesDisabled(t *testing.T) {
+	seen := make(map[string]ctxSnapshot)
+	m := newCtxValuesManager(t, seen)
+	ctx := context.WithValue(context.Background(), userKey{}, "mine")
+	if _, err := m.HandleContext(ctx, &rawData{}); err != nil {
+		t.Fatal(err)
+	}
+	// 没有节点信息时所有节点记录在空名字下
+	s := seen[""]
+	if s.infoOK || s.branchOK || s.execID != "" || s.user != "mine" {
+		t.Errorf("unexpected ctx values %+v", s)
+	}
+	if _, ok := m.RecursionDepth(ctx); ok {
+		t.Errorf("recursion depth should not be tracked")
+	}
+}
@@ -0,0 +1,17 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// 返回距离ctx 截止时间的剩余时间，ctx 没有截止时间时第二个返回值为false
+// 节点收到的ctx 已经合并了单次执行和节点自身的超时，返回的是两者中较近的一个；
+// 判断节点可以据此在时间不足时选择较快的分支
+func RemainingBudget(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
@@ -0,0 +1,25 @@
+package pipeline
+
+// 判断节点的决策，节点名 -> 选择的分支索引
+type Decisions map[string]int
+
+// 返回轨迹中记录的判断节点决策，可以通过 WithForcedDecisions 重放
+func (t *Trace) Decisions() Decisions {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d := make(Decisions)
+	for _, entry := range t.entries {
+		if entry.Typ == NodeTypJudger && entry.BranchIndex >= 0 {
+			d[entry.Node] = entry.BranchIndex
+		}
+	}
+	return d
+}
+
+// 判断节点不再调用判断方法，而是使用d 中的决策，用于按线上的路由重放一次执行
+// 执行到的判断节点在d 中没有决策，或者决策的分支不存在时报错
+func WithForcedDecisions(d Decisions) CallOption {
+	return func(o *callOptions) {
+		o.forced = d
+	}
+}
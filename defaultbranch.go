@@ -0,0 +1,73 @@
+package pipeline
+
+import "fmt"
+
+// 判断节点的默认分支
+type defaultBranch struct {
+	index int
+	set   bool
+	// 所有负数都选择默认分支，否则只有-1
+	anyNegative bool
+}
+
+// 判断节点返回-1 时选择第idx 个分支，而不是报错；idx 在构建时按节点的出边数检查
+// 返回其他越界的序号仍然报错
+func WithDefaultBranch(idx int) NodeOption {
+	return func(o *nodeOptions) {
+		if o.defaultBranch == nil {
+			o.defaultBranch = &defaultBranch{}
+		}
+		o.defaultBranch.index, o.defaultBranch.set = idx, true
+		o.use("WithDefaultBranch", NodeTypJudger)
+	}
+}
+
+// 判断节点返回任意负数时都选择 WithDefaultBranch 设置的默认分支
+func WithDefaultOnAnyNegative() NodeOption {
+	return func(o *nodeOptions) {
+		if o.defaultBranch == nil {
+			o.defaultBranch = &defaultBranch{}
+		}
+		o.defaultBranch.anyNegative = true
+		o.use("WithDefaultOnAnyNegative", NodeTypJudger)
+	}
+}
+
+// 检查判断节点的默认分支是否存在
+func (m *Manager) validateDefaultBranches() error {
+	for _, node := range m.nodes {
+		d := node.opts.defaultBranch
+		if d == nil || node.Typ != NodeTypJudger {
+			continue
+		}
+		if !d.set {
+			return invalidNode(CodeBadOption, node.nodeName, fmt.Errorf("judger node[%s] WithDefaultOnAnyNegative needs WithDefaultBranch", node.nodeName))
+		}
+		if d.index < 0 || d.index >= len(node.Next) {
+			return invalidNode(CodeBadOption, node.nodeName, fmt.Errorf("judger node[%s] default branch %d out of range, valid branches %s",
+				node.nodeName, d.index, node.branchList()))
+		}
+	}
+	return nil
+}
+
+// 判断节点返回的负数序号换成默认分支，没有默认分支或者不适用时原样返回
+func (d *defaultBranch) resolve(pIndex int) int {
+	if d != nil && (pIndex == -1 || pIndex < 0 && d.anyNegative) {
+		return d.index
+	}
+	return pIndex
+}
+
+// 判断节点返回的序号越界时的说明
+func (n *Node) defaultBranchHint() string {
+	d := n.opts.defaultBranch
+	switch {
+	case d == nil:
+		return "use WithDefaultBranch to route -1 to a default branch"
+	case d.anyNegative:
+		return fmt.Sprintf("negative index routes to default branch %d", d.index)
+	default:
+		return fmt.Sprintf("-1 routes to default branch %d", d.index)
+	}
+}
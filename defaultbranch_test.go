@@ -0,0 +1,73 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// 判断节点按输入选择分支，分支a、b 分别把输出设置为 "a"、"b"
+func newDefaultBranchManager(opts ...NodeOption) (*Manager, error) {
+	m := NewManager()
+	_ = m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) int {
+		return in.Data.(int)
+	}, opts...)
+	_ = m.AddWorkerNode("a", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: "a"}, nil
+	})
+	_ = m.AddWorkerNode("b", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: "b"}, nil
+	})
+	err := m.BuildPipeline([][]string{{Head, "j1"}, {"j1", "a"}, {"j1", "b"}, {"a", Tail}, {"b", Tail}})
+	return m, err
+}
+
+// 返回-1 时选择默认分支，其他越界的序号仍然报错
+func TestManager_DefaultBranch(t *testing.T) {
+	m, err := newDefaultBranchManager(WithDefaultBranch(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for decision, expected := range map[int]string{-1: "b", 0: "a", 1: "b"} {
+		if out, err := m.Handle(&rawData{Data: decision}); err != nil || out.Data != expected {
+			t.Errorf("decision %d: expected %s, got %v, %v", decision, expected, out, err)
+		}
+	}
+	for _, decision := range []int{2, -2} {
+		_, err := m.Handle(&rawData{Data: decision})
+		if err == nil || !errors.Is(err, ErrJudgerBranchOutOfRange) || !strings.Contains(err.Error(), "default branch 1") {
+			t.Errorf("decision %d: expected out of range error, got %v", decision, err)
+		}
+	}
+
+	m, err = newDefaultBranchManager(WithDefaultBranch(0), WithDefaultOnAnyNegative())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := m.Handle(&rawData{Data: -5}); err != nil || out.Data != "a" {
+		t.Errorf("any negative: expected a, got %v, %v", out, err)
+	}
+
+	// 没有默认分支时报错提示可以设置默认分支
+	m, err = newDefaultBranchManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Handle(&rawData{Data: -1}); err == nil || !strings.Contains(err.Error(), "WithDefaultBranch") {
+		t.Errorf("expected hint about WithDefaultBranch, got %v", err)
+	}
+}
+
+// 默认分支必须是判断节点已有的分支
+func TestManager_BuildDefaultBranch(t *testing.T) {
+	for _, opts := range [][]NodeOption{
+		{WithDefaultBranch(2)},
+		{WithDefaultBranch(-1)},
+		{WithDefaultOnAnyNegative()},
+	} {
+		if _, err := newDefaultBranchManager(opts...); err == nil || !strings.Contains(err.Error(), "judger node[j1]") {
+			t.Errorf("expected build error, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Manager 级别的节点配置默认值，构建时应用到没有设置对应配置的节点上
+type nodeDefaults struct {
+	timeout time.Duration
+	retry   *retryOptions
+	// 见 WithDefaultSizer
+	sizer func(*rawData) int
+}
+
+// 工作节点、分裂节点、合并节点单次执行（每次重试）的超时，处理方法需要响应ctx 的结束
+func WithTimeout(d time.Duration) NodeOption {
+	return func(o *nodeOptions) {
+		o.timeout, o.noTimeout = d, false
+		o.use("WithTimeout", retryNodeTypes...)
+	}
+}
+
+// 节点不使用超时，包括 WithDefaultNodeTimeout 设置的默认超时
+func WithNoTimeout() NodeOption {
+	return func(o *nodeOptions) {
+		o.timeout, o.noTimeout = 0, true
+		o.use("WithNoTimeout", retryNodeTypes...)
+	}
+}
+
+// 没有设置 WithTimeout、WithNoTimeout 的工作节点、分裂节点、合并节点使用的超时
+func WithDefaultNodeTimeout(d time.Duration) Option {
+	return func(m *Manager) {
+		m.defaults.timeout = d
+	}
+}
+
+// 没有设置重试（WithRetry、WithBackoff、WithMaxBackoff）的工作节点、分裂节点、合并节点使用的重试配置，
+// backoff 为nil 时重试之间不等待；节点设置了任意一项重试配置时完全使用节点的配置
+func WithDefaultRetry(attempts int, backoff Backoff) Option {
+	return func(m *Manager) {
+		m.defaults.retry = &retryOptions{attempts: attempts, backoff: backoff}
+	}
+}
+
+// 构建时计算每个节点生效的配置，之后修改默认值不影响已经构建的流水线
+func (m *Manager) resolveNodeOptions() {
+	for _, node := range m.nodes {
+		node.effective = node.opts
+		applies := false
+		for _, t := range retryNodeTypes {
+			applies = applies || node.Typ == t
+		}
+		if !applies || node.opts.bare {
+			continue
+		}
+		if node.effective.retry == nil && m.defaults.retry != nil {
+			r := *m.defaults.retry
+			node.effective.retry = &r
+		}
+		if node.effective.timeout == 0 && !node.effective.noTimeout {
+			node.effective.timeout = m.defaults.timeout
+		}
+	}
+}
+
+// 单次执行因为节点超时失败时说明超时的设置
+func attemptError(ctx, actx context.Context, node *Node, err error) error {
+	if err == nil || ctx.Err() != nil || !errors.Is(actx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return runtimeError(node, err, "timed out", fmt.Sprintf("after %v: %v", node.effective.timeout, err))
+}
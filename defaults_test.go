@@ -0,0 +1,102 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 等待d 或者ctx 结束
+func sleepWorker(d time.Duration) WorkerFunc {
+	return func(ctx context.Context, in *rawData) (*rawData, error) {
+		select {
+		case <-time.After(d):
+			return in, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func TestManager_DefaultNodeTimeout(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		opts    []NodeOption
+		wantErr bool
+		wantOpt string
+	}{
+		{"default", nil, true, "timeout=20ms"},
+		{"override", []NodeOption{WithTimeout(time.Second)}, false, "timeout=1s"},
+		{"opt out", []NodeOption{WithNoTimeout()}, false, "timeout=none"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			m := NewManager(WithDefaultNodeTimeout(20 * time.Millisecond))
+			if err := m.AddWorkerNode("w1", sleepWorker(100*time.Millisecond), c.opts...); err != nil {
+				t.Fatal(err)
+			}
+			if info, _ := m.NodeInfo("w1"); info.EffectiveOptions != nil {
+				t.Errorf("effective options should be empty before build, got %v", info.EffectiveOptions)
+			}
+			if err := m.BuildPipeline([][]string{{Head, "w1"}, {"w1", Tail}}); err != nil {
+				t.Fatal(err)
+			}
+			info, _ := m.NodeInfo("w1")
+			if len(info.EffectiveOptions) != 1 || info.EffectiveOptions[0] != c.wantOpt {
+				t.Errorf("effective options = %v, want [%s]", info.EffectiveOptions, c.wantOpt)
+			}
+			_, err := m.Handle(&rawData{})
+			if !c.wantErr {
+				if err != nil {
+					t.Errorf("want no error, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), `pipeline: worker "w1": timed out (after 20ms`) {
+				t.Errorf("want node timeout, got %v", err)
+			}
+		})
+	}
+}
+
+func TestManager_DefaultRetry(t *testing.T) {
+	calls := make(map[string]int)
+	flaky := func(name string) WorkerFunc {
+		return func(ctx context.Context, in *rawData) (*rawData, error) {
+			if calls[name]++; calls[name] < 3 {
+				return nil, errors.New("flaky")
+			}
+			return in, nil
+		}
+	}
+	m := NewManager(WithDefaultRetry(3, nil))
+	_ = m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) int {
+		return in.Data.(int)
+	})
+	_ = m.AddWorkerNode("a", flaky("a"))
+	_ = m.AddWorkerNode("b", flaky("b"), WithRetry(2))
+	if err := m.BuildPipeline([][]string{
+		{Head, "j1"},
+		{"j1", "a"},
+		{"j1", "b"},
+		{"a", Tail},
+		{"b", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Handle(&rawData{Data: 0}); err != nil || calls["a"] != 3 {
+		t.Errorf("default retry should apply to a: calls=%d err=%v", calls["a"], err)
+	}
+	if _, err := m.Handle(&rawData{Data: 1}); err == nil || calls["b"] != 2 {
+		t.Errorf("b should use its own retry: calls=%d err=%v", calls["b"], err)
+	}
+	for node, want := range map[string]string{"a": "retry=3", "b": "retry=2"} {
+		if info, _ := m.NodeInfo(node); len(info.EffectiveOptions) != 1 || info.EffectiveOptions[0] != want {
+			t.Errorf("node %s effective options = %v, want [%s]", node, info.EffectiveOptions, want)
+		}
+	}
+	if info, _ := m.NodeInfo("j1"); len(info.EffectiveOptions) != 0 {
+		t.Errorf("defaults should not apply to judgers, got %v", info.EffectiveOptions)
+	}
+}
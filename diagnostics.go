@@ -0,0 +1,115 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 执行中出现了构建之后不应该出现的情况，例如节点处理方法的类型不对
+var errInvariant = errors.New("internal invariant violated")
+
+type diagnostics struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// 执行中出现内部错误（构建后拓扑被修改、处理方法类型不对等）时，将流水线和本次执行的状态写入w，
+// 包括指纹、节点和边、已经执行的节点、队列中的节点以及合并节点收到的输入数，返回的错误注明 diagnostics written
+// 只有设置了 WithPayloadRedactor 时才写入脱敏后的数据
+func WithDiagnostics(w io.Writer) Option {
+	return func(m *Manager) {
+		m.diagnostics = &diagnostics{w: w}
+	}
+}
+
+func actionTypeError(node *Node, action interface{}) error {
+	return runtimeError(node, errInvariant, "unexpected action type", fmt.Sprintf("%T", action))
+}
+
+func isInvariantError(err error) bool {
+	return errors.Is(err, ErrTopologyCorrupted) || errors.Is(err, errInvariant)
+}
+
+// 内部错误时写入诊断信息，返回注明已写入的错误
+func (e *execution) diagnose(err error, queue []*nodeDataWrapper, mergers map[*Node]*mergerState) error {
+	d := e.m.diagnostics
+	if d == nil || !isInvariantError(err) {
+		return err
+	}
+	var buf bytes.Buffer
+	e.writeDiagnostics(&buf, err, queue, mergers)
+	d.mu.Lock()
+	_, werr := d.w.Write(buf.Bytes())
+	d.mu.Unlock()
+	if werr != nil {
+		return err
+	}
+	return fmt.Errorf("%w (diagnostics written)", err)
+}
+
+func (e *execution) writeDiagnostics(w io.Writer, err error, queue []*nodeDataWrapper, mergers map[*Node]*mergerState) {
+	m := e.m
+	fmt.Fprintf(w, "pipeline diagnostics\nerror: %v\nexecution: %s\nfingerprint: %s\n", err, e.id(), m.Fingerprint())
+	names := make([]string, 0, len(m.nodes))
+	for name := range m.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "nodes:")
+	for _, name := range names {
+		node := m.nodes[name]
+		next := make([]string, len(node.Next))
+		for i, n := range node.Next {
+			next[i] = "<nil>"
+			if n != nil {
+				next[i] = n.nodeName
+			}
+		}
+		fmt.Fprintf(w, "  %s %s next=[%s] built_next=%d\n", name, node.Typ, strings.Join(next, " "), node.outEdges)
+	}
+	fmt.Fprintln(w, "edges:")
+	for _, edge := range m.edgeList {
+		fmt.Fprintf(w, "  %s -> %s", edge.from, edge.to)
+		if edge.label != "" {
+			fmt.Fprintf(w, " (%s)", edge.label)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "trace:")
+	if e.trace != nil {
+		for _, entry := range e.trace.Entries() {
+			result := "ok"
+			if entry.Err != nil {
+				result = "error: " + entry.Err.Error()
+			}
+			fmt.Fprintf(w, "  %s %s %s %v %s\n", entry.Node, entry.Typ, entry.Outcome, entry.Duration, result)
+		}
+	}
+	fmt.Fprintln(w, "queue:")
+	for _, nw := range queue {
+		fmt.Fprintf(w, "  %s", nw.node.nodeName)
+		if m.redactor != nil {
+			if in := m.redact(nw.in); in != nil {
+				fmt.Fprintf(w, " payload=%v", in.Data)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "mergers:")
+	waiting := make([]*Node, 0, len(mergers))
+	for node := range mergers {
+		waiting = append(waiting, node)
+	}
+	sort.Slice(waiting, func(i, j int) bool {
+		return waiting[i].nodeName < waiting[j].nodeName
+	})
+	for _, node := range waiting {
+		st := mergers[node]
+		fmt.Fprintf(w, "  %s %d/%d done=%v\n", node.nodeName, st.received+st.missing, node.inEdges, st.done)
+	}
+}
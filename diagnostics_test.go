@@ -0,0 +1,102 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// d1 -> (a, b)，b -> d2 -> (c, x) -> m2，(a, m2) -> m1 -> tail
+func newDiagnosticsManager(t *testing.T, opts ...Option) *Manager {
+	m := NewManager(opts...)
+	divider := func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{in, in}, nil
+	}
+	merger := func(ctx context.Context, ins []*rawData) (*rawData, error) {
+		return ins[0], nil
+	}
+	_ = m.AddDividerNode("d1", divider, WithBranches("left", "right"))
+	_ = m.AddDividerNode("d2", divider)
+	for _, name := range []string{"a", "b", "c", "x"} {
+		_ = m.AddWorkerNode(name, passWorker)
+	}
+	_ = m.AddMergerNode("m1", merger)
+	_ = m.AddMergerNode("m2", merger)
+	if err := m.BuildPipeline([][]string{
+		{Head, "d1"}, {"d1", "a"}, {"d1", "b"}, {"b", "d2"}, {"d2", "c"}, {"d2", "x"},
+		{"c", "m2"}, {"x", "m2"}, {"a", "m1"}, {"m2", "m1"}, {"m1", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// 测试拓扑被修改时写入诊断信息
+func TestManager_DiagnosticsTopology(t *testing.T) {
+	var buf bytes.Buffer
+	m := newDiagnosticsManager(t, WithDiagnostics(&buf))
+	m.nodes["c"].Next = []*Node{nil}
+	_, err := m.Handle(&rawData{Data: "s3cret"})
+	if !errors.Is(err, ErrTopologyCorrupted) || !strings.HasSuffix(err.Error(), "(diagnostics written)") {
+		t.Fatalf("want annotated topology error, got %v", err)
+	}
+	dump := buf.String()
+	for _, want := range []string{
+		`error: pipeline: worker "c": next node is nil (next[0])`,
+		"fingerprint: " + m.Fingerprint(),
+		"  c worker next=[<nil>] built_next=1\n",
+		"  d1 -> a (left)\n",
+		"trace:\n  d1 divider executed",
+		"queue:\n  x\n",
+		"mergers:\n  m1 1/2 done=false\n",
+	} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("dump should contain %q:\n%s", want, dump)
+		}
+	}
+	if strings.Contains(dump, "s3cret") {
+		t.Errorf("dump should not contain payloads without a redactor:\n%s", dump)
+	}
+}
+
+// 测试设置了脱敏方法时写入脱敏后的数据
+func TestManager_DiagnosticsRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	m := newDiagnosticsManager(t, WithDiagnostics(&buf), WithPayloadRedactor(func(d *rawData) *rawData {
+		d.Data = "***"
+		return d
+	}))
+	m.nodes["c"].Next = []*Node{nil}
+	if _, err := m.Handle(&rawData{Data: "s3cret"}); err == nil {
+		t.Fatal("expected error")
+	}
+	if dump := buf.String(); !strings.Contains(dump, "  x payload=***\n") || strings.Contains(dump, "s3cret") {
+		t.Errorf("unexpected dump:\n%s", dump)
+	}
+}
+
+// 测试处理方法的类型不对时写入诊断信息，普通的节点失败不写入
+func TestManager_DiagnosticsActionType(t *testing.T) {
+	var buf bytes.Buffer
+	m := newDiagnosticsManager(t, WithDiagnostics(&buf))
+	m.actionMap[m.nodes["a"].actionId] = JudgerFunc(routeJudger)
+	_, err := m.Handle(&rawData{Data: 1})
+	if err == nil || !strings.Contains(err.Error(), `pipeline: worker "a": unexpected action type (pipeline.JudgerFunc)`) ||
+		!strings.Contains(err.Error(), "diagnostics written") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !strings.Contains(buf.String(), "execution: ") {
+		t.Errorf("unexpected dump:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	m = newDiagnosticsManager(t, WithDiagnostics(&buf))
+	m.actionMap[m.nodes["a"].actionId] = WorkerFunc(func(ctx context.Context, in *rawData) (*rawData, error) {
+		return nil, errors.New("boom")
+	})
+	if _, err = m.Handle(&rawData{Data: 1}); err == nil || strings.Contains(err.Error(), "diagnostics written") || buf.Len() > 0 {
+		t.Errorf("node errors should not write diagnostics: %v\n%s", err, buf.String())
+	}
+}
@@ -0,0 +1,314 @@
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// 两个流水线定义的差异，见 Diff
+type PipelineDiff struct {
+	AddedNodes   []string
+	RemovedNodes []string
+	// 类型、配置以及处理方法都相同，只有名字不同的节点
+	RenamedNodes []NodeRename
+	// 同名节点的类型或配置变化
+	ChangedNodes []NodeChange
+	AddedEdges   [][2]string
+	RemovedEdges [][2]string
+	// 分裂节点、判断节点的后继顺序变化（后继集合不变）
+	ReorderedSuccessors []SuccessorOrderChange
+}
+
+type NodeRename struct {
+	From string
+	To   string
+}
+
+// 节点的变化，每一项形如 "merge_timeout: 1s/proceed -> 2s/proceed"
+type NodeChange struct {
+	Node    string
+	Changes []string
+}
+
+type SuccessorOrderChange struct {
+	Node   string
+	Before []string
+	After  []string
+}
+
+// 没有任何差异
+func (d PipelineDiff) Empty() bool {
+	return len(d.AddedNodes) == 0 && len(d.RemovedNodes) == 0 && len(d.RenamedNodes) == 0 &&
+		len(d.ChangedNodes) == 0 && len(d.AddedEdges) == 0 && len(d.RemovedEdges) == 0 &&
+		len(d.ReorderedSuccessors) == 0
+}
+
+// 按类似unified diff 的格式输出差异
+func (d PipelineDiff) String() string {
+	var b strings.Builder
+	for _, n := range d.RemovedNodes {
+		fmt.Fprintf(&b, "- node %s\n", n)
+	}
+	for _, n := range d.AddedNodes {
+		fmt.Fprintf(&b, "+ node %s\n", n)
+	}
+	for _, r := range d.RenamedNodes {
+		fmt.Fprintf(&b, "~ node %s renamed to %s\n", r.From, r.To)
+	}
+	for _, c := range d.ChangedNodes {
+		for _, change := range c.Changes {
+			fmt.Fprintf(&b, "~ node %s %s\n", c.Node, change)
+		}
+	}
+	for _, e := range d.RemovedEdges {
+		fmt.Fprintf(&b, "- edge %s -> %s\n", e[0], e[1])
+	}
+	for _, e := range d.AddedEdges {
+		fmt.Fprintf(&b, "+ edge %s -> %s\n", e[0], e[1])
+	}
+	for _, r := range d.ReorderedSuccessors {
+		fmt.Fprintf(&b, "~ successors of %s: [%s] -> [%s]\n", r.Node, strings.Join(r.Before, " "), strings.Join(r.After, " "))
+	}
+	return b.String()
+}
+
+// 比较两个流水线定义，a 为当前的定义，b 为新的定义
+// 没有构建过的Manager 只比较节点
+func Diff(a, b *Manager) PipelineDiff {
+	return diffViews(a.nodeViews(), b.nodeViews(), edgePairs(a.edgeList), edgePairs(b.edgeList))
+}
+
+// 比较两个导出的流水线定义，见 Manager.Export
+// 导出的定义中只有通过名字引用的处理方法，其他处理方法的变化无法识别
+func DiffExports(a, b PipelineExport) PipelineDiff {
+	return diffViews(a.nodeViews(), b.nodeViews(), a.Edges, b.Edges)
+}
+
+// 比较节点时用到的信息
+type nodeView struct {
+	typ    NodeTyp
+	stage  string
+	action string
+	opts   []string
+	// 类型、配置以及处理方法的签名，用于识别改名
+	signature string
+}
+
+func diffViews(aNodes, bNodes map[string]nodeView, aEdgeList, bEdgeList [][]string) PipelineDiff {
+	var d PipelineDiff
+	var removed, added []string
+	for name := range aNodes {
+		if _, ok := bNodes[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	for name := range bNodes {
+		if _, ok := aNodes[name]; !ok {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+
+	// 识别改名：删除的节点和新增的节点中签名唯一对应的一对
+	rename := make(map[string]string)
+	for _, from := range removed {
+		sig := aNodes[from].signature
+		var candidates []string
+		for _, to := range added {
+			if _, used := rename[to]; !used && bNodes[to].signature == sig {
+				candidates = append(candidates, to)
+			}
+		}
+		if len(candidates) == 1 {
+			rename[from] = candidates[0]
+			rename[candidates[0]] = from
+			d.RenamedNodes = append(d.RenamedNodes, NodeRename{From: from, To: candidates[0]})
+		}
+	}
+	for _, name := range removed {
+		if _, ok := rename[name]; !ok {
+			d.RemovedNodes = append(d.RemovedNodes, name)
+		}
+	}
+	for _, name := range added {
+		if _, ok := rename[name]; !ok {
+			d.AddedNodes = append(d.AddedNodes, name)
+		}
+	}
+
+	// 同名节点的变化
+	names := make([]string, 0, len(aNodes))
+	for name := range aNodes {
+		if _, ok := bNodes[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if changes := diffNode(aNodes[name], bNodes[name]); len(changes) > 0 {
+			d.ChangedNodes = append(d.ChangedNodes, NodeChange{Node: name, Changes: changes})
+		}
+	}
+
+	// 边按改名映射到新的名字之后再比较
+	mapName := func(name string) string {
+		if to, ok := rename[name]; ok {
+			if _, isOld := aNodes[name]; isOld {
+				return to
+			}
+		}
+		return name
+	}
+	aEdges := make(map[[2]string]bool)
+	for _, e := range aEdgeList {
+		aEdges[[2]string{mapName(e[0]), mapName(e[1])}] = true
+	}
+	bEdges := make(map[[2]string]bool)
+	for _, e := range bEdgeList {
+		bEdges[[2]string{e[0], e[1]}] = true
+	}
+	for _, e := range bEdgeList {
+		if edge := [2]string{e[0], e[1]}; !aEdges[edge] {
+			d.AddedEdges = append(d.AddedEdges, edge)
+			aEdges[edge] = true
+		}
+	}
+	for _, e := range aEdgeList {
+		edge := [2]string{mapName(e[0]), mapName(e[1])}
+		if !bEdges[edge] {
+			d.RemovedEdges = append(d.RemovedEdges, [2]string{e[0], e[1]})
+			bEdges[edge] = true
+		}
+	}
+
+	// 后继顺序的变化
+	aSucc, bSucc := successorsOf(aEdgeList, mapName), successorsOf(bEdgeList, nil)
+	for _, name := range append(names, added...) {
+		node := bNodes[name]
+		if node.typ != NodeTypDivider && node.typ != NodeTypJudger {
+			continue
+		}
+		before, after := aSucc[name], bSucc[name]
+		if !reflect.DeepEqual(before, after) && sameSet(before, after) {
+			d.ReorderedSuccessors = append(d.ReorderedSuccessors, SuccessorOrderChange{Node: name, Before: before, After: after})
+		}
+	}
+	return d
+}
+
+// 用户添加的节点，不含虚拟头、尾节点
+func (m *Manager) userNodes() map[string]*Node {
+	nodes := make(map[string]*Node, len(m.nodes))
+	for name, node := range m.nodes {
+		if node.Typ != NodeTypHead && node.Typ != NodeTypTail {
+			nodes[name] = node
+		}
+	}
+	return nodes
+}
+
+// 用户添加的节点用于比较的信息
+func (m *Manager) nodeViews() map[string]nodeView {
+	nodes := m.userNodes()
+	views := make(map[string]nodeView, len(nodes))
+	for name, node := range nodes {
+		views[name] = nodeView{
+			typ:       node.Typ,
+			stage:     node.stage,
+			action:    node.actionName,
+			opts:      node.opts.summary(),
+			signature: m.nodeSignature(node),
+		}
+	}
+	return views
+}
+
+// 节点的签名：类型、配置以及处理方法，用于识别改名
+func (m *Manager) nodeSignature(node *Node) string {
+	action := node.actionName
+	if action == "" {
+		if f := m.actionMap[node.actionId]; f != nil {
+			action = fmt.Sprintf("%#x", reflect.ValueOf(f).Pointer())
+		}
+	}
+	return fmt.Sprintf("%s|%s|%s", node.Typ, action, strings.Join(node.opts.summary(), ","))
+}
+
+// 同名节点的变化
+func diffNode(a, b nodeView) []string {
+	var changes []string
+	if a.typ != b.typ {
+		changes = append(changes, fmt.Sprintf("type: %s -> %s", a.typ, b.typ))
+	}
+	if a.stage != b.stage {
+		changes = append(changes, fmt.Sprintf("stage: %s -> %s", a.stage, b.stage))
+	}
+	if a.action != b.action {
+		changes = append(changes, fmt.Sprintf("action: %s -> %s", a.action, b.action))
+	}
+	aOpts, bOpts := optionMap(a.opts), optionMap(b.opts)
+	var keys []string
+	for k := range aOpts {
+		keys = append(keys, k)
+	}
+	for k := range bOpts {
+		if _, ok := aOpts[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if aOpts[k] != bOpts[k] {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", k, valueOrNone(aOpts[k]), valueOrNone(bOpts[k])))
+		}
+	}
+	return changes
+}
+
+func optionMap(summary []string) map[string]string {
+	opts := make(map[string]string, len(summary))
+	for _, kv := range summary {
+		i := strings.Index(kv, "=")
+		opts[kv[:i]] = kv[i+1:]
+	}
+	return opts
+}
+
+func valueOrNone(v string) string {
+	if v == "" {
+		return "none"
+	}
+	return v
+}
+
+// 每个节点按edges 顺序排列的后继，mapName 不为nil 时先转换节点名
+func successorsOf(edges [][]string, mapName func(string) string) map[string][]string {
+	succ := make(map[string][]string)
+	for _, e := range edges {
+		from, to := e[0], e[1]
+		if mapName != nil {
+			from, to = mapName(from), mapName(to)
+		}
+		succ[from] = append(succ[from], to)
+	}
+	return succ
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := make(map[string]int)
+	for _, s := range a {
+		count[s]++
+	}
+	for _, s := range b {
+		if count[s]--; count[s] < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newDiffManager(t *testing.T, bName string, timeout time.Duration, extraEdges ...[]string) *Manager {
+	m := NewManager()
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{in, in}, nil
+	})
+	_ = m.AddWorkerNode("a", passWorker)
+	_ = m.AddWorkerNode(bName, passWorker)
+	_ = m.AddMergerNode("m1", func(ctx context.Context, ins []*rawData) (*rawData, error) {
+		return ins[0], nil
+	}, WithMergeTimeout(timeout, ProceedWithPartial))
+	_ = m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) int {
+		return 0
+	})
+	_ = m.AddWorkerNode("w1", passWorker)
+	_ = m.AddWorkerNode("w2", passWorker)
+	edges := [][]string{
+		{Head, "d1"},
+		{"d1", "a"},
+		{"d1", bName},
+		{"a", "m1"},
+		{bName, "m1"},
+		{"m1", "j1"},
+		{"j1", "w1"},
+		{"j1", "w2"},
+		{"w1", Tail},
+		{"w2", Tail},
+	}
+	if err := m.BuildPipeline(append(edges, extraEdges...)); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// 测试改名、新增的边以及修改的超时都出现在差异中，并且没有其他差异
+func TestDiff(t *testing.T) {
+	a := newDiffManager(t, "b", time.Second)
+	b := newDiffManager(t, "b2", 2*time.Second, []string{"j1", Tail})
+
+	d := Diff(a, b)
+	expected := PipelineDiff{
+		RenamedNodes: []NodeRename{{From: "b", To: "b2"}},
+		ChangedNodes: []NodeChange{{Node: "m1", Changes: []string{"merge_timeout: 1s/proceed -> 2s/proceed"}}},
+		AddedEdges:   [][2]string{{"j1", Tail}},
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Errorf("expected diff %+v, got %+v", expected, d)
+	}
+	s := d.String()
+	for _, line := range []string{
+		"~ node b renamed to b2",
+		"~ node m1 merge_timeout: 1s/proceed -> 2s/proceed",
+		"+ edge j1 -> tail",
+	} {
+		if !strings.Contains(s, line) {
+			t.Errorf("diff output missing %q:\n%s", line, s)
+		}
+	}
+	if !Diff(a, a).Empty() {
+		t.Errorf("expected no diff with itself, got %v", Diff(a, a))
+	}
+}
+
+// 测试分裂节点后继顺序的变化
+func TestDiff_ReorderedSuccessors(t *testing.T) {
+	a := newDiffManager(t, "b", time.Second)
+	b := newDiffManager(t, "b", time.Second)
+	b.edgeList[1], b.edgeList[2] = b.edgeList[2], b.edgeList[1]
+	d := Diff(a, b)
+	expected := []SuccessorOrderChange{{Node: "d1", Before: []string{"a", "b"}, After: []string{"b", "a"}}}
+	if !reflect.DeepEqual(d.ReorderedSuccessors, expected) || len(d.AddedEdges)+len(d.RemovedEdges) != 0 {
+		t.Errorf("unexpected diff %+v", d)
+	}
+}
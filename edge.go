@@ -0,0 +1,53 @@
+package pipeline
+
+import "fmt"
+
+// 节点间的一条边
+// BuildPipeline、Connect、配置文件等声明边的方式在构建时都转换为edge，构建之后只读取edge
+type edge struct {
+	from, to string
+	// 在声明中的位置，以及在from 的后继中的位置
+	index, order int
+	// 分裂节点、判断节点命名过的分支名，构建时根据 WithBranches 设置
+	label string
+	// 声明边的位置
+	source string
+}
+
+// 将声明的边转换为edge，e 中虚拟头、尾节点的别名应已转换为 Head、Tail
+// sources 与e 一一对应，长度不足时使用source
+func newEdges(e [][]string, sources []string, source string) ([]*edge, error) {
+	edges := make([]*edge, len(e))
+	for i, pair := range e {
+		if len(pair) < 2 {
+			return nil, invalid(CodeBadEdge, fmt.Errorf("edges[%d] %v should have a front node and a next node", i, pair))
+		}
+		edges[i] = &edge{from: pair[0], to: pair[1], index: i, source: source}
+		if i < len(sources) {
+			edges[i].source = sources[i]
+		}
+	}
+	return edges, nil
+}
+
+// 边的起止节点，用于导出以及与快照比较
+func edgePairs(edges []*edge) [][]string {
+	pairs := make([][]string, len(edges))
+	for i, e := range edges {
+		pairs[i] = []string{e.from, e.to}
+	}
+	return pairs
+}
+
+// 连接节点之后计算每条边在后继中的位置以及分支名
+func (m *Manager) labelEdges() {
+	for _, node := range m.nodes {
+		for i, e := range node.out {
+			e.order = i
+			e.label = ""
+			if (node.Typ == NodeTypDivider || node.Typ == NodeTypJudger) && i < len(node.opts.branches) {
+				e.label = node.opts.branches[i]
+			}
+		}
+	}
+}
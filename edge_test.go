@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const routeConfig = `{
+	"nodes": [
+		{"name": "route", "type": "judger", "action": "route"},
+		{"name": "small", "type": "worker", "action": "pass"},
+		{"name": "large", "type": "worker", "action": "pass"}
+	],
+	"edges": [["head000", "route"], ["route", "small"], ["route", "large"], ["small", "tail111"], ["large", "tail111"]]
+}`
+
+func routeJudger(ctx context.Context, in *rawData) int {
+	return 0
+}
+
+// 测试不同方式声明的边在构建后得到相同的边
+func TestEdge_ConstructionMatrix(t *testing.T) {
+	addNodes := func(m *Manager, opts ...NodeOption) {
+		_ = m.AddJudgerNode("route", routeJudger, opts...)
+		_ = m.AddWorkerNode("small", passWorker)
+		_ = m.AddWorkerNode("large", passWorker)
+	}
+	build := map[string]func(t *testing.T) *Manager{
+		"raw": func(t *testing.T) *Manager {
+			m := NewManager()
+			addNodes(m, WithBranches("small", "large"))
+			if err := m.BuildPipeline([][]string{
+				{Head, "route"}, {"route", "small"}, {"route", "large"}, {"small", Tail}, {"large", Tail},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			return m
+		},
+		"alias": func(t *testing.T) *Manager {
+			m := NewManager()
+			addNodes(m, WithBranches("small", "large"))
+			if err := m.BuildPipeline([][]string{
+				{"head000", "route"}, {"route", "small"}, {"route", "large"}, {"small", "tail111"}, {"large", "tail111"},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			return m
+		},
+		"builder": func(t *testing.T) *Manager {
+			m := NewManager()
+			addNodes(m, WithBranches("small", "large"))
+			if err := m.Connect().From(Head).To("route").To("small").
+				From("route").To("large").
+				From("small").To(Tail).
+				From("large").To(Tail).Build(); err != nil {
+				t.Fatal(err)
+			}
+			return m
+		},
+		"config": func(t *testing.T) *Manager {
+			reg := NewRegistry()
+			_ = reg.RegisterJudger("route", routeJudger)
+			_ = reg.RegisterWorker("pass", passWorker)
+			m := NewManager()
+			edges, err := LoadJSONInto(m, strings.NewReader(routeConfig), reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := m.BuildPipeline(edges); err != nil {
+				t.Fatal(err)
+			}
+			return m
+		},
+	}
+	want := []edge{
+		{from: Head, to: "route", index: 0, order: 0},
+		{from: "route", to: "small", index: 1, order: 0, label: "small"},
+		{from: "route", to: "large", index: 2, order: 1, label: "large"},
+		{from: "small", to: Tail, index: 3, order: 0},
+		{from: "large", to: Tail, index: 4, order: 0},
+	}
+	for name, f := range build {
+		t.Run(name, func(t *testing.T) {
+			m := f(t)
+			var got []edge
+			for _, e := range m.edgeList {
+				if e.source == "" {
+					t.Errorf("edge %s->%s has no source", e.from, e.to)
+				}
+				if name == "builder" && !strings.Contains(e.source, "edge_test.go:") {
+					t.Errorf("edge %s->%s should point at the To call: %s", e.from, e.to, e.source)
+				}
+				c := *e
+				c.source = ""
+				got = append(got, c)
+			}
+			expected := want
+			if name == "config" {
+				// 配置文件不支持分支名
+				expected = make([]edge, len(want))
+				copy(expected, want)
+				for i := range expected {
+					expected[i].label = ""
+				}
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("want edges %+v, got %+v", expected, got)
+			}
+			route := m.nodes["route"]
+			for i, next := range route.Next {
+				if route.out[i].to != next.nodeName {
+					t.Errorf("out[%d] %s does not match next %s", i, route.out[i].to, next.nodeName)
+				}
+			}
+		})
+	}
+	raw, builder := build["raw"](t), build["builder"](t)
+	if raw.ToDOT() != builder.ToDOT() {
+		t.Errorf("dot differs:\n%s\n%s", raw.ToDOT(), builder.ToDOT())
+	}
+	if raw.Fingerprint() != builder.Fingerprint() {
+		t.Errorf("fingerprint differs")
+	}
+}
+
+// 测试边缺少后继节点时报错
+func TestEdge_Incomplete(t *testing.T) {
+	m := NewManager()
+	_ = m.AddWorkerNode("w1", passWorker)
+	if err := m.BuildPipeline([][]string{{Head, "w1"}, {"w1"}}); err == nil || !strings.Contains(err.Error(), "edges[1]") {
+		t.Errorf("want an error about edges[1], got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"time"
+)
+
+// 发送HTTP 请求，*http.Client 实现了该接口
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// 日志，*log.Logger 实现了该接口
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// 随机数，可以在多个goroutine 中使用
+type Rand interface {
+	Int63() int64
+	Intn(n int) int
+	Float64() float64
+}
+
+// 节点处理方法可以使用的外部依赖，通过 EnvFrom(ctx) 取得
+// 处理方法使用Env 而不是time.Now、全局的rand 等，测试时就可以替换成固定的实现
+type Env struct {
+	Clock  Clock
+	Rand   Rand
+	HTTP   Doer
+	Logger Logger
+}
+
+type envKey struct{}
+
+var defaultEnv = Env{
+	Clock:  realClock{},
+	Rand:   newLockedRand(rand.NewSource(time.Now().UnixNano())),
+	HTTP:   http.DefaultClient,
+	Logger: log.New(os.Stderr, "", log.LstdFlags),
+}
+
+// 设置节点处理方法使用的Env，未设置的字段默认为Manager 的时钟（WithClock）、随机数（WithRandSource）、
+// http.DefaultClient 以及输出到标准错误的日志
+// 没有设置时不会向ctx 中注入Env，EnvFrom 返回真实时钟等默认值
+func WithEnv(env Env) Option {
+	return func(m *Manager) {
+		m.env = &env
+	}
+}
+
+// 单次执行使用的Env，非空的字段覆盖Manager 的设置，用于测试
+func WithCallEnv(env Env) CallOption {
+	return func(o *callOptions) {
+		o.env = &env
+	}
+}
+
+// 返回节点处理方法可以使用的Env
+func EnvFrom(ctx context.Context) Env {
+	if env, ok := ctx.Value(envKey{}).(*Env); ok {
+		return *env
+	}
+	return defaultEnv
+}
+
+// 合并Manager 和单次执行的Env 注入ctx
+func (m *Manager) injectEnv(ctx context.Context, call *Env) context.Context {
+	env := Env{Clock: m.clock, Rand: m.rand, HTTP: defaultEnv.HTTP, Logger: defaultEnv.Logger}
+	for _, e := range []*Env{m.env, call} {
+		if e == nil {
+			continue
+		}
+		if e.Clock != nil {
+			env.Clock = e.Clock
+		}
+		if e.Rand != nil {
+			env.Rand = e.Rand
+		}
+		if e.HTTP != nil {
+			env.HTTP = e.HTTP
+		}
+		if e.Logger != nil {
+			env.Logger = e.Logger
+		}
+	}
+	return context.WithValue(ctx, envKey{}, &env)
+}
+
+func (r *lockedRand) Int63() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.Int63()
+}
+
+func (r *lockedRand) Intn(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.Intn(n)
+}
+
+func (r *lockedRand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.Float64()
+}
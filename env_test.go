@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// 按当前时间问候的工作节点
+func greetWorker(ctx context.Context, in *rawData) (*rawData, error) {
+	greeting := "good evening"
+	if h := EnvFrom(ctx).Clock.Now().Hour(); h < 12 {
+		greeting = "good morning"
+	}
+	return &rawData{Data: greeting + ", " + in.Data.(string)}, nil
+}
+
+// 测试依赖时间的工作节点通过注入的时钟得到确定的结果
+func TestManager_EnvClock(t *testing.T) {
+	clock := newFakeClock()
+	m := newSingleWorkerManager(t, greetWorker, WithClock(clock), WithEnv(Env{}))
+	clock.Advance(9 * time.Hour)
+	out, err := m.Handle(&rawData{Data: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Data != "good morning, alice" {
+		t.Errorf("out=%v", out.Data)
+	}
+	clock.Advance(10 * time.Hour)
+	if out, _ = m.Handle(&rawData{Data: "alice"}); out.Data != "good evening, alice" {
+		t.Errorf("out=%v", out.Data)
+	}
+
+	// 单次执行覆盖时钟
+	morning := newFakeClock()
+	morning.Advance(8 * time.Hour)
+	out, err = m.HandleContext(context.Background(), &rawData{Data: "bob"}, WithCallEnv(Env{Clock: morning}))
+	if err != nil || out.Data != "good morning, bob" {
+		t.Errorf("out=%v err=%v", out, err)
+	}
+}
+
+// 测试相同种子的随机数在节点中得到相同的结果
+func TestManager_EnvRand(t *testing.T) {
+	draw := func() []int {
+		var got []int
+		m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+			got = append(got, EnvFrom(ctx).Rand.Intn(1000))
+			return in, nil
+		}, WithRandSource(rand.NewSource(42)), WithEnv(Env{}))
+		for i := 0; i < 3; i++ {
+			if _, err := m.Handle(&rawData{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return got
+	}
+	a, b := draw(), draw()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("draws differ: %v vs %v", a, b)
+		}
+	}
+	if env := EnvFrom(context.Background()); env.Clock == nil || env.Rand == nil || env.HTTP == nil || env.Logger == nil {
+		t.Errorf("default env should be complete: %+v", env)
+	}
+}
@@ -0,0 +1,78 @@
+package pipeline
+
+// 附加了元数据的错误
+type metadataError struct {
+	err error
+	md  map[string]string
+}
+
+func (e *metadataError) Error() string {
+	return e.err.Error()
+}
+
+func (e *metadataError) Unwrap() error {
+	return e.err
+}
+
+// 给节点返回的错误附加键值对，例如 "quarantine=true"，err 为nil 时返回nil
+// 元数据随错误经过 NodeError 等包装，出现在执行轨迹、死信、错误处理子图的输入以及 Finalizer 中，
+// 用 ErrorMetadata 读出；同一个key 以最外层的为准
+func WithErrorMetadata(err error, md map[string]string) error {
+	if err == nil {
+		return nil
+	}
+	cp := make(map[string]string, len(md))
+	for k, v := range md {
+		cp[k] = v
+	}
+	return &metadataError{err: err, md: cp}
+}
+
+// 返回错误链上附加的全部元数据，没有时返回nil
+// 错误处理子图也失败时同时包含两边的元数据，同一个key 以错误处理子图的为准；
+// 聚合多个错误（实现了 Unwrap() []error）时按顺序合并，同一个key 以前面的为准
+func ErrorMetadata(err error) map[string]string {
+	var md map[string]string
+	collectErrorMetadata(err, func(k, v string) {
+		if md == nil {
+			md = make(map[string]string)
+		}
+		if _, ok := md[k]; !ok {
+			md[k] = v
+		}
+	})
+	return md
+}
+
+func collectErrorMetadata(err error, add func(k, v string)) {
+	for err != nil {
+		switch e := err.(type) {
+		case *metadataError:
+			for k, v := range e.md {
+				add(k, v)
+			}
+		case *ErrorHandlerError:
+			collectErrorMetadata(e.Handler, add)
+			collectErrorMetadata(e.Original, add)
+			return
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				collectErrorMetadata(inner, add)
+			}
+			return
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return
+		}
+		err = u.Unwrap()
+	}
+}
+
+// 用新的错误替换from 时保留from 上的元数据
+func keepErrorMetadata(from, to error) error {
+	if md := ErrorMetadata(from); md != nil {
+		return &metadataError{err: to, md: md}
+	}
+	return to
+}
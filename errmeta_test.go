@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/caigoumiao/pipeline/store"
+)
+
+// 测试工作节点附加在错误上的元数据经过重试出现在执行轨迹、死信和 Finalizer 中，以最后一次执行为准
+func TestManager_ErrorMetadata(t *testing.T) {
+	errDown := errors.New("downstream unavailable")
+	calls := 0
+	worker := func(ctx context.Context, in *rawData) (*rawData, error) {
+		calls++
+		return nil, WithErrorMetadata(errDown, map[string]string{"quarantine": "true", "attempt": strconv.Itoa(calls)})
+	}
+	var finalErr error
+	dlq := store.NewMemory()
+	m := NewManager(WithDeadLetterStore(dlq), WithFinalizer(func(ctx context.Context, in, out *rawData, err error) {
+		finalErr = err
+	}))
+	_ = m.AddWorkerNode("w1", worker, WithRetry(2))
+	if err := m.BuildPipeline([][]string{{Head, "w1"}, {"w1", Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	trace := &Trace{}
+	_, err := m.HandleContext(context.Background(), &rawData{Data: "a"}, WithTrace(trace))
+	want := map[string]string{"quarantine": "true", "attempt": "2"}
+	if !errors.Is(err, errDown) || !reflect.DeepEqual(ErrorMetadata(err), want) {
+		t.Errorf("err=%v metadata=%v, want %v", err, ErrorMetadata(err), want)
+	}
+	if got := ErrorMetadata(finalErr); !reflect.DeepEqual(got, want) {
+		t.Errorf("finalizer got metadata %v", got)
+	}
+	if entries := trace.Entries(); len(entries) != 1 || !reflect.DeepEqual(ErrorMetadata(entries[0].Err), want) {
+		t.Errorf("trace entries %+v", entries)
+	}
+	dls, err := DeadLetters(context.Background(), dlq)
+	if err != nil || len(dls) != 1 || !reflect.DeepEqual(dls[0].Metadata, want) {
+		t.Errorf("dead letters %+v, err=%v", dls, err)
+	}
+	if dls[0].Err != "downstream unavailable" {
+		t.Errorf("metadata should not change the error message, got %q", dls[0].Err)
+	}
+}
+
+// 多个错误聚合在一起
+type multiError []error
+
+func (e multiError) Error() string   { return "multiple errors" }
+func (e multiError) Unwrap() []error { return e }
+
+// 测试元数据的合并：外层优先，错误处理子图优先，聚合的错误按顺序
+func TestErrorMetadata_Merge(t *testing.T) {
+	base := WithErrorMetadata(errors.New("x"), map[string]string{"a": "inner", "b": "inner"})
+	outer := WithErrorMetadata(&NodeError{Node: "w1", Err: base}, map[string]string{"a": "outer"})
+	if got := ErrorMetadata(outer); !reflect.DeepEqual(got, map[string]string{"a": "outer", "b": "inner"}) {
+		t.Errorf("nested got %v", got)
+	}
+	handler := &ErrorHandlerError{Original: base, Handler: WithErrorMetadata(errors.New("y"), map[string]string{"b": "handler"})}
+	if got := ErrorMetadata(handler); !reflect.DeepEqual(got, map[string]string{"a": "inner", "b": "handler"}) {
+		t.Errorf("error handler got %v", got)
+	}
+	joined := multiError{errors.New("plain"), WithErrorMetadata(errors.New("z"), map[string]string{"b": "first"}), base}
+	if got := ErrorMetadata(joined); !reflect.DeepEqual(got, map[string]string{"a": "inner", "b": "first"}) {
+		t.Errorf("joined got %v", got)
+	}
+	if ErrorMetadata(errors.New("plain")) != nil || WithErrorMetadata(nil, map[string]string{"a": "b"}) != nil {
+		t.Error("want nil for errors without metadata")
+	}
+}
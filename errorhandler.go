@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 错误处理子图入口节点收到的数据，Data 为 *ErrorInput
+type ErrorInput struct {
+	// 本次执行的原始输入
+	Input *rawData
+	// 失败的节点以及错误
+	Err *NodeError
+}
+
+// 返回错误处理子图中的数据对应的 ErrorInput
+func ErrorInputOf(in *rawData) (*ErrorInput, bool) {
+	if in == nil {
+		return nil, false
+	}
+	ei, ok := in.Data.(*ErrorInput)
+	return ei, ok
+}
+
+// 错误处理子图本身也失败时返回的错误
+type ErrorHandlerError struct {
+	// 主流程中节点的错误
+	Original error
+	// 错误处理子图的错误
+	Handler error
+}
+
+func (e *ErrorHandlerError) Error() string {
+	return fmt.Sprintf("%v; error handler: %v", e.Original, e.Handler)
+}
+
+func (e *ErrorHandlerError) Is(target error) bool {
+	return errors.Is(e.Original, target) || errors.Is(e.Handler, target)
+}
+
+func (e *ErrorHandlerError) Unwrap() error {
+	return e.Handler
+}
+
+// 错误处理子图的可选配置
+type ErrorHandlerOption func(h *errorHandler)
+
+type errorHandler struct {
+	entry string
+	// 只处理这些类型、阶段的节点的失败，为空时不限制
+	typs   map[NodeTyp]bool
+	stages map[string]bool
+	// 构建时计算：入口节点以及错误处理子图中的节点
+	entryNode *Node
+	nodes     map[*Node]bool
+}
+
+// 只处理这些类型的节点的失败
+func ForNodeTypes(typs ...NodeTyp) ErrorHandlerOption {
+	return func(h *errorHandler) {
+		h.typs = make(map[NodeTyp]bool)
+		for _, typ := range typs {
+			h.typs[typ] = true
+		}
+	}
+}
+
+// 只处理这些阶段中的节点的失败，见 DefineStage
+func ForStages(stages ...string) ErrorHandlerOption {
+	return func(h *errorHandler) {
+		h.stages = make(map[string]bool)
+		for _, stage := range stages {
+			h.stages[stage] = true
+		}
+	}
+}
+
+// 设置错误处理子图的入口节点
+// 节点失败（重试之后）时不再直接返回错误，而是将原始输入和 NodeError 包装成 ErrorInput，
+// 从入口节点继续执行，错误处理子图的输出作为流水线的输出
+// 入口节点没有入边，并且必须能到达尾节点，在BuildPipeline 时检查
+func (m *Manager) SetErrorHandlerEntry(node string, opts ...ErrorHandlerOption) {
+	h := &errorHandler{entry: node}
+	for _, opt := range opts {
+		opt(h)
+	}
+	m.errorHandler = h
+}
+
+// 检查错误处理子图：入口节点存在、不在主流程中，并且能到达尾节点
+func (m *Manager) validateErrorHandler() error {
+	h := m.errorHandler
+	if h == nil {
+		return nil
+	}
+	entry, ok := m.nodes[h.entry]
+	if !ok || entry.Typ == NodeTypHead || entry.Typ == NodeTypTail {
+		return fmt.Errorf("error handler entry node[%s] cannot be found in nodes", h.entry)
+	}
+	main := reachableFrom(m.nodes[m.headName])
+	if main[entry] {
+		return fmt.Errorf("error handler entry node[%s] is reachable from head", h.entry)
+	}
+	h.entryNode = entry
+	h.nodes = reachableFrom(entry)
+	if !h.nodes[m.nodes[m.tailName]] {
+		return fmt.Errorf("error handler entry node[%s] cannot reach tail", h.entry)
+	}
+	delete(h.nodes, m.nodes[m.tailName])
+	return nil
+}
+
+// 从node 出发能到达的所有节点，包括node
+func reachableFrom(node *Node) map[*Node]bool {
+	seen := map[*Node]bool{node: true}
+	queue := []*Node{node}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, next := range p.Next {
+			if next != nil && !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return seen
+}
+
+// 主流程中的节点失败时，从错误处理子图的入口继续执行
+func (e *execution) handleError(in *rawData, err error) (*rawData, error) {
+	h := e.m.errorHandler
+	var nodeErr *NodeError
+	if !errors.As(err, &nodeErr) {
+		return nil, err
+	}
+	node := e.m.nodes[nodeErr.Node]
+	if node == nil || h.nodes[node] {
+		return nil, err
+	}
+	if (h.typs != nil && !h.typs[node.Typ]) || (h.stages != nil && !h.stages[node.stage]) {
+		return nil, err
+	}
+	out, herr := e.runFrom(h.entryNode, node, &rawData{Data: &ErrorInput{Input: in, Err: nodeErr}}, e.m.clock.Now())
+	if herr != nil {
+		return nil, &ErrorHandlerError{Original: err, Handler: herr}
+	}
+	return out, nil
+}
@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+var errBranch = errors.New("branch failed")
+
+// 菱形流程中b 分支失败，错误处理子图 notify -> fallback 给出默认输出
+func newErrorHandlerManager(t *testing.T, fallback WorkerFunc, opts ...ErrorHandlerOption) *Manager {
+	m := NewManager()
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{{Data: in.Data}, {Data: in.Data}}, nil
+	})
+	_ = m.AddWorkerNode("a", passWorker)
+	_ = m.AddWorkerNode("b", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return nil, errBranch
+	})
+	_ = m.AddMergerNode("m1", func(ctx context.Context, ins []*rawData) (*rawData, error) {
+		return ins[0], nil
+	})
+	_ = m.AddWorkerNode("notify", passWorker)
+	_ = m.AddWorkerNode("fallback", fallback)
+	m.SetErrorHandlerEntry("notify", opts...)
+	if err := m.BuildPipeline([][]string{
+		{Head, "d1"},
+		{"d1", "a"},
+		{"d1", "b"},
+		{"a", "m1"},
+		{"b", "m1"},
+		{"m1", Tail},
+		{"notify", "fallback"},
+		{"fallback", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// 测试节点失败后从错误处理子图继续执行
+func TestManager_ErrorHandler(t *testing.T) {
+	m := newErrorHandlerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		ei, ok := ErrorInputOf(in)
+		if !ok || ei.Err.Node != "b" || !errors.Is(ei.Err, errBranch) || ei.Input.Data != 7 {
+			t.Errorf("unexpected error input %+v", in.Data)
+		}
+		return &rawData{Data: "default"}, nil
+	})
+	out, err := m.Handle(&rawData{Data: 7})
+	if err != nil || out.Data != "default" {
+		t.Errorf("expected default output, got %v, %v", out, err)
+	}
+}
+
+// 测试错误处理子图本身失败时同时返回两个错误
+func TestManager_ErrorHandlerFails(t *testing.T) {
+	errPersist := errors.New("persist failed")
+	m := newErrorHandlerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		return nil, errPersist
+	})
+	_, err := m.Handle(&rawData{Data: 7})
+	var herr *ErrorHandlerError
+	if !errors.As(err, &herr) || !errors.Is(err, errBranch) || !errors.Is(err, errPersist) {
+		t.Errorf("expected joined errors, got %v", err)
+	}
+}
+
+// 测试只处理指定类型的节点的失败
+func TestManager_ErrorHandlerNodeTypes(t *testing.T) {
+	m := newErrorHandlerManager(t, passWorker, ForNodeTypes(NodeTypMerger))
+	if _, err := m.Handle(&rawData{Data: 7}); !errors.Is(err, errBranch) {
+		t.Errorf("expected worker failure to be returned, got %v", err)
+	}
+}
+
+// 测试错误处理子图的入口必须能到达尾节点
+func TestManager_ErrorHandlerInvalid(t *testing.T) {
+	m := NewManager()
+	_ = m.AddWorkerNode("w1", passWorker)
+	m.SetErrorHandlerEntry("missing")
+	if err := m.BuildPipeline([][]string{{Head, "w1"}, {"w1", Tail}}); err == nil {
+		t.Error("expected missing entry error")
+	}
+}
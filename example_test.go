@@ -0,0 +1,184 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 最简单的直线流水线：Head、Tail 是虚拟的头、尾节点，
+// 每条边的第一个元素是前驱节点，第二个是后继节点
+func ExampleManager_Handle() {
+	m := NewManager()
+	_ = m.AddWorkerNode("double", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: in.Data.(int) * 2}, nil
+	})
+	_ = m.AddWorkerNode("inc", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: in.Data.(int) + 1}, nil
+	})
+	if err := m.BuildPipeline([][]string{
+		{Head, "double"},
+		{"double", "inc"},
+		{"inc", Tail},
+	}); err != nil {
+		fmt.Println(err)
+		return
+	}
+	out, err := m.Handle(&rawData{Data: 20})
+	fmt.Println(out.Data, err)
+	// Output: 41 <nil>
+}
+
+// 分裂节点的第i 个输出交给edges 中它的第i 条出边；
+// 合并节点的入度由构建时的edges 决定，收齐所有分支的输出后才执行
+func ExampleManager_AddDividerNode() {
+	m := NewManager()
+	_ = m.AddDividerNode("split", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{{Data: in.Data}, {Data: in.Data}}, nil
+	}, WithBranches("square", "negate"))
+	_ = m.AddWorkerNode("square", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: in.Data.(int) * in.Data.(int)}, nil
+	})
+	_ = m.AddWorkerNode("negate", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: -in.Data.(int)}, nil
+	})
+	_ = m.AddMergerNode("sum", func(ctx context.Context, ins []*rawData) (*rawData, error) {
+		sum := 0
+		for _, in := range ins {
+			sum += in.Data.(int)
+		}
+		return &rawData{Data: sum}, nil
+	})
+	if err := m.BuildPipeline([][]string{
+		{Head, "split"},
+		{"split", "square"},
+		{"split", "negate"},
+		{"square", "sum"},
+		{"negate", "sum"},
+		{"sum", Tail},
+	}); err != nil {
+		fmt.Println(err)
+		return
+	}
+	out, err := m.Handle(&rawData{Data: 5})
+	fmt.Println(out.Data, err)
+	// Output: 20 <nil>
+}
+
+// 判断节点返回的索引对应edges 中它的第几条出边，只有被选中的分支会执行
+func ExampleManager_AddJudgerNode() {
+	m := NewManager()
+	_ = m.AddJudgerNode("size", func(ctx context.Context, in *rawData) int {
+		if in.Data.(int) < 100 {
+			return 0
+		}
+		return 1
+	}, WithBranches("small", "large"))
+	_ = m.AddWorkerNode("small", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: "small"}, nil
+	})
+	_ = m.AddWorkerNode("large", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: "large"}, nil
+	})
+	if err := m.BuildPipeline([][]string{
+		{Head, "size"},
+		{"size", "small"},
+		{"size", "large"},
+		{"small", Tail},
+		{"large", Tail},
+	}); err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, n := range []int{7, 700} {
+		out, _ := m.Handle(&rawData{Data: n})
+		fmt.Println(n, out.Data)
+	}
+	// Output:
+	// 7 small
+	// 700 large
+}
+
+// 节点处理方法返回的错误会被包装成 NodeError，可以通过errors.As 找到出错的节点，
+// 通过errors.Is 匹配原始错误
+func ExampleNodeError() {
+	errNotFound := errors.New("not found")
+	m := NewManager()
+	_ = m.AddWorkerNode("lookup", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return nil, errNotFound
+	})
+	if err := m.BuildPipeline([][]string{
+		{Head, "lookup"},
+		{"lookup", Tail},
+	}); err != nil {
+		fmt.Println(err)
+		return
+	}
+	_, err := m.Handle(&rawData{})
+	var nodeErr *NodeError
+	if errors.As(err, &nodeErr) {
+		fmt.Println(nodeErr.Node, errors.Is(err, errNotFound))
+	}
+	fmt.Println(err)
+	// Output:
+	// lookup true
+	// not found
+}
+
+// 通过 Registry 注册处理方法，再从配置中加载流水线
+func ExampleLoadJSON() {
+	reg := NewRegistry()
+	_ = reg.RegisterWorker("upper", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: strings.ToUpper(in.Data.(string))}, nil
+	})
+	m, err := LoadJSON(strings.NewReader(`{
+		"version": "1.1",
+		"nodes": [{"name": "u1", "type": "worker", "action": "upper"}],
+		"edges": [["head000", "u1"], ["u1", "tail111"]]
+	}`), reg)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	out, err := m.Handle(&rawData{Data: "pipeline"})
+	fmt.Println(out.Data, err)
+	// Output: PIPELINE <nil>
+}
+
+// 判断节点通过 RemainingBudget 在时间不足时走较快的分支
+func ExampleRemainingBudget() {
+	m := NewManager()
+	_ = m.AddJudgerNode("budget", func(ctx context.Context, in *rawData) int {
+		if remaining, ok := RemainingBudget(ctx); ok && remaining < time.Second {
+			return 0
+		}
+		return 1
+	}, WithBranches("fast", "thorough"))
+	_ = m.AddWorkerNode("fast", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: "cached answer"}, nil
+	})
+	_ = m.AddWorkerNode("thorough", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: "full answer"}, nil
+	})
+	if err := m.BuildPipeline([][]string{
+		{Head, "budget"},
+		{"budget", "fast"},
+		{"budget", "thorough"},
+		{"fast", Tail},
+		{"thorough", Tail},
+	}); err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, timeout := range []time.Duration{100 * time.Millisecond, time.Minute} {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		out, _ := m.HandleContext(ctx, &rawData{})
+		cancel()
+		fmt.Println(timeout, out.Data)
+	}
+	// Output:
+	// 100ms cached answer
+	// 1m0s full answer
+}
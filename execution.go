@@ -0,0 +1,530 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// 一次流水线的执行
+// 所有节点处理方法的调用都经过这里，统一记录健康状况和执行轨迹
+type execution struct {
+	m     *Manager
+	ctx   context.Context
+	trace *Trace
+	// 是否记录数据的血缘
+	lineage bool
+	// 强制使用的判断节点决策
+	forced Decisions
+	// 整个流水线的重试配置，以及当前是第几次执行（从1 开始）
+	pipelineRetry *pipelineRetry
+	attempt       int
+	// 执行的标识，第一次使用时生成
+	execID       string
+	noDeadLetter bool
+	// 已经保存的检查点数，见 WithCheckpoint
+	checkpoints int
+	// 执行的序号，最外层的执行开始时分配，见 Close
+	seq uint64
+	// 最近一次记录的节点执行情况，用于累计分支的执行情况
+	last callInfo
+	// 本次执行中每个阶段已经使用的时间，只记录设置了超时的阶段
+	stageUsed map[string]time.Duration
+	// 本次执行中每个工作节点选择的版本
+	variants map[*Node]string
+	// 软截止时间的看门狗
+	watch *watchdog
+	// 存活检查，见 WithStallDetection
+	stall *stallWatch
+	// 已经执行的工作节点数，用于定期让出调度
+	steps int
+	// 功能开关，见 WithFlags
+	flags map[string]bool
+	// 每个节点的耗时，开启 WithSLO 时记录
+	nodeTimes map[string]time.Duration
+	// 可回收数据的引用计数
+	refs     map[Releasable]int
+	released map[Releasable]bool
+	// 限时分支的ctx，执行结束时取消
+	cancels []context.CancelFunc
+	// 执行开始的时间，包括等待执行槽位的时间
+	start time.Time
+	// 下一个要执行的节点加入队列的时间，以及当前节点在队列中等待的时间
+	queued time.Time
+	wait   time.Duration
+	// 只执行子图时子图中的节点、产出结果的节点，以及 "from->to" 形式的说明，见 HandleSubgraph
+	subgraph map[*Node]bool
+	stopAt   *Node
+	span     string
+	// 本次执行的随机数来源，见 WithExecutionRand
+	rnd *rand.Rand
+	// 正在执行的节点，以及ctx 结束时队列的状态，用于 CancelledError
+	current  *Node
+	snapshot *cancelSnapshot
+	// 判断节点的决策，只在开启 WithExecutionHistory 时记录
+	decisions Decisions
+	// 持有的临界区，以及对应的释放方法
+	sections map[*criticalSection]func(error)
+	// 预热的执行，见 Warmup
+	warmup bool
+	// 节点开始执行时的回调，见 WithCallStartListener
+	onStart func(node *Node)
+	// 采样的结果，以及未采样时只用于记录失败节点的轨迹
+	traceSampled, recordingSampled bool
+	unsampledTrace                 *Trace
+	// 重新执行时原来的执行，以及这条数据第几次执行，见 WithParentExecution
+	parent      string
+	dataAttempt int
+	// 并行执行的调度状态，只在并行执行期间不为空，见 WithStageParallelism
+	par *parallelRun
+}
+
+// seq 为0 时在第一次使用执行的标识时分配
+func (m *Manager) newExecution(ctx context.Context, opts []CallOption, seq uint64, start time.Time) execution {
+	e := execution{
+		m:     m,
+		ctx:   ctx,
+		seq:   seq,
+		start: start,
+	}
+	var env *Env
+	var seed *int64
+	var forceTrace bool
+	if len(opts) > 0 {
+		var o callOptions
+		for _, opt := range opts {
+			opt(&o)
+		}
+		e.trace = o.trace
+		e.lineage = o.lineage
+		e.forced = o.forced
+		e.pipelineRetry = o.retry
+		e.noDeadLetter = o.noDeadLetter
+		env, seed, forceTrace = o.env, o.seed, o.forceTrace
+		if o.warmup != nil {
+			e.warmup, e.decisions = true, o.warmup.decisions
+		}
+		e.onStart = o.onStart
+		e.flags = o.flags
+		if o.trace != nil && o.flags != nil {
+			o.trace.setFlags(o.flags)
+		}
+		e.parent, e.dataAttempt = o.parent, o.attempt
+		if o.trace != nil && (o.parent != "" || o.attempt > 0) {
+			o.trace.setParent(o.parent, o.attempt)
+		}
+	}
+	if env != nil || m.env != nil {
+		e.ctx = m.injectEnv(ctx, env)
+	}
+	if e.flags != nil {
+		e.ctx = context.WithValue(e.ctx, flagsKey{}, e.flags)
+	}
+	if seed != nil || m.executionRand {
+		e.seedRand(seed)
+	}
+	if m.sampling != nil {
+		e.sample(forceTrace)
+	}
+	if m.diagnostics != nil && e.trace == nil {
+		// 诊断信息需要本次执行已经执行过的节点
+		e.trace = &Trace{}
+	}
+	if m.contextValues {
+		e.ctx = context.WithValue(e.ctx, execIDKey{}, e.id())
+	}
+	return e
+}
+
+// 执行工作节点
+func (e *execution) work(ctx context.Context, node *Node, in *rawData) (*rawData, error) {
+	action, ok := e.m.actionMap[node.actionId].(WorkerFunc)
+	if !ok {
+		return nil, actionTypeError(node, e.m.actionMap[node.actionId])
+	}
+	return e.callWorker(ctx, node, action, in)
+}
+
+// 执行工作节点，节点有多个版本时先选择本次执行使用的版本
+func (e *execution) callWorker(ctx context.Context, node *Node, action WorkerFunc, in *rawData) (*rawData, error) {
+	if node.opts.bare {
+		return e.callBare(ctx, node, action, in)
+	}
+	if node.section != nil && node.section.first == node {
+		if err := e.enterSection(ctx, node, in); err != nil {
+			return nil, err
+		}
+	}
+	ctx = e.nodeContext(ctx, node)
+	start := e.begin(node)
+	var variant string
+	if len(node.variants) > 0 {
+		var err error
+		if action, variant, err = e.selectVariant(ctx, node); err != nil {
+			return nil, e.finish(node, start, err, callInfo{branch: -1, variant: variant, in: in})
+		}
+	}
+	if e.shouldSkip(ctx, node, start) {
+		e.record(node, start, nil, callInfo{branch: -1, outcome: OutcomeSkipped})
+		e.current = nil
+		if node.section != nil && node.section.last == node {
+			e.leaveSection(node.section)
+		}
+		return in, nil
+	}
+	invoke := NodeInvoker(action)
+	if e.m.middlewares != nil {
+		invoke = e.m.wrapWorker(node, invoke)
+	}
+	var out *rawData
+	attempts, backoffs, err := e.retry(ctx, node, func(ctx context.Context) (err error) {
+		out, err = invoke(ctx, in)
+		return
+	})
+	err = e.finish(node, start, err, callInfo{branch: -1, attempts: attempts, backoffs: backoffs, variant: variant, in: in})
+	if err == nil && node.opts.checkpoint {
+		err = e.checkpoint(node, out)
+	}
+	if err != nil {
+		return nil, newNodeError(node, err)
+	}
+	return out, nil
+}
+
+// 开始调用节点，返回开始的时间，并计算节点在队列中等待的时间
+// 工作节点链中除第一个节点以外不经过队列，等待时间为0
+func (e *execution) begin(node *Node) time.Time {
+	e.enter(node)
+	if e.onStart != nil {
+		e.onStart(node)
+	}
+	if e.m.nodeStart != nil {
+		e.m.nodeStart(node)
+	}
+	e.current = node
+	start := e.m.clock.Now()
+	if e.stall != nil {
+		e.stall.enter(node, start)
+	}
+	e.wait = 0
+	if !e.queued.IsZero() {
+		e.wait = start.Sub(e.queued)
+		e.queued = time.Time{}
+	}
+	return start
+}
+
+const defaultYieldEvery = 64
+
+// 工作节点链每执行yieldEvery 个节点让出一次调度，并检查ctx 是否已经结束
+func (e *execution) yield(ctx context.Context) error {
+	k := e.m.yieldEvery
+	if k <= 0 {
+		return nil
+	}
+	if e.steps++; e.steps%k != 0 {
+		return nil
+	}
+	runtime.Gosched()
+	return ctx.Err()
+}
+
+// 执行分裂节点，输出的数量必须和分支数一致
+func (e *execution) divide(ctx context.Context, node *Node, in *rawData) ([]BranchOutput, error) {
+	if node.section != nil && node.section.first == node {
+		if err := e.enterSection(ctx, node, in); err != nil {
+			return nil, err
+		}
+	}
+	ctx = e.nodeContext(ctx, node)
+	start := e.begin(node)
+	var outs []BranchOutput
+	attempts, backoffs, err := e.retry(ctx, node, func(ctx context.Context) (err error) {
+		switch action := e.m.actionMap[node.actionId].(type) {
+		case DividerFunc:
+			var datas []*rawData
+			if datas, err = action(ctx, in); err == nil {
+				outs = make([]BranchOutput, len(datas))
+				for i, data := range datas {
+					outs[i].Data = data
+				}
+			}
+		case BranchDividerFunc:
+			outs, err = action(ctx, in)
+		default:
+			err = actionTypeError(node, action)
+		}
+		return
+	})
+	mismatch := err == nil && (len(outs) == 0 || len(outs) != len(node.Next))
+	if mismatch {
+		details := fmt.Sprintf("%d outputs for branches %s", len(outs), node.branchList())
+		if len(outs) < len(node.Next) {
+			details += fmt.Sprintf(", branch[%s] has no output", node.branchName(len(outs)))
+		}
+		err = runtimeError(node, ErrDividerOutputMismatch, "outputs do not match branches", details)
+	}
+	err = e.finish(node, start, err, callInfo{branch: -1, attempts: attempts, backoffs: backoffs, in: in})
+	if err == nil && node.opts.checkpoint {
+		datas := make([]*rawData, len(outs))
+		for i, o := range outs {
+			datas[i] = o.Data
+		}
+		err = e.checkpoint(node, datas...)
+	}
+	if err != nil {
+		return nil, newNodeError(node, err)
+	}
+	return outs, nil
+}
+
+// 执行合并节点
+// accs 为每份输入所在分支的执行情况，只在流水线中有 BranchMergerFunc 时记录
+func (e *execution) merge(ctx context.Context, node *Node, in []*rawData, accs []*branchAcc) (*rawData, error) {
+	var call func(ctx context.Context) (*rawData, error)
+	switch action := e.m.actionMap[node.actionId].(type) {
+	case MergerFunc:
+		if e.m.mergerShuffle != nil {
+			in = e.m.shuffleMergerInputs(in)
+		}
+		invoke := MergerInvoker(action)
+		if e.m.middlewares != nil {
+			invoke = e.m.wrapMerger(node, invoke)
+		}
+		call = func(ctx context.Context) (*rawData, error) { return invoke(ctx, in) }
+	case BranchMergerFunc:
+		cs := contributions(in, accs)
+		if e.m.mergerShuffle != nil {
+			e.m.shuffleMergerOrder(len(cs), func(i, j int) { cs[i], cs[j] = cs[j], cs[i] })
+		}
+		call = func(ctx context.Context) (*rawData, error) { return action(ctx, cs) }
+		if e.m.middlewares != nil {
+			invoke := e.m.wrapMerger(node, func(ctx context.Context, in []*rawData) (*rawData, error) {
+				return action(ctx, withContributionData(cs, in))
+			})
+			data := contributionData(cs)
+			call = func(ctx context.Context) (*rawData, error) { return invoke(ctx, data) }
+		}
+	default:
+		return nil, actionTypeError(node, action)
+	}
+	ctx = e.nodeContext(ctx, node)
+	start := e.begin(node)
+	var out *rawData
+	attempts, backoffs, err := e.retry(ctx, node, func(ctx context.Context) (err error) {
+		out, err = call(ctx)
+		return
+	})
+	err = e.finish(node, start, err, callInfo{branch: -1, attempts: attempts, backoffs: backoffs, ins: in})
+	if err == nil && node.opts.checkpoint {
+		err = e.checkpoint(node, out)
+	}
+	if err != nil {
+		return nil, newNodeError(node, err)
+	}
+	return out, nil
+}
+
+// 执行判断节点，返回的分支索引越界时报错
+func (e *execution) judge(ctx context.Context, node *Node, in *rawData) (int, error) {
+	if node.section != nil && node.section.first == node {
+		if err := e.enterSection(ctx, node, in); err != nil {
+			return -1, err
+		}
+	}
+	ctx = e.nodeContext(ctx, node)
+	start := e.begin(node)
+	var pIndex int
+	var err error
+	if e.forced != nil {
+		// 重放时使用记录的决策，不调用判断方法
+		var ok bool
+		if pIndex, ok = e.forced[node.nodeName]; !ok {
+			return -1, e.finish(node, start, runtimeError(node, &missingDecisionError{node: node}, "no forced decision", ""), callInfo{branch: -1, attempts: 1, in: in})
+		}
+	} else {
+		action, ok := e.m.actionMap[node.actionId].(JudgerFunc)
+		if !ok {
+			return -1, e.finish(node, start, actionTypeError(node, e.m.actionMap[node.actionId]), callInfo{branch: -1, attempts: 1, in: in})
+		}
+		if e.m.middlewares != nil {
+			invoke := e.m.wrapJudger(node, func(ctx context.Context, in *rawData) (int, error) {
+				return action(ctx, in), nil
+			})
+			if err = e.call(ctx, func(ctx context.Context) (err error) {
+				pIndex, err = invoke(ctx, in)
+				return
+			}); err != nil {
+				return -1, newNodeError(node, e.finish(node, start, err, callInfo{branch: -1, attempts: 1, in: in}))
+			}
+		} else {
+			_ = e.call(ctx, func(ctx context.Context) error {
+				pIndex = action(ctx, in)
+				return nil
+			})
+		}
+		if err = e.checkDeadline(ctx, node, nil); err != nil {
+			return -1, e.finish(node, start, err, callInfo{branch: -1, attempts: 1, in: in})
+		}
+	}
+	pIndex = node.opts.defaultBranch.resolve(pIndex)
+	if pIndex < 0 || pIndex >= len(node.Next) {
+		err = runtimeError(node, ErrJudgerBranchOutOfRange, "branch index out of range",
+			fmt.Sprintf("index %d, valid branches %s, %s", pIndex, node.branchList(), node.defaultBranchHint()))
+		pIndex = -1
+	}
+	if err = e.finish(node, start, err, callInfo{branch: pIndex, attempts: 1, in: in}); err != nil {
+		return -1, err
+	}
+	if node.decisionCounts != nil {
+		atomic.AddInt64(&node.decisionCounts[pIndex], 1)
+	}
+	if e.m.history != nil || e.m.snapshots != nil || e.warmup {
+		e.decide(node, pIndex)
+	}
+	return pIndex, nil
+}
+
+// 节点一次调用的附加信息，branch 为判断节点选择的分支，其他节点为-1
+type callInfo struct {
+	branch   int
+	attempts int
+	backoffs []time.Duration
+	outcome  Outcome
+	// 工作节点选择的版本
+	variant string
+	// 节点的输入，只用于错误文本的脱敏，见 WithPayloadRedactor
+	in  *rawData
+	ins []*rawData
+}
+
+// 可选节点在ctx 剩余时间少于阈值时跳过
+func (e *execution) shouldSkip(ctx context.Context, node *Node, now time.Time) bool {
+	if node.opts.skipIfRemaining <= 0 {
+		return false
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && deadline.Sub(now) < node.opts.skipIfRemaining
+}
+
+var (
+	// 分裂节点的输出数与分支数不一致
+	ErrDividerOutputMismatch = errors.New("divider outputs do not match branches")
+	// 判断节点选择的分支不存在
+	ErrJudgerBranchOutOfRange = errors.New("judger branch index out of range")
+)
+
+// 节点失败的错误，Handle 返回时带上出错的节点
+// 处理方法返回的错误保持原来的文本，出错的节点只通过字段给出；流水线执行时发现的问题（见 runtimeError）
+// Problem 不为空，格式为 pipeline: <节点类型> "<节点名>": <Problem> (<Details>)，Err 为对应的哨兵错误或原因
+// 程序中应当使用这些字段以及 errors.Is 判断，不要解析错误的文本
+type NodeError struct {
+	Node string
+	Typ  NodeTyp
+	// 出错节点的信息
+	Info NodeInfo
+	// 流水线发现的问题以及细节，处理方法返回的错误为空
+	Problem string
+	Details string
+	Err     error
+}
+
+func (e *NodeError) Error() string {
+	switch {
+	case e.Problem == "":
+		return e.Err.Error()
+	case e.Details == "":
+		return fmt.Sprintf("pipeline: %s %q: %s", e.Typ, e.Node, e.Problem)
+	}
+	return fmt.Sprintf("pipeline: %s %q: %s (%s)", e.Typ, e.Node, e.Problem, e.Details)
+}
+
+func (e *NodeError) Unwrap() error {
+	return e.Err
+}
+
+// 记录节点的执行结果，节点所在阶段的耗时超过上限时返回阶段超时的错误
+func (e *execution) finish(node *Node, start time.Time, err error, info callInfo) error {
+	if node.Typ != NodeTypJudger {
+		e.m.health.record(node, err)
+	}
+	if err == nil && node.stage != "" {
+		err = e.chargeStage(node, e.m.clock.Now().Sub(start))
+	}
+	if err == nil {
+		e.current = nil
+		if node.section != nil && node.section.last == node {
+			e.leaveSection(node.section)
+		}
+	}
+	e.record(node, start, err, info)
+	return err
+}
+
+// 将节点的执行结果通知监听者，并记录到执行轨迹中
+func (e *execution) record(node *Node, start time.Time, err error, info callInfo) {
+	if err != nil && e.m.redactor != nil {
+		err = e.m.redactError(err, info.in, info.ins)
+	}
+	info.in, info.ins = nil, nil
+	e.last = info
+	if e.stall != nil {
+		e.stall.done(node, e.m.clock.Now())
+	}
+	if e.m.slo != nil {
+		e.chargeSLO(node, e.m.clock.Now().Sub(start))
+	}
+	trace := e.trace
+	if trace == nil && err != nil {
+		// 未采样的执行也记录失败的节点
+		trace = e.unsampledTrace
+	}
+	if e.m.listener == nil && trace == nil {
+		return
+	}
+	duration := e.m.clock.Now().Sub(start)
+	if e.m.listener != nil {
+		e.m.listener(NodeEvent{
+			Node:            node.nodeName,
+			Info:            node.info(),
+			Stage:           node.stage,
+			Variant:         info.variant,
+			PipelineAttempt: e.attempt,
+			ParentExecution: e.parent,
+			Attempt:         e.dataAttempt,
+			Warmup:          e.warmup,
+			Typ:             node.Typ,
+			QueueWait:       e.wait,
+			Outcome:         info.outcome,
+			Duration:        duration,
+			Err:             err,
+		})
+	}
+	if trace != nil {
+		entry := TraceEntry{
+			Node:            node.nodeName,
+			Info:            node.info(),
+			Typ:             node.Typ,
+			Start:           start,
+			Duration:        duration,
+			Err:             err,
+			BranchIndex:     info.branch,
+			Attempts:        info.attempts,
+			Backoffs:        info.backoffs,
+			Outcome:         info.outcome,
+			Stage:           node.stage,
+			Variant:         info.variant,
+			PipelineAttempt: e.attempt,
+			QueueWait:       e.wait,
+			Warmup:          e.warmup,
+		}
+		if info.branch >= 0 {
+			entry.Branch = node.branchName(info.branch)
+		}
+		trace.add(entry)
+	}
+}
@@ -0,0 +1,318 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试命名的分支出现在报错、导出的图以及执行轨迹中
+func TestManager_BranchNames(t *testing.T) {
+	m := NewManager()
+	if err := m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) (pipeIndex int) {
+		return in.Data.(int)
+	}, WithBranches("small", "large")); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := m.AddDividerNode("d1", func(ctx context.Context, in *rawData) (out []*rawData, err error) {
+		return []*rawData{in, {Data: in.Data}}, nil
+	}, WithBranches("eu", "us", "apac")); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	for _, name := range []string{"w1", "eu1", "us1", "apac1"} {
+		if err := m.AddWorkerNode(name, passWorker); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+	if err := m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (out *rawData, err error) {
+		return in[0], nil
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := m.BuildPipeline([][]string{
+		{"head000", "j1"},
+		{"j1", "w1"},
+		{"j1", "d1"},
+		{"w1", "tail111"},
+		{"d1", "eu1"},
+		{"d1", "us1"},
+		{"d1", "apac1"},
+		{"eu1", "m1"},
+		{"us1", "m1"},
+		{"apac1", "m1"},
+		{"m1", "tail111"},
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	// 执行轨迹记录分支名
+	var tr Trace
+	if _, err := m.HandleContext(context.Background(), &rawData{Data: 0}, WithTrace(&tr)); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	entries := tr.Entries()
+	if len(entries) != 2 || entries[0].Node != "j1" || entries[0].Branch != "small" || entries[0].BranchIndex != 0 {
+		t.Errorf("trace=%+v, want j1 taking branch small", entries)
+	}
+
+	// 判断节点越界时列出所有分支
+	_, err := m.Handle(&rawData{Data: 5})
+	if err == nil || !strings.Contains(err.Error(), "[0:small 1:large]") {
+		t.Errorf("err=%v, want valid branches listed", err)
+	}
+
+	// 分裂节点输出不足时指出缺少输出的分支
+	_, err = m.Handle(&rawData{Data: 1})
+	if err == nil || !strings.Contains(err.Error(), "branch[apac] has no output") {
+		t.Errorf("err=%v, want branch apac named", err)
+	}
+
+	// 导出的图中边带有分支名
+	dot := m.ToDOT()
+	for _, want := range []string{
+		`"j1" -> "w1" [label="small"];`,
+		`"d1" -> "apac1" [label="apac"];`,
+		`"w1" -> "tail";`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("dot missing %s:\n%s", want, dot)
+		}
+	}
+	mermaid := m.ToMermaid()
+	for _, want := range []string{"-->|large|", "-->|us|"} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("mermaid missing %s:\n%s", want, mermaid)
+		}
+	}
+}
+
+type regionKey struct{}
+
+// 测试分支的ctx 只对该分支可见，合并节点使用分裂之前的ctx
+func TestManager_BranchContext(t *testing.T) {
+	type rootKey struct{}
+	m := NewManager()
+	regions := []string{"eu", "us", "apac"}
+	if err := m.AddBranchDividerNode("d1", func(ctx context.Context, in *rawData) (out []BranchOutput, err error) {
+		for _, region := range regions {
+			region := region
+			out = append(out, BranchOutput{
+				Data: &rawData{},
+				Ctx: func(ctx context.Context) context.Context {
+					return context.WithValue(ctx, regionKey{}, region)
+				},
+			})
+		}
+		return
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	seen := make(map[string][]string)
+	for _, region := range regions {
+		region := region
+		// 每个分支两个工作节点，第二个节点也应该看到同样的值
+		for _, name := range []string{region + "1", region + "2"} {
+			name := name
+			if err := m.AddWorkerNode(name, func(ctx context.Context, in *rawData) (out *rawData, err error) {
+				v, _ := ctx.Value(regionKey{}).(string)
+				seen[name] = append(seen[name], v)
+				in.Data = v
+				return in, nil
+			}); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+		}
+	}
+	var mergerRegion interface{}
+	var mergerRoot interface{}
+	if err := m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (out *rawData, err error) {
+		mergerRegion = ctx.Value(regionKey{})
+		mergerRoot = ctx.Value(rootKey{})
+		return in[0], nil
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	edges := [][]string{{"head000", "d1"}}
+	for _, region := range regions {
+		edges = append(edges,
+			[]string{"d1", region + "1"},
+			[]string{region + "1", region + "2"},
+			[]string{region + "2", "m1"})
+	}
+	edges = append(edges, []string{"m1", "tail111"})
+	if err := m.BuildPipeline(edges); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	ctx := context.WithValue(context.Background(), rootKey{}, "root")
+	if _, err := m.HandleContext(ctx, &rawData{}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	for _, region := range regions {
+		for _, name := range []string{region + "1", region + "2"} {
+			if len(seen[name]) != 1 || seen[name][0] != region {
+				t.Errorf("node %s saw %v, want [%s]", name, seen[name], region)
+			}
+		}
+	}
+	if mergerRegion != nil || mergerRoot != "root" {
+		t.Errorf("merger saw region=%v root=%v, want <nil> root", mergerRegion, mergerRoot)
+	}
+}
+
+func newBudgetJudgerManager(t *testing.T) *Manager {
+	m := NewManager()
+	_ = m.AddJudgerNode("budget", func(ctx context.Context, in *rawData) int {
+		if remaining, ok := RemainingBudget(ctx); ok && remaining < time.Second {
+			return 0
+		}
+		return 1
+	}, WithBranches("fast", "thorough"))
+	_ = m.AddWorkerNode("fast", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: "fast"}, nil
+	})
+	_ = m.AddWorkerNode("thorough", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: "thorough"}, nil
+	})
+	if err := m.BuildPipeline([][]string{
+		{"head000", "budget"},
+		{"budget", "fast"},
+		{"budget", "thorough"},
+		{"fast", "tail111"},
+		{"thorough", "tail111"},
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	return m
+}
+
+// 测试判断节点根据剩余时间选择分支
+func TestManager_RemainingBudgetJudger(t *testing.T) {
+	m := newBudgetJudgerManager(t)
+	cases := []struct {
+		timeout  time.Duration
+		expected string
+	}{
+		{50 * time.Millisecond, "fast"},
+		{time.Hour, "thorough"},
+	}
+	for _, c := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		out, err := m.HandleContext(ctx, &rawData{})
+		cancel()
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if out.Data != c.expected {
+			t.Errorf("timeout %v: expected %s, got %v", c.timeout, c.expected, out.Data)
+		}
+	}
+	if _, ok := RemainingBudget(context.Background()); ok {
+		t.Error("expected no budget without deadline")
+	}
+}
+
+// 测试判断节点的分支直接连到尾节点
+func TestManager_JudgerToTail(t *testing.T) {
+	m := NewManager()
+	_ = m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) int {
+		return in.Data.(int)
+	}, WithBranches("work", "skip"))
+	_ = m.AddWorkerNode("w1", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: 100}, nil
+	})
+	if err := m.BuildPipeline([][]string{
+		{Head, "j1"},
+		{"j1", "w1"},
+		{"j1", Tail},
+		{"w1", Tail},
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	for decision, expected := range []int{100, 1} {
+		out, err := m.Handle(&rawData{Data: decision})
+		if err != nil || out.Data != expected {
+			t.Errorf("decision %d: expected %d, got %v, %v", decision, expected, out, err)
+		}
+	}
+	if dot := m.ToDOT(); !strings.Contains(dot, `"j1" -> "tail" [label="skip"];`) {
+		t.Errorf("unexpected dot:\n%s", dot)
+	}
+}
+
+// 测试分裂分支内的判断节点直接连到尾节点时构建失败
+func TestManager_JudgerToTailInsideDivider(t *testing.T) {
+	m := NewManager()
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{in, in}, nil
+	})
+	_ = m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) int {
+		return 1
+	}, WithBranches("work", "skip"))
+	_ = m.AddWorkerNode("w1", passWorker)
+	_ = m.AddWorkerNode("w2", passWorker)
+	_ = m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		return in[0], nil
+	})
+	err := m.BuildPipeline([][]string{
+		{Head, "d1"},
+		{"d1", "j1"},
+		{"d1", "w2"},
+		{"j1", "w1"},
+		{"j1", Tail},
+		{"w1", "m1"},
+		{"w2", "m1"},
+		{"m1", Tail},
+	})
+	if err == nil || !strings.Contains(err.Error(), "judger node[j1] routes branch[skip] to tail") {
+		t.Errorf("expected judger tail conflict, got %v", err)
+	}
+}
+
+// 测试记录判断节点的决策并强制重放
+func TestManager_ForcedDecisions(t *testing.T) {
+	m := newBudgetJudgerManager(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	trace := &Trace{}
+	out, err := m.HandleContext(ctx, &rawData{}, WithTrace(trace))
+	cancel()
+	if err != nil || out.Data != "fast" {
+		t.Errorf("expected fast branch, got %v, %v", out, err)
+		t.FailNow()
+	}
+	decisions := trace.Decisions()
+	if decisions["budget"] != 0 {
+		t.Errorf("unexpected decisions %v", decisions)
+		t.FailNow()
+	}
+
+	// 没有截止时间时判断方法会选择thorough，重放时仍然走fast
+	out, err = m.HandleContext(context.Background(), &rawData{}, WithForcedDecisions(decisions))
+	if err != nil || out.Data != "fast" {
+		t.Errorf("expected replayed fast branch, got %v, %v", out, err)
+	}
+
+	_, err = m.HandleContext(context.Background(), &rawData{}, WithForcedDecisions(Decisions{}))
+	if err == nil || !strings.Contains(err.Error(), `pipeline: judger "budget": no forced decision`) {
+		t.Errorf("expected missing decision error, got %v", err)
+	}
+	_, err = m.HandleContext(context.Background(), &rawData{}, WithForcedDecisions(Decisions{"budget": 5}))
+	if err == nil || !errors.Is(err, ErrJudgerBranchOutOfRange) {
+		t.Errorf("expected invalid branch error, got %v", err)
+	}
+}
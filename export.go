@@ -0,0 +1,145 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 导出为Graphviz DOT 格式
+// 分裂节点、判断节点命名过的分支会作为边的标签
+func (m *Manager) ToDOT() string {
+	var b strings.Builder
+	b.WriteString("digraph pipeline {\n")
+	order := m.exportOrder()
+	for _, node := range order {
+		if desc := node.opts.description; desc != "" {
+			fmt.Fprintf(&b, "  %q [shape=%s, tooltip=%q];\n", node.nodeName, dotShape(node.Typ), desc)
+		} else {
+			fmt.Fprintf(&b, "  %q [shape=%s];\n", node.nodeName, dotShape(node.Typ))
+		}
+	}
+	m.exportEdges(func(from, to *Node, label string) {
+		if label != "" {
+			fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", from.nodeName, to.nodeName, label)
+		} else {
+			fmt.Fprintf(&b, "  %q -> %q;\n", from.nodeName, to.nodeName)
+		}
+	})
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// 导出为Mermaid 流程图
+func (m *Manager) ToMermaid() string {
+	var b strings.Builder
+	b.WriteString("graph TD\n")
+	order := m.exportOrder()
+	ids := make(map[*Node]string, len(order))
+	for i, node := range order {
+		ids[node] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(&b, "  %s%s\n", ids[node], mermaidShape(node.Typ, node.nodeName))
+	}
+	m.exportEdges(func(from, to *Node, label string) {
+		if label != "" {
+			fmt.Fprintf(&b, "  %s -->|%s| %s\n", ids[from], label, ids[to])
+		} else {
+			fmt.Fprintf(&b, "  %s --> %s\n", ids[from], ids[to])
+		}
+	})
+	// Mermaid 只能通过click 给节点加提示
+	for _, node := range order {
+		if desc := node.opts.description; desc != "" {
+			fmt.Fprintf(&b, "  click %s \"#\" %q\n", ids[node], desc)
+		}
+	}
+	return b.String()
+}
+
+// 导出时节点的顺序：虚拟头节点在最前，其余按拓扑位置（到没有前驱的节点的最长距离）排序，位置相同时按名字排序
+// 输出只取决于节点和边，每次导出的结果相同
+func (m *Manager) exportOrder() []*Node {
+	preds := make(map[*Node][]*Node)
+	for _, edge := range m.edgeList {
+		from, to := m.nodes[edge.from], m.nodes[edge.to]
+		if from != nil && to != nil {
+			preds[to] = append(preds[to], from)
+		}
+	}
+	rank := make(map[*Node]int, len(m.nodes))
+	var calRank func(node *Node) int
+	calRank = func(node *Node) int {
+		if r, ok := rank[node]; ok {
+			return r
+		}
+		// 有环的流水线不会构建成功，这里只需要保证能结束
+		rank[node] = 0
+		r := 0
+		for _, pred := range preds[node] {
+			if pr := calRank(pred) + 1; pr > r {
+				r = pr
+			}
+		}
+		rank[node] = r
+		return r
+	}
+	order := make([]*Node, 0, len(m.nodes))
+	for _, node := range m.nodes {
+		calRank(node)
+		order = append(order, node)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if (a.Typ == NodeTypHead) != (b.Typ == NodeTypHead) {
+			return a.Typ == NodeTypHead
+		}
+		if rank[a] != rank[b] {
+			return rank[a] < rank[b]
+		}
+		return a.nodeName < b.nodeName
+	})
+	return order
+}
+
+// 导出的边，按声明的顺序
+func (m *Manager) exportEdges(f func(from, to *Node, label string)) {
+	for _, edge := range m.edgeList {
+		from, to := m.nodes[edge.from], m.nodes[edge.to]
+		if from == nil || to == nil || from.Typ == NodeTypTail {
+			continue
+		}
+		f(from, to, edge.label)
+	}
+}
+
+func dotShape(typ NodeTyp) string {
+	switch typ {
+	case NodeTypHead:
+		return "circle"
+	case NodeTypTail:
+		return "doublecircle"
+	case NodeTypDivider:
+		return "trapezium"
+	case NodeTypMerger:
+		return "invtrapezium"
+	case NodeTypJudger:
+		return "diamond"
+	default:
+		return "box"
+	}
+}
+
+func mermaidShape(typ NodeTyp, name string) string {
+	switch typ {
+	case NodeTypHead, NodeTypTail:
+		return fmt.Sprintf("((%q))", name)
+	case NodeTypDivider:
+		return fmt.Sprintf("[/%q\\]", name)
+	case NodeTypMerger:
+		return fmt.Sprintf("[\\%q/]", name)
+	case NodeTypJudger:
+		return fmt.Sprintf("{%q}", name)
+	default:
+		return fmt.Sprintf("[%q]", name)
+	}
+}
@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// 分裂成多个同一拓扑位置的分支，名字的顺序与声明的顺序相反
+func newWideManager(t *testing.T) *Manager {
+	m := NewManager()
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{in, in, in, in, in}, nil
+	}, WithBranches("e", "d", "c", "b", "a"), WithDescription("fan out"))
+	edges := [][]string{{Head, "d1"}}
+	for _, name := range []string{"e", "d", "c", "b", "a"} {
+		_ = m.AddWorkerNode(name, passWorker, WithOwner("team-"+name))
+		edges = append(edges, []string{"d1", name})
+	}
+	_ = m.AddMergerNode("m1", func(ctx context.Context, ins []*rawData) (*rawData, error) {
+		return ins[0], nil
+	})
+	for _, name := range []string{"e", "d", "c", "b", "a"} {
+		edges = append(edges, []string{name, "m1"})
+	}
+	edges = append(edges, []string{"m1", Tail})
+	if err := m.BuildPipeline(edges); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// 导出的内容
+func exportArtifacts(t *testing.T, m *Manager) string {
+	var b bytes.Buffer
+	b.WriteString(m.ToDOT())
+	b.WriteString(m.ToMermaid())
+	data, err := m.ExportJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Write(data)
+	for _, format := range []CatalogFormat{CatalogMarkdownTable, CatalogJSON} {
+		if err = m.WriteCatalog(&b, format); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.WriteString(m.Fingerprint())
+	return b.String()
+}
+
+// 测试多次构建、导出的结果完全相同
+func TestManager_ExportDeterministic(t *testing.T) {
+	want := exportArtifacts(t, newWideManager(t))
+	for i := 0; i < 20; i++ {
+		if got := exportArtifacts(t, newWideManager(t)); got != want {
+			t.Fatalf("export %d differs:\n%s\nwant:\n%s", i, got, want)
+		}
+	}
+}
+
+// 测试节点按拓扑位置、名字排序，边按声明的顺序
+func TestManager_ExportOrder(t *testing.T) {
+	dot := newWideManager(t).ToDOT()
+	lines := strings.Split(dot, "\n")
+	want := []string{
+		`digraph pipeline {`,
+		`  "head" [shape=circle];`,
+		`  "d1" [shape=trapezium, tooltip="fan out"];`,
+		`  "a" [shape=box];`,
+		`  "b" [shape=box];`,
+		`  "c" [shape=box];`,
+		`  "d" [shape=box];`,
+		`  "e" [shape=box];`,
+		`  "m1" [shape=invtrapezium];`,
+		`  "tail" [shape=doublecircle];`,
+		`  "head" -> "d1";`,
+		`  "d1" -> "e" [label="e"];`,
+		`  "d1" -> "d" [label="d"];`,
+	}
+	if len(lines) < len(want) {
+		t.Fatalf("unexpected dot:\n%s", dot)
+	}
+	for i, line := range want {
+		if lines[i] != line {
+			t.Errorf("line %d: want %s, got %s", i, line, lines[i])
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package pipeline
+
+import "fmt"
+
+// 只由工作节点组成的直线流程：head -> w1 -> ... -> wn -> tail
+type linearChain struct {
+	nodes   []*Node
+	actions []WorkerFunc
+	tail    *Node
+}
+
+// 如果流程是一条只有工作节点的直线，预先取出每个节点的处理方法
+func (m *Manager) compileLinearChain() {
+	m.linear = nil
+	chain := &linearChain{}
+	p := m.nodes[m.headName]
+	if len(p.Next) != 1 {
+		return
+	}
+	for p = p.Next[0]; p.Typ == NodeTypWorker; p = p.Next[0] {
+		if len(p.Next) != 1 || p.sizer != nil {
+			return
+		}
+		chain.nodes = append(chain.nodes, p)
+		chain.actions = append(chain.actions, m.actionMap[p.actionId].(WorkerFunc))
+	}
+	if p.Typ != NodeTypTail || len(chain.nodes) == 0 {
+		return
+	}
+	chain.tail = p
+	m.linear = chain
+}
+
+// 构建后第i 个节点的Next 被修改时返回ErrTopologyCorrupted，和队列路径的检查一致
+func (c *linearChain) checkTopology(i int) error {
+	node := c.nodes[i]
+	if err := checkTopology(node); err != nil {
+		return err
+	}
+	want := c.tail
+	if i+1 < len(c.nodes) {
+		want = c.nodes[i+1]
+	}
+	if node.Next[0] != want {
+		return runtimeError(node, ErrTopologyCorrupted, "next nodes changed after build",
+			fmt.Sprintf("next is %s, %s at build", node.Next[0].nodeName, want.nodeName))
+	}
+	return nil
+}
+
+// 按顺序执行直线流程，不需要队列以及合并节点的记录
+func (e *execution) runLinear(in *rawData) (*rawData, error) {
+	chain := e.m.linear
+	e.queued = e.start
+	e.hold(in)
+	for i, node := range chain.nodes {
+		if err := chain.checkTopology(i); err != nil {
+			return nil, e.diagnose(err, nil, nil)
+		}
+		out, err := e.callWorker(e.ctx, node, chain.actions[i], in)
+		if err == nil && out == nil {
+			err = e.checkOutput(node, 0, out)
+		}
+		if out == in {
+			out = e.checkAliasing(e.ctx, node, in, out)
+		}
+		if err != nil {
+			return nil, err
+		}
+		e.hold(out)
+		e.drop(in)
+		if err = e.yield(e.ctx); err != nil {
+			return nil, err
+		}
+		in = out
+	}
+	return in, nil
+}
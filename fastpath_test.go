@@ -0,0 +1,176 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// 构建n 个工作节点组成的直线流程，failAt 处的节点返回错误
+func newLinearManager(tb testing.TB, n int, failAt int) *Manager {
+	m := NewManager()
+	edges := [][]string{{"head000", "w1"}}
+	for i := 1; i <= n; i++ {
+		i := i
+		if err := m.AddWorkerNode(fmt.Sprintf("w%d", i), func(ctx context.Context, in *rawData) (out *rawData, err error) {
+			if i == failAt {
+				return nil, errors.New("boom")
+			}
+			in.Data = in.Data.(int) + 1
+			return in, nil
+		}); err != nil {
+			tb.Fatal(err)
+		}
+		next := "tail111"
+		if i < n {
+			next = fmt.Sprintf("w%d", i+1)
+		}
+		edges = append(edges, []string{fmt.Sprintf("w%d", i), next})
+	}
+	if err := m.BuildPipeline(edges); err != nil {
+		tb.Fatal(err)
+	}
+	return m
+}
+
+// 测试直线流程在快速路径与通用路径下的表现一致
+func TestManager_LinearFastPath(t *testing.T) {
+	for _, failAt := range []int{0, 3} {
+		m := newLinearManager(t, 5, failAt)
+		if m.linear == nil {
+			t.Errorf("linear chain not compiled")
+			t.FailNow()
+		}
+		var results [2]struct {
+			out   *rawData
+			err   error
+			trace []string
+		}
+		for i, general := range []bool{false, true} {
+			m.disableFastPath = general
+			var tr Trace
+			out, err := m.HandleContext(context.Background(), &rawData{Data: 0}, WithTrace(&tr))
+			results[i].out, results[i].err = out, err
+			for _, entry := range tr.Entries() {
+				results[i].trace = append(results[i].trace, fmt.Sprintf("%s:%v", entry.Node, entry.Err))
+			}
+		}
+		fast, general := results[0], results[1]
+		if !reflect.DeepEqual(fast.out, general.out) || fmt.Sprint(fast.err) != fmt.Sprint(general.err) {
+			t.Errorf("failAt=%d: fast=(%v, %v) general=(%v, %v)", failAt, fast.out, fast.err, general.out, general.err)
+		}
+		if !reflect.DeepEqual(fast.trace, general.trace) {
+			t.Errorf("failAt=%d: fast trace=%v general trace=%v", failAt, fast.trace, general.trace)
+		}
+	}
+}
+
+// 测试含有非工作节点的流程不走快速路径
+func TestManager_LinearFastPathNotApplied(t *testing.T) {
+	m := NewManager()
+	if err := m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) (pipeIndex int) {
+		return 0
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := m.AddWorkerNode("w1", passWorker); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := m.BuildPipeline([][]string{
+		{"head000", "j1"},
+		{"j1", "w1"},
+		{"j1", "tail111"},
+		{"w1", "tail111"},
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if m.linear != nil {
+		t.Errorf("linear chain should not be compiled")
+	}
+}
+
+func benchmarkLinear(b *testing.B, general bool) {
+	m := newLinearManager(b, 8, 0)
+	m.disableFastPath = general
+	in := &rawData{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		in.Data = 0
+		if _, err := m.Handle(in); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHandle_LinearFastPath(b *testing.B) {
+	benchmarkLinear(b, false)
+}
+
+func BenchmarkHandle_LinearGeneral(b *testing.B) {
+	benchmarkLinear(b, true)
+}
+
+// 测试很长的工作节点链在K 个节点内发现取消
+func TestManager_YieldEvery(t *testing.T) {
+	const n, cancelAt, k = 1000, 100, 16
+	for _, general := range []bool{false, true} {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		m := NewManager(WithYieldEvery(k))
+		edges := [][]string{{Head, "w1"}}
+		for i := 1; i <= n; i++ {
+			_ = m.AddWorkerNode(fmt.Sprintf("w%d", i), func(c context.Context, in *rawData) (*rawData, error) {
+				if calls++; calls == cancelAt {
+					cancel()
+				}
+				return in, nil
+			})
+			next := Tail
+			if i < n {
+				next = fmt.Sprintf("w%d", i+1)
+			}
+			edges = append(edges, []string{fmt.Sprintf("w%d", i), next})
+		}
+		if err := m.BuildPipeline(edges); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		m.disableFastPath = general
+		_, err := m.HandleContext(ctx, &rawData{})
+		cancel()
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("general=%v: expected context.Canceled, got %v", general, err)
+		}
+		if calls >= cancelAt+k {
+			t.Errorf("general=%v: cancellation observed after %d calls", general, calls)
+		}
+	}
+}
+
+func benchmarkLinearYield(b *testing.B, k int) {
+	m := newLinearManager(b, 10, 0)
+	m.yieldEvery = k
+	in := &rawData{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		in.Data = 0
+		if _, err := m.Handle(in); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHandle_LinearNoYield(b *testing.B) {
+	benchmarkLinearYield(b, 0)
+}
+
+func BenchmarkHandle_LinearDefaultYield(b *testing.B) {
+	benchmarkLinearYield(b, defaultYieldEvery)
+}
@@ -0,0 +1,36 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+)
+
+// 每次执行结束时调用，in 为原始输入，out 为最终输出（失败时为nil），err 为最终的错误
+type Finalizer func(ctx context.Context, in *rawData, out *rawData, err error)
+
+// 设置每次执行结束时的清理方法，例如释放租约、记录账单
+// 无论执行成功、失败、被取消还是被拒绝，每次执行都恰好调用一次；
+// 节点panic 时先调用f 再继续panic
+func WithFinalizer(f Finalizer) Option {
+	return func(m *Manager) {
+		m.finalizer = f
+	}
+}
+
+func (m *Manager) handleWithFinalizer(ctx context.Context, in *rawData, opts []CallOption) (out *rawData, err error) {
+	// 只在这一个defer 中调用，保证每次执行恰好一次
+	defer func() {
+		r := recover()
+		if r != nil {
+			out, err = nil, fmt.Errorf("pipeline panic: %v", r)
+		}
+		if err != nil {
+			out = nil
+		}
+		m.finalizer(ctx, in, out, err)
+		if r != nil {
+			panic(r)
+		}
+	}()
+	return m.handle(ctx, in, opts)
+}
@@ -0,0 +1,82 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type finalizerCall struct {
+	in  *rawData
+	out *rawData
+	err error
+}
+
+// 测试成功、节点报错、panic、取消、被拒绝时清理方法都恰好调用一次
+func TestManager_Finalizer(t *testing.T) {
+	errFail := errors.New("fail")
+	var calls []finalizerCall
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		switch in.Data {
+		case "fail":
+			return nil, errFail
+		case "panic":
+			panic("boom")
+		}
+		return &rawData{Data: "ok"}, nil
+	}, WithFinalizer(func(ctx context.Context, in *rawData, out *rawData, err error) {
+		calls = append(calls, finalizerCall{in: in, out: out, err: err})
+	}), WithMaxInflightExecutions(1, OverflowReject))
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	cases := []struct {
+		name  string
+		ctx   context.Context
+		data  string
+		check func(c finalizerCall) bool
+	}{
+		{"success", context.Background(), "x", func(c finalizerCall) bool {
+			return c.err == nil && c.out.Data == "ok"
+		}},
+		{"node error", context.Background(), "fail", func(c finalizerCall) bool {
+			return errors.Is(c.err, errFail) && c.out == nil
+		}},
+		{"cancelled", cancelled, "x", func(c finalizerCall) bool {
+			return errors.Is(c.err, context.Canceled) && c.out == nil
+		}},
+		{"panic", context.Background(), "panic", func(c finalizerCall) bool {
+			return c.err != nil && c.out == nil
+		}},
+	}
+	for _, c := range cases {
+		calls = nil
+		in := &rawData{Data: c.data}
+		func() {
+			defer func() {
+				if r := recover(); r != nil && c.name != "panic" {
+					t.Errorf("%s: unexpected panic %v", c.name, r)
+				}
+			}()
+			_, _ = m.HandleContext(c.ctx, in)
+		}()
+		if len(calls) != 1 {
+			t.Errorf("%s: expected 1 finalizer call, got %d", c.name, len(calls))
+			continue
+		}
+		if calls[0].in != in || !c.check(calls[0]) {
+			t.Errorf("%s: unexpected finalizer call %+v", c.name, calls[0])
+		}
+	}
+
+	// 超过并发上限被拒绝
+	calls = nil
+	if err := m.admit(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_, err := m.Handle(&rawData{Data: "x"})
+	m.release()
+	if !errors.Is(err, ErrOverloaded) || len(calls) != 1 || !errors.Is(calls[0].err, ErrOverloaded) {
+		t.Errorf("expected one finalizer call with ErrOverloaded, got %v %+v", err, calls)
+	}
+}
@@ -0,0 +1,160 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// 构建的流水线与预期的指纹不一致
+	ErrFingerprintMismatch = errors.New("pipeline fingerprint mismatch")
+	// 构建的流水线与基线的差异超出了允许的范围
+	ErrIncompatiblePipeline = errors.New("pipeline is incompatible with baseline")
+)
+
+// 流水线定义的快照，可以序列化保存，用作 AssertCompatibleWith 的基线
+type PipelineExport struct {
+	// 按节点名排序，不含虚拟头、尾节点
+	Nodes []NodeExport `json:"nodes"`
+	// 与BuildPipeline 的edges 顺序一致，虚拟头、尾节点使用 Head、Tail
+	Edges [][]string `json:"edges"`
+}
+
+// 节点的快照
+type NodeExport struct {
+	Name string  `json:"name"`
+	Typ  NodeTyp `json:"type"`
+	// 从配置加载时引用的处理方法名，直接通过AddXxxNode 添加的节点为空
+	Action string `json:"action,omitempty"`
+	Stage  string `json:"stage,omitempty"`
+	// 节点配置的摘要，每一项形如 "key=value"，按key 排序
+	Options []string `json:"options,omitempty"`
+	// 节点的说明和负责人，不参与指纹的计算
+	Description string `json:"description,omitempty"`
+	Owner       string `json:"owner,omitempty"`
+}
+
+// 导出流水线定义的快照
+func (m *Manager) Export() PipelineExport {
+	var x PipelineExport
+	for _, node := range m.userNodes() {
+		x.Nodes = append(x.Nodes, NodeExport{
+			Name:        node.nodeName,
+			Typ:         node.Typ,
+			Action:      node.actionName,
+			Stage:       node.stage,
+			Options:     node.opts.summary(),
+			Description: node.opts.description,
+			Owner:       node.opts.owner,
+		})
+	}
+	sort.Slice(x.Nodes, func(i, j int) bool {
+		return x.Nodes[i].Name < x.Nodes[j].Name
+	})
+	x.Edges = edgePairs(m.edgeList)
+	return x
+}
+
+// 快照中的节点用于比较的信息
+func (x PipelineExport) nodeViews() map[string]nodeView {
+	views := make(map[string]nodeView, len(x.Nodes))
+	for _, n := range x.Nodes {
+		views[n.Name] = nodeView{
+			typ:       n.Typ,
+			stage:     n.Stage,
+			action:    n.Action,
+			opts:      n.Options,
+			signature: fmt.Sprintf("%s|%s|%s", n.Typ, n.Action, strings.Join(n.Options, ",")),
+		}
+	}
+	return views
+}
+
+// 流水线定义的指纹：节点、节点配置以及边相同的流水线指纹相同
+// 直接通过AddXxxNode 添加的处理方法，以及节点的说明和负责人不参与计算
+func (m *Manager) Fingerprint() string {
+	x := m.Export()
+	for i := range x.Nodes {
+		x.Nodes[i].Description, x.Nodes[i].Owner = "", ""
+	}
+	data, _ := json.Marshal(x)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// 检查构建的流水线的指纹是否为expected，不一致时返回ErrFingerprintMismatch
+func (m *Manager) AssertFingerprint(expected string) error {
+	if !m.built {
+		return ErrorsPipelineNotBuilt
+	}
+	if got := m.Fingerprint(); got != expected {
+		return fmt.Errorf("%w: got %s, expected %s", ErrFingerprintMismatch, got, expected)
+	}
+	return nil
+}
+
+// 与基线比较时允许的差异，节点和边的增删、改名以及后继顺序、节点类型的变化总是不允许
+type CompatPolicy struct {
+	// 允许同名节点的配置以及阶段变化
+	AllowOptionChanges bool
+	// 允许同名节点引用的处理方法变化
+	AllowActionChanges bool
+}
+
+// 检查构建的流水线与基线的差异是否在policy 允许的范围内，
+// 否则返回ErrIncompatiblePipeline 并列出所有不允许的差异
+func (m *Manager) AssertCompatibleWith(baseline PipelineExport, policy CompatPolicy) error {
+	if !m.built {
+		return ErrorsPipelineNotBuilt
+	}
+	d := DiffExports(baseline, m.Export())
+	var violations []string
+	for _, n := range d.RemovedNodes {
+		violations = append(violations, fmt.Sprintf("- node %s", n))
+	}
+	for _, n := range d.AddedNodes {
+		violations = append(violations, fmt.Sprintf("+ node %s", n))
+	}
+	for _, r := range d.RenamedNodes {
+		violations = append(violations, fmt.Sprintf("~ node %s renamed to %s", r.From, r.To))
+	}
+	for _, c := range d.ChangedNodes {
+		for _, change := range c.Changes {
+			if policy.allows(change) {
+				continue
+			}
+			violations = append(violations, fmt.Sprintf("~ node %s %s", c.Node, change))
+		}
+	}
+	for _, e := range d.RemovedEdges {
+		violations = append(violations, fmt.Sprintf("- edge %s -> %s", e[0], e[1]))
+	}
+	for _, e := range d.AddedEdges {
+		violations = append(violations, fmt.Sprintf("+ edge %s -> %s", e[0], e[1]))
+	}
+	for _, r := range d.ReorderedSuccessors {
+		violations = append(violations, fmt.Sprintf("~ successors of %s: [%s] -> [%s]",
+			r.Node, strings.Join(r.Before, " "), strings.Join(r.After, " ")))
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d differences: %s", ErrIncompatiblePipeline, len(violations), strings.Join(violations, "; "))
+}
+
+// 同名节点的一项变化是否被允许，change 的格式见 NodeChange
+func (p CompatPolicy) allows(change string) bool {
+	switch {
+	case strings.HasPrefix(change, "type:"):
+		return false
+	case strings.HasPrefix(change, "action:"):
+		return p.AllowActionChanges
+	default:
+		return p.AllowOptionChanges
+	}
+}
@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManager_AssertFingerprint(t *testing.T) {
+	a := newDiffManager(t, "b", time.Second)
+	b := newDiffManager(t, "b", time.Second)
+	if err := b.AssertFingerprint(a.Fingerprint()); err != nil {
+		t.Errorf("same definition should match: %v", err)
+	}
+	c := newDiffManager(t, "b", 2*time.Second)
+	if err := c.AssertFingerprint(a.Fingerprint()); !errors.Is(err, ErrFingerprintMismatch) {
+		t.Errorf("want ErrFingerprintMismatch, got %v", err)
+	}
+}
+
+// 基线经过序列化保存后再比较
+func pinnedBaseline(t *testing.T, m *Manager) PipelineExport {
+	data, err := json.Marshal(m.Export())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var x PipelineExport
+	if err = json.Unmarshal(data, &x); err != nil {
+		t.Fatal(err)
+	}
+	return x
+}
+
+func TestManager_AssertCompatibleWith(t *testing.T) {
+	baseline := pinnedBaseline(t, newDiffManager(t, "b", time.Second))
+	m := newDiffManager(t, "b", 2*time.Second)
+	if err := m.AssertCompatibleWith(baseline, CompatPolicy{AllowOptionChanges: true}); err != nil {
+		t.Errorf("option change should be allowed: %v", err)
+	}
+	err := m.AssertCompatibleWith(baseline, CompatPolicy{})
+	if !errors.Is(err, ErrIncompatiblePipeline) {
+		t.Fatalf("want ErrIncompatiblePipeline, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "~ node m1 merge_timeout: 1s/proceed -> 2s/proceed") {
+		t.Errorf("error should list the option change: %v", err)
+	}
+}
+
+func TestManager_AssertCompatibleWithEdgeAdded(t *testing.T) {
+	baseline := pinnedBaseline(t, newDiffManager(t, "b", time.Second))
+	m := newDiffManager(t, "c", time.Second, []string{"j1", Tail})
+	err := m.AssertCompatibleWith(baseline, CompatPolicy{AllowOptionChanges: true, AllowActionChanges: true})
+	if !errors.Is(err, ErrIncompatiblePipeline) {
+		t.Fatalf("want ErrIncompatiblePipeline, got %v", err)
+	}
+	for _, want := range []string{"~ node b renamed to c", "+ edge j1 -> tail"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should contain %q: %v", want, err)
+		}
+	}
+}
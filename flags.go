@@ -0,0 +1,44 @@
+package pipeline
+
+import "context"
+
+type flagsKey struct{}
+
+// 本次执行的功能开关，节点处理方法（包括判断节点）通过 Flag 读取，不需要放在数据中
+// flags 在调用 WithFlags 时复制，执行过程中不会改变；开关会记录到执行轨迹（Trace.Flags）和执行摘要中
+func WithFlags(flags map[string]bool) CallOption {
+	flags = cloneFlags(flags)
+	return func(o *callOptions) {
+		o.flags = flags
+	}
+}
+
+// 返回本次执行的功能开关name，没有设置时为false
+func Flag(ctx context.Context, name string) bool {
+	flags, _ := ctx.Value(flagsKey{}).(map[string]bool)
+	return flags[name]
+}
+
+// 返回执行设置的功能开关的副本，没有设置时为nil
+func (t *Trace) Flags() map[string]bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return cloneFlags(t.flags)
+}
+
+func (t *Trace) setFlags(flags map[string]bool) {
+	t.mu.Lock()
+	t.flags = flags
+	t.mu.Unlock()
+}
+
+func cloneFlags(flags map[string]bool) map[string]bool {
+	if flags == nil {
+		return nil
+	}
+	cp := make(map[string]bool, len(flags))
+	for k, v := range flags {
+		cp[k] = v
+	}
+	return cp
+}
@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// 测试判断节点按功能开关选择分支，并发的两次执行互不影响，开关记录在轨迹和执行摘要中
+func TestManager_Flags(t *testing.T) {
+	// 两次执行都到达判断节点之后才继续，保证两次执行同时进行
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	m := NewManager(WithExecutionHistory(2))
+	_ = m.AddWorkerNode("wait", func(ctx context.Context, in *rawData) (*rawData, error) {
+		arrived.Done()
+		arrived.Wait()
+		return in, nil
+	})
+	_ = m.AddJudgerNode("route", func(ctx context.Context, in *rawData) int {
+		if Flag(ctx, "new-ranking") {
+			return 1
+		}
+		return 0
+	})
+	_ = m.AddWorkerNode("old", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: "old"}, nil
+	})
+	_ = m.AddWorkerNode("new", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: "new"}, nil
+	})
+	if err := m.BuildPipeline([][]string{{Head, "wait"}, {"wait", "route"}, {"route", "old"}, {"route", "new"}, {"old", Tail}, {"new", Tail}}); err != nil {
+		t.Fatal(err)
+	}
+
+	flagSets := []map[string]bool{{"new-ranking": false}, {"new-ranking": true}}
+	outs := make([]interface{}, 2)
+	traces := []*Trace{{}, {}}
+	opts := []CallOption{WithFlags(flagSets[0]), WithFlags(flagSets[1])}
+	var wg sync.WaitGroup
+	for i := range opts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			out, err := m.HandleContext(context.Background(), &rawData{}, opts[i], WithTrace(traces[i]))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			outs[i] = out.Data
+		}(i)
+	}
+	// 之后修改传入的map 不影响执行
+	flagSets[0]["new-ranking"] = true
+	wg.Wait()
+	if outs[0] != "old" || outs[1] != "new" {
+		t.Errorf("want [old new], got %v", outs)
+	}
+	for i, want := range []map[string]bool{{"new-ranking": false}, {"new-ranking": true}} {
+		if got := traces[i].Flags(); !reflect.DeepEqual(got, want) {
+			t.Errorf("trace %d flags %v, want %v", i, got, want)
+		}
+	}
+	summaries := m.RecentExecutions()
+	if len(summaries) != 2 || summaries[0].Flags["new-ranking"] == summaries[1].Flags["new-ranking"] {
+		t.Errorf("unexpected summaries %+v", summaries)
+	}
+	if Flag(context.Background(), "new-ranking") {
+		t.Error("absent flag should be false")
+	}
+}
@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+type HealthStatus string
+
+const (
+	// 已构建且所有节点最近一次执行都成功
+	HealthReady HealthStatus = "ready"
+	// 已构建，但有节点最近一次执行失败
+	HealthDegraded HealthStatus = "degraded"
+	// 未构建或构建失败
+	HealthDown HealthStatus = "down"
+)
+
+// pipeline 的健康状况
+type HealthReport struct {
+	Status     HealthStatus `json:"status"`
+	Built      bool         `json:"built"`
+	BuildError string       `json:"buildError,omitempty"`
+	// 最近一次执行失败的节点，按节点名排序
+	FailingNodes []NodeHealth `json:"failingNodes,omitempty"`
+	// 开启 WithSLO 时执行耗时目标的状态，违反目标不影响Status
+	SLO *SLOStatus `json:"slo,omitempty"`
+}
+
+// 单个节点的健康状况
+type NodeHealth struct {
+	Name      string  `json:"name"`
+	Typ       NodeTyp `json:"type"`
+	LastError string  `json:"lastError"`
+}
+
+// 记录每个节点最近一次执行的错误
+type healthState struct {
+	mu       sync.RWMutex
+	buildErr error
+	nodeErr  map[*Node]error
+}
+
+func (h *healthState) setBuildErr(err error) {
+	h.mu.Lock()
+	h.buildErr = err
+	h.mu.Unlock()
+}
+
+// 记录节点一次执行的结果，成功时仅在之前失败过的情况下才需要加写锁
+func (h *healthState) record(node *Node, err error) {
+	if err == nil {
+		h.mu.RLock()
+		_, failing := h.nodeErr[node]
+		h.mu.RUnlock()
+		if !failing {
+			return
+		}
+	}
+	h.mu.Lock()
+	if err == nil {
+		delete(h.nodeErr, node)
+	} else {
+		if h.nodeErr == nil {
+			h.nodeErr = make(map[*Node]error)
+		}
+		h.nodeErr[node] = err
+	}
+	h.mu.Unlock()
+}
+
+// 返回pipeline 的健康状况，开销很小，可以被频繁调用
+func (m *Manager) Health(ctx context.Context) HealthReport {
+	m.health.mu.RLock()
+	defer m.health.mu.RUnlock()
+	report := HealthReport{
+		Status: HealthReady,
+		Built:  m.built,
+		SLO:    m.slo.status(),
+	}
+	if m.health.buildErr != nil {
+		report.BuildError = m.health.buildErr.Error()
+	}
+	if !m.built {
+		report.Status = HealthDown
+		return report
+	}
+	for node, err := range m.health.nodeErr {
+		report.FailingNodes = append(report.FailingNodes, NodeHealth{
+			Name:      node.nodeName,
+			Typ:       node.Typ,
+			LastError: err.Error(),
+		})
+	}
+	if len(report.FailingNodes) > 0 {
+		report.Status = HealthDegraded
+		sort.Slice(report.FailingNodes, func(i, j int) bool {
+			return report.FailingNodes[i].Name < report.FailingNodes[j].Name
+		})
+	}
+	return report
+}
+
+// 以JSON 格式输出健康状况的http.Handler，状态为down 时返回503
+func (m *Manager) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := m.Health(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		if report.Status == HealthDown {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 测试健康检查的三种状态
+func TestManager_Health(t *testing.T) {
+	m := NewManager()
+	if err := m.AddWorkerNode("w1", func(ctx context.Context, in *rawData) (out *rawData, err error) {
+		if in.Data == nil {
+			return nil, errors.New("data is nil")
+		}
+		return in, nil
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	// 未构建
+	if r := m.Health(context.Background()); r.Status != HealthDown {
+		t.Errorf("status=%s, want down", r.Status)
+	}
+	if err := m.BuildPipeline([][]string{
+		{"head000", "w1"},
+		{"w1", "tail111"},
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if r := m.Health(context.Background()); r.Status != HealthReady {
+		t.Errorf("status=%s, want ready", r.Status)
+	}
+	// 节点执行失败
+	if _, err := m.Handle(&rawData{}); err == nil {
+		t.Errorf("predict error occurs, but not")
+		t.FailNow()
+	}
+	r := m.Health(context.Background())
+	if r.Status != HealthDegraded || len(r.FailingNodes) != 1 || r.FailingNodes[0].Name != "w1" {
+		t.Errorf("report=%+v, want degraded with w1", r)
+	}
+	// 节点恢复
+	if _, err := m.Handle(&rawData{Data: 1}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if r := m.Health(context.Background()); r.Status != HealthReady {
+		t.Errorf("status=%s, want ready", r.Status)
+	}
+}
+
+// 测试构建失败时健康检查接口返回503
+func TestManager_HealthHandler(t *testing.T) {
+	m := NewManager()
+	if err := m.BuildPipeline(nil); err == nil {
+		t.Errorf("predict error occurs, but not")
+		t.FailNow()
+	}
+	rec := httptest.NewRecorder()
+	m.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("code=%d, want 503", rec.Code)
+	}
+	var r HealthReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &r); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if r.Status != HealthDown || r.BuildError != "no nodes registered (0 nodes registered, 0 edges provided)" {
+		t.Errorf("report=%+v", r)
+	}
+}
@@ -0,0 +1,163 @@
+package pipeline
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// 执行的结果
+type ExecutionStatus string
+
+const (
+	ExecutionSucceeded ExecutionStatus = "succeeded"
+	ExecutionFailed    ExecutionStatus = "failed"
+	ExecutionCancelled ExecutionStatus = "cancelled"
+)
+
+// 一次执行的摘要，不包含输入、输出数据
+type ExecutionSummary struct {
+	ID       string          `json:"id"`
+	Start    time.Time       `json:"start"`
+	End      time.Time       `json:"end"`
+	Duration time.Duration   `json:"duration"`
+	Status   ExecutionStatus `json:"status"`
+	// 失败或取消时正在执行的节点，以及错误信息
+	FailedNode string `json:"failed_node,omitempty"`
+	Err        string `json:"error,omitempty"`
+	// 执行过的判断节点选择的分支
+	Decisions Decisions `json:"decisions,omitempty"`
+	// 是否为预热的执行，见 Warmup
+	Warmup bool `json:"warmup,omitempty"`
+	// 设置了采样（WithTraceSampling、WithRecordingSampling）时本次执行是否记录了轨迹、血缘
+	TraceSampled     bool `json:"trace_sampled,omitempty"`
+	RecordingSampled bool `json:"recording_sampled,omitempty"`
+	// 执行的功能开关，见 WithFlags
+	Flags map[string]bool `json:"flags,omitempty"`
+	// 重新执行时原来失败的执行，以及这条数据第几次执行，见 WithParentExecution
+	ParentID string `json:"parent_exec,omitempty"`
+	Attempt  int    `json:"attempt,omitempty"`
+	// HandleSubgraph 的执行，形如 "from->to"
+	Subgraph string `json:"subgraph,omitempty"`
+}
+
+// 在内存中保留最近n 次执行的摘要，通过 RecentExecutions、Execution 查询
+// 记录 Handle、HandleContext、HandleSubgraph 的执行，没有开始执行（ctx 已结束、没有执行名额）的调用不记录
+func WithExecutionHistory(n int) Option {
+	return func(m *Manager) {
+		if n > 0 {
+			m.history = &executionHistory{ring: make([]ExecutionSummary, n)}
+		}
+	}
+}
+
+// 返回最近的执行摘要，最新的在前；没有开启 WithExecutionHistory 时返回nil
+func (m *Manager) RecentExecutions() []ExecutionSummary {
+	if m.history == nil {
+		return nil
+	}
+	return m.history.recent()
+}
+
+// 按执行的标识查找仍在历史中的执行摘要
+func (m *Manager) Execution(id string) (ExecutionSummary, bool) {
+	if m.history == nil {
+		return ExecutionSummary{}, false
+	}
+	return m.history.find(id)
+}
+
+// 固定容量的环形缓冲区，写满后覆盖最早的摘要
+type executionHistory struct {
+	mu   sync.Mutex
+	ring []ExecutionSummary
+	// 下一个写入的位置，以及已经写入的数量
+	next  int
+	count int
+}
+
+func (h *executionHistory) add(s ExecutionSummary) {
+	h.mu.Lock()
+	h.ring[h.next] = s
+	h.next = (h.next + 1) % len(h.ring)
+	if h.count < len(h.ring) {
+		h.count++
+	}
+	h.mu.Unlock()
+}
+
+func (h *executionHistory) recent() []ExecutionSummary {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]ExecutionSummary, 0, h.count)
+	for i := 1; i <= h.count; i++ {
+		out = append(out, h.ring[(h.next-i+len(h.ring))%len(h.ring)].clone())
+	}
+	return out
+}
+
+func (h *executionHistory) find(id string) (ExecutionSummary, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := 1; i <= h.count; i++ {
+		if s := h.ring[(h.next-i+len(h.ring))%len(h.ring)]; s.ID == id {
+			return s.clone(), true
+		}
+	}
+	return ExecutionSummary{}, false
+}
+
+func (s ExecutionSummary) clone() ExecutionSummary {
+	if s.Decisions != nil {
+		d := make(Decisions, len(s.Decisions))
+		for k, v := range s.Decisions {
+			d[k] = v
+		}
+		s.Decisions = d
+	}
+	s.Flags = cloneFlags(s.Flags)
+	return s
+}
+
+// 记录判断节点的决策，只在开启 WithExecutionHistory、WithSnapshotStore 时调用
+func (e *execution) decide(node *Node, pIndex int) {
+	if e.decisions == nil {
+		e.decisions = make(Decisions)
+	}
+	e.decisions[node.nodeName] = pIndex
+}
+
+// 执行结束时把摘要写入历史，in 为原始输入，用于错误文本的脱敏
+func (e *execution) remember(in *rawData, err error) {
+	end := e.m.clock.Now()
+	s := ExecutionSummary{
+		ID:        e.id(),
+		Start:     e.start,
+		End:       end,
+		Duration:  end.Sub(e.start),
+		Status:    ExecutionSucceeded,
+		Decisions: e.decisions,
+		Warmup:    e.warmup,
+		Flags:     e.flags,
+		ParentID:  e.parent,
+		Attempt:   e.dataAttempt,
+		Subgraph:  e.span,
+	}
+	if e.m.sampling != nil {
+		s.TraceSampled, s.RecordingSampled = e.traceSampled, e.recordingSampled
+	}
+	if err != nil {
+		s.Status, s.Err = ExecutionFailed, e.m.redactError(err, in, nil).Error()
+		var cancelled *CancelledError
+		if errors.As(err, &cancelled) {
+			s.Status = ExecutionCancelled
+		}
+		var nodeErr *NodeError
+		if errors.As(err, &nodeErr) {
+			s.FailedNode = nodeErr.Node
+		} else if e.current != nil {
+			s.FailedNode = e.current.nodeName
+		}
+	}
+	e.m.history.add(s)
+}
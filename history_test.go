@@ -0,0 +1,140 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// j1 按输入选择分支，w2 总是失败；返回的函数执行一次在w1 中取消的流水线
+func newHistoryManager(t *testing.T, n int) (*Manager, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewManager(WithExecutionHistory(n))
+	if err := m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) int {
+		if in.Data == "fail" {
+			return 1
+		}
+		return 0
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddWorkerNode("w1", func(ctx context.Context, in *rawData) (*rawData, error) {
+		if in.Data == "cancel" {
+			cancel()
+			return nil, ctx.Err()
+		}
+		return in, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddWorkerNode("w2", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return nil, errors.New("boom")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.BuildPipeline([][]string{
+		{Head, "j1"},
+		{"j1", "w1"},
+		{"j1", "w2"},
+		{"w1", Tail},
+		{"w2", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return m, func() {
+		_, _ = m.HandleContext(ctx, &rawData{Data: "cancel"})
+	}
+}
+
+func TestManager_ExecutionHistoryEviction(t *testing.T) {
+	m, _ := newHistoryManager(t, 3)
+	var ids []string
+	for i := 0; i < 5; i++ {
+		if _, err := m.Handle(&rawData{Data: "ok"}); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, m.RecentExecutions()[0].ID)
+	}
+	var got []string
+	for _, s := range m.RecentExecutions() {
+		got = append(got, s.ID)
+	}
+	if want := []string{ids[4], ids[3], ids[2]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("recent = %v, want %v", got, want)
+	}
+	if _, ok := m.Execution(ids[1]); ok {
+		t.Errorf("execution %s should have been evicted", ids[1])
+	}
+	s, ok := m.Execution(ids[3])
+	if !ok {
+		t.Fatalf("execution %s not found", ids[3])
+	}
+	if s.Status != ExecutionSucceeded || !reflect.DeepEqual(s.Decisions, Decisions{"j1": 0}) {
+		t.Errorf("unexpected summary: %+v", s)
+	}
+	if s.End.Before(s.Start) || s.Duration != s.End.Sub(s.Start) {
+		t.Errorf("unexpected timing: %+v", s)
+	}
+	// 修改返回的副本不影响历史
+	s.Decisions["j1"] = 1
+	if s, _ = m.Execution(ids[3]); s.Decisions["j1"] != 0 {
+		t.Errorf("history should not share decisions with callers")
+	}
+}
+
+func TestManager_ExecutionHistoryOutcomes(t *testing.T) {
+	m, runCancelled := newHistoryManager(t, 4)
+	if _, err := m.Handle(&rawData{Data: "fail"}); err == nil {
+		t.Fatal("want error")
+	}
+	runCancelled()
+	recent := m.RecentExecutions()
+	if len(recent) != 2 {
+		t.Fatalf("want 2 summaries, got %d", len(recent))
+	}
+	cancelled, failed := recent[0], recent[1]
+	if failed.Status != ExecutionFailed || failed.FailedNode != "w2" || failed.Err == "" ||
+		!reflect.DeepEqual(failed.Decisions, Decisions{"j1": 1}) {
+		t.Errorf("unexpected failed summary: %+v", failed)
+	}
+	if cancelled.Status != ExecutionCancelled || cancelled.FailedNode != "w1" {
+		t.Errorf("unexpected cancelled summary: %+v", cancelled)
+	}
+}
+
+func TestManager_ExecutionHistoryConcurrent(t *testing.T) {
+	m, _ := newHistoryManager(t, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = m.Handle(&rawData{Data: "ok"})
+			m.RecentExecutions()
+		}()
+	}
+	wg.Wait()
+	recent := m.RecentExecutions()
+	if len(recent) != 8 {
+		t.Fatalf("want 8 summaries, got %d", len(recent))
+	}
+	seen := make(map[string]bool)
+	for _, s := range recent {
+		if seen[s.ID] {
+			t.Errorf("duplicate execution %s", s.ID)
+		}
+		seen[s.ID] = true
+	}
+}
+
+func TestManager_ExecutionHistoryDisabled(t *testing.T) {
+	m := newLinearManager(t, 2, 0)
+	if _, err := m.Handle(&rawData{Data: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if recent := m.RecentExecutions(); recent != nil {
+		t.Errorf("history should be off by default, got %v", recent)
+	}
+}
@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// 同时执行的流水线数量达到上限时的处理策略
+type OverflowPolicy int
+
+const (
+	// 阻塞等待，直到有空闲的名额或ctx 结束
+	OverflowBlock OverflowPolicy = iota
+	// 立即返回ErrOverloaded
+	OverflowReject
+)
+
+var ErrOverloaded = errors.New("pipeline is overloaded")
+
+// 限制同时执行的流水线数量
+type inflightLimiter struct {
+	sem    chan struct{}
+	policy OverflowPolicy
+	count  int64
+}
+
+// 限制同时执行的流水线数量为n，超过时按overflow 的策略处理
+func WithMaxInflightExecutions(n int, overflow OverflowPolicy) Option {
+	return func(m *Manager) {
+		if n > 0 {
+			m.inflight.sem = make(chan struct{}, n)
+		}
+		m.inflight.policy = overflow
+	}
+}
+
+// 当前正在执行的流水线数量
+func (m *Manager) InflightExecutions() int {
+	return int(atomic.LoadInt64(&m.inflight.count))
+}
+
+// 获取一个执行名额
+func (m *Manager) admit(ctx context.Context) error {
+	if m.inflight.sem != nil {
+		if m.inflight.policy == OverflowReject {
+			select {
+			case m.inflight.sem <- struct{}{}:
+			default:
+				return ErrOverloaded
+			}
+		} else {
+			select {
+			case m.inflight.sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	atomic.AddInt64(&m.inflight.count, 1)
+	return nil
+}
+
+// 归还执行名额
+func (m *Manager) release() {
+	atomic.AddInt64(&m.inflight.count, -1)
+	if m.inflight.sem != nil {
+		<-m.inflight.sem
+	}
+}
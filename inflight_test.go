@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 构建一个只有一个工作节点的流水线
+func newSingleWorkerManager(t *testing.T, f WorkerFunc, opts ...Option) *Manager {
+	m := NewManager(opts...)
+	if err := m.AddWorkerNode("w1", f); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := m.BuildPipeline([][]string{
+		{"head000", "w1"},
+		{"w1", "tail111"},
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	return m
+}
+
+// 测试超过上限的执行被立即拒绝
+func TestManager_MaxInflightReject(t *testing.T) {
+	started := make(chan struct{}, 20)
+	release := make(chan struct{})
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (out *rawData, err error) {
+		started <- struct{}{}
+		<-release
+		return in, nil
+	}, WithMaxInflightExecutions(5, OverflowReject))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.Handle(&rawData{})
+			errs <- err
+		}()
+	}
+	// 5个执行占住名额，其余15个被拒绝
+	for i := 0; i < 5; i++ {
+		<-started
+	}
+	for i := 0; i < 15; i++ {
+		if err := <-errs; err != ErrOverloaded {
+			t.Errorf("err=%v, want ErrOverloaded", err)
+		}
+	}
+	if n := m.InflightExecutions(); n != 5 {
+		t.Errorf("inflight=%d, want 5", n)
+	}
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if n := m.InflightExecutions(); n != 0 {
+		t.Errorf("inflight=%d, want 0", n)
+	}
+}
+
+// 测试阻塞模式下同时执行的数量不超过上限
+func TestManager_MaxInflightBlock(t *testing.T) {
+	var cur, max int64
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (out *rawData, err error) {
+		n := atomic.AddInt64(&cur, 1)
+		for {
+			old := atomic.LoadInt64(&max)
+			if n <= old || atomic.CompareAndSwapInt64(&max, old, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt64(&cur, -1)
+		return in, nil
+	}, WithMaxInflightExecutions(5, OverflowBlock))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.Handle(&rawData{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if max > 5 {
+		t.Errorf("max concurrency=%d, want <=5", max)
+	}
+}
+
+// 测试阻塞等待时ctx 结束会返回ctx 的错误
+func TestManager_MaxInflightBlockCancel(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (out *rawData, err error) {
+		close(started)
+		<-release
+		return in, nil
+	}, WithMaxInflightExecutions(1, OverflowBlock))
+	go m.Handle(&rawData{})
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := m.HandleContext(ctx, &rawData{}); err != context.DeadlineExceeded {
+		t.Errorf("err=%v, want context.DeadlineExceeded", err)
+	}
+	close(release)
+}
@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// 输入超过上限时的处理策略，见 WithMaxInputSize
+type OversizePolicy struct {
+	route string
+}
+
+// 返回ErrPayloadTooLarge
+var FailOnOversize = OversizePolicy{}
+
+// 不调用节点，将输入交给node 继续执行
+// node 必须存在、不是合并节点，并且能到达尾节点，在BuildPipeline 时检查
+func RouteTo(node string) OversizePolicy {
+	return OversizePolicy{route: node}
+}
+
+// 可以限制输入大小的节点类型
+var maxInputSizeNodeTypes = []NodeTyp{NodeTypWorker, NodeTypDivider, NodeTypJudger}
+
+type maxInputSize struct {
+	limit  int
+	sizer  func(*rawData) int
+	policy OversizePolicy
+}
+
+// 调用工作节点、分裂节点、判断节点之前用sizer 计算输入的大小，超过limit 时按onExceed 的策略处理
+// sizer 为nil 时使用 WithDefaultSizer 设置的方法
+func WithMaxInputSize(limit int, sizer func(*rawData) int, onExceed OversizePolicy) NodeOption {
+	return func(o *nodeOptions) {
+		o.maxInputSize = &maxInputSize{limit: limit, sizer: sizer, policy: onExceed}
+		o.use("WithMaxInputSize", maxInputSizeNodeTypes...)
+	}
+}
+
+// 没有指定sizer 的 WithMaxInputSize 使用的计算输入大小的方法
+func WithDefaultSizer(sizer func(*rawData) int) Option {
+	return func(m *Manager) {
+		m.defaults.sizer = sizer
+	}
+}
+
+// 检查输入大小的配置：有计算大小的方法，转去的节点存在并且能到达尾节点
+func (m *Manager) validateInputSizes() error {
+	tail := m.nodes[m.tailName]
+	for _, node := range m.nodes {
+		node.sizer, node.oversizeRoute = nil, nil
+		l := node.opts.maxInputSize
+		if l == nil || !(optionUse{types: maxInputSizeNodeTypes}).appliesTo(node.Typ) {
+			continue
+		}
+		if node.sizer = l.sizer; node.sizer == nil {
+			node.sizer = m.defaults.sizer
+		}
+		if node.sizer == nil {
+			return invalidNode(CodeBadOption, node.nodeName, fmt.Errorf("node[%s] max input size has no sizer, set one or use WithDefaultSizer", node.nodeName))
+		}
+		if l.policy.route == "" {
+			continue
+		}
+		route, ok := m.nodes[l.policy.route]
+		if !ok || route.Typ == NodeTypHead || route.Typ == NodeTypTail {
+			return invalidNode(CodeBadOption, node.nodeName, fmt.Errorf("node[%s] oversize route node[%s] cannot be found in nodes", node.nodeName, l.policy.route))
+		}
+		if route == node || route.Typ == NodeTypMerger {
+			return invalidNode(CodeBadOption, node.nodeName, fmt.Errorf("node[%s] oversize route node[%s] should be another non-merger node", node.nodeName, l.policy.route))
+		}
+		if !reachableFrom(route)[tail] {
+			return invalidNode(CodeBadOption, node.nodeName, fmt.Errorf("node[%s] oversize route node[%s] cannot reach tail", node.nodeName, l.policy.route))
+		}
+		node.oversizeRoute = route
+	}
+	return nil
+}
+
+// 调用节点之前检查输入的大小，超过上限时返回转去的节点，或者返回ErrPayloadTooLarge
+func (e *execution) checkInputSize(ctx context.Context, node *Node, in *rawData) (*Node, error) {
+	if node.sizer == nil {
+		return nil, nil
+	}
+	l := node.opts.maxInputSize
+	size := node.sizer(in)
+	if size <= l.limit {
+		return nil, nil
+	}
+	start := e.begin(node)
+	if node.oversizeRoute != nil {
+		e.record(node, start, nil, callInfo{branch: -1, outcome: OutcomeRerouted})
+		e.current = nil
+		return node.oversizeRoute, nil
+	}
+	err := runtimeError(node, ErrPayloadTooLarge, "input too large", fmt.Sprintf("size %d exceeds limit %d", size, l.limit))
+	return nil, newNodeError(node, e.finish(node, start, err, callInfo{branch: -1, in: in}))
+}
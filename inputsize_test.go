@@ -0,0 +1,125 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func stringSize(in *rawData) int {
+	return len(in.Data.(string))
+}
+
+// w1 -> tail 限制输入大小，超过时按policy 处理；oversize 不在主流程中
+func newInputSizeManager(t *testing.T, calls *[]string, policy OversizePolicy, opts ...Option) *Manager {
+	m := NewManager(opts...)
+	record := func(name string) WorkerFunc {
+		return func(ctx context.Context, in *rawData) (*rawData, error) {
+			*calls = append(*calls, name)
+			return &rawData{Data: name}, nil
+		}
+	}
+	var sizer func(*rawData) int
+	if len(opts) == 0 {
+		sizer = stringSize
+	}
+	_ = m.AddWorkerNode("w1", record("w1"), WithMaxInputSize(4, sizer, policy))
+	_ = m.AddWorkerNode("oversize", record("oversize"))
+	if err := m.BuildPipeline([][]string{{Head, "w1"}, {"w1", Tail}, {"oversize", Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// 测试输入超过上限时返回ErrPayloadTooLarge，不调用节点
+func TestManager_MaxInputSizeFail(t *testing.T) {
+	var calls []string
+	m := newInputSizeManager(t, &calls, FailOnOversize)
+	if out, err := m.Handle(&rawData{Data: "abcd"}); err != nil || out.Data != "w1" {
+		t.Fatalf("unexpected result %v %v", out, err)
+	}
+	_, err := m.Handle(&rawData{Data: "abcdef"})
+	var nodeErr *NodeError
+	if !errors.Is(err, ErrPayloadTooLarge) || !errors.As(err, &nodeErr) || nodeErr.Node != "w1" {
+		t.Fatalf("want ErrPayloadTooLarge from w1, got %v", err)
+	}
+	for _, want := range []string{`worker "w1"`, "size 6", "limit 4"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should contain %s: %v", want, err)
+		}
+	}
+	if len(calls) != 1 {
+		t.Errorf("w1 should not be called for the oversize input, calls %v", calls)
+	}
+}
+
+// 测试输入超过上限时转去指定的节点，使用Manager 级别的sizer
+func TestManager_MaxInputSizeRoute(t *testing.T) {
+	var calls []string
+	var events []NodeEvent
+	m := newInputSizeManager(t, &calls, RouteTo("oversize"),
+		WithDefaultSizer(stringSize), WithListener(func(ev NodeEvent) { events = append(events, ev) }))
+	out, err := m.Handle(&rawData{Data: "abcdef"})
+	if err != nil || out.Data != "oversize" {
+		t.Fatalf("unexpected result %v %v", out, err)
+	}
+	if strings.Join(calls, ",") != "oversize" {
+		t.Errorf("unexpected calls %v", calls)
+	}
+	if len(events) != 2 || events[0].Node != "w1" || events[0].Outcome != OutcomeRerouted {
+		t.Errorf("unexpected events %+v", events)
+	}
+	if info, _ := m.NodeInfo("w1"); !strings.Contains(strings.Join(info.EffectiveOptions, " "), "max_input_size=4/route:oversize") {
+		t.Errorf("unexpected options %v", info.EffectiveOptions)
+	}
+}
+
+// 测试判断节点转去的节点在分支中继续执行
+func TestManager_MaxInputSizeRouteJudger(t *testing.T) {
+	m := NewManager()
+	_ = m.AddJudgerNode("j1", routeJudger, WithMaxInputSize(1, stringSize, RouteTo("w2")))
+	_ = m.AddWorkerNode("w1", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: "w1"}, nil
+	})
+	_ = m.AddWorkerNode("w2", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: "w2"}, nil
+	})
+	if err := m.BuildPipeline([][]string{{Head, "j1"}, {"j1", "w1"}, {"j1", "w2"}, {"w1", Tail}, {"w2", Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	for in, want := range map[string]string{"a": "w1", "ab": "w2"} {
+		if out, err := m.Handle(&rawData{Data: in}); err != nil || out.Data != want {
+			t.Errorf("input %s: want %s, got %v %v", in, want, out, err)
+		}
+	}
+}
+
+// 测试构建时检查转去的节点以及sizer
+func TestManager_MaxInputSizeInvalid(t *testing.T) {
+	merger := func(ctx context.Context, ins []*rawData) (*rawData, error) {
+		return ins[0], nil
+	}
+	divider := func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{in, in}, nil
+	}
+	for name, c := range map[string]struct {
+		opt  NodeOption
+		want string
+	}{
+		"missing": {WithMaxInputSize(1, stringSize, RouteTo("missing")), "node[missing] cannot be found"},
+		"self":    {WithMaxInputSize(1, stringSize, RouteTo("w1")), "should be another non-merger node"},
+		"merger":  {WithMaxInputSize(1, stringSize, RouteTo("m1")), "should be another non-merger node"},
+		"sizer":   {WithMaxInputSize(1, nil, FailOnOversize), "has no sizer"},
+	} {
+		m := NewManager()
+		_ = m.AddDividerNode("d1", divider)
+		_ = m.AddWorkerNode("w1", passWorker, c.opt)
+		_ = m.AddWorkerNode("w2", passWorker)
+		_ = m.AddMergerNode("m1", merger)
+		err := m.BuildPipeline([][]string{{Head, "d1"}, {"d1", "w1"}, {"d1", "w2"}, {"w1", "m1"}, {"w2", "m1"}, {"m1", Tail}})
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: want error containing %q, got %v", name, c.want, err)
+		}
+	}
+}
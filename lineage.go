@@ -0,0 +1,60 @@
+package pipeline
+
+import "time"
+
+// 输出数据的Meta 中保存血缘的键
+const LineageMetaKey = "pipeline.lineage"
+
+// 一条血缘记录
+// 分裂节点、判断节点的Branch 为数据经过的分支；合并节点的Inputs 为每份输入各自的血缘
+type LineageEntry struct {
+	Node      string
+	Typ       NodeTyp
+	Timestamp time.Time
+	Branch    string
+	Inputs    [][]LineageEntry
+}
+
+// 记录输出数据经过的节点，结果通过 ResultLineage 读取
+func WithLineage() CallOption {
+	return func(o *callOptions) {
+		o.lineage = true
+	}
+}
+
+// 返回HandleContext 开启 WithLineage 时输出数据的血缘，按经过节点的顺序排列
+func ResultLineage(out *rawData) ([]LineageEntry, bool) {
+	if out == nil || out.Meta == nil {
+		return nil, false
+	}
+	l, ok := out.Meta[LineageMetaKey].([]LineageEntry)
+	return l, ok
+}
+
+// 追加一条血缘记录，返回新的切片，不会修改其他分支共享的部分
+func (e *execution) addLineage(l []LineageEntry, node *Node, branch int, inputs [][]LineageEntry) []LineageEntry {
+	if !e.lineage {
+		return nil
+	}
+	entry := LineageEntry{
+		Node:      node.nodeName,
+		Typ:       node.Typ,
+		Timestamp: e.m.clock.Now(),
+		Inputs:    inputs,
+	}
+	if branch >= 0 {
+		entry.Branch = node.branchName(branch)
+	}
+	return append(l[:len(l):len(l)], entry)
+}
+
+// 将血缘保存到输出数据的Meta 中
+func (e *execution) attachLineage(out *rawData, l []LineageEntry) {
+	if !e.lineage || out == nil {
+		return
+	}
+	if out.Meta == nil {
+		out.Meta = make(map[string]interface{})
+	}
+	out.Meta[LineageMetaKey] = l
+}
@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// 将血缘渲染成字符串便于比较，例如 m1<[d1:0 a] [d1:1 b]>
+func formatLineage(l []LineageEntry) string {
+	parts := make([]string, 0, len(l))
+	for _, entry := range l {
+		s := entry.Node
+		if entry.Branch != "" {
+			s += ":" + entry.Branch
+		}
+		if len(entry.Inputs) > 0 {
+			inputs := make([]string, 0, len(entry.Inputs))
+			for _, input := range entry.Inputs {
+				inputs = append(inputs, fmt.Sprintf("[%s]", formatLineage(input)))
+			}
+			s += "<" + strings.Join(inputs, " ") + ">"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+// 测试菱形流程输出数据的血缘
+func TestManager_Lineage(t *testing.T) {
+	clock := newFakeClock()
+	m := newDiamondManager(t, WithClock(clock))
+	out, err := m.HandleContext(context.Background(), &rawData{Data: 1}, WithLineage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, ok := ResultLineage(out)
+	if !ok {
+		t.Fatal("expected lineage")
+	}
+	if s, expected := formatLineage(l), "m1<[d1:0 a] [d1:1 b]>"; s != expected {
+		t.Errorf("expected lineage %s, got %s", expected, s)
+	}
+	if !l[0].Timestamp.Equal(clock.Now()) || l[0].Typ != NodeTypMerger {
+		t.Errorf("unexpected merger entry %+v", l[0])
+	}
+
+	// 没有开启时不记录
+	out, err = m.Handle(&rawData{Data: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ResultLineage(out); ok {
+		t.Error("expected no lineage without WithLineage")
+	}
+}
@@ -0,0 +1,96 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 节点配置用在不适用的节点类型上，配置不会生效
+var ErrOptionIgnored = errors.New("node option is ignored")
+
+// 构建时发现的问题，不影响构建和执行
+type LintFinding struct {
+	Node string
+	// 不生效的配置，例如 "WithRetry"
+	Option  string
+	Message string
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("node[%s] %s: %s", f.Node, f.Option, f.Message)
+}
+
+// 节点配置用在不适用的节点类型上时BuildPipeline 报错（ErrOptionIgnored），
+// 默认只记录到 Lint 的结果中
+func WithStrictOptions() Option {
+	return func(m *Manager) {
+		m.strictOptions = true
+	}
+}
+
+// 返回最近一次BuildPipeline 发现的问题，按edges 中节点首次出现的顺序排列
+func (m *Manager) Lint() []LintFinding {
+	return m.lintFindings
+}
+
+// 节点使用过的配置，以及配置适用的节点类型，types 为空表示适用于所有节点
+type optionUse struct {
+	name  string
+	types []NodeTyp
+}
+
+// 记录节点使用了name 配置
+func (o *nodeOptions) use(name string, types ...NodeTyp) {
+	for _, u := range o.used {
+		if u.name == name {
+			return
+		}
+	}
+	o.used = append(o.used, optionUse{name: name, types: types})
+}
+
+func (u optionUse) appliesTo(typ NodeTyp) bool {
+	if len(u.types) == 0 {
+		return true
+	}
+	for _, t := range u.types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// 找出用在不适用的节点类型上的配置
+func lintNodeOptions(order []*Node) []LintFinding {
+	var findings []LintFinding
+	for _, node := range order {
+		for _, u := range node.opts.used {
+			if u.appliesTo(node.Typ) {
+				continue
+			}
+			findings = append(findings, LintFinding{
+				Node:    node.nodeName,
+				Option:  u.name,
+				Message: fmt.Sprintf("ignored by %s nodes, only applies to %v", node.Typ, u.types),
+			})
+		}
+	}
+	return findings
+}
+
+// 记录构建时发现的问题，开启 WithStrictOptions 时返回第一个不生效的配置
+func (m *Manager) checkNodeOptions(order []*Node) error {
+	ignored := lintNodeOptions(order)
+	m.lintFindings = ignored
+	if m.strictDocs {
+		m.lintFindings = append(m.lintFindings, lintDocumentation(order)...)
+	}
+	m.lintFindings = append(m.lintFindings, m.lintMiddlewares(order)...)
+	m.lintFindings = append(m.lintFindings, lintBareNodes(order)...)
+	if !m.strictOptions || len(ignored) == 0 {
+		return nil
+	}
+	f := ignored[0]
+	return fmt.Errorf("node[%s] option %s %s: %w", f.Node, f.Option, f.Message, ErrOptionIgnored)
+}
@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 构建 j1 -> (w1 | d1 -> a, b -> m1) 的流程，opts 为每个节点的配置
+func buildLintManager(opts map[string][]NodeOption, mOpts ...Option) (*Manager, error) {
+	m := NewManager(mOpts...)
+	if err := m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) int {
+		return 0
+	}, opts["j1"]...); err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"w1", "a", "b"} {
+		if err := m.AddWorkerNode(name, passWorker, opts[name]...); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{in, in}, nil
+	}, opts["d1"]...); err != nil {
+		return nil, err
+	}
+	if err := m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		return in[0], nil
+	}, opts["m1"]...); err != nil {
+		return nil, err
+	}
+	return m, m.BuildPipeline([][]string{
+		{"head", "j1"},
+		{"j1", "w1"},
+		{"j1", "d1"},
+		{"w1", "tail"},
+		{"d1", "a"},
+		{"d1", "b"},
+		{"a", "m1"},
+		{"b", "m1"},
+		{"m1", "tail"},
+	})
+}
+
+var lintCases = []struct {
+	name   string
+	node   string
+	option string
+	opt    NodeOption
+}{
+	{"retry on judger", "j1", "WithRetry", WithRetry(3)},
+	{"backoff on judger", "j1", "WithBackoff", WithBackoff(ConstantBackoff(time.Millisecond))},
+	{"merge timeout on worker", "w1", "WithMergeTimeout", WithMergeTimeout(time.Second, ProceedWithPartial)},
+	{"merge timeout on divider", "d1", "WithMergeTimeout", WithMergeTimeout(time.Second, FailOnMergeTimeout)},
+	{"branches on worker", "w1", "WithBranches", WithBranches("only")},
+	{"branches on merger", "m1", "WithBranches", WithBranches("only")},
+}
+
+func TestManager_LintIgnoredOptions(t *testing.T) {
+	for _, c := range lintCases {
+		t.Run(c.name, func(t *testing.T) {
+			m, err := buildLintManager(map[string][]NodeOption{c.node: {c.opt}})
+			if err != nil {
+				t.Fatalf("lint mode should build, got %v", err)
+			}
+			findings := m.Lint()
+			if len(findings) != 1 {
+				t.Fatalf("want 1 finding, got %v", findings)
+			}
+			if f := findings[0]; f.Node != c.node || f.Option != c.option {
+				t.Errorf("finding = %s, want node[%s] %s", f, c.node, c.option)
+			}
+			if _, err := m.Handle(&rawData{Data: 1}); err != nil {
+				t.Errorf("ignored option should not affect execution: %v", err)
+			}
+		})
+	}
+}
+
+func TestManager_StrictOptions(t *testing.T) {
+	for _, c := range lintCases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := buildLintManager(map[string][]NodeOption{c.node: {c.opt}}, WithStrictOptions())
+			if !errors.Is(err, ErrOptionIgnored) {
+				t.Fatalf("want ErrOptionIgnored, got %v", err)
+			}
+			if msg := err.Error(); !strings.Contains(msg, "node["+c.node+"]") || !strings.Contains(msg, c.option) {
+				t.Errorf("error should name the node and option: %v", err)
+			}
+		})
+	}
+}
+
+func TestManager_LintApplicableOptions(t *testing.T) {
+	opts := map[string][]NodeOption{
+		"j1": {WithBranches("single", "fanout"), WithCost(1)},
+		"w1": {WithRetry(2), WithMaxBackoff(time.Millisecond)},
+		"d1": {WithRetry(2), WithBranches("left", "right")},
+		"m1": {WithRetry(2), WithMergeTimeout(time.Second, ProceedWithPartial)},
+	}
+	m, err := buildLintManager(opts, WithStrictOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findings := m.Lint(); len(findings) != 0 {
+		t.Errorf("want no findings, got %v", findings)
+	}
+}
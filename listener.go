@@ -0,0 +1,87 @@
+package pipeline
+
+import "time"
+
+// 节点的执行结果，用于区分节点真正执行还是被跳过等情况
+type Outcome int
+
+const (
+	// 节点处理方法被调用
+	OutcomeExecuted Outcome = iota
+	// 命中结果缓存，没有调用处理方法
+	OutcomeCacheHit
+	// 节点被跳过，输入原样传给下一个节点，见 WithSkipIfRemaining
+	OutcomeSkipped
+	// 与其他执行中的相同请求合并
+	OutcomeDeduplicated
+	// 节点失败后由降级方法给出结果
+	OutcomeFallbackUsed
+	// 注入的故障代替了处理方法
+	OutcomeFaultInjected
+	// 输入超过上限，转去其他节点，见 WithMaxInputSize
+	OutcomeRerouted
+	// 节点还没有完成，执行停滞，见 WithStallDetection
+	OutcomeStalled
+)
+
+var outcomeNames = [...]string{"executed", "cache_hit", "skipped", "deduplicated", "fallback_used", "fault_injected", "rerouted", "stalled"}
+
+func (o Outcome) String() string {
+	if o < 0 || int(o) >= len(outcomeNames) {
+		return "unknown"
+	}
+	return outcomeNames[o]
+}
+
+// 单个节点执行完成的事件
+type NodeEvent struct {
+	Node    string
+	Stage   string
+	Variant string
+	// 开启 WithPipelineRetry 时为整个流水线的第几次执行
+	PipelineAttempt int
+	// 重新执行时原来失败的执行，以及这条数据第几次执行，见 WithParentExecution
+	ParentExecution string
+	Attempt         int
+	// 是否为预热的执行，见 Warmup
+	Warmup  bool
+	Typ     NodeTyp
+	Outcome Outcome
+	// 执行时间，以及开始执行前在队列中等待的时间，见 TraceEntry
+	Duration  time.Duration
+	QueueWait time.Duration
+	Err       error
+	// 节点的信息
+	Info NodeInfo
+}
+
+// 节点执行完成时的回调，用于上报监控指标；会被多个执行并发调用
+type Listener func(ev NodeEvent)
+
+// 设置Manager 的监听者，每个节点执行完成（包括被跳过）后都会收到一个事件
+// 开启 WithStallDetection 时执行停滞也会收到一个 OutcomeStalled 事件，此时节点并没有完成
+func WithListener(l Listener) Option {
+	return func(m *Manager) {
+		m.listener = l
+	}
+}
+
+// 每个节点开始执行时调用f，参数为节点名，在节点的处理方法被调用之前同步调用；会被多个执行并发调用
+// 与 WithListener 一起用于测试中跟踪节点的执行，例如 pipelinetest.Scheduler
+func WithStartListener(f func(node string)) Option {
+	return func(m *Manager) {
+		m.nodeStart = func(node *Node) {
+			f(node.nodeName)
+		}
+	}
+}
+
+// 与 WithStartListener 相同，只对本次执行生效，
+// 例如 pipelinetest.CheckCancellation 在节点执行时取消ctx
+func WithCallStartListener(f func(node string)) CallOption {
+	return func(o *callOptions) {
+		o.onStart = func(node *Node) {
+			f(node.nodeName)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 测试执行和跳过的节点分别以对应的结果通知监听者
+func TestManager_ListenerOutcome(t *testing.T) {
+	var mu sync.Mutex
+	outcomes := make(map[string]Outcome)
+	clock := newFakeClock()
+	m := NewManager(WithClock(clock), WithListener(func(ev NodeEvent) {
+		mu.Lock()
+		outcomes[ev.Node] = ev.Outcome
+		mu.Unlock()
+	}))
+	_ = m.AddWorkerNode("w1", passWorker)
+	_ = m.AddWorkerNode("optional", passWorker, WithSkipIfRemaining(time.Second))
+	if err := m.BuildPipeline([][]string{
+		{"head000", "w1"},
+		{"w1", "optional"},
+		{"optional", "tail111"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := fakeDeadlineCtx{Context: context.Background(), deadline: clock.Now().Add(time.Millisecond)}
+	if _, err := m.HandleContext(ctx, &rawData{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]Outcome{"w1": OutcomeExecuted, "optional": OutcomeSkipped}
+	if !reflect.DeepEqual(outcomes, expected) {
+		t.Errorf("expected %v, got %v", expected, outcomes)
+	}
+}
+
+// 测试节点开始执行时按顺序通知，只对设置的这次执行生效
+func TestManager_StartListener(t *testing.T) {
+	m := newLinearManager(t, 3, 0)
+	var started []string
+	if _, err := m.HandleContext(context.Background(), &rawData{Data: 0}, WithCallStartListener(func(node string) {
+		started = append(started, node)
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"w1", "w2", "w3"}; !reflect.DeepEqual(started, want) {
+		t.Errorf("started %v, want %v", started, want)
+	}
+	if _, err := m.Handle(&rawData{Data: 0}); err != nil || len(started) != 3 {
+		t.Errorf("err=%v started %v, want other executions not reported", err, started)
+	}
+}
@@ -0,0 +1,28 @@
+package pipeline
+
+import "math/rand"
+
+// 调试用：每次调用合并节点前按seed 生成的随机顺序打乱输入，用于发现依赖输入顺序的合并方法
+// 开启后不再保证输入按入边的顺序排列（见 AddMergerNode），不开启时不做任何处理；配合 pipelinetest.AssertOrderInsensitive 使用
+func WithMergerOrderShuffling(seed int64) Option {
+	return func(m *Manager) {
+		m.mergerShuffle = newLockedRand(rand.NewSource(seed))
+	}
+}
+
+// 返回打乱顺序后的输入，不修改in
+func (m *Manager) shuffleMergerInputs(in []*rawData) []*rawData {
+	s := make([]*rawData, len(in))
+	copy(s, in)
+	m.shuffleMergerOrder(len(s), func(i, j int) {
+		s[i], s[j] = s[j], s[i]
+	})
+	return s
+}
+
+// 用调试的随机数来源打乱n 个元素的顺序
+func (m *Manager) shuffleMergerOrder(n int, swap func(i, j int)) {
+	m.mergerShuffle.mu.Lock()
+	m.mergerShuffle.rnd.Shuffle(n, swap)
+	m.mergerShuffle.mu.Unlock()
+}
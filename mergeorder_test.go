@@ -0,0 +1,145 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// 分裂成三个分支分别加上不同后缀，再由merge 合并
+func newJoinManager(t *testing.T, merge MergerFunc, opts ...Option) *Manager {
+	m := NewManager(opts...)
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{{Data: in.Data}, {Data: in.Data}, {Data: in.Data}}, nil
+	})
+	edges := [][]string{{Head, "d1"}, {"m1", Tail}}
+	for _, suffix := range []string{"x", "y", "z"} {
+		suffix := suffix
+		_ = m.AddWorkerNode(suffix, func(ctx context.Context, in *rawData) (*rawData, error) {
+			return &rawData{Data: in.Data.(string) + suffix}, nil
+		})
+		edges = append(edges, []string{"d1", suffix}, []string{suffix, "m1"})
+	}
+	_ = m.AddMergerNode("m1", merge)
+	if err := m.BuildPipeline(edges); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func joinInputs(in []*rawData) []string {
+	parts := make([]string, len(in))
+	for i, d := range in {
+		parts[i] = d.Data.(string)
+	}
+	return parts
+}
+
+// 测试开启后合并节点收到的输入顺序会变化，不开启时按入边的顺序
+func TestManager_MergerOrderShuffling(t *testing.T) {
+	join := func(ctx context.Context, in []*rawData) (*rawData, error) {
+		return &rawData{Data: strings.Join(joinInputs(in), ",")}, nil
+	}
+	orders := make(map[string]bool)
+	m := newJoinManager(t, join, WithMergerOrderShuffling(1))
+	for i := 0; i < 20; i++ {
+		out, err := m.Handle(&rawData{Data: "a"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		orders[out.Data.(string)] = true
+	}
+	if len(orders) < 2 {
+		t.Errorf("orders %v, want the inputs shuffled", orders)
+	}
+	// 不开启时不打乱顺序
+	out, err := newJoinManager(t, join).Handle(&rawData{Data: "a"})
+	if err != nil || out.Data != "ax,ay,az" {
+		t.Errorf("out=%v err=%v", out, err)
+	}
+}
+
+// d1 分出inner、a、b 三个分支，inner 经过d2 -> (x, y) -> m2 之后才到达m1
+// d1 的出边按d1Order 的顺序声明，决定分支的执行顺序，也就是m1 的输入到达的顺序；m1 的入边按m1Order 的顺序声明
+func newPositionalManager(t *testing.T, d1Order, m1Order []string, merge MergerFunc, mergerOpts []NodeOption, opts ...Option) *Manager {
+	m := NewManager(opts...)
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{{}, {}, {}}, nil
+	})
+	_ = m.AddDividerNode("d2", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{{}, {}}, nil
+	})
+	for _, name := range []string{"a", "b", "x", "y"} {
+		name := name
+		_ = m.AddWorkerNode(name, func(ctx context.Context, in *rawData) (*rawData, error) {
+			return &rawData{Data: name}, nil
+		})
+	}
+	_ = m.AddMergerNode("m2", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		return &rawData{Data: "inner"}, nil
+	})
+	_ = m.AddMergerNode("m1", merge, mergerOpts...)
+	edges := [][]string{{Head, "d1"}}
+	for _, n := range d1Order {
+		edges = append(edges, []string{"d1", n})
+	}
+	edges = append(edges, []string{"d2", "x"}, []string{"d2", "y"}, []string{"x", "m2"}, []string{"y", "m2"})
+	for _, n := range m1Order {
+		edges = append(edges, []string{n, "m1"})
+	}
+	edges = append(edges, []string{"m1", Tail})
+	if err := m.BuildPipeline(edges); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// 三个元素的全排列
+func permutations3(s []string) [][]string {
+	var out [][]string
+	for i := range s {
+		for j := range s {
+			for k := range s {
+				if i != j && j != k && i != k {
+					out = append(out, []string{s[i], s[j], s[k]})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// 测试合并方法的输入总是按入边的顺序排列，与分支到达的先后无关；WithArrivalOrder 时按到达的顺序
+// 通过排列d1 的出边（到达顺序）、m1 的入边（期望的位置）以及遍历方式，覆盖不同的到达顺序
+func TestManager_MergerPositionalOrder(t *testing.T) {
+	join := func(ctx context.Context, in []*rawData) (*rawData, error) {
+		return &rawData{Data: strings.Join(joinInputs(in), ",")}, nil
+	}
+	data := map[string]string{"a": "a", "b": "b", "m2": "inner"}
+	arrivals := make(map[string]bool)
+	for _, traversal := range []Traversal{BFS, DFS} {
+		for _, d1Order := range permutations3([]string{"d2", "a", "b"}) {
+			for _, m1Order := range permutations3([]string{"m2", "a", "b"}) {
+				m := newPositionalManager(t, d1Order, m1Order, join, nil, WithTraversal(traversal))
+				out, err := m.Handle(&rawData{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := data[m1Order[0]] + "," + data[m1Order[1]] + "," + data[m1Order[2]]
+				if out.Data != want {
+					t.Errorf("traversal %d d1 %v m1 %v: inputs %v, want edge order %s", traversal, d1Order, m1Order, out.Data, want)
+				}
+			}
+			m := newPositionalManager(t, d1Order, []string{"m2", "a", "b"}, join, []NodeOption{WithArrivalOrder()}, WithTraversal(traversal))
+			out, err := m.Handle(&rawData{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			arrivals[out.Data.(string)] = true
+		}
+	}
+	// 排列覆盖了三个输入所有的到达顺序
+	if len(arrivals) != 6 {
+		t.Errorf("arrival orders %v, want permutations to vary them", arrivals)
+	}
+}
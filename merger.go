@@ -0,0 +1,171 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 合并节点等待超时后的处理策略
+type MergeTimeoutPolicy int
+
+const (
+	// 使用已经到达的输入执行合并
+	ProceedWithPartial MergeTimeoutPolicy = iota
+	// 返回ErrMergeTimeout
+	FailOnMergeTimeout
+)
+
+var ErrMergeTimeout = errors.New("merger wait timeout")
+
+type mergeTimeout struct {
+	d      time.Duration
+	policy MergeTimeoutPolicy
+}
+
+// 合并节点等待输入的最长时间，从第一份输入到达时开始计时
+// 超时后取消对应分裂节点还没有结束的分支，并按onTimeout 的策略处理，超时后才到达的输入会被丢弃
+func WithMergeTimeout(d time.Duration, onTimeout MergeTimeoutPolicy) NodeOption {
+	return func(o *nodeOptions) {
+		o.mergeTimeout = &mergeTimeout{d: d, policy: onTimeout}
+		o.use("WithMergeTimeout", NodeTypMerger)
+	}
+}
+
+// 合并节点在一次执行中的状态
+// 每份输入放在预先分配的位置上，received 为已经到达的输入数
+type mergerState struct {
+	ins      []*rawData
+	from     []*Node
+	received int
+	// 按到达的顺序排列输入，见 WithArrivalOrder
+	arrival bool
+	// 每份输入的血缘
+	lineages [][]LineageEntry
+	// 每份输入所在分支的执行情况，见 BranchMeta
+	accs []*branchAcc
+	// 第一份输入所在分支分裂之前的ctx
+	outer []context.Context
+	// 第一份输入到达的时间
+	first time.Time
+	// 已经执行过合并
+	done bool
+	// 超时放弃的限时分支数
+	missing int
+	// 合并之后所在的限时分支
+	branch *activeBranch
+	// 等待计时的分支，见 startMergeWait
+	wait *activeBranch
+}
+
+// 合并节点的输入按到达的顺序排列，而不是按入边的顺序，见 AddMergerNode
+func WithArrivalOrder() NodeOption {
+	return func(o *nodeOptions) {
+		o.arrivalOrder = true
+		o.use("WithArrivalOrder", NodeTypMerger)
+	}
+}
+
+// 第一份输入到达时创建合并节点的状态，按入度thre 预先分配，每份输入到达时的处理是O(1) 的
+func (e *execution) newMergerState(nw *nodeDataWrapper, thre int) *mergerState {
+	st := &mergerState{
+		ins:     make([]*rawData, thre),
+		from:    make([]*Node, thre),
+		arrival: nw.node.opts.arrivalOrder,
+		outer:   nw.outer,
+		first:   nw.at,
+		branch:  nw.branch.endAt(nw.node),
+		wait:    nw.branch.mergeWait(nw.node),
+	}
+	if st.wait != nil {
+		// 从第一份输入加入队列时开始计时
+		st.first = st.wait.started
+	}
+	if e.lineage {
+		st.lineages = make([][]LineageEntry, thre)
+	}
+	if e.m.branchMeta {
+		st.accs = make([]*branchAcc, thre)
+	}
+	return st
+}
+
+// 把一份输入放到对应的位置上：默认为发出该输入的入边在合并节点入边中的顺序，
+// 同一个前驱节点有多条入边时依次使用；开启 WithArrivalOrder 时为到达的顺序
+func (st *mergerState) add(nw *nodeDataWrapper) {
+	i := st.received
+	if !st.arrival {
+		i = st.slot(nw.node, nw.from)
+	}
+	st.received++
+	st.ins[i], st.from[i] = nw.in, nw.from
+	if st.lineages != nil {
+		st.lineages[i] = nw.lineage
+	}
+	if st.accs != nil {
+		st.accs[i] = nw.meta
+	}
+}
+
+// from 的输入的位置，找不到空的位置时（例如构建后入度被修改）使用第一个空的位置
+func (st *mergerState) slot(node, from *Node) int {
+	for _, i := range node.predSlots[from] {
+		if i < len(st.from) && st.from[i] == nil {
+			return i
+		}
+	}
+	for i, f := range st.from {
+		if f == nil {
+			return i
+		}
+	}
+	return st.received
+}
+
+// 合并之前去掉没有到达的输入的位置（等待超时、限时分支超时），其余输入的顺序不变
+func (st *mergerState) compact() {
+	if st.received == len(st.ins) {
+		return
+	}
+	n := 0
+	for i, from := range st.from {
+		if from == nil {
+			continue
+		}
+		st.ins[n], st.from[n] = st.ins[i], from
+		if st.lineages != nil {
+			st.lineages[n] = st.lineages[i]
+		}
+		if st.accs != nil {
+			st.accs[n] = st.accs[i]
+		}
+		n++
+	}
+	st.ins, st.from = st.ins[:n], st.from[:n]
+	if st.lineages != nil {
+		st.lineages = st.lineages[:n]
+	}
+	if st.accs != nil {
+		st.accs = st.accs[:n]
+	}
+}
+
+// 超时的报错，列出还没有输入的前驱节点
+func (st *mergerState) timeoutError(node *Node, d time.Duration, preds []*Node) error {
+	arrived := make(map[*Node]int)
+	for _, from := range st.from {
+		arrived[from]++
+	}
+	var missing []string
+	for _, pred := range preds {
+		if arrived[pred] > 0 {
+			arrived[pred]--
+			continue
+		}
+		missing = append(missing, pred.nodeName)
+	}
+	return runtimeError(node, ErrMergeTimeout, "wait timeout",
+		fmt.Sprintf("waited %s, missing branches [%s]", d, strings.Join(missing, " ")))
+}
@@ -0,0 +1,371 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 构建一个菱形流程：d1 分出fast、slow 两个分支后在m1 合并，slow 分支会让时间前进delay
+func newMergeTimeoutManager(t *testing.T, clock *fakeClock, delay time.Duration, policy MergeTimeoutPolicy) *Manager {
+	m := NewManager(WithClock(clock))
+	if err := m.AddDividerNode("d1", func(ctx context.Context, in *rawData) (out []*rawData, err error) {
+		return []*rawData{{Data: "fast"}, {Data: "slow"}}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddWorkerNode("fast", passWorker); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddWorkerNode("slow", func(ctx context.Context, in *rawData) (out *rawData, err error) {
+		clock.Advance(delay)
+		return in, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (out *rawData, err error) {
+		var parts []string
+		for _, data := range in {
+			parts = append(parts, data.Data.(string))
+		}
+		return &rawData{Data: strings.Join(parts, ",")}, nil
+	}, WithMergeTimeout(time.Second, policy)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.BuildPipeline([][]string{
+		{"head000", "d1"},
+		{"d1", "fast"},
+		{"d1", "slow"},
+		{"fast", "m1"},
+		{"slow", "m1"},
+		{"m1", "tail111"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// 测试超时后使用已经到达的输入合并
+func TestManager_MergeTimeoutProceed(t *testing.T) {
+	clock := newFakeClock()
+	m := newMergeTimeoutManager(t, clock, 2*time.Second, ProceedWithPartial)
+	out, err := m.Handle(&rawData{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Data.(string) != "fast" {
+		t.Errorf("out=%v, want fast", out.Data)
+	}
+	// 在等待时间内到达则正常合并
+	m = newMergeTimeoutManager(t, clock, 500*time.Millisecond, ProceedWithPartial)
+	if out, err = m.Handle(&rawData{}); err != nil {
+		t.Fatal(err)
+	}
+	if out.Data.(string) != "fast,slow" {
+		t.Errorf("out=%v, want fast,slow", out.Data)
+	}
+}
+
+// 测试超时后返回ErrMergeTimeout 并列出缺少的分支
+func TestManager_MergeTimeoutFail(t *testing.T) {
+	m := newMergeTimeoutManager(t, newFakeClock(), 2*time.Second, FailOnMergeTimeout)
+	_, err := m.Handle(&rawData{})
+	if !errors.Is(err, ErrMergeTimeout) {
+		t.Fatalf("err=%v, want ErrMergeTimeout", err)
+	}
+	if !strings.Contains(err.Error(), "missing branches [slow]") {
+		t.Errorf("err=%v, want missing branch slow", err)
+	}
+}
+
+// 测试合并节点等待超时后取消还没有结束的分支：block 分支一直等到ctx 被取消，不会自己结束
+func TestManager_MergeTimeoutCancelsBranch(t *testing.T) {
+	for _, traversal := range []Traversal{BFS, DFS} {
+		for _, policy := range []MergeTimeoutPolicy{ProceedWithPartial, FailOnMergeTimeout} {
+			clock := newFakeClock()
+			blocked := make(chan struct{})
+			m := NewManager(WithClock(clock), WithTraversal(traversal))
+			_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+				return []*rawData{{Data: "fast"}, {Data: "block"}}, nil
+			})
+			_ = m.AddWorkerNode("fast", passWorker)
+			_ = m.AddWorkerNode("block", func(ctx context.Context, in *rawData) (*rawData, error) {
+				close(blocked)
+				<-ctx.Done()
+				return nil, ctx.Err()
+			})
+			_ = m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+				var parts []string
+				for _, data := range in {
+					parts = append(parts, data.Data.(string))
+				}
+				return &rawData{Data: strings.Join(parts, ",")}, nil
+			}, WithMergeTimeout(time.Second, policy))
+			if err := m.BuildPipeline([][]string{
+				{Head, "d1"}, {"d1", "fast"}, {"d1", "block"}, {"fast", "m1"}, {"block", "m1"}, {"m1", Tail},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			var out *rawData
+			done := make(chan error, 1)
+			go func() {
+				var err error
+				out, err = m.Handle(&rawData{})
+				done <- err
+			}()
+			<-blocked
+			clock.Advance(time.Second)
+			var err error
+			select {
+			case err = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("traversal %v policy %v: merge timeout did not cancel the blocked branch", traversal, policy)
+			}
+			if policy == ProceedWithPartial && (err != nil || out.Data != "fast") {
+				t.Errorf("traversal %v: out=%v err=%v, want only the fast branch", traversal, out, err)
+			}
+			if policy == FailOnMergeTimeout && (!errors.Is(err, ErrMergeTimeout) || !strings.Contains(err.Error(), "missing branches [block]")) {
+				t.Errorf("traversal %v: err=%v, want ErrMergeTimeout missing block", traversal, err)
+			}
+		}
+	}
+}
+
+// 只用于测试的ctx 类型，在它上面派生可以取消的ctx 时标准库需要启动goroutine 等待它结束
+type opaqueContext struct {
+	context.Context
+	done chan struct{}
+}
+
+func (c opaqueContext) Done() <-chan struct{} {
+	return c.done
+}
+
+// 测试只设置了 WithMergeTimeout 时，执行结束（包括失败）后取消合并等待的ctx 并停止计时器
+func TestManager_MergeTimeoutReleasedOnFinish(t *testing.T) {
+	clock := newFakeClock()
+	errBoom := errors.New("boom")
+	m := NewManager(WithClock(clock))
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{{Data: "fast"}, {Data: "fail"}}, nil
+	})
+	_ = m.AddWorkerNode("fast", passWorker)
+	_ = m.AddWorkerNode("fail", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return nil, errBoom
+	})
+	_ = m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		return in[0], nil
+	}, WithMergeTimeout(time.Second, ProceedWithPartial))
+	if err := m.BuildPipeline([][]string{
+		{Head, "d1"}, {"d1", "fast"}, {"d1", "fail"}, {"fast", "m1"}, {"fail", "m1"}, {"m1", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := opaqueContext{Context: context.Background(), done: make(chan struct{})}
+	defer close(ctx.done)
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		if _, err := m.HandleContext(ctx, &rawData{}); !errors.Is(err, errBoom) {
+			t.Fatalf("err=%v, want the failing branch's error", err)
+		}
+	}
+	if n := clock.pending(); n != 0 {
+		t.Errorf("%d merge timers still pending after the executions finished", n)
+	}
+	// 取消之后等待的goroutine 退出需要一点时间
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before+10 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before+10 {
+		t.Errorf("%d goroutines after 100 executions, %d before", n, before)
+	}
+}
+
+// 构建一个最简单的菱形流程：d1 -> (a, b) -> m1
+func newDiamondManager(tb testing.TB, opts ...Option) *Manager {
+	m := NewManager(opts...)
+	if err := m.AddDividerNode("d1", func(ctx context.Context, in *rawData) (out []*rawData, err error) {
+		return []*rawData{in, {Data: in.Data}}, nil
+	}); err != nil {
+		tb.Fatal(err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if err := m.AddWorkerNode(name, passWorker); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (out *rawData, err error) {
+		return &rawData{Data: len(in)}, nil
+	}); err != nil {
+		tb.Fatal(err)
+	}
+	if err := m.BuildPipeline([][]string{
+		{"head000", "d1"},
+		{"d1", "a"},
+		{"d1", "b"},
+		{"a", "m1"},
+		{"b", "m1"},
+		{"m1", "tail111"},
+	}); err != nil {
+		tb.Fatal(err)
+	}
+	return m
+}
+
+// 测试合并节点入度的检查只在开启运行时断言时才执行
+func TestManager_RuntimeAssertions(t *testing.T) {
+	// 构建之后被改动的入度：不开启断言时按照改动后的入度执行
+	m := newDiamondManager(t)
+	m.nodes["m1"].inEdges = 1
+	out, err := m.Handle(&rawData{Data: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Data.(int) != 1 {
+		t.Errorf("out=%v, want 1", out.Data)
+	}
+
+	m = newDiamondManager(t, WithRuntimeAssertions())
+	if _, err = m.Handle(&rawData{Data: 1}); err != nil {
+		t.Fatal(err)
+	}
+	m.nodes["m1"].inEdges = 1
+	if _, err = m.Handle(&rawData{Data: 1}); err == nil || !strings.Contains(err.Error(), "inEdges=1") {
+		t.Errorf("err=%v, want inEdges assertion", err)
+	}
+}
+
+func BenchmarkHandle_Diamond(b *testing.B) {
+	m := newDiamondManager(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.Handle(&rawData{Data: 1}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// 测试构建后修改Next 会在执行时报ErrTopologyCorrupted
+func TestManager_TopologyCorrupted(t *testing.T) {
+	m := newDiamondManager(t)
+	if _, err := m.Handle(&rawData{Data: 1}); err != nil {
+		t.Fatal(err)
+	}
+	m.nodes["m1"].Next = nil
+	_, err := m.Handle(&rawData{Data: 1})
+	if !errors.Is(err, ErrTopologyCorrupted) || !strings.Contains(err.Error(), `merger "m1"`) {
+		t.Errorf("expected topology error for m1, got %v", err)
+	}
+
+	m = newBudgetJudgerManager(t)
+	m.nodes["budget"].Next = m.nodes["budget"].Next[:1]
+	if _, err = m.Handle(&rawData{}); !errors.Is(err, ErrTopologyCorrupted) {
+		t.Errorf("expected topology error for judger, got %v", err)
+	}
+	// 直线流程走快速路径时同样检查
+	for _, corrupt := range []func(m *Manager){
+		func(m *Manager) { m.nodes["a"].Next = nil },
+		func(m *Manager) { m.nodes["a"].Next = []*Node{m.nodes[Tail]} },
+	} {
+		m, err = Linear(NamedWorker{"a", passWorker}, NamedWorker{"b", passWorker})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.linear == nil {
+			t.Fatal("expected a linear fast path")
+		}
+		corrupt(m)
+		if _, err = m.Handle(&rawData{}); !errors.Is(err, ErrTopologyCorrupted) || !strings.Contains(err.Error(), `worker "a"`) {
+			t.Errorf("expected topology error for a on the fast path, got %v", err)
+		}
+	}
+}
+
+// 测试命名的分支数和出度不一致时构建失败
+func TestManager_BranchCountMismatch(t *testing.T) {
+	m := NewManager()
+	_ = m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) int {
+		return 2
+	}, WithBranches("a", "b", "c"))
+	_ = m.AddWorkerNode("a", passWorker)
+	_ = m.AddWorkerNode("b", passWorker)
+	err := m.BuildPipeline([][]string{
+		{"head000", "j1"},
+		{"j1", "a"},
+		{"j1", "b"},
+		{"a", "tail111"},
+		{"b", "tail111"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "node[j1] declares 3 branches") {
+		t.Errorf("expected branch count error, got %v", err)
+	}
+}
+
+// 分裂节点分出n 个分支，每个分支经过一个工作节点后汇合到同一个合并节点
+func newGatherManager(tb testing.TB, n int, merge MergerFunc) *Manager {
+	m := NewManager()
+	_ = m.AddDividerNode("scatter", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		outs := make([]*rawData, n)
+		for i := range outs {
+			outs[i] = &rawData{Data: i}
+		}
+		return outs, nil
+	})
+	_ = m.AddMergerNode("gather", merge)
+	edges := [][]string{{Head, "scatter"}, {"gather", Tail}}
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("w%d", i)
+		_ = m.AddWorkerNode(name, passWorker)
+		edges = append(edges, []string{"scatter", name}, []string{name, "gather"})
+	}
+	if err := m.BuildPipeline(edges); err != nil {
+		tb.Fatal(err)
+	}
+	return m
+}
+
+// 测试上千路的合并节点收到全部输入，并且按分支的顺序排列
+func TestManager_WideMerger(t *testing.T) {
+	const n = 1000
+	var got []*rawData
+	m := newGatherManager(t, n, func(ctx context.Context, in []*rawData) (*rawData, error) {
+		got = in
+		return &rawData{Data: len(in)}, nil
+	})
+	out, err := m.Handle(&rawData{})
+	if err != nil || out.Data != n {
+		t.Fatalf("out=%v err=%v", out, err)
+	}
+	for i, in := range got {
+		if in.Data != i {
+			t.Fatalf("input %d is from branch %v", i, in.Data)
+		}
+	}
+	if len(got) != cap(got) {
+		t.Errorf("inputs should be preallocated to the in-degree, len=%d cap=%d", len(got), cap(got))
+	}
+}
+
+// 合并节点的输入数从100 增加到1000 时，每次执行的耗时和分配次数都应当线性增长
+func BenchmarkHandle_WideMerger(b *testing.B) {
+	for _, n := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			m := newGatherManager(b, n, func(ctx context.Context, in []*rawData) (*rawData, error) {
+				return in[0], nil
+			})
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := m.Handle(&rawData{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
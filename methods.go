@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	// 方法签名对应的节点类型
+	methodNodeTypes = []struct {
+		typ    NodeTyp
+		action reflect.Type
+	}{
+		{NodeTypWorker, reflect.TypeOf(WorkerFunc(nil))},
+		{NodeTypDivider, reflect.TypeOf(DividerFunc(nil))},
+		{NodeTypDivider, reflect.TypeOf(BranchDividerFunc(nil))},
+		{NodeTypMerger, reflect.TypeOf(MergerFunc(nil))},
+		{NodeTypMerger, reflect.TypeOf(BranchMergerFunc(nil))},
+		{NodeTypJudger, reflect.TypeOf(JudgerFunc(nil))},
+	}
+)
+
+// 把svc 中签名与节点处理方法相同的导出方法批量添加为节点，节点名为prefix 加上首字母小写的方法名
+// 第一个参数是 context.Context 但签名不完全匹配的方法（例如漏写了error 返回值）会被列在返回的错误中，
+// 此时不会添加任何节点
+func (m *Manager) RegisterMethods(prefix string, svc interface{}) error {
+	type method struct {
+		name   string
+		typ    NodeTyp
+		action interface{}
+	}
+	var methods []method
+	var mismatched []string
+	v := reflect.ValueOf(svc)
+	for i := 0; i < v.NumMethod(); i++ {
+		name := v.Type().Method(i).Name
+		mv := v.Method(i)
+		mt := mv.Type()
+		if mt.NumIn() == 0 || mt.In(0) != contextType {
+			continue
+		}
+		matched := false
+		for _, c := range methodNodeTypes {
+			if mt.ConvertibleTo(c.action) {
+				methods = append(methods, method{name: name, typ: c.typ, action: mv.Convert(c.action).Interface()})
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			mismatched = append(mismatched, fmt.Sprintf("%s%s", name, strings.TrimPrefix(mt.String(), "func")))
+		}
+	}
+	if len(mismatched) > 0 {
+		return fmt.Errorf("methods of %T do not match any node signature: %s", svc, strings.Join(mismatched, "; "))
+	}
+	source := fmt.Sprintf("RegisterMethods(%T) at %s", svc, callSite(1))
+	for _, method := range methods {
+		name := prefix + lowerFirst(method.name)
+		if err := m.addNode(name, method.typ, method.action, nil, source); err != nil {
+			return fmt.Errorf("node[%s]: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func lowerFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[n:]
+}
@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type textSvc struct {
+	suffix string
+}
+
+func (s *textSvc) Split(ctx context.Context, in *rawData) ([]*rawData, error) {
+	return []*rawData{in, {Data: in.Data}}, nil
+}
+
+func (s *textSvc) Upper(ctx context.Context, in *rawData) (*rawData, error) {
+	return &rawData{Data: strings.ToUpper(in.Data.(string))}, nil
+}
+
+func (s *textSvc) Join(ctx context.Context, in []*rawData) (*rawData, error) {
+	return &rawData{Data: in[0].Data.(string) + in[1].Data.(string) + s.suffix}, nil
+}
+
+func (s *textSvc) Route(ctx context.Context, in *rawData) int {
+	return 0
+}
+
+// 不是节点的方法
+func (s *textSvc) String() string {
+	return "textSvc"
+}
+
+type brokenSvc struct {
+	textSvc
+}
+
+// 漏写了error 返回值
+func (s *brokenSvc) Trim(ctx context.Context, in *rawData) *rawData {
+	return in
+}
+
+// 测试把结构体的方法批量添加为节点，添加的节点与普通节点一样连接和执行
+func TestManager_RegisterMethods(t *testing.T) {
+	m := NewManager()
+	if err := m.RegisterMethods("text.", &textSvc{suffix: "!"}); err != nil {
+		t.Fatal(err)
+	}
+	for name, typ := range map[string]NodeTyp{
+		"text.route": NodeTypJudger,
+		"text.split": NodeTypDivider,
+		"text.upper": NodeTypWorker,
+		"text.join":  NodeTypMerger,
+	} {
+		if info, ok := m.NodeInfo(name); !ok || info.Typ != typ {
+			t.Errorf("node[%s]: info=%+v ok=%v, want type %s", name, info, ok, typ)
+		}
+	}
+	_ = m.AddWorkerNode("keep", passWorker)
+	_ = m.AddWorkerNode("other", passWorker)
+	if err := m.BuildPipeline([][]string{
+		{Head, "text.route"},
+		{"text.route", "text.split"}, {"text.route", "other"},
+		{"other", Tail},
+		{"text.split", "text.upper"}, {"text.split", "keep"},
+		{"text.upper", "text.join"}, {"keep", "text.join"},
+		{"text.join", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := m.Handle(&rawData{Data: "ab"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Data != "ABab!" {
+		t.Errorf("out=%v, want ABab!", out.Data)
+	}
+}
+
+// 测试签名不匹配的方法会被报告，并且不添加任何节点
+func TestManager_RegisterMethodsMismatch(t *testing.T) {
+	m := NewManager()
+	err := m.RegisterMethods("", &brokenSvc{})
+	if err == nil || !strings.Contains(err.Error(), "Trim(context.Context, *pipeline.rawData) *pipeline.rawData") {
+		t.Fatalf("err=%v, should list the malformed method Trim", err)
+	}
+	if _, ok := m.NodeInfo("upper"); ok {
+		t.Errorf("no node should be added when a method is malformed")
+	}
+}
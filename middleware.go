@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+)
+
+type (
+	// 工作节点处理方法的调用
+	NodeInvoker func(ctx context.Context, in *rawData) (out *rawData, err error)
+	// 判断节点处理方法的调用，中间件可以返回错误，此时节点失败
+	JudgerInvoker func(ctx context.Context, in *rawData) (pipeIndex int, err error)
+	// 合并节点处理方法的调用，BranchMergerFunc 的输入为每份 Contribution 的Data
+	MergerInvoker func(ctx context.Context, in []*rawData) (out *rawData, err error)
+)
+
+// 一组中间件，可以只实现其中一部分，为nil 的类型的节点不经过该中间件
+// 节点的每次调用（包括重试）都经过中间件，node 为被调用的节点的信息
+type MiddlewareSet struct {
+	// 出现在 Lint 的结果中
+	Name   string
+	Worker func(node NodeInfo, next NodeInvoker) NodeInvoker
+	Judger func(node NodeInfo, next JudgerInvoker) JudgerInvoker
+	Merger func(node NodeInfo, next MergerInvoker) MergerInvoker
+}
+
+// 添加中间件，先添加的在外层；分裂节点不经过中间件
+// 图中存在中间件没有实现的节点类型时，构建时记录到 Lint 的结果中
+func WithMiddleware(sets ...MiddlewareSet) Option {
+	return func(m *Manager) {
+		m.middlewares = append(m.middlewares, sets...)
+	}
+}
+
+// 用中间件包装工作节点的处理方法
+func (m *Manager) wrapWorker(node *Node, f NodeInvoker) NodeInvoker {
+	for i := len(m.middlewares) - 1; i >= 0; i-- {
+		if mw := m.middlewares[i].Worker; mw != nil {
+			f = mw(node.info(), f)
+		}
+	}
+	return f
+}
+
+func (m *Manager) wrapJudger(node *Node, f JudgerInvoker) JudgerInvoker {
+	for i := len(m.middlewares) - 1; i >= 0; i-- {
+		if mw := m.middlewares[i].Judger; mw != nil {
+			f = mw(node.info(), f)
+		}
+	}
+	return f
+}
+
+func (m *Manager) wrapMerger(node *Node, f MergerInvoker) MergerInvoker {
+	for i := len(m.middlewares) - 1; i >= 0; i-- {
+		if mw := m.middlewares[i].Merger; mw != nil {
+			f = mw(node.info(), f)
+		}
+	}
+	return f
+}
+
+// 找出中间件没有覆盖的节点类型，每个中间件每种类型记录一次，Node 为该类型的第一个节点
+func (m *Manager) lintMiddlewares(order []*Node) []LintFinding {
+	var findings []LintFinding
+	for _, set := range m.middlewares {
+		covered := map[NodeTyp]bool{
+			NodeTypWorker: set.Worker != nil,
+			NodeTypJudger: set.Judger != nil,
+			NodeTypMerger: set.Merger != nil,
+		}
+		reported := make(map[NodeTyp]bool)
+		for _, node := range order {
+			if c, ok := covered[node.Typ]; !ok || c || reported[node.Typ] {
+				continue
+			}
+			reported[node.Typ] = true
+			findings = append(findings, LintFinding{
+				Node:    node.nodeName,
+				Option:  "WithMiddleware",
+				Message: fmt.Sprintf("middleware[%s] does not cover %s nodes, they are called without it", set.Name, node.Typ),
+			})
+		}
+	}
+	return findings
+}
+
+// 合并节点每份输入的数据
+func contributionData(cs []Contribution) []*rawData {
+	data := make([]*rawData, len(cs))
+	for i, c := range cs {
+		data[i] = c.Data
+	}
+	return data
+}
+
+// 中间件传给下一层的输入替换到 Contribution 中，多出的输入没有分支的执行情况
+func withContributionData(cs []Contribution, in []*rawData) []Contribution {
+	out := make([]Contribution, len(in))
+	for i, data := range in {
+		if i < len(cs) {
+			out[i].Meta = cs[i].Meta
+		}
+		out[i].Data = data
+	}
+	return out
+}
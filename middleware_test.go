@@ -0,0 +1,116 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type authKey struct{}
+
+var errUnauthorized = errors.New("unauthorized")
+
+// 检查ctx 中的身份，没有身份时拒绝调用，并记录经过的节点
+func authMiddleware(calls *[]string) MiddlewareSet {
+	check := func(ctx context.Context, node NodeInfo) error {
+		*calls = append(*calls, node.Name)
+		if ctx.Value(authKey{}) == nil {
+			return errUnauthorized
+		}
+		return nil
+	}
+	return MiddlewareSet{
+		Name: "auth",
+		Worker: func(node NodeInfo, next NodeInvoker) NodeInvoker {
+			return func(ctx context.Context, in *rawData) (*rawData, error) {
+				if err := check(ctx, node); err != nil {
+					return nil, err
+				}
+				return next(ctx, in)
+			}
+		},
+		Judger: func(node NodeInfo, next JudgerInvoker) JudgerInvoker {
+			return func(ctx context.Context, in *rawData) (int, error) {
+				if err := check(ctx, node); err != nil {
+					return -1, err
+				}
+				return next(ctx, in)
+			}
+		},
+		Merger: func(node NodeInfo, next MergerInvoker) MergerInvoker {
+			return func(ctx context.Context, in []*rawData) (*rawData, error) {
+				if err := check(ctx, node); err != nil {
+					return nil, err
+				}
+				return next(ctx, in)
+			}
+		},
+	}
+}
+
+func newMiddlewareManager(t *testing.T, sets ...MiddlewareSet) *Manager {
+	m := NewManager(WithMiddleware(sets...))
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{in, in}, nil
+	})
+	_ = m.AddWorkerNode("a", passWorker)
+	_ = m.AddWorkerNode("b", passWorker)
+	_ = m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		return &rawData{Data: len(in)}, nil
+	})
+	_ = m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) int { return 0 })
+	_ = m.AddWorkerNode("c", passWorker)
+	if err := m.BuildPipeline([][]string{
+		{Head, "d1"}, {"d1", "a"}, {"d1", "b"}, {"a", "m1"}, {"b", "m1"}, {"m1", "j1"}, {"j1", "c"}, {"j1", Tail}, {"c", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// 测试实现了全部类型的中间件包装工作、判断、合并节点，分裂节点不经过中间件
+func TestManager_Middleware(t *testing.T) {
+	var calls []string
+	m := newMiddlewareManager(t, authMiddleware(&calls))
+	ctx := context.WithValue(context.Background(), authKey{}, "alice")
+	out, err := m.HandleContext(ctx, &rawData{})
+	if err != nil || out.Data != 2 {
+		t.Fatalf("out=%v err=%v", out, err)
+	}
+	if want := []string{"a", "b", "m1", "j1", "c"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("middleware saw %v, want %v", calls, want)
+	}
+	if len(m.Lint()) != 0 {
+		t.Errorf("want no lint findings, got %v", m.Lint())
+	}
+
+	// 判断节点同样被拦截
+	calls = nil
+	auth := authMiddleware(&calls)
+	auth.Worker, auth.Merger = nil, nil
+	m = newMiddlewareManager(t, auth)
+	var nodeErr *NodeError
+	if _, err := m.HandleContext(context.Background(), &rawData{}); !errors.Is(err, errUnauthorized) || !errors.As(err, &nodeErr) || nodeErr.Node != "j1" {
+		t.Errorf("want judger j1 to be rejected, got %v", err)
+	}
+}
+
+// 测试只实现了工作节点的中间件在 Lint 中提示没有覆盖的节点类型
+func TestManager_MiddlewareLint(t *testing.T) {
+	var calls []string
+	auth := authMiddleware(&calls)
+	auth.Judger, auth.Merger = nil, nil
+	m := newMiddlewareManager(t, auth)
+	var got []string
+	for _, f := range m.Lint() {
+		got = append(got, f.String())
+	}
+	want := []string{
+		"node[m1] WithMiddleware: middleware[auth] does not cover merger nodes, they are called without it",
+		"node[j1] WithMiddleware: middleware[auth] does not cover judger nodes, they are called without it",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lint %v, want %v", got, want)
+	}
+}
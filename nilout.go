@@ -0,0 +1,29 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 节点的输出为nil，并且下一个节点不是虚拟尾节点，见 WithAllowNilData
+var ErrNilNodeOutput = errors.New("node output is nil")
+
+// 允许节点输出nil 并传给下一个节点，用于有意用nil 表示“没有数据”的流水线
+// 默认情况下工作节点、合并节点返回nil，或者分裂节点的某个分支为nil 时，只要下一个节点不是虚拟尾节点，执行就返回 ErrNilNodeOutput
+func WithAllowNilData() Option {
+	return func(m *Manager) {
+		m.allowNilData = true
+	}
+}
+
+// 检查节点第i 个分支的输出，nil 只能直接交给虚拟尾节点
+func (e *execution) checkOutput(node *Node, i int, out *rawData) error {
+	if out != nil || e.m.allowNilData || node == e.stopAt || i >= len(node.Next) {
+		return nil
+	}
+	next := node.Next[i]
+	if next == nil || next.Typ == NodeTypTail {
+		return nil
+	}
+	return runtimeError(node, ErrNilNodeOutput, "output is nil", fmt.Sprintf("next node[%s]", next.nodeName))
+}
@@ -0,0 +1,111 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// head -> w1 -> d1 -> (a, b) -> m1 -> w2 -> tail，nilAt 中的节点输出nil
+func newNilOutputManager(t *testing.T, nilAt string, opts ...Option) *Manager {
+	m := NewManager(opts...)
+	worker := func(name string) WorkerFunc {
+		return func(ctx context.Context, in *rawData) (*rawData, error) {
+			if name == nilAt {
+				return nil, nil
+			}
+			return in, nil
+		}
+	}
+	for _, name := range []string{"w1", "a", "b", "w2"} {
+		if err := m.AddWorkerNode(name, worker(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		if nilAt == "d1" {
+			return []*rawData{in, nil}, nil
+		}
+		return []*rawData{in, in}, nil
+	})
+	_ = m.AddMergerNode("m1", func(ctx context.Context, ins []*rawData) (*rawData, error) {
+		if nilAt == "m1" {
+			return nil, nil
+		}
+		return ins[0], nil
+	})
+	if err := m.BuildPipeline([][]string{
+		{Head, "w1"}, {"w1", "d1"}, {"d1", "a"}, {"d1", "b"}, {"a", "m1"}, {"b", "m1"}, {"m1", "w2"}, {"w2", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// 默认情况下，节点输出的nil 不能传给下一个节点，错误中带有产生nil 的节点
+func TestManager_NilOutputRejected(t *testing.T) {
+	for _, name := range []string{"w1", "d1", "a", "m1"} {
+		m := newNilOutputManager(t, name)
+		_, err := m.Handle(&rawData{Data: 1})
+		if !errors.Is(err, ErrNilNodeOutput) {
+			t.Fatalf("%s: want ErrNilNodeOutput, got %v", name, err)
+		}
+		var ne *NodeError
+		if !errors.As(err, &ne) || ne.Node != name {
+			t.Errorf("%s: want NodeError for the producing node, got %v", name, err)
+		}
+	}
+
+	// 直线流程的快速路径
+	for _, general := range []bool{false, true} {
+		m := NewManager()
+		_ = m.AddWorkerNode("w1", func(ctx context.Context, in *rawData) (*rawData, error) { return nil, nil })
+		_ = m.AddWorkerNode("w2", passWorker)
+		if err := m.BuildPipeline([][]string{{Head, "w1"}, {"w1", "w2"}, {"w2", Tail}}); err != nil {
+			t.Fatal(err)
+		}
+		m.disableFastPath = general
+		_, err := m.Handle(&rawData{Data: 1})
+		var ne *NodeError
+		if !errors.Is(err, ErrNilNodeOutput) || !errors.As(err, &ne) || ne.Node != "w1" {
+			t.Errorf("general=%v: want ErrNilNodeOutput from w1, got %v", general, err)
+		}
+	}
+}
+
+// 直接传给虚拟尾节点的nil 作为执行结果返回
+func TestManager_NilOutputToTail(t *testing.T) {
+	m := newNilOutputManager(t, "w2")
+	out, err := m.Handle(&rawData{Data: 1})
+	if err != nil || out != nil {
+		t.Errorf("want nil result, got %v %v", out, err)
+	}
+}
+
+// WithAllowNilData 时nil 可以一直传到虚拟尾节点，判断节点也会收到nil
+func TestManager_AllowNilData(t *testing.T) {
+	for _, name := range []string{"w1", "d1", "a", "m1"} {
+		m := newNilOutputManager(t, name, WithAllowNilData())
+		if _, err := m.Handle(&rawData{Data: 1}); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	m := NewManager(WithAllowNilData(), WithEnv(Env{}))
+	_ = m.AddWorkerNode("nil", func(ctx context.Context, in *rawData) (*rawData, error) { return nil, nil })
+	_ = m.AddJudgerNode("route", func(ctx context.Context, in *rawData) int { return 0 })
+	_ = m.AddWorkerNode("keep", passWorker)
+	_ = m.AddWorkerNode("other", passWorker)
+	if err := m.BuildPipeline([][]string{
+		{Head, "nil"}, {"nil", "route"}, {"route", "keep"}, {"route", "other"}, {"keep", Tail}, {"other", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := m.Handle(&rawData{Data: 1})
+	if err != nil || out != nil {
+		t.Errorf("want nil result, got %v %v", out, err)
+	}
+	if got := m.Capabilities().Options; len(got) != 2 || got[0] != "WithAllowNilData" {
+		t.Errorf("want WithAllowNilData in options, got %v", got)
+	}
+}
@@ -2,6 +2,8 @@ package pipeline
 
 import (
 	"context"
+	"strconv"
+	"strings"
 )
 
 type (
@@ -9,17 +11,22 @@ type (
 	//WorkerFunc interface {
 	//    Process(ctx context.Context, in *rawData) (out *rawData, err error)
 	//}
+	// 返回之后节点不能再持有或修改out，out 可以是原地修改后的in
 	WorkerFunc func(ctx context.Context, in *rawData) (out *rawData, err error)
 	// 划分节点的处理方法
 	//DividerFunc interface {
 	//    Divide(ctx context.Context, in *rawData) (out []*rawData, err error)
 	//}
 	DividerFunc func(ctx context.Context, in *rawData) (out []*rawData, err error)
+	// 带分支ctx 的划分节点的处理方法
+	BranchDividerFunc func(ctx context.Context, in *rawData) (out []BranchOutput, err error)
 	// 合并节点的处理方法
 	//MergerFunc interface {
 	//    Merge(ctx context.Context, in []*rawData) (out *rawData, err error)
 	//}
 	MergerFunc func(ctx context.Context, in []*rawData) (out *rawData, err error)
+	// 带分支执行情况的合并节点的处理方法，见 BranchMeta
+	BranchMergerFunc func(ctx context.Context, in []Contribution) (out *rawData, err error)
 	// 判断节点的处理方法
 	//JudgerFunc interface {
 	//    Judge(ctx context.Context, in *rawData) (pipeIndex int)
@@ -35,6 +42,13 @@ const (
 	HandlerStatusTimeout HandlerStatus = 2
 )
 
+// 划分节点的一个分支的输出
+type BranchOutput struct {
+	Data *rawData
+	// 基于分裂之前的ctx 生成该分支使用的ctx，为nil 时沿用原来的ctx
+	Ctx func(ctx context.Context) context.Context
+}
+
 // 节点之间传递的数据
 type rawData struct {
 	Status HandlerStatus
@@ -42,6 +56,9 @@ type rawData struct {
 	Meta   map[string]interface{}
 }
 
+// rawData 的别名，其他包（例如 pipelinetest、nodes）通过它构建和处理节点之间传递的数据
+type Data = rawData
+
 type NodeTyp string
 
 const (
@@ -59,5 +76,147 @@ type (
 		nodeName string
 		actionId string
 		Next     []*Node
+		// 与Next 一一对应的出边
+		out  []*edge
+		opts nodeOptions
+		// 合并节点的入度，以及每个前驱节点的输入在合并方法的输入中的位置，构建时计算
+		inEdges   int
+		predSlots map[*Node][]int
+		// 构建时的出度，执行时用于发现构建后被修改的Next
+		outEdges int
+		// 从配置加载时引用的处理方法名
+		actionName string
+		// 节点的来源，见 addNode
+		source string
+		// 所属的阶段，见 DefineStage
+		stage string
+		// 工作节点的其他版本，见 AddWorkerVariant
+		variants []workerVariant
+		// 分裂节点每个分支的超时，见 SetBranchTimeout
+		branchTimeouts []*branchTimeout
+		// 分裂节点对应的设置了 WithMergeTimeout 的合并节点，构建时计算
+		mergeWait *Node
+		// 以该节点开始或结束的临界区，见 DefineCriticalSection
+		section *criticalSection
+		// 计算输入大小的方法以及超过上限时转去的节点，见 WithMaxInputSize，构建时计算
+		sizer         func(*rawData) int
+		oversizeRoute *Node
+		// 应用Manager 的默认值之后生效的配置，构建时计算
+		effective nodeOptions
+		// 构建时生成的节点信息，见 NodeInfo
+		snapshot *NodeInfo
+		// 判断节点每个分支被选择的次数，见 NodeRuntimeState
+		decisionCounts []int64
+		// 是否已经提醒过返回了自己的输入，见 checkAliasing
+		aliasWarned int32
 	}
 )
+
+// 第i 个分支的名字，没有命名时为分支的索引
+func (n *Node) branchName(i int) string {
+	if i < len(n.opts.branches) {
+		return n.opts.branches[i]
+	}
+	return strconv.Itoa(i)
+}
+
+// 所有分支的列表，形如 [0:fast 1:slow]
+func (n *Node) branchList() string {
+	names := make([]string, len(n.Next))
+	for i := range n.Next {
+		names[i] = strconv.Itoa(i)
+		if i < len(n.opts.branches) {
+			names[i] += ":" + n.opts.branches[i]
+		}
+	}
+	return "[" + strings.Join(names, " ") + "]"
+}
+
+// 节点的只读信息，构建时为每个节点生成一次
+// 交给调用方的都是副本，修改其中的字段（包括切片的元素）不影响流水线以及其他调用方
+type NodeInfo struct {
+	Name  string
+	Typ   NodeTyp
+	Stage string
+	// 分裂节点、判断节点的分支名，见 WithBranches
+	Branches []string
+	// 见 WithDescription、WithOwner
+	Description string
+	Owner       string
+	// 节点设置的配置，格式与 NodeExport.Options 相同
+	Options []string
+	// 应用 WithDefaultNodeTimeout 等默认值之后生效的配置，格式与 NodeExport.Options 相同，构建之前为空
+	EffectiveOptions []string
+	// 节点的来源：配置文件或添加节点的代码位置
+	Source string
+}
+
+// 返回节点的信息
+func (m *Manager) NodeInfo(name string) (NodeInfo, bool) {
+	node, ok := m.nodes[name]
+	if !ok {
+		return NodeInfo{}, false
+	}
+	return node.info(), true
+}
+
+// 节点信息的副本，构建之前按当前的配置生成
+func (n *Node) info() NodeInfo {
+	if n.snapshot != nil {
+		return n.snapshot.clone()
+	}
+	return n.newInfo(false)
+}
+
+func (n *Node) newInfo(built bool) NodeInfo {
+	info := NodeInfo{
+		Name:        n.nodeName,
+		Typ:         n.Typ,
+		Stage:       n.stage,
+		Branches:    copyStrings(n.opts.branches),
+		Description: n.opts.description,
+		Owner:       n.opts.owner,
+		Options:     n.opts.summary(),
+		Source:      n.source,
+	}
+	if built {
+		info.EffectiveOptions = n.effective.summary()
+	}
+	return info
+}
+
+func (i NodeInfo) clone() NodeInfo {
+	i.Branches = copyStrings(i.Branches)
+	i.Options = copyStrings(i.Options)
+	i.EffectiveOptions = copyStrings(i.EffectiveOptions)
+	return i
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(s)), s...)
+}
+
+// 构建时生成每个节点的信息
+func (m *Manager) snapshotNodeInfo() {
+	for _, node := range m.nodes {
+		info := node.newInfo(true)
+		node.snapshot = &info
+	}
+}
+
+// 节点失败的错误，err 已经是该节点的 runtimeError 时原样返回
+func newNodeError(node *Node, err error) *NodeError {
+	if ne, ok := err.(*NodeError); ok && ne.Problem != "" && ne.Node == node.nodeName {
+		return ne
+	}
+	return &NodeError{Node: node.nodeName, Typ: node.Typ, Info: node.info(), Err: err}
+}
+
+// 执行时由流水线发现的节点的问题，执行中所有这类错误都由这里构造，保证格式一致
+// err 为哨兵错误或原因，用 errors.Is 判断；details 为空时不输出括号
+func runtimeError(node *Node, err error, problem, details string) *NodeError {
+	return &NodeError{Node: node.nodeName, Typ: node.Typ, Info: node.info(), Problem: problem, Details: details, Err: err}
+}
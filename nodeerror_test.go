@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// 测试工作、分裂、合并节点处理方法返回的错误包装成 NodeError，带上出错的节点，并且可以取出原来的错误
+func TestManager_NodeError(t *testing.T) {
+	errBoom := errors.New("boom")
+	cases := []struct {
+		fail string
+		typ  NodeTyp
+	}{
+		{"d1", NodeTypDivider},
+		{"a", NodeTypWorker},
+		{"m1", NodeTypMerger},
+	}
+	for _, c := range cases {
+		fail := c.fail
+		m := NewManager()
+		_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+			if fail == "d1" {
+				return nil, errBoom
+			}
+			return []*rawData{in, in}, nil
+		})
+		_ = m.AddWorkerNode("a", func(ctx context.Context, in *rawData) (*rawData, error) {
+			if fail == "a" {
+				return nil, errBoom
+			}
+			return in, nil
+		})
+		_ = m.AddWorkerNode("b", passWorker)
+		_ = m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+			if fail == "m1" {
+				return nil, errBoom
+			}
+			return in[0], nil
+		})
+		if err := m.BuildPipeline([][]string{
+			{"head000", "d1"}, {"d1", "a"}, {"d1", "b"}, {"a", "m1"}, {"b", "m1"}, {"m1", "tail111"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := m.Handle(&rawData{Data: 1})
+		var ne *NodeError
+		if !errors.As(err, &ne) || ne.Node != c.fail || ne.Typ != c.typ {
+			t.Errorf("%s: err=%v, want NodeError of the failing node", c.fail, err)
+			continue
+		}
+		if !errors.Is(err, errBoom) || err.Error() != "boom" {
+			t.Errorf("%s: err=%q, want it to wrap the action error", c.fail, err)
+		}
+	}
+}
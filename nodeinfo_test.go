@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试修改交出的 NodeInfo 不影响之后的执行以及其他调用方
+func TestManager_NodeInfoImmutable(t *testing.T) {
+	var events []NodeEvent
+	m := NewManager(WithListener(func(ev NodeEvent) { events = append(events, ev) }))
+	_ = m.AddJudgerNode("j1", routeJudger, WithBranches("small", "large"), WithDescription("route"))
+	_ = m.AddWorkerNode("small", passWorker, WithTimeout(time.Second))
+	_ = m.AddWorkerNode("large", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return nil, errors.New("boom")
+	}, WithRetry(1))
+	if err := m.BuildPipeline([][]string{{Head, "j1"}, {"j1", "small"}, {"j1", "large"}, {"small", Tail}, {"large", Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := m.NodeInfo("j1")
+	if !reflect.DeepEqual(want.Branches, []string{"small", "large"}) || len(want.Options) == 0 ||
+		!strings.Contains(want.Source, "nodeinfo_test.go:") {
+		t.Fatalf("unexpected info %+v", want)
+	}
+	info, _ := m.NodeInfo("j1")
+	info.Name = "changed"
+	info.Branches[0], info.Options[0] = "changed", "changed"
+
+	trace := &Trace{}
+	if _, err := m.HandleContext(context.Background(), &rawData{}, WithTrace(trace)); err != nil {
+		t.Fatal(err)
+	}
+	trace.Entries()[0].Info.Branches[1] = "changed"
+	events[0].Info.Branches[1] = "changed"
+	if got := trace.Entries()[0].Info; !reflect.DeepEqual(got, want) {
+		t.Errorf("trace entry info changed by another caller: %+v", got)
+	}
+
+	// 修改过的信息不影响之后的执行
+	events = nil
+	_, err := m.HandleContext(context.Background(), &rawData{}, WithForcedDecisions(Decisions{"j1": 1}))
+	var nodeErr *NodeError
+	if !errors.As(err, &nodeErr) || nodeErr.Info.Name != "large" || !strings.Contains(strings.Join(nodeErr.Info.Options, " "), "retry=1") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	nodeErr.Info.Options[0] = "changed"
+	if got := events[0].Info; !reflect.DeepEqual(got, want) {
+		t.Errorf("event info changed: %+v", got)
+	}
+	if got, _ := m.NodeInfo("large"); got.Options[0] == "changed" {
+		t.Errorf("node info changed through NodeError: %+v", got)
+	}
+	if got, _ := m.NodeInfo("j1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("node info changed: %+v", got)
+	}
+}
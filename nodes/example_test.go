@@ -0,0 +1,43 @@
+package nodes_test
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/caigoumiao/pipeline"
+	"github.com/caigoumiao/pipeline/nodes"
+)
+
+// 使用常用节点组成流水线：解析JSON 字段、校验、按字段路由
+func ExampleRouteByKey() {
+	m := pipeline.NewManager()
+	_ = m.AddWorkerNode("extract", nodes.ExtractField("order.amount"))
+	_ = m.AddWorkerNode("validate", nodes.Validate(func(in *pipeline.Data) error {
+		if amount, ok := in.Data.(float64); !ok || amount <= 0 {
+			return fmt.Errorf("amount %v should be positive", in.Data)
+		}
+		return nil
+	}))
+	_ = m.AddWorkerNode("tag", nodes.SetField("tier", "standard"))
+	_ = m.AddJudgerNode("route", nodes.RouteByKey("tier", map[string]int{"standard": 0, "vip": 1}, 0),
+		pipeline.WithBranches("standard", "vip"))
+	_ = m.AddWorkerNode("standard", nodes.Static("queued"))
+	_ = m.AddWorkerNode("vip", nodes.Static("expedited"))
+	if err := m.Connect().
+		From(pipeline.Head).To("extract").To("validate").To("tag").To("route").To("standard").To(pipeline.Tail).
+		From("route").To("vip").To(pipeline.Tail).Build(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, order := range []string{`{"order": {"amount": 12.5}}`, `{"order": {"amount": -1}}`} {
+		out, err := m.Handle(&pipeline.Data{Data: order})
+		if err != nil {
+			fmt.Println(errors.Is(err, nodes.ErrInvalidPayload))
+			continue
+		}
+		fmt.Println(out.Data, out.Meta["tier"])
+	}
+	// Output:
+	// queued standard
+	// true
+}
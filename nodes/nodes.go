@@ -0,0 +1,155 @@
+// nodes 提供常用的节点处理方法，可以直接用于 Manager.AddWorkerNode、Manager.AddJudgerNode
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/caigoumiao/pipeline"
+)
+
+var (
+	// Validate 的校验没有通过
+	ErrInvalidPayload = errors.New("invalid payload")
+	// ExtractField 取不到字段
+	ErrFieldNotFound = errors.New("field not found")
+)
+
+// 用pred 校验输入，通过时输入原样传给下一个节点，否则返回包装了ErrInvalidPayload 的错误
+func Validate(pred func(in *pipeline.Data) error) pipeline.WorkerFunc {
+	return func(ctx context.Context, in *pipeline.Data) (*pipeline.Data, error) {
+		if err := pred(in); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+		}
+		return in, nil
+	}
+}
+
+// 等待d 之后输入原样传给下一个节点，用于测试；使用 pipeline.EnvFrom(ctx) 的时钟，ctx 结束时立即返回
+func Delay(d time.Duration) pipeline.WorkerFunc {
+	return func(ctx context.Context, in *pipeline.Data) (*pipeline.Data, error) {
+		select {
+		case <-pipeline.EnvFrom(ctx).Clock.After(d):
+			return in, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// 在输入的Meta 中设置key，输出为输入的浅拷贝，不修改输入
+func SetField(key string, v interface{}) pipeline.WorkerFunc {
+	return func(ctx context.Context, in *pipeline.Data) (*pipeline.Data, error) {
+		out := clone(in)
+		if out == nil {
+			out = &pipeline.Data{}
+		}
+		if out.Meta == nil {
+			out.Meta = make(map[string]interface{}, 1)
+		}
+		out.Meta[key] = v
+		return out, nil
+	}
+}
+
+// 输出的Data 为v，Status、Meta 与输入相同
+func Static(v interface{}) pipeline.WorkerFunc {
+	return func(ctx context.Context, in *pipeline.Data) (*pipeline.Data, error) {
+		out := clone(in)
+		if out == nil {
+			out = &pipeline.Data{}
+		}
+		out.Data = v
+		return out, nil
+	}
+}
+
+// 从JSON 对象中取出path 对应的字段作为输出的Data，path 用. 分隔多级字段，例如 "user.id"
+// 输入的Data 可以是 []byte、string 或者已经解析好的 map[string]interface{}；输入为nil 或者取不到字段时返回包装了ErrFieldNotFound 的错误
+func ExtractField(path string) pipeline.WorkerFunc {
+	keys := strings.Split(path, ".")
+	return func(ctx context.Context, in *pipeline.Data) (*pipeline.Data, error) {
+		if in == nil {
+			return nil, fmt.Errorf("%w: %s", ErrFieldNotFound, path)
+		}
+		var v interface{}
+		switch data := in.Data.(type) {
+		case []byte:
+			if err := json.Unmarshal(data, &v); err != nil {
+				return nil, err
+			}
+		case string:
+			if err := json.Unmarshal([]byte(data), &v); err != nil {
+				return nil, err
+			}
+		default:
+			v = data
+		}
+		for _, key := range keys {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrFieldNotFound, path)
+			}
+			if v, ok = obj[key]; !ok {
+				return nil, fmt.Errorf("%w: %s", ErrFieldNotFound, path)
+			}
+		}
+		out := clone(in)
+		out.Data = v
+		return out, nil
+	}
+}
+
+// 将输入写入日志，输入原样传给下一个节点；logger 为nil 时使用 pipeline.EnvFrom(ctx) 的日志
+// 日志中是原始数据，不经过 pipeline.WithPayloadRedactor 设置的脱敏方法
+func LogPayload(logger pipeline.Logger) pipeline.WorkerFunc {
+	return func(ctx context.Context, in *pipeline.Data) (*pipeline.Data, error) {
+		l := logger
+		if l == nil {
+			l = pipeline.EnvFrom(ctx).Logger
+		}
+		if in == nil {
+			l.Printf("payload: <nil>")
+		} else {
+			l.Printf("payload: status=%d data=%v meta=%v", in.Status, in.Data, in.Meta)
+		}
+		return in, nil
+	}
+}
+
+// 按输入Meta 中key 的值选择分支，值用 fmt.Sprint 转换为字符串后在branches 中查找，
+// 没有该字段或者找不到时选择fallback
+func RouteByKey(key string, branches map[string]int, fallback int) pipeline.JudgerFunc {
+	return func(ctx context.Context, in *pipeline.Data) int {
+		if in == nil || in.Meta == nil {
+			return fallback
+		}
+		v, ok := in.Meta[key]
+		if !ok {
+			return fallback
+		}
+		if i, ok := branches[fmt.Sprint(v)]; ok {
+			return i
+		}
+		return fallback
+	}
+}
+
+// 数据的浅拷贝，Meta 复制一份，修改输出的Meta 不影响输入
+func clone(d *pipeline.Data) *pipeline.Data {
+	if d == nil {
+		return nil
+	}
+	c := *d
+	if d.Meta != nil {
+		c.Meta = make(map[string]interface{}, len(d.Meta))
+		for k, v := range d.Meta {
+			c.Meta[k] = v
+		}
+	}
+	return &c
+}
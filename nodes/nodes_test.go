@@ -0,0 +1,212 @@
+package nodes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caigoumiao/pipeline"
+)
+
+func TestValidate(t *testing.T) {
+	w := Validate(func(in *pipeline.Data) error {
+		if _, ok := in.Data.(int); !ok {
+			return errors.New("want int")
+		}
+		return nil
+	})
+	in := &pipeline.Data{Data: 1}
+	if out, err := w(context.Background(), in); err != nil || out != in {
+		t.Errorf("valid input: %v %v", out, err)
+	}
+	if _, err := w(context.Background(), &pipeline.Data{Data: "a"}); !errors.Is(err, ErrInvalidPayload) || !strings.Contains(err.Error(), "want int") {
+		t.Errorf("want ErrInvalidPayload, got %v", err)
+	}
+}
+
+// 只在调用 advance 时前进的时钟，只支持 After
+type stepClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []chan time.Time
+}
+
+func (c *stepClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *stepClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, ch)
+	return ch
+}
+
+func (c *stepClock) AfterFunc(d time.Duration, f func()) pipeline.Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (c *stepClock) pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// 时间前进d，所有等待都到期
+func (c *stepClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, ch := range c.waiters {
+		ch <- c.now
+	}
+	c.waiters = nil
+}
+
+func TestDelay(t *testing.T) {
+	clock := &stepClock{}
+	m := pipeline.NewManager(pipeline.WithClock(clock), pipeline.WithEnv(pipeline.Env{}))
+	_ = m.AddWorkerNode("delay", Delay(time.Minute))
+	if err := m.BuildPipeline([][]string{{pipeline.Head, "delay"}, {"delay", pipeline.Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Handle(&pipeline.Data{Data: 1})
+		done <- err
+	}()
+	for clock.pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("returned before the clock advanced: %v", err)
+	default:
+	}
+	clock.advance(time.Minute)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Delay(time.Hour)(ctx, &pipeline.Data{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+}
+
+func TestSetField(t *testing.T) {
+	in := &pipeline.Data{Data: 1, Meta: map[string]interface{}{"a": 1}}
+	out, err := SetField("b", 2)(context.Background(), in)
+	if err != nil || out.Meta["a"] != 1 || out.Meta["b"] != 2 || out.Data != 1 {
+		t.Fatalf("unexpected output %+v %v", out, err)
+	}
+	if _, ok := in.Meta["b"]; ok {
+		t.Error("input should not be modified")
+	}
+	if out, _ = SetField("b", 2)(context.Background(), &pipeline.Data{}); out.Meta["b"] != 2 {
+		t.Errorf("unexpected output %+v", out)
+	}
+}
+
+func TestStatic(t *testing.T) {
+	in := &pipeline.Data{Data: 1, Status: pipeline.HandlerStatusError, Meta: map[string]interface{}{"a": 1}}
+	out, err := Static("v")(context.Background(), in)
+	if err != nil || out.Data != "v" || out.Status != pipeline.HandlerStatusError || out.Meta["a"] != 1 || in.Data != 1 {
+		t.Errorf("unexpected output %+v %v", out, err)
+	}
+}
+
+func TestExtractField(t *testing.T) {
+	w := ExtractField("user.id")
+	for _, data := range []interface{}{
+		`{"user": {"id": "u1"}}`,
+		[]byte(`{"user": {"id": "u1"}}`),
+		map[string]interface{}{"user": map[string]interface{}{"id": "u1"}},
+	} {
+		if out, err := w(context.Background(), &pipeline.Data{Data: data}); err != nil || out.Data != "u1" {
+			t.Errorf("%T: unexpected output %v %v", data, out, err)
+		}
+	}
+	for _, data := range []interface{}{`{"user": {}}`, `{"user": 1}`, 1} {
+		if _, err := w(context.Background(), &pipeline.Data{Data: data}); !errors.Is(err, ErrFieldNotFound) {
+			t.Errorf("%v: want ErrFieldNotFound, got %v", data, err)
+		}
+	}
+	if _, err := w(context.Background(), &pipeline.Data{Data: "{"}); err == nil {
+		t.Error("want a JSON error")
+	}
+}
+
+func TestLogPayload(t *testing.T) {
+	var buf bytes.Buffer
+	in := &pipeline.Data{Data: "hello"}
+	out, err := LogPayload(log.New(&buf, "", 0))(context.Background(), in)
+	if err != nil || out != in || !strings.Contains(buf.String(), "data=hello") {
+		t.Errorf("unexpected result %v %v %q", out, err, buf.String())
+	}
+	// 没有指定logger 时使用Env 中的日志
+	buf.Reset()
+	m := pipeline.NewManager()
+	_ = m.AddWorkerNode("log", LogPayload(nil))
+	if err = m.BuildPipeline([][]string{{pipeline.Head, "log"}, {"log", pipeline.Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.HandleContext(context.Background(), in, pipeline.WithCallEnv(pipeline.Env{Logger: log.New(&buf, "", 0)}))
+	if err != nil || !strings.Contains(buf.String(), "data=hello") {
+		t.Errorf("unexpected result %v %q", err, buf.String())
+	}
+}
+
+func TestRouteByKey(t *testing.T) {
+	j := RouteByKey("region", map[string]int{"eu": 1, "us": 2}, 0)
+	for _, c := range []struct {
+		in   *pipeline.Data
+		want int
+	}{
+		{&pipeline.Data{Meta: map[string]interface{}{"region": "eu"}}, 1},
+		{&pipeline.Data{Meta: map[string]interface{}{"region": "us"}}, 2},
+		{&pipeline.Data{Meta: map[string]interface{}{"region": "apac"}}, 0},
+		{&pipeline.Data{}, 0},
+	} {
+		if got := j(context.Background(), c.in); got != c.want {
+			t.Errorf("%v: want %d, got %d", c.in.Meta, c.want, got)
+		}
+	}
+}
+
+// 测试开启 WithAllowNilData 时各个节点都能处理nil 输入
+func TestNilInput(t *testing.T) {
+	m := pipeline.NewManager(pipeline.WithAllowNilData(), pipeline.WithEnv(pipeline.Env{}))
+	_ = m.AddWorkerNode("nil", func(ctx context.Context, in *pipeline.Data) (*pipeline.Data, error) { return nil, nil })
+	_ = m.AddWorkerNode("log", LogPayload(nil))
+	_ = m.AddJudgerNode("route", RouteByKey("k", map[string]int{"x": 1}, 0))
+	_ = m.AddWorkerNode("keep", func(ctx context.Context, in *pipeline.Data) (*pipeline.Data, error) { return in, nil })
+	_ = m.AddWorkerNode("extract", ExtractField("a"))
+	if err := m.BuildPipeline([][]string{
+		{pipeline.Head, "nil"}, {"nil", "log"}, {"log", "route"}, {"route", "keep"}, {"route", "extract"},
+		{"keep", pipeline.Tail}, {"extract", pipeline.Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := m.Handle(&pipeline.Data{Data: 1})
+	if err != nil || out != nil {
+		t.Errorf("want nil result, got %v %v", out, err)
+	}
+	if _, err := ExtractField("a")(context.Background(), nil); !errors.Is(err, ErrFieldNotFound) {
+		t.Errorf("want ErrFieldNotFound, got %v", err)
+	}
+	for name, w := range map[string]pipeline.WorkerFunc{"set": SetField("k", 1), "static": Static(1)} {
+		if out, err := w(context.Background(), nil); err != nil || out == nil {
+			t.Errorf("%s: want a new output for nil input, got %v %v", name, out, err)
+		}
+	}
+}
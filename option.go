@@ -0,0 +1,183 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Manager 的可选配置
+type Option func(m *Manager)
+
+// 限制从头节点到尾节点的最长路径上的节点数（不含虚拟头、尾节点）
+// 超过限制时BuildPipeline 返回ErrorsPipelineTooDeep
+func WithMaxDepth(n int) Option {
+	return func(m *Manager) {
+		m.maxDepth = n
+	}
+}
+
+// 执行时再次检查构建时已经校验过的结构（例如合并节点的入度），用于调试
+func WithRuntimeAssertions() Option {
+	return func(m *Manager) {
+		m.runtimeAssertions = true
+	}
+}
+
+// 工作节点链每执行k 个节点调用一次runtime.Gosched 并检查ctx 是否已经结束，
+// 避免很长的工作节点链长时间占用调度且迟迟发现不了取消；0 表示不让出，默认为64
+func WithYieldEvery(k int) Option {
+	return func(m *Manager) {
+		m.yieldEvery = k
+	}
+}
+
+// 节点的可选配置
+type NodeOption func(o *nodeOptions)
+
+type nodeOptions struct {
+	// 分裂节点、判断节点每个分支的名字，按edges 中的顺序对应
+	branches []string
+	// 合并节点等待输入的超时
+	mergeTimeout *mergeTimeout
+	// 执行一次的估算成本
+	cost float64
+	// 失败重试的配置
+	retry *retryOptions
+	// 剩余时间少于该值时跳过节点
+	skipIfRemaining time.Duration
+	// 单次执行的超时，以及是否不使用默认超时
+	timeout   time.Duration
+	noTimeout bool
+	// 输入大小的上限，见 WithMaxInputSize
+	maxInputSize *maxInputSize
+	// 判断节点的默认分支，见 WithDefaultBranch
+	defaultBranch *defaultBranch
+	// 节点的说明和负责人，不参与指纹的计算
+	description, owner string
+	// 合并节点的输入按到达的顺序排列，见 WithArrivalOrder
+	arrivalOrder bool
+	// 直接调用处理方法，见 WithBareExecution
+	bare bool
+	// 成功执行之后保存检查点，见 WithCheckpoint
+	checkpoint bool
+	// 使用过的配置，构建时检查是否适用于节点类型
+	used []optionUse
+}
+
+// 节点配置的摘要，每一项形如 "key=value"，按key 排序，未设置的配置不出现
+func (o *nodeOptions) summary() []string {
+	var s []string
+	if len(o.branches) > 0 {
+		s = append(s, fmt.Sprintf("branches=%s", strings.Join(o.branches, ",")))
+	}
+	if o.bare {
+		s = append(s, "bare=true")
+	}
+	if o.checkpoint {
+		s = append(s, "checkpoint=true")
+	}
+	if o.cost != 0 {
+		s = append(s, fmt.Sprintf("cost=%g", o.cost))
+	}
+	if d := o.defaultBranch; d != nil {
+		if d.anyNegative {
+			s = append(s, fmt.Sprintf("default_branch=%d/any_negative", d.index))
+		} else {
+			s = append(s, fmt.Sprintf("default_branch=%d", d.index))
+		}
+	}
+	if o.mergeTimeout != nil {
+		policy := "proceed"
+		if o.mergeTimeout.policy == FailOnMergeTimeout {
+			policy = "fail"
+		}
+		s = append(s, fmt.Sprintf("merge_timeout=%v/%s", o.mergeTimeout.d, policy))
+	}
+	if r := o.retry; r != nil {
+		s = append(s, fmt.Sprintf("retry=%d", r.attempts))
+		if r.backoff != nil {
+			s = append(s, fmt.Sprintf("retry_backoff=%T%v", r.backoff, r.backoff))
+		}
+		if r.maxBackoff > 0 {
+			s = append(s, fmt.Sprintf("retry_max_backoff=%v", r.maxBackoff))
+		}
+	}
+	if l := o.maxInputSize; l != nil {
+		policy := "fail"
+		if l.policy.route != "" {
+			policy = "route:" + l.policy.route
+		}
+		s = append(s, fmt.Sprintf("max_input_size=%d/%s", l.limit, policy))
+	}
+	if o.arrivalOrder {
+		s = append(s, "arrival_order=true")
+	}
+	if o.skipIfRemaining > 0 {
+		s = append(s, fmt.Sprintf("skip_if_remaining=%v", o.skipIfRemaining))
+	}
+	if o.timeout > 0 {
+		s = append(s, fmt.Sprintf("timeout=%v", o.timeout))
+	} else if o.noTimeout {
+		s = append(s, "timeout=none")
+	}
+	sort.Strings(s)
+	return s
+}
+
+// 给分裂节点、判断节点的分支命名，名字按edges 中的顺序依次对应每个分支
+// 分支名会出现在报错、导出的图以及执行轨迹中
+func WithBranches(names ...string) NodeOption {
+	return func(o *nodeOptions) {
+		o.branches = names
+		o.use("WithBranches", NodeTypDivider, NodeTypJudger)
+	}
+}
+
+// 节点执行一次的估算成本（例如API 调用次数、GPU 秒数），用于 EstimateCost
+func WithCost(c float64) NodeOption {
+	return func(o *nodeOptions) {
+		o.cost = c
+	}
+}
+
+// 标记工作节点为可选：执行前ctx 剩余的时间少于lt 时跳过该节点，输入原样传给下一个节点
+// 只能用于工作节点，用在其他节点上时BuildPipeline 报错
+func WithSkipIfRemaining(lt time.Duration) NodeOption {
+	return func(o *nodeOptions) {
+		o.skipIfRemaining = lt
+	}
+}
+
+// 单次执行的可选配置
+type CallOption func(o *callOptions)
+
+type callOptions struct {
+	trace   *Trace
+	lineage bool
+	forced  Decisions
+	retry   *pipelineRetry
+	// 失败时不保存死信
+	noDeadLetter bool
+	env          *Env
+	seed         *int64
+	// 预热的执行，见 Warmup
+	warmup *warmupRun
+	// 不受采样限制，见 WithForceTrace
+	forceTrace bool
+	// 节点开始执行时的回调，见 WithCallStartListener
+	onStart func(node *Node)
+	// 功能开关，见 WithFlags
+	flags map[string]bool
+	// 重新执行时原来的执行，以及这条数据第几次执行，见 WithParentExecution
+	parent  string
+	attempt int
+}
+
+// 将本次执行的轨迹记录到t 中
+func WithTrace(t *Trace) CallOption {
+	return func(o *callOptions) {
+		o.trace = t
+	}
+}
@@ -0,0 +1,180 @@
+package pipeline
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 并行执行：队列中输入已经到达的节点交给执行槽位并发执行，而不是在执行的goroutine 中依次执行，
+// 分裂节点之后的各个分支（包括分支上的工作节点链、嵌套的分裂和合并）同时执行，同时执行的节点数不超过GOMAXPROCS；
+// 设置了 WithRunner 时节点在 Runner 上执行，Runner 拒绝执行时执行失败，错误为 Runner 返回的错误
+// 合并节点的输入仍然按入边的顺序排列，结果与依次执行时一致；WithArrivalOrder 的顺序、监听事件和执行轨迹的顺序
+// 取决于节点实际完成的顺序，WithTraversal 不起作用。一个节点失败或者执行到末尾之后不再调度新的节点，并取消还在执行的节点
+// 执行的状态由一把锁保护，只在调用节点的处理方法（包括中间件、重试的等待和获取临界区）期间释放，
+// 监听者、Releasable 的回调等仍然依次调用
+func WithStageParallelism() Option {
+	return func(m *Manager) {
+		m.parallelism = runtime.GOMAXPROCS(0)
+	}
+}
+
+// 一次并行执行的调度状态
+type parallelRun struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	// 等待调度的节点以及合并节点的状态，由执行的锁保护
+	queue   []*nodeDataWrapper
+	mergers map[*Node]*mergerState
+	// 正在执行的节点数
+	running int
+	// 释放了锁、正在调用处理方法的节点，同一个节点可能同时处理多份输入
+	inflight map[*Node]int
+	// 执行到末尾或者失败之后不再调度新的节点，current 为结束时持有锁的节点，
+	// busy 为失败时所有正在执行的节点，用于 CancelledError
+	stopped bool
+	out     *rawData
+	err     error
+	current *Node
+	busy    []string
+	cancel  context.CancelFunc
+	// 节点执行时的panic，所有节点结束后在执行的goroutine 中重新抛出
+	panicked interface{}
+}
+
+func (p *parallelRun) stop(out *rawData, err error, current *Node) {
+	if p.stopped {
+		return
+	}
+	p.stopped, p.out, p.err, p.current = true, out, err, current
+	if err != nil {
+		if current != nil {
+			p.busy = append(p.busy, current.nodeName)
+		}
+		for node := range p.inflight {
+			if node != nil && node != current {
+				p.busy = append(p.busy, node.nodeName)
+			}
+		}
+		sort.Strings(p.busy)
+	}
+	p.cancel()
+}
+
+// 并行执行队列中的节点，节点的后继加入同一个队列，见 WithStageParallelism
+// 节点在其他goroutine 中执行，使用执行在堆上的副本，结束后复制回e；
+// 直接使用e 会让依次执行时的e 也分配在堆上
+func (e *execution) runParallel(queue []*nodeDataWrapper) *parallelRun {
+	pe := new(execution)
+	*pe = *e
+	p := &parallelRun{queue: queue, mergers: make(map[*Node]*mergerState), inflight: make(map[*Node]int)}
+	defer func() {
+		pe.ctx, pe.par = e.ctx, nil
+		*e = *pe
+	}()
+	pe.schedule(p)
+	return p
+}
+
+// 调度队列中的节点直到执行结束，所有调度出去的节点都结束后返回
+func (e *execution) schedule(p *parallelRun) {
+	m := e.m
+	e.ctx, p.cancel = context.WithCancel(e.ctx)
+	defer p.cancel()
+	for _, nw := range p.queue {
+		nw.ctx = e.ctx
+	}
+	p.cond = sync.NewCond(&p.mu)
+	g := m.newRunnerGroup(m.parallelism)
+	e.par = p
+	p.mu.Lock()
+	for {
+		for !p.stopped && len(p.queue) > 0 && p.running < m.branchLimit() {
+			var nw *nodeDataWrapper
+			nw, p.queue = m.popNode(p.queue)
+			p.running++
+			// Runner 可能阻塞或者等待重试，启动期间释放锁；running 小于槽位数，
+			// 等待槽位的只是刚结束的节点释放槽位
+			p.mu.Unlock()
+			_ = g.acquire(context.Background())
+			err := g.run(e.ctx, func() { e.runTask(p, nw) })
+			p.mu.Lock()
+			if err != nil {
+				p.running--
+				e.drop(nw.in)
+				p.stop(nil, err, nil)
+			}
+		}
+		if p.running == 0 && (p.stopped || len(p.queue) == 0) {
+			break
+		}
+		p.cond.Wait()
+	}
+	e.par = nil
+	e.current = p.current
+	p.mu.Unlock()
+	if p.panicked != nil {
+		panic(p.panicked)
+	}
+	if !p.stopped {
+		p.err = ErrorsCannotReachTail
+	}
+}
+
+// 在执行槽位上执行队列中的一项，执行期间持有执行的锁
+func (e *execution) runTask(p *parallelRun, nw *nodeDataWrapper) {
+	if a := e.m.adaptiveBranches; a != nil {
+		a.start()
+		start := e.m.clock.Now()
+		defer func() {
+			a.release(e.m.clock.Now().Sub(start))
+		}()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			p.running--
+			if p.panicked == nil {
+				p.panicked = r
+			}
+			p.stop(nil, nil, e.current)
+			p.cond.Signal()
+		}
+	}()
+	if p.stopped {
+		// 调度之后执行已经结束
+		e.drop(nw.in)
+	} else if out, done, err := e.step(nw, &p.queue, p.mergers); done || err != nil {
+		p.stop(out, err, e.current)
+	}
+	p.running--
+	p.cond.Signal()
+}
+
+// 并行执行时每个节点自己的调用状态，释放锁期间可能被其他节点改写，重新加锁后恢复
+type callRegs struct {
+	current *Node
+	wait    time.Duration
+}
+
+// 调用节点的处理方法，并行执行时调用期间释放执行的锁
+func (e *execution) call(ctx context.Context, f func(ctx context.Context) error) error {
+	p := e.par
+	if p == nil {
+		return f(ctx)
+	}
+	regs := callRegs{current: e.current, wait: e.wait}
+	p.inflight[regs.current]++
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		if p.inflight[regs.current]--; p.inflight[regs.current] == 0 {
+			delete(p.inflight, regs.current)
+		}
+		e.current, e.wait = regs.current, regs.wait
+	}()
+	return f(ctx)
+}
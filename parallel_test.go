@@ -0,0 +1,233 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 串联diamonds 个菱形，每个菱形的分裂节点dK 分出width 个分支wK_i，在合并节点mK 按入边的顺序拼接各分支的结果
+// 每个分支的工作节点在输入后加上自己的名字
+func newMultiDiamondManager(tb testing.TB, diamonds, width int, work func(name string), opts ...Option) *Manager {
+	m := NewManager(opts...)
+	edges := [][]string{}
+	prev := Head
+	for k := 0; k < diamonds; k++ {
+		d, mg := fmt.Sprintf("d%d", k), fmt.Sprintf("m%d", k)
+		_ = m.AddDividerNode(d, func(ctx context.Context, in *rawData) ([]*rawData, error) {
+			outs := make([]*rawData, width)
+			for i := range outs {
+				outs[i] = &rawData{Data: in.Data}
+			}
+			return outs, nil
+		})
+		_ = m.AddMergerNode(mg, func(ctx context.Context, in []*rawData) (*rawData, error) {
+			parts := make([]string, len(in))
+			for i, data := range in {
+				parts[i] = data.Data.(string)
+			}
+			return &rawData{Data: "[" + strings.Join(parts, ",") + "]"}, nil
+		})
+		edges = append(edges, []string{prev, d})
+		for i := 0; i < width; i++ {
+			name := fmt.Sprintf("w%d_%d", k, i)
+			_ = m.AddWorkerNode(name, func(ctx context.Context, in *rawData) (*rawData, error) {
+				if work != nil {
+					work(name)
+				}
+				return &rawData{Data: in.Data.(string) + "/" + name}, nil
+			})
+			edges = append(edges, []string{d, name}, []string{name, mg})
+		}
+		prev = mg
+	}
+	edges = append(edges, []string{prev, Tail})
+	if err := m.BuildPipeline(edges); err != nil {
+		tb.Fatal(err)
+	}
+	return m
+}
+
+// 两个节点都开始执行之后才能继续的屏障，超时说明节点没有同时执行
+type rendezvous struct {
+	mu      sync.Mutex
+	arrived map[string]chan struct{}
+}
+
+func (r *rendezvous) wait(name, other string) error {
+	r.mu.Lock()
+	if r.arrived == nil {
+		r.arrived = make(map[string]chan struct{})
+	}
+	for _, n := range []string{name, other} {
+		if r.arrived[n] == nil {
+			r.arrived[n] = make(chan struct{})
+		}
+	}
+	close(r.arrived[name])
+	ch := r.arrived[other]
+	r.mu.Unlock()
+	select {
+	case <-ch:
+		return nil
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("%s did not run together with %s", name, other)
+	}
+}
+
+// 测试并行执行时各个分支（包括分支上的工作节点链和合并之后的下一个菱形）同时执行
+func TestManager_StageParallelism(t *testing.T) {
+	var r rendezvous
+	m := NewManager(WithStageParallelism())
+	m.parallelism = 4
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{in, in}, nil
+	})
+	pair := map[string]string{"a2": "b", "b": "a2", "c": "d", "d": "c"}
+	for _, name := range []string{"a1", "a2", "b", "c", "d"} {
+		name := name
+		_ = m.AddWorkerNode(name, func(ctx context.Context, in *rawData) (*rawData, error) {
+			if other, ok := pair[name]; ok {
+				if err := r.wait(name, other); err != nil {
+					return nil, err
+				}
+			}
+			return in, nil
+		})
+	}
+	_ = m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		return in[0], nil
+	})
+	_ = m.AddDividerNode("d2", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{in, in}, nil
+	})
+	_ = m.AddMergerNode("m2", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		return &rawData{Data: len(in)}, nil
+	})
+	if err := m.BuildPipeline([][]string{
+		{Head, "d1"}, {"d1", "a1"}, {"a1", "a2"}, {"d1", "b"}, {"a2", "m1"}, {"b", "m1"},
+		{"m1", "d2"}, {"d2", "c"}, {"d2", "d"}, {"c", "m2"}, {"d", "m2"}, {"m2", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	trace := &Trace{}
+	out, err := m.HandleContext(context.Background(), &rawData{Data: 1}, WithTrace(trace))
+	if err != nil || out.Data != 2 {
+		t.Fatalf("out=%v err=%v", out, err)
+	}
+	if n := len(trace.Entries()); n != 9 {
+		t.Errorf("trace has %d entries, want all 9 nodes", n)
+	}
+	if options := fmt.Sprint(m.Capabilities().Options); !strings.Contains(options, "WithStageParallelism") {
+		t.Errorf("capabilities %v, want WithStageParallelism", options)
+	}
+}
+
+// 测试并行执行的结果与依次执行（广度优先、深度优先）一致：合并节点的输入按入边的顺序排列，与完成的顺序无关
+func TestManager_StageParallelismDeterminism(t *testing.T) {
+	want, err := newMultiDiamondManager(t, 3, 5, nil).Handle(&rawData{Data: "in"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dfs, err := newMultiDiamondManager(t, 3, 5, nil, WithTraversal(DFS)).Handle(&rawData{Data: "in"})
+	if err != nil || dfs.Data != want.Data {
+		t.Fatalf("dfs out=%v err=%v, want %v", dfs, err, want.Data)
+	}
+	var mu sync.Mutex
+	rnd := rand.New(rand.NewSource(1))
+	jitter := func(string) {
+		mu.Lock()
+		d := time.Duration(rnd.Intn(200)) * time.Microsecond
+		mu.Unlock()
+		time.Sleep(d)
+	}
+	m := newMultiDiamondManager(t, 3, 5, jitter, WithStageParallelism())
+	m.parallelism = 4
+	for i := 0; i < 20; i++ {
+		out, err := m.Handle(&rawData{Data: "in"})
+		if err != nil || out.Data != want.Data {
+			t.Fatalf("run %d: out=%v err=%v, want %v", i, out, err, want.Data)
+		}
+	}
+}
+
+// 测试并行执行时一个节点失败后取消还在执行的节点，并返回该节点的错误；节点的panic 在调用方重新抛出
+func TestManager_StageParallelismFailure(t *testing.T) {
+	errBoom := errors.New("boom")
+	r := &countingRunner{}
+	for _, fail := range []string{"error", "panic"} {
+		fail := fail
+		m := NewManager(WithStageParallelism(), WithRunner(r))
+		m.parallelism = 2
+		_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+			return []*rawData{in, in}, nil
+		})
+		_ = m.AddWorkerNode("block", func(ctx context.Context, in *rawData) (*rawData, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		_ = m.AddWorkerNode("fail", func(ctx context.Context, in *rawData) (*rawData, error) {
+			if fail == "panic" {
+				panic(errBoom)
+			}
+			return nil, errBoom
+		})
+		_ = m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+			return in[0], nil
+		})
+		if err := m.BuildPipeline([][]string{
+			{Head, "d1"}, {"d1", "block"}, {"d1", "fail"}, {"block", "m1"}, {"fail", "m1"}, {"m1", Tail},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		var recovered interface{}
+		func() {
+			defer func() { recovered = recover() }()
+			_, err = m.Handle(&rawData{})
+		}()
+		var ne *NodeError
+		if fail == "error" && (!errors.As(err, &ne) || ne.Node != "fail" || !errors.Is(err, errBoom)) {
+			t.Errorf("err=%v, want the failing node's error", err)
+		}
+		if fail == "panic" && recovered != errBoom {
+			t.Errorf("recovered %v, want the node's panic", recovered)
+		}
+	}
+	if atomic.LoadInt64(&r.calls) == 0 {
+		t.Errorf("nodes did not run on the runner")
+	}
+}
+
+// 4 个串联的8 路菱形，每个分支模拟一次50µs 的调用，比较依次执行和并行执行
+func BenchmarkHandle_MultiDiamond(b *testing.B) {
+	sleep := func(string) { time.Sleep(50 * time.Microsecond) }
+	for _, c := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sequential", nil},
+		{"parallel", []Option{WithStageParallelism()}},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			m := newMultiDiamondManager(b, 4, 8, sleep, c.opts...)
+			if m.parallelism > 0 {
+				m.parallelism = 8
+			}
+			in := &rawData{Data: "in"}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := m.Handle(in); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -4,13 +4,123 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
+	"runtime"
+	"strings"
+	"time"
 )
 
 type Manager struct {
-	nodes          map[string]*Node
+	nodes map[string]*Node
+	// 虚拟头、尾节点在nodes 中的名字，见 virtualName
+	headName, tailName string
+	// 声明的边，只作为输入保留；构建之后读取 edgeList
 	edges          [][]string
+	edgeList       []*edge
 	actionMap      map[string]interface{}
 	inEdgeOfMerger map[string]int
+	// 构建成功后置为true
+	built bool
+	// 最长路径的节点数上限，0 表示不限制
+	maxDepth int
+	// 构建时计算出的最长路径
+	depth     int
+	depthPath []string
+	// 匿名节点的序号，用于生成节点名
+	anonSeq int
+	health  healthState
+	// 同时执行的流水线数量限制
+	inflight inflightLimiter
+	// 纯工作节点组成的直线流程，构建时预先编译，执行时不需要队列
+	linear *linearChain
+	// 仅用于测试：强制走通用的执行流程
+	disableFastPath bool
+	// 每个合并节点的前驱节点，按edges 中的顺序
+	predsOfMerger map[*Node][]*Node
+	clock         Clock
+	rand          *lockedRand
+	finalizer     Finalizer
+	// 选择工作节点版本的方法
+	variantSelector VariantSelector
+	// 工作节点链每执行多少个节点让出一次调度
+	yieldEvery int
+	// 节点失败后继续执行的错误处理子图
+	errorHandler *errorHandler
+	softDeadline *softDeadline
+	// 已经开始的执行数，用于生成执行的标识
+	execSeq uint64
+	// 阶段名 -> 阶段内的节点，以及每个阶段的累计耗时上限
+	stages        map[string][]string
+	stageTimeouts map[string]time.Duration
+	listener      Listener
+	// 交给观察者之前对数据脱敏
+	redactor PayloadRedactor
+	// 内部错误时写入诊断信息，见 WithDiagnostics
+	diagnostics *diagnostics
+	// 通用执行流程的遍历顺序
+	traversal Traversal
+	// 保存执行失败的数据
+	deadLetters Store
+	// 保存节点边界上的检查点，见 WithSnapshotStore
+	snapshots Store
+	// 节点处理方法使用的外部依赖
+	env *Env
+	// 调试用：打乱合并节点输入的顺序
+	mergerShuffle *lockedRand
+	// 分裂节点分支的超时
+	branchTimeouts []branchTimeoutConfig
+	// 在节点中嵌套调用的深度上限
+	maxRecursion int
+	// 执行时再次检查构建时已经校验过的结构
+	runtimeAssertions bool
+	// 不允许使用不带ctx 的 Handle，以及检查处理方法是否忽略了ctx 的截止时间
+	requireContext     bool
+	deadlineAssertions bool
+	// 最近一次构建发现的问题，以及是否把问题当作构建错误
+	lintFindings  []LintFinding
+	strictOptions bool
+	// 每次执行使用独立的随机数来源
+	executionRand bool
+	// 向节点的ctx 中注入执行的标识、节点信息等，见 WithContextValues
+	contextValues bool
+	// 最近一次加载的配置的版本
+	configVersion string
+	// 最近执行的摘要，见 WithExecutionHistory
+	history *executionHistory
+	// 跨节点持有锁的临界区
+	sections []*criticalSection
+	// 批量、流式执行的自适应并发数
+	adaptive *adaptiveLimiter
+	// 并行执行时节点的自适应并发数，见 WithAdaptiveConcurrency
+	adaptiveBranches *adaptiveLimiter
+	// 节点配置的默认值
+	defaults nodeDefaults
+	// 没有说明的节点记录到 Lint 的结果中
+	strictDocs bool
+	// 轨迹和记录的采样比例，nil 表示全部采样
+	sampling *samplingRates
+	// 允许节点输出nil，见 WithAllowNilData
+	allowNilData bool
+	// 正在执行的流水线，以及是否已经关闭，见 Close
+	closing closeState
+	// 有 BranchMergerFunc 类型的合并节点，执行时记录分支的执行情况
+	branchMeta bool
+	// 节点开始执行时的回调，见 WithStartListener
+	nodeStart func(node *Node)
+	// 复制节点数据的方法，见 WithCloner
+	cloner func(in *rawData) *rawData
+	// 执行耗时的目标以及违反时的回调，见 WithSLO
+	slo            *sloTracker
+	onSLOViolation func(v SLOViolation)
+	// 包装节点调用的中间件，见 WithMiddleware
+	middlewares []MiddlewareSet
+	// 执行的存活检查，见 WithStallDetection
+	stall *stallPolicy
+	// 执行批量、流式数据的goroutine 池，见 WithRunner
+	runner      Runner
+	runnerRetry time.Duration
+	// 并行执行时同时执行的节点数，为0 时依次执行，见 WithStageParallelism
+	parallelism int
 }
 
 var (
@@ -21,173 +131,405 @@ var (
 	ErrorsCannotReachTail        = errors.New("pipeline cannot reach tail")
 	ErrorsHeadNodeNotUnique      = errors.New("headNode number is not 1")
 	ErrorsTailNodeNotUnique      = errors.New("tailNode number is not 1")
+	ErrorsPipelineNotBuilt       = errors.New("pipeline is not built")
+	ErrorsPipelineHasCycle       = errors.New("pipeline has cycle")
+	ErrorsPipelineTooDeep        = errors.New("pipeline is deeper than max depth")
+	// 没有从虚拟头节点出发的边，或者没有到达虚拟尾节点的边
+	ErrorsHeadEdgeMissing = errors.New("edges have no head edge")
+	ErrorsTailEdgeMissing = errors.New("edges have no tail edge")
+	// 构建之后节点的Next 被修改
+	ErrTopologyCorrupted = errors.New("pipeline topology corrupted after build")
+	// 没有添加任何节点，或者没有传入任何边
+	// 两者都包装了 ErrorsNodesOrEdgesEmpty，errors.Is 仍然成立；ErrorsNodesOrEdgesEmpty 已废弃，不再直接返回
+	ErrorsNoNodesRegistered error = &emptyError{"no nodes registered"}
+	ErrorsNoEdgesProvided   error = &emptyError{"no edges provided"}
+	// 所有的边都没有用到已添加的节点，通常是节点名写错或者少了前缀
+	ErrorsEdgesMatchNoNodes = errors.New("no edge references a registered node")
 )
 
-func NewManager() *Manager {
-	return &Manager{
+// 包装 ErrorsNodesOrEdgesEmpty 的错误，兼容原来的判断
+type emptyError struct {
+	msg string
+}
+
+func (e *emptyError) Error() string {
+	return e.msg
+}
+
+func (e *emptyError) Unwrap() error {
+	return ErrorsNodesOrEdgesEmpty
+}
+
+func NewManager(opts ...Option) *Manager {
+	m := &Manager{
 		nodes:          make(map[string]*Node),
 		edges:          nil,
 		actionMap:      make(map[string]interface{}),
 		inEdgeOfMerger: make(map[string]int),
+		predsOfMerger:  make(map[*Node][]*Node),
+		clock:          realClock{},
+		rand:           newLockedRand(rand.NewSource(time.Now().UnixNano())),
+		yieldEvery:     defaultYieldEvery,
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
+	return m
 }
 
 // 添加一个工作节点
-func (m *Manager) AddWorkerNode(name string, f func(ctx context.Context, in *rawData) (out *rawData, err error)) error {
-	if _, ok := m.nodes[name]; ok {
-		return ErrorsNodeNameDuplicate
-	}
-	actionId := fmt.Sprintf("worker-%d", len(m.actionMap)+1)
-	m.actionMap[actionId] = WorkerFunc(f)
-	m.nodes[name] = &Node{
-		Typ:      NodeTypWorker,
-		actionId: actionId,
-		nodeName: name,
-	}
-	return nil
+func (m *Manager) AddWorkerNode(name string, f func(ctx context.Context, in *rawData) (out *rawData, err error), opts ...NodeOption) error {
+	return m.addNode(name, NodeTypWorker, WorkerFunc(f), opts, callSite(1))
 }
 
 // 添加一个分裂节点
-func (m *Manager) AddDividerNode(name string, f func(ctx context.Context, in *rawData) (out []*rawData, err error)) error {
-	if _, ok := m.nodes[name]; ok {
-		return ErrorsNodeNameDuplicate
-	}
-	actionId := fmt.Sprintf("divider-%d", len(m.actionMap)+1)
-	m.actionMap[actionId] = DividerFunc(f)
-	m.nodes[name] = &Node{
-		Typ:      NodeTypDivider,
-		actionId: actionId,
-		nodeName: name,
-	}
-	return nil
+func (m *Manager) AddDividerNode(name string, f func(ctx context.Context, in *rawData) (out []*rawData, err error), opts ...NodeOption) error {
+	return m.addNode(name, NodeTypDivider, DividerFunc(f), opts, callSite(1))
+}
+
+// 添加一个分裂节点，每个分支除了数据以外还可以带上只对该分支可见的ctx
+func (m *Manager) AddBranchDividerNode(name string, f func(ctx context.Context, in *rawData) (out []BranchOutput, err error), opts ...NodeOption) error {
+	return m.addNode(name, NodeTypDivider, BranchDividerFunc(f), opts, callSite(1))
 }
 
 // 添加一个合并节点
-func (m *Manager) AddMergerNode(name string, f func(ctx context.Context, in []*rawData) (out *rawData, err error)) error {
-	if _, ok := m.nodes[name]; ok {
-		return ErrorsNodeNameDuplicate
-	}
-	actionId := fmt.Sprintf("merger-%d", len(m.actionMap)+1)
-	m.actionMap[actionId] = MergerFunc(f)
-	m.nodes[name] = &Node{
-		Typ:      NodeTypMerger,
-		nodeName: name,
-		actionId: actionId,
-	}
-	return nil
+// f 的输入按合并节点入边在edges 中的顺序排列，与各分支完成的先后无关，in[i] 来自第i 条入边；
+// 等待超时等原因缺少的输入不占位置，其余输入保持这个顺序；WithArrivalOrder 改为按到达的顺序
+func (m *Manager) AddMergerNode(name string, f func(ctx context.Context, in []*rawData) (out *rawData, err error), opts ...NodeOption) error {
+	return m.addNode(name, NodeTypMerger, MergerFunc(f), opts, callSite(1))
 }
 
 // 添加一个判断节点
-func (m *Manager) AddJudgerNode(name string, f func(ctx context.Context, in *rawData) (pipeIndex int)) error {
-	if _, ok := m.nodes[name]; ok {
-		return ErrorsNodeNameDuplicate
-	}
-	actionId := fmt.Sprintf("judger-%d", len(m.actionMap)+1)
-	m.actionMap[actionId] = JudgerFunc(f)
-	m.nodes[name] = &Node{
-		Typ:      NodeTypJudger,
+func (m *Manager) AddJudgerNode(name string, f func(ctx context.Context, in *rawData) (pipeIndex int), opts ...NodeOption) error {
+	return m.addNode(name, NodeTypJudger, JudgerFunc(f), opts, callSite(1))
+}
+
+// source 为节点的来源（配置文件或添加节点的代码位置），节点重名时出现在报错中
+func (m *Manager) addNode(name string, typ NodeTyp, action interface{}, opts []NodeOption, source string) error {
+	if prev, ok := m.nodes[name]; ok {
+		return invalidNode(CodeDuplicateNode, name, fmt.Errorf("%w: node[%s] from %s, already added from %s", ErrorsNodeNameDuplicate, name, source, prev.source))
+	}
+	if name == headNodeName || name == tailNodeName {
+		return invalidNode(CodeReservedName, name, fmt.Errorf("node name[%s] is reserved for the virtual head or tail", name))
+	}
+	actionId := fmt.Sprintf("%s-%d", typ, len(m.actionMap)+1)
+	m.actionMap[actionId] = action
+	if _, ok := action.(BranchMergerFunc); ok {
+		m.branchMeta = true
+	}
+	node := &Node{
+		Typ:      typ,
 		nodeName: name,
 		actionId: actionId,
+		source:   source,
 	}
+	for _, opt := range opts {
+		opt(&node.opts)
+	}
+	m.nodes[name] = node
 	return nil
 }
 
+// 调用者的代码位置，形如 /path/to/file.go:42，skip 为0 时是调用callSite 的位置
+func callSite(skip int) string {
+	_, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
 const (
+	// 边中虚拟头、尾节点的名字
+	Head = "head"
+	Tail = "tail"
+	// 原来的内部名字，不能用作节点名，在边中总是表示虚拟头、尾节点
 	headNodeName = "head000"
 	tailNodeName = "tail111"
 )
 
+// 虚拟头、尾节点的其他写法，用户没有添加同名的节点时表示虚拟头、尾节点
+var virtualNodeAliases = map[string]NodeTyp{
+	Head:       NodeTypHead,
+	"__head__": NodeTypHead,
+	Tail:       NodeTypTail,
+	"__tail__": NodeTypTail,
+}
+
+// 虚拟节点在nodes 中的名字：用户添加了名为name 的节点时（别名出现之前已有的流水线）使用原来的内部名字
+func (m *Manager) virtualName(name, internal string) string {
+	if node, ok := m.nodes[name]; ok && node.Typ != NodeTypHead && node.Typ != NodeTypTail {
+		return internal
+	}
+	return name
+}
+
+// 将边中虚拟头、尾节点的各种写法转换为它们在nodes 中的名字，返回新的边；
+// 与用户添加的节点同名的别名仍然表示用户的节点
+func (m *Manager) normalizeEdges(e [][]string) [][]string {
+	m.headName, m.tailName = m.virtualName(Head, headNodeName), m.virtualName(Tail, tailNodeName)
+	edges := make([][]string, len(e))
+	for i, edge := range e {
+		edges[i] = make([]string, len(edge))
+		for j, name := range edge {
+			typ, alias := virtualNodeAliases[name]
+			if alias {
+				if node, ok := m.nodes[name]; ok && node.Typ != NodeTypHead && node.Typ != NodeTypTail {
+					typ = ""
+				}
+			}
+			switch {
+			case name == headNodeName || typ == NodeTypHead:
+				name = m.headName
+			case name == tailNodeName || typ == NodeTypTail:
+				name = m.tailName
+			}
+			edges[i][j] = name
+		}
+	}
+	return edges
+}
+
 func (m *Manager) BuildPipeline(e [][]string) (err error) {
-	m.edges = e
+	return m.buildPipeline(e, nil, callSite(1))
+}
+
+// 按声明的边构建流水线，sources 为每条边的声明位置，没有时使用source
+func (m *Manager) buildPipeline(e [][]string, sources []string, source string) (err error) {
+	defer func() {
+		m.health.setBuildErr(err)
+	}()
+	m.edges = m.normalizeEdges(e)
+	if m.edgeList, err = newEdges(m.edges, sources, source); err != nil {
+		return
+	}
 	if err = m.connectNodes(); err != nil {
 		return
 	}
 	if err = m.validate(); err != nil {
 		return
 	}
+	if err = m.calLongestPath(); err != nil {
+		return
+	}
+	if err = m.validateJudgerTailBranches(); err != nil {
+		return
+	}
+	if err = m.validateStages(); err != nil {
+		return invalid(CodeBadStage, err)
+	}
+	if err = m.validateErrorHandler(); err != nil {
+		return invalidNode(CodeBadErrorHandler, m.errorHandler.entry, err)
+	}
+	if err = m.validateInputSizes(); err != nil {
+		return
+	}
+	if err = m.validateDefaultBranches(); err != nil {
+		return
+	}
+	if err = m.validateBranchTimeouts(); err != nil {
+		return invalid(CodeBadBranchTimeout, err)
+	}
+	if err = m.validateCriticalSections(); err != nil {
+		return invalid(CodeBadCriticalSection, err)
+	}
+	if err = m.validateBareNodes(); err != nil {
+		return
+	}
+	if err = m.validateCheckpoints(); err != nil {
+		return
+	}
 	m.calInEdgeOfMerger()
+	m.resolveNodeOptions()
+	m.snapshotNodeInfo()
+	m.initRuntimeState()
+	for _, node := range m.nodes {
+		node.outEdges = len(node.Next)
+	}
+	m.compileLinearChain()
+	m.built = true
 	return
 }
 
 // 将节点连成链表
 func (m *Manager) connectNodes() error {
-	if len(m.edges) == 0 || len(m.nodes) == 0 {
-		return ErrorsNodesOrEdgesEmpty
+	if err := m.checkEmpty(); err != nil {
+		return err
 	}
-	// 添加虚拟头、尾节点
-	m.nodes[headNodeName] = &Node{
+	// 添加虚拟头、尾节点，先去掉上一次构建时添加的
+	for name, node := range m.nodes {
+		if node.Typ == NodeTypHead || node.Typ == NodeTypTail {
+			delete(m.nodes, name)
+		}
+	}
+	m.nodes[m.headName] = &Node{
 		Typ:      NodeTypHead,
-		nodeName: headNodeName,
+		nodeName: m.headName,
 	}
-	m.nodes[tailNodeName] = &Node{
+	m.nodes[m.tailName] = &Node{
 		Typ:      NodeTypTail,
-		nodeName: tailNodeName,
+		nodeName: m.tailName,
 	}
 	// 尝试连接节点
-	for _, edge := range m.edges {
-		frontNode := m.nodes[edge[0]]
+	for _, edge := range m.edgeList {
+		frontNode := m.nodes[edge.from]
 		if frontNode == nil || frontNode.Typ == NodeTypTail {
 			continue
 		}
-		forwardNode := m.nodes[edge[1]]
+		forwardNode := m.nodes[edge.to]
 		if forwardNode == nil {
 			continue
 		}
 		frontNode.Next = append(frontNode.Next, forwardNode)
+		frontNode.out = append(frontNode.out, edge)
 	}
+	m.labelEdges()
 	return nil
 }
 
+// 检查是否没有节点、没有边，或者边完全没有用到添加的节点
+func (m *Manager) checkEmpty() error {
+	nodes := 0
+	for _, node := range m.nodes {
+		if node.Typ != NodeTypHead && node.Typ != NodeTypTail {
+			nodes++
+		}
+	}
+	counts := fmt.Sprintf("%d nodes registered, %d edges provided", nodes, len(m.edgeList))
+	if nodes == 0 {
+		return invalid(CodeEmpty, fmt.Errorf("%w (%s)", ErrorsNoNodesRegistered, counts))
+	}
+	if len(m.edgeList) == 0 {
+		return invalid(CodeEmpty, fmt.Errorf("%w (%s)", ErrorsNoEdgesProvided, counts))
+	}
+	for _, edge := range m.edgeList {
+		for _, name := range []string{edge.from, edge.to} {
+			if node, ok := m.nodes[name]; ok && node.Typ != NodeTypHead && node.Typ != NodeTypTail {
+				return nil
+			}
+		}
+	}
+	e := m.edgeList[0]
+	return invalidEdge(CodeUnknownNode, e, "", fmt.Errorf("%w (%s), first edge is %s->%s", ErrorsEdgesMatchNoNodes, counts, e.from, e.to))
+}
+
 // todo:
 // 检查节点以及连接的正确性
 // 1、检查节点的是否存在，出入度是否合乎规则
 // 2、检查从头节点到尾节点的连通性
 func (m *Manager) validate() error {
 	// 检查节点
-	var headNodeCount, tailNodeCount int
+	var headEdges []string
+	var extraHead *edge
+	var tailNodeCount int
 	var inEdges = make(map[*Node]int)
 	var outEdges = make(map[*Node]int)
-	for i := 0; i < len(m.edges); i++ {
-		preNode, ok := m.nodes[m.edges[i][0]]
+	// 节点按在edges 中首次出现的顺序排列，保证报错顺序稳定
+	var order []*Node
+	var seen = make(map[*Node]bool)
+	for _, edge := range m.edgeList {
+		preNode, ok := m.nodes[edge.from]
 		if !ok {
-			return fmt.Errorf("edges[nodename=%s] cannot be fouond in nodes", m.edges[i][0])
+			return invalidEdge(CodeUnknownNode, edge, edge.from, fmt.Errorf("edges[nodename=%s] cannot be fouond in nodes", edge.from))
 		}
-		forNode, ok := m.nodes[m.edges[i][1]]
+		forNode, ok := m.nodes[edge.to]
 		if !ok {
-			return fmt.Errorf("edges[nodename=%s] cannot be fouond in nodes", m.edges[i][1])
+			return invalidEdge(CodeUnknownNode, edge, edge.to, fmt.Errorf("edges[nodename=%s] cannot be fouond in nodes", edge.to))
 		}
 		if preNode.Typ == NodeTypTail {
-			return fmt.Errorf("tailNode[%s] out edges not equals 0", preNode.nodeName)
+			return invalidEdge(CodeBadOutDegree, edge, preNode.nodeName, fmt.Errorf("tailNode[%s] out edges not equals 0", preNode.nodeName))
 		} else if preNode.Typ == NodeTypHead {
-			headNodeCount++
+			headEdges = append(headEdges, preNode.nodeName+"->"+forNode.nodeName)
+			if len(headEdges) == 2 {
+				extraHead = edge
+			}
 		}
 		if forNode.Typ == NodeTypHead {
-			return fmt.Errorf("headNode[%s] in edges not equals 0", forNode.nodeName)
+			return invalidEdge(CodeBadInDegree, edge, forNode.nodeName, fmt.Errorf("headNode[%s] in edges not equals 0", forNode.nodeName))
 		} else if forNode.Typ == NodeTypTail {
 			tailNodeCount++
 		}
+		for _, node := range []*Node{preNode, forNode} {
+			if !seen[node] {
+				seen[node] = true
+				order = append(order, node)
+			}
+		}
 		inEdges[forNode]++
 		outEdges[preNode]++
 	}
 	// 头节点唯一性的检查
-	if headNodeCount != 1 {
-		return ErrorsHeadNodeNotUnique
+	switch {
+	case len(headEdges) == 0:
+		return invalid(CodeMissingHeadEdge, fmt.Errorf("%w: exactly one edge must start from %q (or %q), e.g. {%q, \"first-node\"}",
+			ErrorsHeadEdgeMissing, Head, headNodeName, Head))
+	case len(headEdges) > 1:
+		return invalidEdge(CodeMultipleHeadEdges, extraHead, Head,
+			fmt.Errorf("%w: found %d head edges: [%s]", ErrorsHeadNodeNotUnique, len(headEdges), strings.Join(headEdges, " ")))
+	case tailNodeCount == 0:
+		var dangling []string
+		var first string
+		for _, node := range order {
+			if outEdges[node] == 0 {
+				dangling = append(dangling, fmt.Sprintf("node[%s]", node.nodeName))
+				if first == "" {
+					first = node.nodeName
+				}
+			}
+		}
+		return invalidNode(CodeMissingTailEdge, first, fmt.Errorf("%w: at least one edge must end at %q (or %q), e.g. {\"last-node\", %q}; nodes without next: [%s]",
+			ErrorsTailEdgeMissing, Tail, tailNodeName, Tail, strings.Join(dangling, " ")))
+	}
+	if err := validateEdgesOfNodes(order, inEdges, outEdges); err != nil {
+		return err
 	}
-	if err := validateEdgesOfNodes(&inEdges, &outEdges); err != nil {
+	if err := validateNodeOptions(order, outEdges); err != nil {
 		return err
 	}
+	if err := m.checkNodeOptions(order); err != nil {
+		return invalid(CodeBadOption, err)
+	}
 	// 检查连通性
-	if err := validateNodesConnectivity(m.nodes); err != nil {
+	if err := validateNodesConnectivity(m.nodes, m.nodes[m.headName]); err != nil {
 		return err
 	}
 	return nil
 }
 
+// 检查节点的配置是否适用于节点类型
+func validateNodeOptions(order []*Node, outEdges map[*Node]int) error {
+	for _, node := range order {
+		if node.opts.skipIfRemaining > 0 && node.Typ != NodeTypWorker {
+			return invalidNode(CodeBadOption, node.nodeName, fmt.Errorf("node[%s] option WithSkipIfRemaining only applies to worker nodes", node.nodeName))
+		}
+		// 命名的分支数必须和出度一致
+		if n := len(node.opts.branches); n > 0 && n != outEdges[node] {
+			return invalidNode(CodeBadOption, node.nodeName, fmt.Errorf("node[%s] declares %d branches %v but has %d out edges",
+				node.nodeName, n, node.opts.branches, outEdges[node]))
+		}
+	}
+	return nil
+}
+
+// 构建后Next 被修改的节点返回ErrTopologyCorrupted
+func checkTopology(node *Node) error {
+	if len(node.Next) != node.outEdges {
+		return runtimeError(node, ErrTopologyCorrupted, "next nodes changed after build",
+			fmt.Sprintf("%d next nodes, %d at build", len(node.Next), node.outEdges))
+	}
+	for i, next := range node.Next {
+		if next == nil {
+			return runtimeError(node, ErrTopologyCorrupted, "next node is nil", fmt.Sprintf("next[%d]", i))
+		}
+	}
+	return nil
+}
+
 // 检查节点的连通性
-func validateNodesConnectivity(nodes map[string]*Node) error {
+func validateNodesConnectivity(nodes map[string]*Node, head *Node) error {
 	var queue []*Node
 	var vis = make(map[*Node]bool)
-	queue = append(queue, nodes[headNodeName])
+	queue = append(queue, head)
 	for len(queue) > 0 {
 		node := queue[0]
 		queue = queue[1:]
@@ -202,8 +544,11 @@ func validateNodesConnectivity(nodes map[string]*Node) error {
 		//
 		p := node
 		for {
-			if p == nil || (p.Typ != NodeTypTail && len(p.Next) == 0) || (p.Typ != NodeTypTail && p.Next[0] == nil) {
-				return ErrorsNodeNil
+			if p == nil {
+				return invalid(CodeUnreachable, ErrorsNodeNil)
+			}
+			if p.Typ != NodeTypTail && (len(p.Next) == 0 || p.Next[0] == nil) {
+				return invalidNode(CodeUnreachable, p.nodeName, fmt.Errorf("%w: node[%s] has no next node", ErrorsNodeNil, p.nodeName))
 			}
 			vis[p] = true
 			if p.Typ == NodeTypTail || vis[p.Next[0]] {
@@ -216,69 +561,85 @@ func validateNodesConnectivity(nodes map[string]*Node) error {
 }
 
 // 节点入度、出度的检查
-func validateEdgesOfNodes(inEdges *map[*Node]int, outEdges *map[*Node]int) error {
+// 按order 的顺序依次检查，多个节点不合规时报错结果是确定的
+func validateEdgesOfNodes(order []*Node, inEdges map[*Node]int, outEdges map[*Node]int) error {
 	// 节点入度的检查
-	for node, c := range *inEdges {
+	for _, node := range order {
+		c, ok := inEdges[node]
+		if !ok {
+			continue
+		}
 		switch node.Typ {
 		case NodeTypHead:
-			return fmt.Errorf("headNode[%s] in edges should eq 0", node.nodeName)
+			return invalidNode(CodeBadInDegree, node.nodeName, fmt.Errorf("headNode[%s] in edges should eq 0", node.nodeName))
 		case NodeTypWorker:
 			if c != 1 {
-				return fmt.Errorf("workerNode[%s] in edges should eq 1", node.nodeName)
+				return invalidNode(CodeBadInDegree, node.nodeName, fmt.Errorf("workerNode[%s] in edges should eq 1", node.nodeName))
 			}
 		case NodeTypDivider:
 			if c != 1 {
-				return fmt.Errorf("dividerNode[%s] in edges should eq 1", node.nodeName)
+				return invalidNode(CodeBadInDegree, node.nodeName, fmt.Errorf("dividerNode[%s] in edges should eq 1", node.nodeName))
 			}
 		case NodeTypMerger:
 			if c <= 1 {
-				return fmt.Errorf("mergerNode[%s] in edges should gt 1", node.nodeName)
+				return invalidNode(CodeBadInDegree, node.nodeName, fmt.Errorf("mergerNode[%s] in edges should gt 1", node.nodeName))
 			}
 		case NodeTypJudger:
 			if c != 1 {
-				return fmt.Errorf("judgerNode[%s] in edges should be 1", node.nodeName)
+				return invalidNode(CodeBadInDegree, node.nodeName, fmt.Errorf("judgerNode[%s] in edges should be 1", node.nodeName))
 			}
 		case NodeTypTail:
 			if c < 1 {
-				return fmt.Errorf("tailNode[%s] in edges should lt 1", node.nodeName)
+				return invalidNode(CodeBadInDegree, node.nodeName, fmt.Errorf("tailNode[%s] in edges should lt 1", node.nodeName))
 			}
 		}
 	}
 	// 节点出度的检查
-	for node, c := range *outEdges {
+	for _, node := range order {
+		c, ok := outEdges[node]
+		if !ok {
+			continue
+		}
 		switch node.Typ {
 		case NodeTypHead:
 			if c != 1 {
-				return fmt.Errorf("headNode[%s] out edges should eq 1", node.nodeName)
+				return invalidNode(CodeBadOutDegree, node.nodeName, fmt.Errorf("headNode[%s] out edges should eq 1", node.nodeName))
 			}
 		case NodeTypWorker:
 			if c != 1 {
-				return fmt.Errorf("workerNode[%s] out edges should eq 1", node.nodeName)
+				return invalidNode(CodeBadOutDegree, node.nodeName, fmt.Errorf("workerNode[%s] out edges should eq 1", node.nodeName))
 			}
 		case NodeTypDivider:
 			if c <= 1 {
-				return fmt.Errorf("dividerNode[%s] out edges should gt 1", node.nodeName)
+				return invalidNode(CodeBadOutDegree, node.nodeName, fmt.Errorf("dividerNode[%s] out edges should gt 1", node.nodeName))
 			}
 		case NodeTypMerger:
 			if c != 1 {
-				return fmt.Errorf("mergerNode[%s] out edges should eq 1", node.nodeName)
+				return invalidNode(CodeBadOutDegree, node.nodeName, fmt.Errorf("mergerNode[%s] out edges should eq 1", node.nodeName))
 			}
 		case NodeTypJudger:
 			if c <= 1 {
-				return fmt.Errorf("judgerNode[%s] out edges should gt 1", node.nodeName)
+				return invalidNode(CodeBadOutDegree, node.nodeName, fmt.Errorf("judgerNode[%s] out edges should gt 1", node.nodeName))
 			}
 		case NodeTypTail:
-			return fmt.Errorf("tailNode[%s] out edges should eq 0", node.nodeName)
+			return invalidNode(CodeBadOutDegree, node.nodeName, fmt.Errorf("tailNode[%s] out edges should eq 0", node.nodeName))
 		}
 	}
 	return nil
 }
 
-// 计算每个合并节点的入度
+// 计算每个合并节点的入度以及前驱节点
 func (m *Manager) calInEdgeOfMerger() {
-	for i := 0; i < len(m.edges); i++ {
-		if m.nodes[m.edges[i][1]].Typ == NodeTypMerger {
-			m.inEdgeOfMerger[m.edges[i][1]]++
+	for _, edge := range m.edgeList {
+		if node := m.nodes[edge.to]; node.Typ == NodeTypMerger {
+			m.inEdgeOfMerger[edge.to]++
+			m.predsOfMerger[node] = append(m.predsOfMerger[node], m.nodes[edge.from])
+			node.inEdges = m.inEdgeOfMerger[edge.to]
+			if node.predSlots == nil {
+				node.predSlots = make(map[*Node][]int)
+			}
+			from := m.nodes[edge.from]
+			node.predSlots[from] = append(node.predSlots[from], node.inEdges-1)
 		}
 	}
 }
@@ -287,110 +648,454 @@ func (m *Manager) calInEdgeOfMerger() {
 type nodeDataWrapper struct {
 	node *Node
 	in   *rawData
+	// 产生in 的节点
+	from *Node
+	// 加入队列的时间
+	at time.Time
+	// 执行该节点使用的ctx
+	ctx context.Context
+	// 经过的分裂节点之前的ctx，合并节点执行时恢复为最近一层
+	outer []context.Context
+	// in 的血缘，开启 WithLineage 时记录
+	lineage []LineageEntry
+	// 所在的限时分支
+	branch *activeBranch
+	// 限时分支超时或者合并节点等待超时，通知合并节点缺少该分支的输入
+	missing bool
+	// 所在分支的执行情况，见 BranchMeta
+	meta *branchAcc
+}
+
+// 不执行当前节点，将输入交给node
+func (nw *nodeDataWrapper) reroute(node *Node, at time.Time) *nodeDataWrapper {
+	return &nodeDataWrapper{
+		node:    node,
+		in:      nw.in,
+		from:    nw.node,
+		at:      at,
+		ctx:     nw.ctx,
+		outer:   nw.outer,
+		lineage: nw.lineage,
+		branch:  nw.branch,
+		meta:    nw.meta,
+	}
 }
 
 // 执行整个流水线
 func (m *Manager) Handle(in *rawData) (out *rawData, err error) {
-	head := m.nodes[headNodeName]
-	p := head.Next[0]
-	mergerNodeInDataMap := make(map[string][]*rawData)
+	if m.requireContext {
+		return nil, ErrContextRequired
+	}
+	return m.HandleContext(context.Background(), in)
+}
+
+// 执行整个流水线，ctx 会传给每个节点的处理方法
+func (m *Manager) HandleContext(ctx context.Context, in *rawData, opts ...CallOption) (out *rawData, err error) {
+	if ctx == nil && m.requireContext {
+		return nil, ErrContextRequired
+	}
+	if !m.built {
+		return nil, ErrorsPipelineNotBuilt
+	}
+	if m.finalizer != nil {
+		return m.handleWithFinalizer(ctx, in, opts)
+	}
+	return m.handle(ctx, in, opts)
+}
+
+func (m *Manager) handle(ctx context.Context, in *rawData, opts []CallOption) (out *rawData, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	start := m.clock.Now()
+	depth := 1
+	if m.tracksRecursion() {
+		if ctx, depth, err = m.enterRecursion(ctx); err != nil {
+			return
+		}
+	}
+	var seq uint64
+	if depth == 1 {
+		// 嵌套的调用沿用最外层调用的执行名额
+		if seq, err = m.track(start); err != nil {
+			return
+		}
+		defer m.untrack(seq)
+		if err = m.admit(ctx); err != nil {
+			return
+		}
+		defer m.release()
+	}
+	e := m.newExecution(ctx, opts, seq, start)
+	if m.softDeadline != nil {
+		defer e.startWatchdog().Stop()
+	}
+	// 分支超时和合并等待都会登记需要取消的ctx 和计时器
+	defer e.cancelBranches()
+	if m.stall != nil {
+		e.startStallWatch()
+		defer e.stall.finish(nil)
+	}
+	// 失败时错误处理子图、死信和执行摘要的脱敏需要原始输入，执行期间不回收
+	pinned := m.errorHandler != nil || m.deadLetters != nil && !e.noDeadLetter || m.redactor != nil && m.history != nil
+	if pinned {
+		e.hold(in)
+	}
+	// 节点可能原地修改输入，设置了 WithCloner 时死信保存执行开始时的副本
+	original := in
+	if m.cloner != nil && m.deadLetters != nil && !e.noDeadLetter {
+		original = m.cloner(in)
+	}
+	if e.pipelineRetry != nil {
+		out, err = e.runWithRetry(in)
+	} else {
+		out, err = e.runOnce(in)
+	}
+	if pinned && err == nil {
+		e.unpin(in, out)
+	}
+	if err != nil {
+		err = e.cancelled(err)
+	}
+	if e.stall != nil {
+		err = e.stall.finish(err)
+	}
+	if err != nil && m.errorHandler != nil {
+		out, err = e.handleError(in, err)
+	}
+	if err != nil && m.deadLetters != nil && !e.noDeadLetter {
+		err = e.saveDeadLetter(original, err)
+	}
+	if e.sections != nil {
+		e.releaseSections(err)
+	}
+	if m.history != nil {
+		e.remember(in, err)
+	}
+	if m.slo != nil && depth == 1 && !e.warmup {
+		e.observeSLO()
+	}
+	return
+}
+
+// 从头节点执行一次，纯工作节点的直线流程走快速路径
+func (e *execution) runOnce(in *rawData) (out *rawData, err error) {
+	if e.m.linear != nil && !e.m.disableFastPath && !e.lineage {
+		out, err = e.runLinear(in)
+	} else {
+		out, err = e.run(in)
+	}
+	if e.sections != nil {
+		// 每次执行结束时释放临界区，流水线重试时重新获取
+		e.releaseSections(err)
+	}
+	return
+}
+
+func (e *execution) run(in *rawData) (out *rawData, err error) {
+	head := e.m.nodes[e.m.headName]
+	return e.runFrom(head.Next[0], head, in, e.start)
+}
+
+// 从节点p 开始执行，from 为产生in 的节点
+// at 为in 加入队列的时间
+func (e *execution) runFrom(p *Node, from *Node, in *rawData, at time.Time) (out *rawData, err error) {
+	m := e.m
+	mergers := make(map[*Node]*mergerState)
 	var queue []*nodeDataWrapper
+	var running []string
+	defer func() {
+		if err != nil && e.ctx.Err() != nil {
+			e.snapshotQueue(queue, mergers)
+			e.snapshot.running = running
+		}
+		if err != nil && m.diagnostics != nil {
+			err = e.diagnose(err, queue, mergers)
+		}
+	}()
+	e.hold(in)
 	queue = append(queue, &nodeDataWrapper{
 		node: p,
 		in:   in,
+		from: from,
+		at:   at,
+		ctx:  e.ctx,
 	})
+	if m.parallelism > 0 {
+		p := e.runParallel(queue)
+		queue, mergers, running = p.queue, p.mergers, p.busy
+		return p.out, p.err
+	}
 	for len(queue) > 0 {
-		nw := queue[0]
-		queue = queue[1:]
-		switch nw.node.Typ {
-		case NodeTypDivider:
-			// 处理分裂节点
-			// divide 方法的到的数据列表依次分给每个子节点
-			action := m.actionMap[nw.node.actionId].(DividerFunc)
-			if outs, err := action(context.Background(), nw.in); err != nil {
-				return nil, err
-			} else {
-				if len(outs) == 0 || len(outs) != len(nw.node.Next) {
-					err = fmt.Errorf("divider node[%s] outs null or length of outs and Next is not match", nw.node.nodeName)
-					return nil, err
-				}
-				for i := 0; i < len(nw.node.Next); i++ {
-					queue = append(queue, &nodeDataWrapper{
-						node: nw.node.Next[i],
-						in:   outs[i],
-					})
-				}
+		var nw *nodeDataWrapper
+		var done bool
+		nw, queue = m.popNode(queue)
+		if out, done, err = e.step(nw, &queue, mergers); done || err != nil {
+			return
+		}
+	}
+	err = ErrorsCannotReachTail
+	return
+}
+
+// 执行队列中的一项，后继加入queue；执行到末尾或者 stopAt 时done 为true
+func (e *execution) step(nw *nodeDataWrapper, queue *[]*nodeDataWrapper, mergers map[*Node]*mergerState) (out *rawData, done bool, err error) {
+	m := e.m
+	if e.stall != nil {
+		e.stall.observe(*queue, mergers)
+	}
+	if e.subgraph != nil && !e.subgraph[nw.node] {
+		// 只执行子图中的节点
+		e.drop(nw.in)
+		return nil, false, nil
+	}
+	e.queued = nw.at
+	switch nw.node.Typ {
+	case NodeTypDivider:
+		// 处理分裂节点
+		// divide 方法的到的数据列表依次分给每个子节点
+		if err := checkTopology(nw.node); err != nil {
+			return nil, false, err
+		}
+		if route, err := e.checkInputSize(nw.ctx, nw.node, nw.in); err != nil {
+			if mw := e.abandonBranch(nw.branch, nw.node); mw != nil {
+				e.drop(nw.in)
+				*queue = append(*queue, e.arrive(mw))
+				return nil, false, nil
 			}
-		case NodeTypMerger:
-			// 处理合并节点
-			thre := m.inEdgeOfMerger[nw.node.nodeName]
-			if thre <= 1 {
-				// 报错
-				err = fmt.Errorf("merger node[%s] inEdges=%d", nw.node.nodeName, thre)
-				return
+			return nil, false, err
+		} else if route != nil {
+			*queue = append(*queue, e.arrive(nw.reroute(route, m.clock.Now())))
+			return nil, false, nil
+		}
+		outs, err := e.divide(nw.ctx, nw.node, nw.in)
+		nw.meta.add(e.last)
+		for i := 0; err == nil && i < len(outs); i++ {
+			err = e.checkOutput(nw.node, i, outs[i].Data)
+		}
+		if err != nil {
+			if mw := e.abandonBranch(nw.branch, nw.node); mw != nil {
+				e.drop(nw.in)
+				*queue = append(*queue, e.arrive(mw))
+				return nil, false, nil
+			}
+			return nil, false, err
+		}
+		for i := range outs {
+			e.hold(outs[i].Data)
+		}
+		e.drop(nw.in)
+		outer := append(nw.outer[:len(nw.outer):len(nw.outer)], nw.ctx)
+		wctx, wait := e.startMergeWait(nw.node, nw.ctx, outer, nw.branch)
+		first := len(*queue)
+		for i := 0; i < len(nw.node.Next); i++ {
+			// 分支的ctx 只对该分支上的节点可见
+			ctx := e.branchContext(wctx, nw.node, i)
+			if outs[i].Ctx != nil {
+				ctx = outs[i].Ctx(ctx)
+			}
+			branch := wait
+			var b *activeBranch
+			if ctx, b = e.startBranch(nw.node, i, ctx, outer, wait); b != nil {
+				branch = b
+			}
+			*queue = append(*queue, e.arrive(&nodeDataWrapper{
+				node:    nw.node.Next[i],
+				in:      outs[i].Data,
+				from:    nw.node,
+				at:      m.clock.Now(),
+				ctx:     ctx,
+				outer:   outer,
+				lineage: e.addLineage(nw.lineage, nw.node, i, nil),
+				branch:  branch,
+				meta:    e.startBranchMeta(nw.node, i, nw.meta, m.clock.Now()),
+			}))
+		}
+		m.orderBranches((*queue)[first:])
+	case NodeTypMerger:
+		// 处理合并节点
+		// 入度在构建时已经校验过，只有开启了运行时断言才再次检查
+		thre := nw.node.inEdges
+		if m.runtimeAssertions && thre <= 1 {
+			err = runtimeError(nw.node, errInvariant, "in-degree invariant violated", fmt.Sprintf("inEdges=%d", thre))
+			return
+		}
+		st := mergers[nw.node]
+		if st == nil {
+			st = e.newMergerState(nw, thre)
+			mergers[nw.node] = st
+		}
+		if st.done {
+			// 已经合并过，超时后才到达的输入直接丢弃
+			e.drop(nw.in)
+			return nil, false, nil
+		}
+		opt := nw.node.opts.mergeTimeout
+		if nw.missing {
+			// 限时分支超时或者等待超时后被取消，不会再有该分支的输入
+			st.missing++
+			st.done = st.received+st.missing == thre
+		} else if opt != nil && nw.at.Sub(st.first) > opt.d {
+			// 超过等待时间
+			if opt.policy == FailOnMergeTimeout {
+				return nil, false, st.timeoutError(nw.node, opt.d, m.predsOfMerger[nw.node])
+			}
+			st.done = true
+			e.drop(nw.in)
+		} else {
+			if m.branchMeta {
+				nw.meta.arrive(nw.at)
+			}
+			st.add(nw)
+			st.done = st.received+st.missing == thre
+		}
+		if st.done && st.missing > 0 && st.wait.waitExpired() && opt.policy == FailOnMergeTimeout {
+			return nil, false, st.timeoutError(nw.node, opt.d, m.predsOfMerger[nw.node])
+		}
+		if st.done {
+			st.wait.stop()
+			st.compact()
+			// 执行merge 方法，ctx 恢复为分裂之前的ctx
+			ctx, outer := e.ctx, st.outer
+			if len(outer) > 0 {
+				ctx, outer = outer[len(outer)-1], outer[:len(outer)-1]
 			}
-			mergerNodeInDataMap[nw.node.nodeName] = append(mergerNodeInDataMap[nw.node.nodeName], nw.in)
-			if len(mergerNodeInDataMap[nw.node.nodeName]) == thre {
-				// 执行merge 方法
-				action := m.actionMap[nw.node.actionId].(MergerFunc)
-				if out, err = action(context.Background(), mergerNodeInDataMap[nw.node.nodeName]); err != nil {
-					return
-				} else {
-					if len(nw.node.Next) == 0 || nw.node.Next[0] == nil {
-						err = fmt.Errorf("merger node[%s] next node is nil", nw.node.nodeName)
-						return
-					}
-					// 将下一个节点加入队列
-					queue = append(queue, &nodeDataWrapper{
-						node: nw.node.Next[0],
-						in:   out,
-					})
+			if out, err = e.merge(ctx, nw.node, st.ins, st.accs); err == nil {
+				err = e.checkOutput(nw.node, 0, out)
+			}
+			if err != nil {
+				if mw := e.abandonBranch(st.branch, nw.node); mw != nil {
+					*queue = append(*queue, e.arrive(mw))
+					return nil, false, nil
 				}
+				return
 			}
-		case NodeTypJudger:
-			// 处理判断节点的情况
-			action := m.actionMap[nw.node.actionId].(JudgerFunc)
-			pIndex := action(context.Background(), nw.in)
-			if pIndex >= len(nw.node.Next) {
-				err = fmt.Errorf("judger node[%s] pIndex outbound %d>=%d", nw.node.nodeName, pIndex, len(nw.node.Next))
+			e.hold(out)
+			for _, data := range st.ins {
+				e.drop(data)
+			}
+			if err = checkTopology(nw.node); err != nil {
 				return
 			}
-			queue = append(queue, &nodeDataWrapper{
-				node: nw.node.Next[pIndex],
-				in:   nw.in,
-			})
-		case NodeTypWorker:
-			// 如果是worker节点则一直往下执行
-			p := nw.node
-			in = nw.in
-			for p != nil && p.Typ == NodeTypWorker {
-				action := m.actionMap[p.actionId].(WorkerFunc)
-				if out, err = action(context.Background(), in); err != nil {
-					return nil, err
-				} else {
-					in = out
-					if len(p.Next) <= 0 {
-						err = fmt.Errorf("node[%s] Next is nil", p.nodeName)
-						return
-					}
-					p = p.Next[0]
+			lineage := e.addLineage(nil, nw.node, -1, st.lineages)
+			meta := mergeBranchMeta(st.accs)
+			meta.add(e.last)
+			if nw.node == e.stopAt {
+				e.attachLineage(out, lineage)
+				return out, true, nil
+			}
+			// 将下一个节点加入队列
+			*queue = append(*queue, e.arrive(&nodeDataWrapper{
+				node:    nw.node.Next[0],
+				in:      out,
+				from:    nw.node,
+				at:      m.clock.Now(),
+				ctx:     ctx,
+				outer:   outer,
+				lineage: lineage,
+				branch:  st.branch,
+				meta:    meta,
+			}))
+		}
+	case NodeTypJudger:
+		// 处理判断节点的情况
+		if err := checkTopology(nw.node); err != nil {
+			return nil, false, err
+		}
+		if route, err := e.checkInputSize(nw.ctx, nw.node, nw.in); err != nil {
+			if mw := e.abandonBranch(nw.branch, nw.node); mw != nil {
+				e.drop(nw.in)
+				*queue = append(*queue, e.arrive(mw))
+				return nil, false, nil
+			}
+			return nil, false, err
+		} else if route != nil {
+			*queue = append(*queue, e.arrive(nw.reroute(route, m.clock.Now())))
+			return nil, false, nil
+		}
+		pIndex, err := e.judge(nw.ctx, nw.node, nw.in)
+		nw.meta.add(e.last)
+		if err != nil {
+			if mw := e.abandonBranch(nw.branch, nw.node); mw != nil {
+				e.drop(nw.in)
+				*queue = append(*queue, e.arrive(mw))
+				return nil, false, nil
+			}
+			return nil, false, err
+		}
+		lineage := e.addLineage(nw.lineage, nw.node, pIndex, nil)
+		if nw.node == e.stopAt {
+			e.attachLineage(nw.in, lineage)
+			return nw.in, true, nil
+		}
+		*queue = append(*queue, e.arrive(&nodeDataWrapper{
+			node:    nw.node.Next[pIndex],
+			in:      nw.in,
+			from:    nw.node,
+			at:      m.clock.Now(),
+			ctx:     e.branchContext(nw.ctx, nw.node, pIndex),
+			outer:   nw.outer,
+			lineage: lineage,
+			branch:  nw.branch,
+			meta:    nw.meta,
+		}))
+	case NodeTypWorker:
+		// 如果是worker节点则一直往下执行
+		p, from, lineage := nw.node, nw.from, nw.lineage
+		in := nw.in
+		for p != nil && p.Typ == NodeTypWorker && (e.subgraph == nil || e.subgraph[p]) {
+			var route *Node
+			if route, err = e.checkInputSize(nw.ctx, p, in); err == nil {
+				if route != nil {
+					from, p = p, route
+					continue
+				}
+				if out, err = e.work(nw.ctx, p, in); err == nil {
+					nw.meta.add(e.last)
+					out = e.checkAliasing(nw.ctx, p, in, out)
+					err = e.checkOutput(p, 0, out)
 				}
 			}
-			// 特殊情况，报错
-			if p == nil {
-				err = ErrorsNodeNil
+			if err != nil {
+				if mw := e.abandonBranch(nw.branch, p); mw != nil {
+					e.drop(in)
+					*queue = append(*queue, e.arrive(mw))
+					return nil, false, nil
+				}
+				return nil, false, err
+			}
+			e.hold(out)
+			e.drop(in)
+			if err = e.yield(nw.ctx); err != nil {
+				return nil, false, err
+			}
+			lineage = e.addLineage(lineage, p, -1, nil)
+			if p == e.stopAt {
+				e.attachLineage(out, lineage)
+				return out, true, nil
+			}
+			in, from = out, p
+			if err = checkTopology(p); err != nil {
 				return
 			}
-			// 其他类型的节点直接加入队列
-			queue = append(queue, &nodeDataWrapper{
-				node: p,
-				in:   in,
-			})
-		case NodeTypTail:
-			// 如果执行到末尾则返回结果
-			return nw.in, nil
+			p = p.Next[0]
 		}
+		// 其他类型的节点直接加入队列
+		*queue = append(*queue, e.arrive(&nodeDataWrapper{
+			node:    p,
+			in:      in,
+			from:    from,
+			at:      m.clock.Now(),
+			ctx:     nw.ctx,
+			outer:   nw.outer,
+			lineage: lineage,
+			branch:  nw.branch,
+			meta:    nw.meta,
+		}))
+	case NodeTypTail:
+		// 如果执行到末尾则返回结果
+		e.attachLineage(nw.in, nw.lineage)
+		return nw.in, true, nil
 	}
-	err = ErrorsCannotReachTail
-	return
+	return nil, false, nil
 }
@@ -3,6 +3,7 @@ package pipeline
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -306,3 +307,122 @@ func TestManager_Handle3(t *testing.T) {
 		fmt.Println(err.Error())
 	}
 }
+
+// 测试校验报错顺序的稳定性：
+// 存在多个出度不合规的节点时，总是按节点在edges 中首次出现的顺序报错
+func TestManager_ValidateErrorOrder(t *testing.T) {
+	passFunc := func(ctx context.Context, in *rawData) (out *rawData, err error) {
+		return in, nil
+	}
+	// 依次修复前面的违规，后面的违规按顺序暴露出来
+	cases := []struct {
+		edges [][]string
+		want  string
+	}{
+		{
+			edges: [][]string{
+				{"head000", "w1"},
+				{"w1", "w2"}, {"w1", "tail111"},
+				{"w2", "w3"}, {"w2", "tail111"},
+				{"w3", "w4"}, {"w3", "tail111"},
+				{"w4", "tail111"},
+			},
+			want: "workerNode[w1] out edges should eq 1",
+		},
+		{
+			edges: [][]string{
+				{"head000", "w1"},
+				{"w1", "w2"},
+				{"w2", "w3"}, {"w2", "tail111"},
+				{"w3", "w4"}, {"w3", "tail111"},
+				{"w4", "tail111"},
+			},
+			want: "workerNode[w2] out edges should eq 1",
+		},
+		{
+			edges: [][]string{
+				{"head000", "w1"},
+				{"w1", "w2"},
+				{"w2", "w3"},
+				{"w3", "w4"}, {"w3", "tail111"},
+				{"w4", "tail111"},
+			},
+			want: "workerNode[w3] out edges should eq 1",
+		},
+	}
+	for round := 0; round < 2; round++ {
+		for i, c := range cases {
+			m := NewManager()
+			for _, name := range []string{"w1", "w2", "w3", "w4"} {
+				if err := m.AddWorkerNode(name, passFunc); err != nil {
+					t.Error(err)
+					t.FailNow()
+				}
+			}
+			err := m.BuildPipeline(c.edges)
+			if err == nil {
+				t.Errorf("round %d case %d: predict error occurs, but not", round, i)
+				t.FailNow()
+			}
+			if err.Error() != c.want {
+				t.Errorf("round %d case %d: err=%q, want=%q", round, i, err.Error(), c.want)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+// 测试虚拟头、尾节点的各种写法，导出的图中统一使用 Head、Tail
+func TestManager_VirtualNodeAliases(t *testing.T) {
+	for _, edge := range [][2]string{{Head, Tail}, {"head000", "tail111"}, {"__head__", "__tail__"}} {
+		m := NewManager()
+		_ = m.AddWorkerNode("w1", func(ctx context.Context, in *rawData) (out *rawData, err error) {
+			return in, nil
+		})
+		if err := m.BuildPipeline([][]string{
+			{edge[0], "w1"},
+			{"w1", edge[1]},
+		}); err != nil {
+			t.Errorf("edges %v: %v", edge, err)
+			t.FailNow()
+		}
+		dot := m.ToDOT()
+		if !strings.Contains(dot, `"head" -> "w1";`) || !strings.Contains(dot, `"w1" -> "tail";`) {
+			t.Errorf("edges %v: unexpected dot:\n%s", edge, dot)
+		}
+	}
+	m := NewManager()
+	if err := m.AddWorkerNode("head000", nil); err == nil {
+		t.Error("expected reserved name error")
+	}
+}
+
+// 测试别名出现之前已有的名为 head、tail 的节点仍然可用，此时虚拟头、尾节点使用原来的内部名字
+func TestManager_UserNodesNamedLikeAliases(t *testing.T) {
+	m := NewManager()
+	for _, name := range []string{"head", "tail"} {
+		name := name
+		if err := m.AddWorkerNode(name, func(ctx context.Context, in *rawData) (out *rawData, err error) {
+			return &rawData{Data: in.Data.(string) + "/" + name}, nil
+		}); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+	if err := m.BuildPipeline([][]string{
+		{"head000", "head"},
+		{"head", "tail"},
+		{"tail", "__tail__"},
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	out, err := m.Handle(&rawData{Data: "in"})
+	if err != nil || out.Data != "in/head/tail" {
+		t.Errorf("out=%v err=%v, want in/head/tail", out, err)
+	}
+	dot := m.ToDOT()
+	if !strings.Contains(dot, `"head000" -> "head";`) || !strings.Contains(dot, `"tail" -> "tail111";`) {
+		t.Errorf("unexpected dot:\n%s", dot)
+	}
+}
@@ -0,0 +1,31 @@
+package pipeline
+
+import "fmt"
+
+type pipelineRetry struct {
+	attempts int
+	retryIf  func(error) bool
+}
+
+// 执行失败并且retryIf 返回true 时，用原始输入从头重新执行整个流水线，最多执行attempts 次
+// 只适用于幂等的流水线；节点自身的重试（WithRetry）在每次执行内部照常进行
+// 每次执行的序号记录在执行轨迹和监听事件中，最终的错误会带上执行次数
+func WithPipelineRetry(attempts int, retryIf func(error) bool) CallOption {
+	return func(o *callOptions) {
+		o.retry = &pipelineRetry{attempts: attempts, retryIf: retryIf}
+	}
+}
+
+func (e *execution) runWithRetry(in *rawData) (out *rawData, err error) {
+	// 原始输入在重试之间不能被回收
+	e.hold(in)
+	for e.attempt = 1; ; e.attempt++ {
+		e.stageUsed = nil
+		if out, err = e.runOnce(in); err == nil {
+			return out, nil
+		}
+		if e.attempt >= e.pipelineRetry.attempts || e.ctx.Err() != nil || e.pipelineRetry.retryIf == nil || !e.pipelineRetry.retryIf(err) {
+			return nil, fmt.Errorf("pipeline failed after %d attempts: %w", e.attempt, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var errConflict = errors.New("conflict")
+
+// 测试前两次执行冲突，第三次成功，每次执行的序号记录在执行轨迹和监听事件中
+func TestManager_PipelineRetry(t *testing.T) {
+	calls := 0
+	var events []NodeEvent
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		if calls++; calls < 3 {
+			return nil, errConflict
+		}
+		return &rawData{Data: in.Data.(int) + 1}, nil
+	}, WithListener(func(ev NodeEvent) { events = append(events, ev) }))
+	var tr Trace
+	out, err := m.HandleContext(context.Background(), &rawData{Data: 1}, WithTrace(&tr),
+		WithPipelineRetry(5, func(err error) bool { return errors.Is(err, errConflict) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Data.(int) != 2 || calls != 3 {
+		t.Errorf("out=%v calls=%d", out.Data, calls)
+	}
+	entries := tr.Entries()
+	if len(entries) != 3 || len(events) != 3 {
+		t.Fatalf("entries=%d events=%d, want 3", len(entries), len(events))
+	}
+	for i := range entries {
+		if entries[i].PipelineAttempt != i+1 || events[i].PipelineAttempt != i+1 {
+			t.Errorf("#%d: trace attempt=%d event attempt=%d", i, entries[i].PipelineAttempt, events[i].PipelineAttempt)
+		}
+	}
+}
+
+// 测试不满足重试条件的错误不会重试，最终错误带上执行次数
+func TestManager_PipelineRetryNotMatched(t *testing.T) {
+	calls := 0
+	boom := errors.New("boom")
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		calls++
+		return nil, boom
+	})
+	_, err := m.HandleContext(context.Background(), &rawData{Data: 1},
+		WithPipelineRetry(5, func(err error) bool { return errors.Is(err, errConflict) }))
+	if !errors.Is(err, boom) || calls != 1 {
+		t.Fatalf("err=%v calls=%d", err, calls)
+	}
+	if !strings.Contains(err.Error(), "after 1 attempts") {
+		t.Errorf("err=%q should carry the attempt count", err)
+	}
+	var nodeErr *NodeError
+	if !errors.As(err, &nodeErr) {
+		t.Errorf("err=%v should wrap NodeError", err)
+	}
+}
@@ -0,0 +1,100 @@
+package pipelinetest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/caigoumiao/pipeline"
+)
+
+// CheckCancellation 的可选配置
+type CancellationOption func(c *cancellationCheck)
+
+type cancellationCheck struct {
+	mu    sync.Mutex
+	allow map[string]bool
+	// 已经检查过的节点，以及没有在规定时间内返回的节点
+	checked, reported map[string]bool
+}
+
+// 不检查这些节点，用于已知不响应取消、但最终会返回的旧节点
+func AllowBlocking(nodes ...string) CancellationOption {
+	return func(c *cancellationCheck) {
+		for _, name := range nodes {
+			c.allow[name] = true
+		}
+	}
+}
+
+// 检查节点是否响应ctx 的取消：用in 的浅拷贝多次执行m，每次在执行到的第一个还没检查过的节点开始执行时取消ctx，
+// 取消后perNodeGrace 内执行没有返回时报告该节点，返回是否全部通过
+// 只检查in 执行到的节点；判断节点不会阻塞，不做检查；AllowBlocking 中的节点正常执行，不做检查
+// 不响应取消的节点所在的执行会一直留在后台，直到节点自己返回；之后的执行再次执行到该节点时停止检查
+func CheckCancellation(t TestingT, m *pipeline.Manager, in *pipeline.Data, perNodeGrace time.Duration, opts ...CancellationOption) bool {
+	t.Helper()
+	if !m.Capabilities().Built {
+		t.Errorf("CheckCancellation: %v", pipeline.ErrorsPipelineNotBuilt)
+		return false
+	}
+	c := &cancellationCheck{allow: make(map[string]bool), checked: make(map[string]bool), reported: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	ok := true
+	for {
+		node, returned, stop := c.run(m, in, perNodeGrace)
+		if stop {
+			return ok
+		}
+		if !returned {
+			t.Errorf("CheckCancellation: node[%s] did not return within %v after ctx was cancelled", node, perNodeGrace)
+			ok = false
+		}
+	}
+}
+
+// 执行一次，在第一个没有检查过的节点开始执行时取消ctx，返回该节点以及执行是否在grace 内返回
+// 没有执行到需要检查的节点，或者执行到了已经报告过的节点时stop 为true
+func (c *cancellationCheck) run(m *pipeline.Manager, in *pipeline.Data, grace time.Duration) (target string, returned, stop bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cancelled, blocked, done := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	go func() {
+		defer close(done)
+		_, _ = m.HandleContext(ctx, clone(in), pipeline.WithCallStartListener(func(node string) {
+			info, _ := m.NodeInfo(node)
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			switch {
+			case target != "" || info.Typ == pipeline.NodeTypJudger || c.allow[node] || c.checked[node]:
+			case c.reported[node]:
+				once.Do(func() { close(blocked) })
+			default:
+				target = node
+				c.checked[node] = true
+				once.Do(func() {
+					cancel()
+					close(cancelled)
+				})
+			}
+		}))
+	}()
+	select {
+	case <-cancelled:
+	case <-blocked:
+		return "", false, true
+	case <-done:
+		return "", true, true
+	}
+	select {
+	case <-done:
+		return target, true, false
+	case <-time.After(grace):
+		c.mu.Lock()
+		c.reported[target] = true
+		c.mu.Unlock()
+		return target, false, false
+	}
+}
@@ -0,0 +1,63 @@
+package pipelinetest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caigoumiao/pipeline"
+)
+
+// 测试 CheckCancellation 找出不响应取消的节点
+func TestCheckCancellation(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	m := pipeline.NewManager()
+	_ = m.AddWorkerNode("compliant", func(ctx context.Context, in *pipeline.Data) (*pipeline.Data, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+			return in, nil
+		}
+	})
+	_ = m.AddJudgerNode("j1", func(ctx context.Context, in *pipeline.Data) int {
+		return 0
+	})
+	// 不响应取消，过一段时间后返回
+	_ = m.AddWorkerNode("stuck", func(ctx context.Context, in *pipeline.Data) (*pipeline.Data, error) {
+		select {
+		case <-release:
+		case <-time.After(200 * time.Millisecond):
+		}
+		return in, nil
+	})
+	// j1 总是选择stuck，other 执行不到，不做检查
+	_ = m.AddWorkerNode("other", func(ctx context.Context, in *pipeline.Data) (*pipeline.Data, error) {
+		<-release
+		return in, nil
+	})
+	if err := m.BuildPipeline([][]string{
+		{pipeline.Head, "compliant"}, {"compliant", "j1"}, {"j1", "stuck"}, {"j1", "other"}, {"stuck", pipeline.Tail}, {"other", pipeline.Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	rt := &recordingT{}
+	if CheckCancellation(rt, m, &pipeline.Data{}, 50*time.Millisecond) {
+		t.Error("expected the check to fail")
+	}
+	if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], "node[stuck]") {
+		t.Errorf("want only stuck reported, got %v", rt.errors)
+	}
+
+	rt = &recordingT{}
+	if !CheckCancellation(rt, m, &pipeline.Data{}, 50*time.Millisecond, AllowBlocking("stuck")) || len(rt.errors) > 0 {
+		t.Errorf("allowlisted node should not be reported: %v", rt.errors)
+	}
+
+	rt = &recordingT{}
+	if CheckCancellation(rt, pipeline.NewManager(), &pipeline.Data{}, time.Millisecond) || len(rt.errors) != 1 {
+		t.Errorf("errors %v, want the unbuilt pipeline reported", rt.errors)
+	}
+}
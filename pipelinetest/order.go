@@ -0,0 +1,70 @@
+// pipelinetest 提供测试流水线的工具
+package pipelinetest
+
+import (
+	"reflect"
+
+	"github.com/caigoumiao/pipeline"
+)
+
+// 测试工具使用的测试接口，*testing.T 实现了该接口
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// 用开启了 pipeline.WithMergerOrderShuffling 的m 把每条输入执行runs 次，每次合并节点收到的输入顺序不同，
+// 输出不一致时报告错误，返回是否全部一致
+// 每次执行使用输入的浅拷贝（Meta 也会复制一份）
+func AssertOrderInsensitive(t TestingT, m *pipeline.Manager, inputs []*pipeline.Data, runs int) bool {
+	t.Helper()
+	if !hasOption(m, "WithMergerOrderShuffling") {
+		t.Errorf("AssertOrderInsensitive: manager is not created with WithMergerOrderShuffling")
+		return false
+	}
+	ok := true
+	for i, in := range inputs {
+		var first *pipeline.Data
+		var firstErr error
+		for r := 0; r < runs; r++ {
+			out, err := m.Handle(clone(in))
+			if r == 0 {
+				first, firstErr = out, err
+				continue
+			}
+			if !reflect.DeepEqual(out, first) || (err == nil) != (firstErr == nil) ||
+				err != nil && err.Error() != firstErr.Error() {
+				t.Errorf("input[%d] run %d: output (%v, %v) differs from first run (%v, %v), merger may depend on input order",
+					i, r, out, err, first, firstErr)
+				ok = false
+				break
+			}
+		}
+	}
+	return ok
+}
+
+// m 是否开启了名为name 的配置，见 pipeline.CapabilityReport
+func hasOption(m *pipeline.Manager, name string) bool {
+	for _, option := range m.Capabilities().Options {
+		if option == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 数据的浅拷贝，Meta 复制一份，执行中修改Meta 不影响下一次执行
+func clone(d *pipeline.Data) *pipeline.Data {
+	if d == nil {
+		return nil
+	}
+	c := *d
+	if d.Meta != nil {
+		c.Meta = make(map[string]interface{}, len(d.Meta))
+		for k, v := range d.Meta {
+			c.Meta[k] = v
+		}
+	}
+	return &c
+}
@@ -0,0 +1,77 @@
+package pipelinetest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/caigoumiao/pipeline"
+)
+
+// 记录错误的 TestingT
+type recordingT struct {
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+// 分裂成三个分支分别加上不同后缀，再由merge 合并
+func newJoinManager(t *testing.T, merge pipeline.MergerFunc, opts ...pipeline.Option) *pipeline.Manager {
+	m := pipeline.NewManager(opts...)
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *pipeline.Data) ([]*pipeline.Data, error) {
+		return []*pipeline.Data{{Data: in.Data}, {Data: in.Data}, {Data: in.Data}}, nil
+	})
+	edges := [][]string{{pipeline.Head, "d1"}, {"m1", pipeline.Tail}}
+	for _, suffix := range []string{"x", "y", "z"} {
+		suffix := suffix
+		_ = m.AddWorkerNode(suffix, func(ctx context.Context, in *pipeline.Data) (*pipeline.Data, error) {
+			return &pipeline.Data{Data: in.Data.(string) + suffix}, nil
+		})
+		edges = append(edges, []string{"d1", suffix}, []string{suffix, "m1"})
+	}
+	_ = m.AddMergerNode("m1", merge)
+	if err := m.BuildPipeline(edges); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func joinInputs(in []*pipeline.Data) []string {
+	parts := make([]string, len(in))
+	for i, d := range in {
+		parts[i] = d.Data.(string)
+	}
+	return parts
+}
+
+// 测试依赖输入顺序的合并方法被发现，与顺序无关的合并方法通过，没有开启打乱顺序时报错
+func TestAssertOrderInsensitive(t *testing.T) {
+	sensitive := func(ctx context.Context, in []*pipeline.Data) (*pipeline.Data, error) {
+		return &pipeline.Data{Data: strings.Join(joinInputs(in), ",")}, nil
+	}
+	insensitive := func(ctx context.Context, in []*pipeline.Data) (*pipeline.Data, error) {
+		parts := joinInputs(in)
+		sort.Strings(parts)
+		return &pipeline.Data{Data: strings.Join(parts, ",")}, nil
+	}
+	inputs := []*pipeline.Data{{Data: "a"}, {Data: "b"}}
+
+	rt := &recordingT{}
+	if AssertOrderInsensitive(rt, newJoinManager(t, sensitive, pipeline.WithMergerOrderShuffling(1)), inputs, 20) || len(rt.errors) == 0 {
+		t.Errorf("order sensitive merger should be caught")
+	}
+	rt = &recordingT{}
+	if !AssertOrderInsensitive(rt, newJoinManager(t, insensitive, pipeline.WithMergerOrderShuffling(1)), inputs, 20) {
+		t.Errorf("order insensitive merger reported: %v", rt.errors)
+	}
+	rt = &recordingT{}
+	if AssertOrderInsensitive(rt, newJoinManager(t, insensitive), inputs, 20) || len(rt.errors) != 1 {
+		t.Errorf("errors %v, want the missing option reported", rt.errors)
+	}
+}
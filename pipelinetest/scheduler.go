@@ -0,0 +1,222 @@
+package pipelinetest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/caigoumiao/pipeline"
+)
+
+// 按步骤驱动依赖时间的流水线的测试工具，同时也是 pipeline.Clock：时间只在调用 Advance 时前进
+// 用 Option 创建Manager 之后，AwaitNodeBlocked 等待节点开始等待时间（例如重试的退避、nodes.Delay），
+// 再 Advance 让时间前进，AwaitNodeFinished 等待节点执行完成，测试可以顺序编写，不需要sleep
+// 节点开始等待时间时，等待记在最近开始且还没有完成的节点上，适用于同时只有一个执行的测试
+type Scheduler struct {
+	t       TestingT
+	timeout time.Duration
+	mu      sync.Mutex
+	now     time.Time
+	timers  []*schedulerTimer
+	// 开始执行但还没有完成的节点，按开始的顺序
+	running []string
+	// 每个节点还没有触发的定时器数量
+	blocked map[string]int
+	// 每个节点完成的次数，以及已经被 AwaitNodeFinished 等到的次数
+	finished, awaited map[string]int
+	// 状态变化时关闭并重新创建
+	changed chan struct{}
+}
+
+type schedulerTimer struct {
+	s    *Scheduler
+	at   time.Time
+	node string
+	ch   chan time.Time
+	// AfterFunc 注册的回调，ch 为nil
+	f func()
+}
+
+// 创建 Scheduler，时间从 2020-01-01 00:00:00 UTC 开始，Await 系列方法默认最多等待5 秒
+func NewScheduler(t TestingT) *Scheduler {
+	return &Scheduler{
+		t:        t,
+		timeout:  5 * time.Second,
+		now:      time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		blocked:  make(map[string]int),
+		finished: make(map[string]int),
+		awaited:  make(map[string]int),
+		changed:  make(chan struct{}),
+	}
+}
+
+// 设置 Await 系列方法等待的最长（真实）时间
+func (s *Scheduler) SetAwaitTimeout(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timeout = d
+}
+
+// Manager 使用 Scheduler 作为时间来源，并跟踪节点的开始和完成
+// Option 会设置 Manager 的监听者，需要同时监听节点事件时把监听者传给 Option，不要再使用 pipeline.WithListener
+func (s *Scheduler) Option(listeners ...pipeline.Listener) pipeline.Option {
+	return func(m *pipeline.Manager) {
+		pipeline.WithClock(s)(m)
+		pipeline.WithStartListener(s.start)(m)
+		pipeline.WithListener(func(ev pipeline.NodeEvent) {
+			if ev.Outcome != pipeline.OutcomeStalled {
+				s.finish(ev.Node)
+			}
+			for _, l := range listeners {
+				l(ev)
+			}
+		})(m)
+	}
+}
+
+func (s *Scheduler) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+func (s *Scheduler) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d <= 0 {
+		ch <- s.now
+		return ch
+	}
+	s.add(&schedulerTimer{s: s, at: s.now.Add(d), ch: ch})
+	return ch
+}
+
+func (s *Scheduler) AfterFunc(d time.Duration, f func()) pipeline.Timer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := &schedulerTimer{s: s, at: s.now.Add(d), f: f}
+	s.add(t)
+	return t
+}
+
+func (t *schedulerTimer) Stop() bool {
+	s := t.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, other := range s.timers {
+		if other == t {
+			s.timers = append(s.timers[:i], s.timers[i+1:]...)
+			s.blocked[t.node]--
+			s.signal()
+			return true
+		}
+	}
+	return false
+}
+
+// 时间前进d，到期的定时器按注册的顺序触发，AfterFunc 的回调在返回之前同步调用
+func (s *Scheduler) Advance(d time.Duration) {
+	s.mu.Lock()
+	s.now = s.now.Add(d)
+	var fired []func()
+	pending := make([]*schedulerTimer, 0, len(s.timers))
+	for _, t := range s.timers {
+		if t.at.After(s.now) {
+			pending = append(pending, t)
+			continue
+		}
+		s.blocked[t.node]--
+		if t.f != nil {
+			fired = append(fired, t.f)
+		} else {
+			t.ch <- s.now
+		}
+	}
+	s.timers = pending
+	s.signal()
+	s.mu.Unlock()
+	for _, f := range fired {
+		f()
+	}
+}
+
+// 等待节点name 开始等待时间，超时时报告错误并返回false
+func (s *Scheduler) AwaitNodeBlocked(name string) bool {
+	s.t.Helper()
+	return s.await("node["+name+"] to block on the clock", func() bool {
+		return s.blocked[name] > 0
+	})
+}
+
+// 等待节点name 再完成一次，每次调用对应一次完成，超时时报告错误并返回false
+func (s *Scheduler) AwaitNodeFinished(name string) bool {
+	s.t.Helper()
+	if !s.await("node["+name+"] to finish", func() bool {
+		return s.finished[name] > s.awaited[name]
+	}) {
+		return false
+	}
+	s.mu.Lock()
+	s.awaited[name]++
+	s.mu.Unlock()
+	return true
+}
+
+// 等待cond 成立，cond 在持有锁时调用
+func (s *Scheduler) await(what string, cond func() bool) bool {
+	s.t.Helper()
+	s.mu.Lock()
+	deadline := time.NewTimer(s.timeout)
+	timeout := s.timeout
+	s.mu.Unlock()
+	defer deadline.Stop()
+	for {
+		s.mu.Lock()
+		ok, changed := cond(), s.changed
+		s.mu.Unlock()
+		if ok {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-deadline.C:
+			s.t.Errorf("Scheduler: timed out after %v waiting for %s", timeout, what)
+			return false
+		}
+	}
+}
+
+// 定时器记在最近开始且还没有完成的节点上，调用时持有锁
+func (s *Scheduler) add(t *schedulerTimer) {
+	if n := len(s.running); n > 0 {
+		t.node = s.running[n-1]
+	}
+	s.timers = append(s.timers, t)
+	s.blocked[t.node]++
+	s.signal()
+}
+
+func (s *Scheduler) start(node string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = append(s.running, node)
+	s.signal()
+}
+
+func (s *Scheduler) finish(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.running) - 1; i >= 0; i-- {
+		if s.running[i] == name {
+			s.running = append(s.running[:i], s.running[i+1:]...)
+			break
+		}
+	}
+	s.finished[name]++
+	s.signal()
+}
+
+func (s *Scheduler) signal() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
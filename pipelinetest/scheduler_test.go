@@ -0,0 +1,156 @@
+package pipelinetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caigoumiao/pipeline"
+	"github.com/caigoumiao/pipeline/nodes"
+)
+
+func passWorker(ctx context.Context, in *pipeline.Data) (*pipeline.Data, error) {
+	return in, nil
+}
+
+// 等待记在正在执行的节点上，没有等到时报告错误
+func TestScheduler(t *testing.T) {
+	s := NewScheduler(t)
+	var events []string
+	m := pipeline.NewManager(pipeline.WithEnv(pipeline.Env{}), s.Option(func(ev pipeline.NodeEvent) {
+		events = append(events, ev.Node)
+	}))
+	_ = m.AddWorkerNode("w1", passWorker)
+	_ = m.AddWorkerNode("sleep", nodes.Delay(time.Minute))
+	if err := m.BuildPipeline([][]string{{pipeline.Head, "w1"}, {"w1", "sleep"}, {"sleep", pipeline.Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	start := s.Now()
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.HandleContext(context.Background(), &pipeline.Data{})
+		done <- err
+	}()
+	if !s.AwaitNodeFinished("w1") || !s.AwaitNodeBlocked("sleep") {
+		t.FailNow()
+	}
+	s.Advance(time.Minute)
+	if !s.AwaitNodeFinished("sleep") {
+		t.FailNow()
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Now().Sub(start); got != time.Minute {
+		t.Errorf("clock advanced %v, want 1m", got)
+	}
+	if len(events) != 2 {
+		t.Errorf("listeners passed to Option should still get events, got %v", events)
+	}
+
+	rt := &recordingT{}
+	s = NewScheduler(rt)
+	s.SetAwaitTimeout(10 * time.Millisecond)
+	if s.AwaitNodeBlocked("w1") || s.AwaitNodeFinished("w1") || len(rt.errors) != 2 {
+		t.Errorf("want timeouts to be reported, got %v", rt.errors)
+	}
+
+	// 取消的定时器不再算作等待
+	s = NewScheduler(t)
+	timer := s.AfterFunc(time.Second, func() { t.Error("stopped timer fired") })
+	if !timer.Stop() || timer.Stop() {
+		t.Error("Stop should succeed exactly once")
+	}
+	s.Advance(time.Second)
+}
+
+// d1 分出fast、slow 两个分支后在m1 合并，m1 最多等待1 秒，slow 等待delay 之后才完成
+// slow 开始等待后时间前进delay，返回执行的结果
+func runMergeTimeout(t *testing.T, delay time.Duration, policy pipeline.MergeTimeoutPolicy) (*pipeline.Data, error) {
+	s := NewScheduler(t)
+	m := pipeline.NewManager(pipeline.WithEnv(pipeline.Env{}), s.Option())
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *pipeline.Data) ([]*pipeline.Data, error) {
+		return []*pipeline.Data{{Data: "fast"}, {Data: "slow"}}, nil
+	})
+	_ = m.AddWorkerNode("fast", passWorker)
+	_ = m.AddWorkerNode("slow", nodes.Delay(delay))
+	_ = m.AddMergerNode("m1", func(ctx context.Context, in []*pipeline.Data) (*pipeline.Data, error) {
+		var parts []string
+		for _, data := range in {
+			parts = append(parts, data.Data.(string))
+		}
+		return &pipeline.Data{Data: strings.Join(parts, ",")}, nil
+	}, pipeline.WithMergeTimeout(time.Second, policy))
+	if err := m.BuildPipeline([][]string{
+		{pipeline.Head, "d1"}, {"d1", "fast"}, {"d1", "slow"}, {"fast", "m1"}, {"slow", "m1"}, {"m1", pipeline.Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	type result struct {
+		out *pipeline.Data
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := m.Handle(&pipeline.Data{})
+		done <- result{out, err}
+	}()
+	s.AwaitNodeBlocked("slow")
+	s.Advance(delay)
+	s.AwaitNodeFinished("slow")
+	r := <-done
+	return r.out, r.err
+}
+
+// 按步骤测试合并节点的等待超时：超时后使用已经到达的输入或者失败，在等待时间内到达则正常合并
+func TestScheduler_MergeTimeout(t *testing.T) {
+	out, err := runMergeTimeout(t, 2*time.Second, pipeline.ProceedWithPartial)
+	if err != nil || out.Data != "fast" {
+		t.Errorf("out=%v err=%v, want fast", out, err)
+	}
+	out, err = runMergeTimeout(t, 500*time.Millisecond, pipeline.ProceedWithPartial)
+	if err != nil || out.Data != "fast,slow" {
+		t.Errorf("out=%v err=%v, want fast,slow", out, err)
+	}
+	_, err = runMergeTimeout(t, 2*time.Second, pipeline.FailOnMergeTimeout)
+	if !errors.Is(err, pipeline.ErrMergeTimeout) || !strings.Contains(err.Error(), "missing branches [slow]") {
+		t.Errorf("err=%v, want ErrMergeTimeout missing slow", err)
+	}
+}
+
+// 按步骤测试重试的退避：每次节点开始等待之后让时间前进对应的退避时间
+func TestScheduler_RetryBackoff(t *testing.T) {
+	s := NewScheduler(t)
+	errUnavailable := errors.New("unavailable")
+	m := pipeline.NewManager(s.Option())
+	_ = m.AddWorkerNode("w1", func(ctx context.Context, in *pipeline.Data) (*pipeline.Data, error) {
+		return nil, errUnavailable
+	}, pipeline.WithRetry(3), pipeline.WithBackoff(pipeline.BackoffFunc(func(attempt int) time.Duration {
+		return time.Duration(attempt) * time.Second
+	})))
+	if err := m.BuildPipeline([][]string{{pipeline.Head, "w1"}, {"w1", pipeline.Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	trace := &pipeline.Trace{}
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.HandleContext(context.Background(), &pipeline.Data{}, pipeline.WithTrace(trace))
+		done <- err
+	}()
+	for _, d := range []time.Duration{time.Second, 2 * time.Second} {
+		if !s.AwaitNodeBlocked("w1") {
+			t.FailNow()
+		}
+		s.Advance(d)
+	}
+	if err := <-done; !errors.Is(err, errUnavailable) {
+		t.Fatalf("want the last error, got %v", err)
+	}
+	entry := trace.Entries()[0]
+	if entry.Attempts != 3 || !reflect.DeepEqual(entry.Backoffs, []time.Duration{time.Second, 2 * time.Second}) {
+		t.Errorf("attempts %d backoffs %v, want 3 attempts with 1s, 2s", entry.Attempts, entry.Backoffs)
+	}
+}
@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// 从next 中按需拉取数据执行，每条数据的结果交给sink，用于不适合channel 的数据来源，例如分页的接口
+// 同时执行的数据最多为 WithStreamConcurrency 条，有空闲的执行槽位时才调用next，next 不会被并发调用；
+// next 返回false 或错误时不再拉取，等已经拉取的数据都交给sink 之后返回，next 的错误作为返回值
+// sink 不会被并发调用，执行失败时out 为nil、err 为 StreamError；sink 返回错误时取消其他数据的执行，
+// 不再调用sink，并返回该错误；ctx 结束时返回ctx 的错误
+// 支持 WithStreamConcurrency、WithOrderedOutput、WithStreamStats、WithSaturationChange，其余 StreamOption 不生效
+func (m *Manager) HandlePull(ctx context.Context, next func(ctx context.Context) (*rawData, bool, error),
+	sink func(ctx context.Context, out *rawData, err error) error, opts ...StreamOption) error {
+	o := streamOptions{concurrency: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.concurrency <= 0 {
+		o.concurrency = 1
+	}
+	if o.saturation != nil && o.stats == nil {
+		o.stats = &StreamStats{}
+	}
+	if o.stats != nil {
+		o.stats.notify = o.saturation
+		atomic.StoreInt64(&o.stats.capacity, int64(o.concurrency))
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	p := &puller{next: next, sink: sink, cancel: cancel}
+	if o.ordered {
+		p.order = newStreamOrder()
+	}
+	if m.runner != nil {
+		// 每条数据直接交给 Runner 执行，有空闲的槽位时在当前goroutine 中拉取
+		g := m.newRunnerGroup(o.concurrency)
+		for g.acquire(context.Background()) == nil {
+			item, ok := p.pull(runCtx)
+			if !ok {
+				g.release()
+				break
+			}
+			o.stats.addLoad(1)
+			if err := g.run(runCtx, func() {
+				m.handlePullItem(runCtx, p, item)
+				o.stats.addLoad(-1)
+				o.stats.processed(0)
+			}); err != nil {
+				m.sinkPullItem(runCtx, p, item, nil, err)
+				o.stats.addLoad(-1)
+				o.stats.processed(0)
+			}
+		}
+		g.wait()
+	} else {
+		var wg sync.WaitGroup
+		for i := 0; i < o.concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					item, ok := p.pull(runCtx)
+					if !ok {
+						return
+					}
+					o.stats.addLoad(1)
+					m.handlePullItem(runCtx, p, item)
+					o.stats.addLoad(-1)
+					o.stats.processed(0)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	if p.err == nil {
+		return ctx.Err()
+	}
+	return p.err
+}
+
+// HandlePull 的状态
+type puller struct {
+	next   func(ctx context.Context) (*rawData, bool, error)
+	sink   func(ctx context.Context, out *rawData, err error) error
+	cancel context.CancelFunc
+	order  *streamOrder
+	// 串行调用next，done 之后不再拉取
+	pullMu sync.Mutex
+	seq    uint64
+	done   bool
+	// 串行调用sink，sink 返回错误之后aborted 为true
+	sinkMu  sync.Mutex
+	aborted bool
+	// 第一个next 或sink 的错误
+	mu  sync.Mutex
+	err error
+}
+
+// 拉取下一条数据，没有更多数据、出错或ctx 结束时返回false
+func (p *puller) pull(ctx context.Context) (streamItem, bool) {
+	p.pullMu.Lock()
+	defer p.pullMu.Unlock()
+	if p.done || ctx.Err() != nil {
+		return streamItem{}, false
+	}
+	in, ok, err := p.next(ctx)
+	if err != nil {
+		p.fail(err)
+	}
+	if err != nil || !ok {
+		p.done = true
+		return streamItem{}, false
+	}
+	item := streamItem{seq: p.seq, in: in}
+	p.seq++
+	return item, true
+}
+
+// 记录第一个错误
+func (p *puller) fail(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+}
+
+func (m *Manager) handlePullItem(ctx context.Context, p *puller, item streamItem) {
+	out, err := m.handleAdaptive(ctx, item.in)
+	m.sinkPullItem(ctx, p, item, out, err)
+}
+
+// 把一条数据的结果交给sink，开启 WithOrderedOutput 时等待轮到该数据
+func (m *Manager) sinkPullItem(ctx context.Context, p *puller, item streamItem, out *rawData, err error) {
+	if p.order != nil {
+		if !p.order.wait(item.seq) {
+			return
+		}
+		defer p.order.done()
+	}
+	if err != nil {
+		out, err = nil, m.streamError(item, err)
+	}
+	p.sinkMu.Lock()
+	defer p.sinkMu.Unlock()
+	if p.aborted {
+		return
+	}
+	if serr := p.sink(ctx, out, err); serr != nil {
+		p.aborted = true
+		p.fail(serr)
+		p.cancel()
+		p.order.cancel()
+	}
+}
@@ -0,0 +1,150 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 分页的数据来源，每次请求一页，next 逐条返回；记录调用次数以及已经拉取、还没有交给sink 的条数
+type pagedSource struct {
+	mu       sync.Mutex
+	total    int
+	pageSize int
+	failAt   int
+	page     []int
+	served   int
+	calls    int
+	pages    int
+	inflight int
+	maxIn    int
+}
+
+func (s *pagedSource) next(ctx context.Context) (*rawData, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if len(s.page) == 0 {
+		if s.served >= s.total {
+			return nil, false, nil
+		}
+		if s.failAt > 0 && s.served >= s.failAt {
+			return nil, false, errors.New("page request failed")
+		}
+		s.pages++
+		for i := s.served; i < s.total && i < s.served+s.pageSize; i++ {
+			s.page = append(s.page, i)
+		}
+	}
+	v := s.page[0]
+	s.page = s.page[1:]
+	s.served++
+	s.inflight++
+	if s.inflight > s.maxIn {
+		s.maxIn = s.inflight
+	}
+	return &rawData{Data: v}, true, nil
+}
+
+func (s *pagedSource) delivered() {
+	s.mu.Lock()
+	s.inflight--
+	s.mu.Unlock()
+}
+
+// 测试只在有空闲槽位时拉取，全部数据交给sink 后正常结束
+func TestManager_HandlePull(t *testing.T) {
+	src := &pagedSource{total: 20, pageSize: 3}
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		time.Sleep(time.Millisecond)
+		if in.Data.(int) == 7 {
+			return nil, errors.New("bad item")
+		}
+		return in, nil
+	})
+	seen := make(map[int]bool)
+	var failed []StreamError
+	err := m.HandlePull(context.Background(), src.next, func(ctx context.Context, out *rawData, err error) error {
+		defer src.delivered()
+		if err != nil {
+			failed = append(failed, err.(StreamError))
+			return nil
+		}
+		seen[out.Data.(int)] = true
+		return nil
+	}, WithStreamConcurrency(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 19 || len(failed) != 1 || failed[0].Node != "w1" || failed[0].Input.Data != 7 {
+		t.Errorf("seen %d outputs, failed %v", len(seen), failed)
+	}
+	if src.maxIn > 3 {
+		t.Errorf("%d items pulled before delivery, want at most 3", src.maxIn)
+	}
+	// 数据取完之后只多调用一次
+	if src.calls != 21 || src.pages != 7 {
+		t.Errorf("calls=%d pages=%d, want 21 calls over 7 pages", src.calls, src.pages)
+	}
+}
+
+// 测试按拉取的顺序交给sink
+func TestManager_HandlePullOrdered(t *testing.T) {
+	src := &pagedSource{total: 30, pageSize: 4}
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		time.Sleep(time.Duration(3-in.Data.(int)%3) * time.Millisecond)
+		return in, nil
+	})
+	var got []int
+	err := m.HandlePull(context.Background(), src.next, func(ctx context.Context, out *rawData, err error) error {
+		got = append(got, out.Data.(int))
+		return err
+	}, WithStreamConcurrency(4), WithOrderedOutput())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("output %d is item %d, want in pull order: %v", i, v, got)
+		}
+	}
+	if len(got) != 30 {
+		t.Errorf("got %d outputs, want 30", len(got))
+	}
+}
+
+// 测试sink 返回错误时停止拉取并返回该错误，next 的错误同样结束执行
+func TestManager_HandlePullAbort(t *testing.T) {
+	errFull := errors.New("sink is full")
+	src := &pagedSource{total: 1000, pageSize: 10}
+	m := newSingleWorkerManager(t, passWorker)
+	sunk := 0
+	err := m.HandlePull(context.Background(), src.next, func(ctx context.Context, out *rawData, err error) error {
+		sunk++
+		if sunk == 5 {
+			return errFull
+		}
+		return nil
+	}, WithStreamConcurrency(2))
+	if err != errFull {
+		t.Fatalf("err=%v, want sink error", err)
+	}
+	if sunk != 5 || src.served > 5+2 {
+		t.Errorf("sink called %d times, %d items pulled after abort", sunk, src.served)
+	}
+
+	src = &pagedSource{total: 100, pageSize: 10, failAt: 20}
+	sunk = 0
+	err = m.HandlePull(context.Background(), src.next, func(ctx context.Context, out *rawData, err error) error {
+		sunk++
+		return nil
+	}, WithStreamConcurrency(2))
+	if err == nil || err.Error() != "page request failed" {
+		t.Fatalf("err=%v, want source error", err)
+	}
+	if sunk != 20 {
+		t.Errorf("sink called %d times, want the 20 items pulled before the error", sunk)
+	}
+}
@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 测试执行槽位被占用时，等待的时间计入第一个节点的QueueWait，不计入执行时间
+func TestManager_QueueWait(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var waits []time.Duration
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		if in.Data == "holder" {
+			close(started)
+			<-release
+		}
+		return in, nil
+	}, WithMaxInflightExecutions(1, OverflowBlock), WithListener(func(ev NodeEvent) {
+		mu.Lock()
+		waits = append(waits, ev.QueueWait)
+		mu.Unlock()
+	}))
+	go func() {
+		_, _ = m.Handle(&rawData{Data: "holder"})
+	}()
+	<-started
+
+	done := make(chan struct{})
+	var tr Trace
+	go func() {
+		defer close(done)
+		if _, err := m.HandleContext(context.Background(), &rawData{Data: "blocked"}, WithTrace(&tr)); err != nil {
+			t.Error(err)
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	<-done
+
+	entries := tr.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("entries=%v", entries)
+	}
+	if entries[0].QueueWait < 25*time.Millisecond || entries[0].Duration > 10*time.Millisecond {
+		t.Errorf("queue wait=%v exec=%v, want a long queue wait and a short exec time", entries[0].QueueWait, entries[0].Duration)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	found := false
+	for _, w := range waits {
+		found = found || w == entries[0].QueueWait
+	}
+	if !found {
+		t.Errorf("event queue waits=%v, trace=%v", waits, entries[0].QueueWait)
+	}
+}
@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type randKey struct{}
+
+// 每次执行使用独立的随机数来源，种子由执行的标识得出并记录在执行轨迹中（Trace.Seed），
+// 节点处理方法通过 RandFrom(ctx) 取得；不设置时只有使用 WithSeed 的执行有独立的随机数来源
+func WithExecutionRand() Option {
+	return func(m *Manager) {
+		m.executionRand = true
+	}
+}
+
+// 本次执行使用以s 为种子的随机数来源，用相同的种子重放可以复现每一次随机选择
+func WithSeed(s int64) CallOption {
+	return func(o *callOptions) {
+		o.seed = &s
+	}
+}
+
+var sharedRand = rand.New(&lockedSource{src: rand.NewSource(time.Now().UnixNano())})
+
+// 返回本次执行的随机数来源，只能在本次执行中使用
+// 没有开启 WithExecutionRand 也没有使用 WithSeed 时返回全局共享的随机数来源，结果不可复现
+func RandFrom(ctx context.Context) *rand.Rand {
+	if r, ok := ctx.Value(randKey{}).(*rand.Rand); ok {
+		return r
+	}
+	return sharedRand
+}
+
+// 创建本次执行的随机数来源并注入ctx，seed 为空时由执行的标识得出
+func (e *execution) seedRand(seed *int64) {
+	var s int64
+	if seed != nil {
+		s = *seed
+	} else {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(e.id()))
+		s = int64(h.Sum64())
+	}
+	src := rand.NewSource(s)
+	if e.m.parallelism > 0 {
+		// 并行执行时多个节点可能同时使用
+		src = &lockedSource{src: src}
+	}
+	e.rnd = rand.New(src)
+	e.ctx = context.WithValue(e.ctx, randKey{}, e.rnd)
+	if e.trace != nil {
+		e.trace.setSeed(s)
+	}
+}
+
+// 按权重随机选择分支的判断节点，第i 个权重对应第i 个分支，使用 RandFrom(ctx)
+func WeightedJudger(weights ...float64) JudgerFunc {
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	return func(ctx context.Context, in *rawData) int {
+		x := RandFrom(ctx).Float64() * total
+		for i, w := range weights {
+			if x < w {
+				return i
+			}
+			x -= w
+		}
+		return len(weights) - 1
+	}
+}
+
+// 并发安全的 rand.Source
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
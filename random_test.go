@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// head -> sample -> j1 按权重分给 w0、w1、w2，sample 把一个随机数写入数据
+func newWeightedManager(t *testing.T, opts ...Option) *Manager {
+	m := NewManager(opts...)
+	if err := m.AddWorkerNode("sample", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: RandFrom(ctx).Int63()}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddJudgerNode("j1", WeightedJudger(1, 2, 3)); err != nil {
+		t.Fatal(err)
+	}
+	edges := [][]string{{Head, "sample"}, {"sample", "j1"}}
+	for _, name := range []string{"w0", "w1", "w2"} {
+		if err := m.AddWorkerNode(name, passWorker); err != nil {
+			t.Fatal(err)
+		}
+		edges = append(edges, []string{"j1", name}, []string{name, Tail})
+	}
+	if err := m.BuildPipeline(edges); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// 用seeds 中的每个种子执行一次，返回每次的随机数和判断节点的决策
+func weightedRuns(t *testing.T, m *Manager, seeds []int64) (samples []int64, branches []string) {
+	for _, seed := range seeds {
+		trace := &Trace{}
+		out, err := m.HandleContext(context.Background(), &rawData{}, WithSeed(seed), WithTrace(trace))
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples = append(samples, out.Data.(int64))
+		for _, entry := range trace.Entries() {
+			if entry.Node == "j1" {
+				branches = append(branches, entry.Branch)
+			}
+		}
+	}
+	return
+}
+
+func TestManager_SeedReproducesWeightedJudger(t *testing.T) {
+	m := newWeightedManager(t)
+	seeds := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	samples1, branches1 := weightedRuns(t, m, seeds)
+	samples2, branches2 := weightedRuns(t, m, seeds)
+	if !reflect.DeepEqual(samples1, samples2) || !reflect.DeepEqual(branches1, branches2) {
+		t.Errorf("same seeds diverged: %v %v, %v %v", samples1, branches1, samples2, branches2)
+	}
+	other := make([]int64, len(seeds))
+	for i, seed := range seeds {
+		other[i] = seed + 1000
+	}
+	samples3, branches3 := weightedRuns(t, m, other)
+	if reflect.DeepEqual(samples1, samples3) || reflect.DeepEqual(branches1, branches3) {
+		t.Errorf("different seeds should diverge: %v %v", branches1, branches3)
+	}
+}
+
+// 测试种子记录在执行轨迹中，用记录的种子重放得到相同的结果
+func TestManager_ExecutionRandReplay(t *testing.T) {
+	m := newWeightedManager(t, WithExecutionRand())
+	trace := &Trace{}
+	out, err := m.HandleContext(context.Background(), &rawData{}, WithTrace(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, ok := trace.Seed()
+	if !ok {
+		t.Fatal("seed not recorded in trace")
+	}
+	replay, err := m.HandleContext(context.Background(), &rawData{}, WithSeed(seed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.Data != out.Data {
+		t.Errorf("replay=%v, want %v", replay.Data, out.Data)
+	}
+	if _, ok = (&Trace{}).Seed(); ok {
+		t.Errorf("empty trace should have no seed")
+	}
+}
+
+func TestManager_SeedReproducesJitteredBackoff(t *testing.T) {
+	m := NewManager()
+	if err := m.AddWorkerNode("flaky", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return nil, errors.New("boom")
+	}, WithRetry(4), WithBackoff(ExponentialJitterBackoff(100*time.Microsecond))); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.BuildPipeline([][]string{{Head, "flaky"}, {"flaky", Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	backoffs := func(seed int64) []time.Duration {
+		trace := &Trace{}
+		if _, err := m.HandleContext(context.Background(), &rawData{}, WithSeed(seed), WithTrace(trace)); err == nil {
+			t.Fatal("want error")
+		}
+		return trace.Entries()[0].Backoffs
+	}
+	first := backoffs(42)
+	if len(first) != 3 {
+		t.Fatalf("backoffs=%v, want 3", first)
+	}
+	if again := backoffs(42); !reflect.DeepEqual(first, again) {
+		t.Errorf("same seed: %v != %v", first, again)
+	}
+	if other := backoffs(43); reflect.DeepEqual(first, other) {
+		t.Errorf("different seeds should diverge: %v", other)
+	}
+}
@@ -0,0 +1,47 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+)
+
+// 节点的处理方法中可以再次调用同一个Manager 的 Handle/HandleContext（需要传入收到的ctx），
+// 每次调用都有独立的执行状态
+// 设置了 WithMaxRecursionDepth 或 WithMaxInflightExecutions 时，嵌套的深度通过ctx 传递：
+// 超过深度上限时返回 *RecursionDepthError；嵌套的调用沿用最外层调用的执行名额，不会等待自己占用的名额
+
+// 嵌套调用的深度超过上限
+type RecursionDepthError struct {
+	Depth int
+	Max   int
+}
+
+func (e *RecursionDepthError) Error() string {
+	return fmt.Sprintf("recursion depth %d exceeds max %d", e.Depth, e.Max)
+}
+
+// 限制在节点中嵌套调用同一个Manager 的深度，最外层的调用深度为1，0 表示不限制
+func WithMaxRecursionDepth(n int) Option {
+	return func(m *Manager) {
+		m.maxRecursion = n
+	}
+}
+
+type recursionKey struct {
+	m *Manager
+}
+
+// 是否需要通过ctx 记录嵌套深度
+func (m *Manager) tracksRecursion() bool {
+	return m.maxRecursion > 0 || m.inflight.sem != nil
+}
+
+// 进入一层调用，返回记录了当前深度的ctx
+func (m *Manager) enterRecursion(ctx context.Context) (context.Context, int, error) {
+	depth, _ := ctx.Value(recursionKey{m}).(int)
+	depth++
+	if m.maxRecursion > 0 && depth > m.maxRecursion {
+		return ctx, depth, &RecursionDepthError{Depth: depth, Max: m.maxRecursion}
+	}
+	return context.WithValue(ctx, recursionKey{m}, depth), depth, nil
+}
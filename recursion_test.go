@@ -0,0 +1,48 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 处理方法中递归调用同一个Manager，直到嵌套levels 层
+func newRecursiveManager(t *testing.T, levels int, opts ...Option) *Manager {
+	var m *Manager
+	m = newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		depth := in.Data.(int)
+		if depth >= levels {
+			return in, nil
+		}
+		return m.HandleContext(ctx, &rawData{Data: depth + 1})
+	}, opts...)
+	return m
+}
+
+// 测试嵌套3 层的调用成功，并且执行名额为1 时不会死锁
+func TestManager_Recursion(t *testing.T) {
+	m := newRecursiveManager(t, 3, WithMaxRecursionDepth(3), WithMaxInflightExecutions(1, OverflowBlock))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	out, err := m.HandleContext(ctx, &rawData{Data: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Data.(int) != 3 {
+		t.Errorf("out=%v, want 3", out.Data)
+	}
+	if n := m.InflightExecutions(); n != 0 {
+		t.Errorf("inflight=%d after return", n)
+	}
+}
+
+// 测试嵌套超过上限时返回 RecursionDepthError
+func TestManager_RecursionTooDeep(t *testing.T) {
+	m := newRecursiveManager(t, 4, WithMaxRecursionDepth(3))
+	_, err := m.HandleContext(context.Background(), &rawData{Data: 1})
+	var depthErr *RecursionDepthError
+	if !errors.As(err, &depthErr) || depthErr.Depth != 4 || depthErr.Max != 3 {
+		t.Fatalf("err=%v, want RecursionDepthError at depth 4", err)
+	}
+}
@@ -0,0 +1,165 @@
+package pipeline
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// 对交给观察者的数据进行脱敏，返回脱敏后的数据：死信和流式处理的失败记录中的输入、诊断信息中的数据，
+// 以及监听事件、执行轨迹、死信、流式处理的失败记录和执行摘要中的错误文本里出现的数据原文（见 redactError）
+// 节点之间流转的数据以及返回给调用方的错误不受影响
+type PayloadRedactor func(d *rawData) *rawData
+
+// 设置数据脱敏方法，未设置时原样交出数据
+func WithPayloadRedactor(r PayloadRedactor) Option {
+	return func(m *Manager) {
+		m.redactor = r
+	}
+}
+
+// 脱敏方法拿到的是数据的浅拷贝（Meta 也会复制一份），直接修改字段不会影响原始数据
+func (m *Manager) redact(d *rawData) *rawData {
+	if m.redactor == nil || d == nil {
+		return d
+	}
+	return m.redactor(cloneData(d))
+}
+
+// 返回数据的浅拷贝，Meta 也会复制一份
+func cloneData(d *rawData) *rawData {
+	if d == nil {
+		return nil
+	}
+	c := *d
+	if d.Meta != nil {
+		c.Meta = make(map[string]interface{}, len(d.Meta))
+		for k, v := range d.Meta {
+			c.Meta[k] = v
+		}
+	}
+	return &c
+}
+
+// 数据中短于这个长度的字符串不在错误的文本中替换，避免误伤错误文本中的其他内容
+const minRedactedLen = 4
+
+// 脱敏方法去掉的字段在错误的文本中替换成的内容
+const redactedText = "[REDACTED]"
+
+// 交给观察者的错误：文本中数据的原文替换成了脱敏后的内容，errors.Is、errors.As 仍然可以找到原来的错误
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// 错误的文本中出现了数据中的字符串（Data、Meta 中的值，包括结构体、map、切片中的字符串）的原文时，
+// 替换为脱敏后同一位置的内容；没有设置脱敏方法或者文本中没有出现原文时原样返回err
+func (m *Manager) redactError(err error, in *rawData, ins []*rawData) error {
+	if m.redactor == nil || err == nil {
+		return err
+	}
+	pairs := make(map[string]string)
+	for _, d := range append([]*rawData{in}, ins...) {
+		if d == nil {
+			continue
+		}
+		r := m.redact(d)
+		if r == nil {
+			r = &rawData{}
+		}
+		redactPairs(reflect.ValueOf(d.Data), reflect.ValueOf(r.Data), pairs, 0)
+		for k, v := range d.Meta {
+			redactPairs(reflect.ValueOf(v), reflect.ValueOf(r.Meta[k]), pairs, 0)
+		}
+	}
+	msg := err.Error()
+	originals := make([]string, 0, len(pairs))
+	for o := range pairs {
+		if strings.Contains(msg, o) {
+			originals = append(originals, o)
+		}
+	}
+	if len(originals) == 0 {
+		return err
+	}
+	// 先替换长的原文，包含在其他原文中的短原文不会打断替换
+	sort.Slice(originals, func(i, j int) bool {
+		if len(originals[i]) != len(originals[j]) {
+			return len(originals[i]) > len(originals[j])
+		}
+		return originals[i] < originals[j]
+	})
+	for _, o := range originals {
+		msg = strings.Replace(msg, o, pairs[o], -1)
+	}
+	return &redactedError{msg: msg, err: err}
+}
+
+// 按相同的位置配对原始数据和脱敏后的数据中的字符串，记录发生了变化的原文
+func redactPairs(orig, red reflect.Value, pairs map[string]string, depth int) {
+	if depth > 8 {
+		return
+	}
+	for orig.IsValid() && (orig.Kind() == reflect.Ptr || orig.Kind() == reflect.Interface) {
+		if orig.IsNil() {
+			return
+		}
+		orig = orig.Elem()
+	}
+	for red.IsValid() && (red.Kind() == reflect.Ptr || red.Kind() == reflect.Interface) {
+		if red.IsNil() {
+			red = reflect.Value{}
+			break
+		}
+		red = red.Elem()
+	}
+	if !orig.IsValid() {
+		return
+	}
+	if red.IsValid() && red.Type() != orig.Type() {
+		red = reflect.Value{}
+	}
+	switch orig.Kind() {
+	case reflect.String:
+		o, r := orig.String(), redactedText
+		if red.IsValid() {
+			r = red.String()
+		}
+		if len(o) >= minRedactedLen && o != r {
+			pairs[o] = r
+		}
+	case reflect.Struct:
+		for i := 0; i < orig.NumField(); i++ {
+			var rf reflect.Value
+			if red.IsValid() {
+				rf = red.Field(i)
+			}
+			redactPairs(orig.Field(i), rf, pairs, depth+1)
+		}
+	case reflect.Map:
+		for _, k := range orig.MapKeys() {
+			var rv reflect.Value
+			if red.IsValid() {
+				rv = red.MapIndex(k)
+			}
+			redactPairs(orig.MapIndex(k), rv, pairs, depth+1)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < orig.Len(); i++ {
+			var rv reflect.Value
+			if red.IsValid() && i < red.Len() {
+				rv = red.Index(i)
+			}
+			redactPairs(orig.Index(i), rv, pairs, depth+1)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/caigoumiao/pipeline/store"
+)
+
+// 测试流式处理的失败记录中是脱敏后的数据，输出和原始数据保持不变
+func TestManager_PayloadRedactor(t *testing.T) {
+	errBad := errors.New("bad item")
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		if in.Meta["fail"] == true {
+			return nil, errBad
+		}
+		return in, nil
+	}, WithPayloadRedactor(func(d *rawData) *rawData {
+		d.Data = "***"
+		delete(d.Meta, "phone")
+		return d
+	}))
+
+	good := &rawData{Data: "alice", Meta: map[string]interface{}{"phone": "123"}}
+	bad := &rawData{Data: "bob", Meta: map[string]interface{}{"phone": "456", "fail": true}}
+	in := make(chan *rawData, 2)
+	in <- good
+	in <- bad
+	close(in)
+	outs, errs := m.HandleStream(context.Background(), in)
+
+	out := <-outs
+	if out.Out.Data != "alice" || out.Out.Meta["phone"] != "123" {
+		t.Errorf("output should keep original values, got %+v", out.Out)
+	}
+	se := <-errs
+	if !errors.Is(se, errBad) || se.Input.Data != "***" || se.Input.Meta["phone"] != nil {
+		t.Errorf("dead letter should be redacted, got %+v", se.Input)
+	}
+	if bad.Data != "bob" || bad.Meta["phone"] != "456" {
+		t.Errorf("original input modified: %+v", bad)
+	}
+}
+
+type redactCustomer struct {
+	Name string
+	Card string
+}
+
+// 测试错误文本中出现的数据原文在监听事件、执行轨迹、死信、执行摘要和流式处理的失败记录中都被替换，
+// 返回给调用方的错误保持原样
+func TestManager_PayloadRedactorErrors(t *testing.T) {
+	errBad := errors.New("bad item")
+	var mu sync.Mutex
+	var events []error
+	dlq := store.NewMemory()
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		return nil, fmt.Errorf("%w: customer %+v phone %s", errBad, in.Data, in.Meta["phone"])
+	}, WithPayloadRedactor(func(d *rawData) *rawData {
+		c := d.Data.(redactCustomer)
+		c.Card = "****"
+		d.Data = c
+		delete(d.Meta, "phone")
+		return d
+	}), WithListener(func(ev NodeEvent) {
+		mu.Lock()
+		events = append(events, ev.Err)
+		mu.Unlock()
+	}), WithDeadLetterStore(dlq), WithExecutionHistory(4))
+	newInput := func() *rawData {
+		return &rawData{Data: redactCustomer{Name: "bob", Card: "4111-1111"}, Meta: map[string]interface{}{"phone": "555-0100"}}
+	}
+	const original = "bad item: customer {Name:bob Card:4111-1111} phone 555-0100"
+	const redacted = "bad item: customer {Name:bob Card:****} phone [REDACTED]"
+
+	trace := &Trace{}
+	_, err := m.HandleContext(context.Background(), newInput(), WithTrace(trace))
+	if err == nil || err.Error() != original {
+		t.Errorf("caller should get the original error, got %v", err)
+	}
+	if len(events) != 1 || events[0].Error() != redacted || !errors.Is(events[0], errBad) {
+		t.Errorf("listener events %v, want %q", events, redacted)
+	}
+	if entries := trace.Entries(); len(entries) != 1 || entries[0].Err.Error() != redacted || !errors.Is(entries[0].Err, errBad) {
+		t.Errorf("trace entries %+v, want %q", entries, redacted)
+	}
+	dls, err := DeadLetters(context.Background(), dlq)
+	if err != nil || len(dls) != 1 || dls[0].Err != redacted || dls[0].Node != "w1" {
+		t.Errorf("dead letters %+v err=%v, want %q", dls, err, redacted)
+	}
+	if recent := m.RecentExecutions(); len(recent) != 1 || recent[0].Err != redacted {
+		t.Errorf("execution history %+v, want %q", recent, redacted)
+	}
+
+	in := make(chan *rawData, 1)
+	in <- newInput()
+	close(in)
+	outs, errs := m.HandleStream(context.Background(), in)
+	se := <-errs
+	for range outs {
+	}
+	if se.Err == nil || se.Err.Error() != redacted || !errors.Is(se.Err, errBad) {
+		t.Errorf("stream error %v, want %q", se.Err, redacted)
+	}
+}
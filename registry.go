@@ -0,0 +1,132 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var ErrorsActionDuplicate = errors.New("action name is duplicate")
+
+// 处理方法的注册表，配置文件中的节点通过名字引用注册过的处理方法
+// 处理方法按命名空间隔离，加载配置时只能引用指定命名空间中的处理方法
+type Registry struct {
+	mu         sync.RWMutex
+	namespaces map[string]*Namespace
+}
+
+// 注册表中的一个命名空间
+type Namespace struct {
+	r       *Registry
+	name    string
+	actions map[string]registeredAction
+}
+
+type registeredAction struct {
+	typ    NodeTyp
+	action interface{}
+}
+
+// 注册过的处理方法的描述
+type ActionInfo struct {
+	Namespace string  `json:"namespace"`
+	Name      string  `json:"name"`
+	Typ       NodeTyp `json:"type"`
+}
+
+func NewRegistry() *Registry {
+	return &Registry{namespaces: make(map[string]*Namespace)}
+}
+
+// 返回名为name 的命名空间，不存在时创建
+func (r *Registry) Namespace(name string) *Namespace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ns, ok := r.namespaces[name]
+	if !ok {
+		ns = &Namespace{r: r, name: name, actions: make(map[string]registeredAction)}
+		r.namespaces[name] = ns
+	}
+	return ns
+}
+
+// 在默认命名空间中注册工作节点的处理方法
+func (r *Registry) RegisterWorker(name string, f WorkerFunc) error {
+	return r.Namespace("").RegisterWorker(name, f)
+}
+
+// 在默认命名空间中注册划分节点的处理方法
+func (r *Registry) RegisterDivider(name string, f DividerFunc) error {
+	return r.Namespace("").RegisterDivider(name, f)
+}
+
+// 在默认命名空间中注册合并节点的处理方法
+func (r *Registry) RegisterMerger(name string, f MergerFunc) error {
+	return r.Namespace("").RegisterMerger(name, f)
+}
+
+// 在默认命名空间中注册判断节点的处理方法
+func (r *Registry) RegisterJudger(name string, f JudgerFunc) error {
+	return r.Namespace("").RegisterJudger(name, f)
+}
+
+// 列出命名空间中注册过的处理方法，按名字排序
+func (r *Registry) List(namespace string) []ActionInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ns, ok := r.namespaces[namespace]
+	if !ok {
+		return nil
+	}
+	infos := make([]ActionInfo, 0, len(ns.actions))
+	for name, a := range ns.actions {
+		infos = append(infos, ActionInfo{Namespace: namespace, Name: name, Typ: a.typ})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// 注册工作节点的处理方法
+func (ns *Namespace) RegisterWorker(name string, f WorkerFunc) error {
+	return ns.register(name, NodeTypWorker, f)
+}
+
+// 注册划分节点的处理方法
+func (ns *Namespace) RegisterDivider(name string, f DividerFunc) error {
+	return ns.register(name, NodeTypDivider, f)
+}
+
+// 注册合并节点的处理方法
+func (ns *Namespace) RegisterMerger(name string, f MergerFunc) error {
+	return ns.register(name, NodeTypMerger, f)
+}
+
+// 注册判断节点的处理方法
+func (ns *Namespace) RegisterJudger(name string, f JudgerFunc) error {
+	return ns.register(name, NodeTypJudger, f)
+}
+
+func (ns *Namespace) register(name string, typ NodeTyp, action interface{}) error {
+	ns.r.mu.Lock()
+	defer ns.r.mu.Unlock()
+	if _, ok := ns.actions[name]; ok {
+		return fmt.Errorf("%w: action[%s] in namespace[%s]", ErrorsActionDuplicate, name, ns.name)
+	}
+	ns.actions[name] = registeredAction{typ: typ, action: action}
+	return nil
+}
+
+// 查找处理方法
+func (r *Registry) lookup(namespace, name string) (registeredAction, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ns, ok := r.namespaces[namespace]
+	if !ok {
+		return registeredAction{}, false
+	}
+	a, ok := ns.actions[name]
+	return a, ok
+}
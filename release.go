@@ -0,0 +1,70 @@
+package pipeline
+
+import "reflect"
+
+// 可以回收的数据
+// rawData 的Data 实现该接口时，执行引擎在没有节点再引用它之后调用Release，例如将缓冲区还给对象池：
+// 节点的输出与输入不是同一个对象时，节点执行完后回收输入；合并节点执行完后回收所有的输入；
+// 分裂节点多个分支共享同一个对象时，所有分支都处理完后才回收。最终的输出由调用方负责
+// 执行失败时还没有处理的数据不会回收；设置了错误处理子图或死信存储时原始输入在执行成功结束后才回收，失败时不回收
+// Data 必须是可比较的类型（通常是指针），否则不会回收
+type Releasable interface {
+	Release()
+}
+
+func releasableOf(data *rawData) (Releasable, bool) {
+	if data == nil || data.Data == nil {
+		return nil, false
+	}
+	r, ok := data.Data.(Releasable)
+	if !ok || !reflect.TypeOf(r).Comparable() {
+		return nil, false
+	}
+	return r, true
+}
+
+// 数据交给下一个节点，引用计数加一
+func (e *execution) hold(data *rawData) {
+	r, ok := releasableOf(data)
+	if !ok {
+		return
+	}
+	if e.refs == nil {
+		e.refs = make(map[Releasable]int)
+	}
+	e.refs[r]++
+}
+
+// 执行成功后放开对原始输入的持有，原始输入就是最终输出时由调用方负责，不回收
+func (e *execution) unpin(in, out *rawData) {
+	r, ok := releasableOf(in)
+	if !ok {
+		return
+	}
+	if o, ok := releasableOf(out); ok && o == r {
+		return
+	}
+	e.drop(in)
+}
+
+// 节点处理完数据，引用计数减一，没有引用时回收，每个对象最多回收一次
+func (e *execution) drop(data *rawData) {
+	r, ok := releasableOf(data)
+	if !ok {
+		return
+	}
+	if e.refs == nil {
+		e.refs = make(map[Releasable]int)
+	}
+	if e.refs[r]--; e.refs[r] > 0 {
+		return
+	}
+	delete(e.refs, r)
+	if e.released == nil {
+		e.released = make(map[Releasable]bool)
+	}
+	if !e.released[r] {
+		e.released[r] = true
+		r.Release()
+	}
+}
@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// 记录回收次数的数据
+type pooledBuffer struct {
+	name string
+	pool *bufferPool
+}
+
+func (b *pooledBuffer) Release() {
+	b.pool.mu.Lock()
+	b.pool.released[b.name]++
+	b.pool.mu.Unlock()
+}
+
+type bufferPool struct {
+	mu       sync.Mutex
+	released map[string]int
+}
+
+func (p *bufferPool) get(name string) *rawData {
+	return &rawData{Data: &pooledBuffer{name: name, pool: p}}
+}
+
+// 测试菱形流程中每个可回收对象恰好回收一次，最终输出不回收
+func TestManager_ReleasableDiamond(t *testing.T) {
+	cases := []struct {
+		name      string
+		broadcast bool
+		expected  map[string]int
+	}{
+		{"clone", false, map[string]int{"in": 1, "d1-0": 1, "d1-1": 1, "a": 1, "b": 1}},
+		{"broadcast", true, map[string]int{"in": 1, "a": 1, "b": 1}},
+	}
+	for _, c := range cases {
+		pool := &bufferPool{released: make(map[string]int)}
+		broadcast := c.broadcast
+		m := NewManager()
+		_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+			if broadcast {
+				return []*rawData{in, in}, nil
+			}
+			return []*rawData{pool.get("d1-0"), pool.get("d1-1")}, nil
+		})
+		for _, name := range []string{"a", "b"} {
+			name := name
+			_ = m.AddWorkerNode(name, func(ctx context.Context, in *rawData) (*rawData, error) {
+				return pool.get(name), nil
+			})
+		}
+		_ = m.AddWorkerNode("pass", passWorker)
+		_ = m.AddMergerNode("m1", func(ctx context.Context, ins []*rawData) (*rawData, error) {
+			return pool.get("m1"), nil
+		})
+		if err := m.BuildPipeline([][]string{
+			{Head, "d1"},
+			{"d1", "a"},
+			{"d1", "b"},
+			{"a", "m1"},
+			{"b", "m1"},
+			{"m1", "pass"},
+			{"pass", Tail},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		out, err := m.Handle(pool.get("in"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Data.(*pooledBuffer).name != "m1" {
+			t.Fatalf("%s: unexpected output %v", c.name, out.Data)
+		}
+		if len(pool.released) != len(c.expected) {
+			t.Errorf("%s: expected releases %v, got %v", c.name, c.expected, pool.released)
+		}
+		for name, n := range c.expected {
+			if pool.released[name] != n {
+				t.Errorf("%s: expected %s released %d times, got %d", c.name, name, n, pool.released[name])
+			}
+		}
+	}
+}
+
+// 测试直线流程中输入被替换后回收
+func TestManager_ReleasableLinear(t *testing.T) {
+	pool := &bufferPool{released: make(map[string]int)}
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		return pool.get("out"), nil
+	})
+	if _, err := m.Handle(pool.get("in")); err != nil {
+		t.Fatal(err)
+	}
+	if pool.released["in"] != 1 || pool.released["out"] != 0 {
+		t.Errorf("unexpected releases %v", pool.released)
+	}
+}
+
+// 回收后内容被清空的数据，可以编码成JSON
+type reusedPayload struct {
+	Val      string
+	Released int
+}
+
+func (p *reusedPayload) Release() {
+	p.Released++
+	p.Val = "RELEASED"
+}
+
+// w1 输出新的对象之后原始输入本来可以回收，w2 由fail 决定是否失败
+func newReleaseFailManager(t *testing.T, fail *bool, opts ...Option) *Manager {
+	m := NewManager(opts...)
+	_ = m.AddWorkerNode("w1", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: "copy"}, nil
+	})
+	_ = m.AddWorkerNode("w2", func(ctx context.Context, in *rawData) (*rawData, error) {
+		if *fail {
+			return nil, errBranch
+		}
+		return in, nil
+	})
+	return m
+}
+
+// 测试错误处理子图收到的原始输入还没有被回收，执行成功时原始输入仍然回收一次
+func TestManager_ReleasableErrorHandler(t *testing.T) {
+	fail := true
+	m := newReleaseFailManager(t, &fail)
+	var seen string
+	_ = m.AddWorkerNode("recover", func(ctx context.Context, in *rawData) (*rawData, error) {
+		ei, _ := ErrorInputOf(in)
+		seen = ei.Input.Data.(*reusedPayload).Val
+		return &rawData{Data: "default"}, nil
+	})
+	m.SetErrorHandlerEntry("recover")
+	if err := m.BuildPipeline([][]string{{Head, "w1"}, {"w1", "w2"}, {"w2", Tail}, {"recover", Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	in := &reusedPayload{Val: "in"}
+	if out, err := m.Handle(&rawData{Data: in}); err != nil || out.Data != "default" {
+		t.Fatalf("out=%v err=%v", out, err)
+	}
+	if seen != "in" || in.Released != 0 {
+		t.Errorf("handler saw %q, input released %d times, want the original unreleased input", seen, in.Released)
+	}
+	fail = false
+	in = &reusedPayload{Val: "in"}
+	if _, err := m.Handle(&rawData{Data: in}); err != nil {
+		t.Fatal(err)
+	}
+	if in.Released != 1 {
+		t.Errorf("input released %d times after success, want 1", in.Released)
+	}
+}
@@ -0,0 +1,181 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// 重试之间的等待策略
+// attempt 为即将进行的重试序号，第一次重试为1；rnd 为Manager 的随机数来源，调用时已加锁
+type Backoff interface {
+	Delay(attempt int, rnd *rand.Rand) time.Duration
+}
+
+// 用户自定义的等待策略
+type BackoffFunc func(attempt int) time.Duration
+
+func (f BackoffFunc) Delay(attempt int, _ *rand.Rand) time.Duration {
+	return f(attempt)
+}
+
+type constantBackoff time.Duration
+
+func (b constantBackoff) Delay(int, *rand.Rand) time.Duration {
+	return time.Duration(b)
+}
+
+// 每次重试前等待固定的时间
+func ConstantBackoff(d time.Duration) Backoff {
+	return constantBackoff(d)
+}
+
+type exponentialBackoff struct {
+	base   time.Duration
+	jitter bool
+}
+
+func (b exponentialBackoff) Delay(attempt int, rnd *rand.Rand) time.Duration {
+	d := b.base
+	for i := 1; i < attempt && d < maxBackoffLimit/2; i++ {
+		d *= 2
+	}
+	if b.jitter && d > 0 {
+		d = time.Duration(rnd.Int63n(int64(d)))
+	}
+	return d
+}
+
+// 指数退避时单次等待的上限，防止溢出
+const maxBackoffLimit = time.Duration(1<<62 - 1)
+
+// 第n 次重试前等待 base*2^(n-1)
+func ExponentialBackoff(base time.Duration) Backoff {
+	return exponentialBackoff{base: base}
+}
+
+// 指数退避加全抖动：第n 次重试前等待 [0, base*2^(n-1)) 内的随机时间
+func ExponentialJitterBackoff(base time.Duration) Backoff {
+	return exponentialBackoff{base: base, jitter: true}
+}
+
+type retryOptions struct {
+	// 最多执行的次数，包括第一次
+	attempts   int
+	backoff    Backoff
+	maxBackoff time.Duration
+}
+
+func (o *nodeOptions) retryOptions() *retryOptions {
+	if o.retry == nil {
+		o.retry = &retryOptions{}
+	}
+	return o.retry
+}
+
+// 重试配置适用的节点类型，判断节点不会返回错误
+var retryNodeTypes = []NodeTyp{NodeTypWorker, NodeTypDivider, NodeTypMerger}
+
+// 节点执行失败时重试，attempts 为最多执行的次数（包括第一次）
+// 只对工作节点、分裂节点、合并节点生效
+func WithRetry(attempts int) NodeOption {
+	return func(o *nodeOptions) {
+		o.retryOptions().attempts = attempts
+		o.use("WithRetry", retryNodeTypes...)
+	}
+}
+
+// 设置重试之间的等待策略，默认不等待
+func WithBackoff(strategy Backoff) NodeOption {
+	return func(o *nodeOptions) {
+		o.retryOptions().backoff = strategy
+		o.use("WithBackoff", retryNodeTypes...)
+	}
+}
+
+// 限制单次重试前等待的最长时间
+func WithMaxBackoff(d time.Duration) NodeOption {
+	return func(o *nodeOptions) {
+		o.retryOptions().maxBackoff = d
+		o.use("WithMaxBackoff", retryNodeTypes...)
+	}
+}
+
+// 并发安全的随机数来源
+type lockedRand struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newLockedRand(src rand.Source) *lockedRand {
+	return &lockedRand{rnd: rand.New(src)}
+}
+
+// 设置Manager 使用的随机数来源，用于重试的抖动等，测试时可以传入固定种子
+func WithRandSource(src rand.Source) Option {
+	return func(m *Manager) {
+		m.rand = newLockedRand(src)
+	}
+}
+
+// 计算第attempt 次重试前的等待时间，rnd 为本次执行的随机数来源，为空时使用Manager 的随机数来源
+func (m *Manager) backoffDelay(r *retryOptions, attempt int, rnd *rand.Rand) time.Duration {
+	if r.backoff == nil {
+		return 0
+	}
+	var d time.Duration
+	if rnd != nil {
+		d = r.backoff.Delay(attempt, rnd)
+	} else {
+		m.rand.mu.Lock()
+		d = r.backoff.Delay(attempt, m.rand.rnd)
+		m.rand.mu.Unlock()
+	}
+	if d < 0 {
+		d = 0
+	}
+	if r.maxBackoff > 0 && d > r.maxBackoff {
+		d = r.maxBackoff
+	}
+	return d
+}
+
+// 执行一次，节点设置了超时时f 收到带有超时的ctx
+func (e *execution) callOnce(ctx context.Context, node *Node, f func(ctx context.Context) error) error {
+	if node.effective.timeout <= 0 {
+		return e.checkDeadline(ctx, node, e.call(ctx, f))
+	}
+	actx, cancel := context.WithTimeout(ctx, node.effective.timeout)
+	defer cancel()
+	return attemptError(ctx, actx, node, e.checkDeadline(actx, node, e.call(actx, f)))
+}
+
+// 按节点的重试配置执行f，返回执行次数以及每次重试前等待的时间
+// 等待期间ctx 被取消时立即返回，每次重试前ctx 已经结束时不再重试
+func (e *execution) retry(ctx context.Context, node *Node, f func(ctx context.Context) error) (attempts int, backoffs []time.Duration, err error) {
+	r := node.effective.retry
+	for attempts = 1; ; attempts++ {
+		err = e.callOnce(ctx, node, f)
+		if err == nil || r == nil || attempts >= r.attempts || errors.Is(err, ErrContextIgnored) {
+			return
+		}
+		d := e.m.backoffDelay(r, attempts, e.rnd)
+		backoffs = append(backoffs, d)
+		if werr := e.call(ctx, func(ctx context.Context) error {
+			select {
+			case <-e.m.clock.After(d):
+				// 等待时间为0 时两个分支可能同时就绪，ctx 已经结束时不再重试
+				return ctx.Err()
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}); werr != nil {
+			err = runtimeError(node, keepErrorMetadata(err, werr), "retry backoff interrupted",
+				fmt.Sprintf("after %d attempts, last error: %v: %v", attempts, err, werr))
+			return
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newRetryManager(t *testing.T, clock Clock, seed int64, f WorkerFunc, opts ...NodeOption) *Manager {
+	m := NewManager(WithClock(clock), WithRandSource(rand.NewSource(seed)))
+	if err := m.AddWorkerNode("w1", f, opts...); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := m.BuildPipeline([][]string{
+		{"head000", "w1"},
+		{"w1", "tail111"},
+	}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	return m
+}
+
+// 测试固定种子下指数退避加抖动的等待序列，以及单次等待的上限
+func TestManager_RetryExponentialJitter(t *testing.T) {
+	const (
+		seed     = 42
+		base     = 100 * time.Millisecond
+		maxDelay = 300 * time.Millisecond
+	)
+	expected := make([]time.Duration, 0, 3)
+	rnd := rand.New(rand.NewSource(seed))
+	for attempt := 1; attempt <= 3; attempt++ {
+		d := time.Duration(rnd.Int63n(int64(base << uint(attempt-1))))
+		if d > maxDelay {
+			d = maxDelay
+		}
+		expected = append(expected, d)
+	}
+
+	clock := newFakeClock()
+	calls := 0
+	m := newRetryManager(t, clock, seed, func(ctx context.Context, in *rawData) (*rawData, error) {
+		calls++
+		if calls < 4 {
+			return nil, errors.New("unavailable")
+		}
+		return in, nil
+	}, WithRetry(5), WithBackoff(ExponentialJitterBackoff(base)), WithMaxBackoff(maxDelay))
+
+	trace := &Trace{}
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.HandleContext(context.Background(), &rawData{}, WithTrace(trace))
+		done <- err
+	}()
+	for _, d := range expected {
+		clock.blockUntilWaiters(1)
+		clock.Advance(d)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	entries := trace.Entries()
+	if len(entries) != 1 {
+		t.Errorf("expected 1 trace entry, got %d", len(entries))
+		t.FailNow()
+	}
+	if entries[0].Attempts != 4 {
+		t.Errorf("expected 4 attempts, got %d", entries[0].Attempts)
+	}
+	if !reflect.DeepEqual(entries[0].Backoffs, expected) {
+		t.Errorf("expected backoffs %v, got %v", expected, entries[0].Backoffs)
+	}
+}
+
+// 测试重试次数用完后返回最后一次的错误，等待时间按策略计算
+func TestManager_RetryExhausted(t *testing.T) {
+	clock := newFakeClock()
+	errUnavailable := errors.New("unavailable")
+	m := newRetryManager(t, clock, 1, func(ctx context.Context, in *rawData) (*rawData, error) {
+		return nil, errUnavailable
+	}, WithRetry(3), WithBackoff(BackoffFunc(func(attempt int) time.Duration {
+		return time.Duration(attempt) * time.Second
+	})))
+
+	trace := &Trace{}
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.HandleContext(context.Background(), &rawData{}, WithTrace(trace))
+		done <- err
+	}()
+	clock.blockUntilWaiters(1)
+	clock.Advance(time.Second)
+	clock.blockUntilWaiters(1)
+	clock.Advance(2 * time.Second)
+	if err := <-done; !errors.Is(err, errUnavailable) {
+		t.Errorf("expected last error, got %v", err)
+		t.FailNow()
+	}
+	entry := trace.Entries()[0]
+	if entry.Attempts != 3 || !reflect.DeepEqual(entry.Backoffs, []time.Duration{time.Second, 2 * time.Second}) {
+		t.Errorf("unexpected attempts %d backoffs %v", entry.Attempts, entry.Backoffs)
+	}
+}
+
+// 测试等待重试时ctx 被取消会立即返回
+func TestManager_RetryCancelledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	m := newRetryManager(t, newFakeClock(), 1, func(c context.Context, in *rawData) (*rawData, error) {
+		calls++
+		cancel()
+		return nil, errors.New("unavailable")
+	}, WithRetry(3), WithBackoff(ConstantBackoff(time.Hour)))
+
+	_, err := m.HandleContext(ctx, &rawData{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+		t.FailNow()
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
+
+// 测试没有等待时间时ctx 已经结束也不再重试
+func TestManager_RetryCancelledWithoutBackoff(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		m := newRetryManager(t, newFakeClock(), 1, func(c context.Context, in *rawData) (*rawData, error) {
+			calls++
+			cancel()
+			return nil, errors.New("unavailable")
+		}, WithRetry(3))
+
+		_, err := m.HandleContext(ctx, &rawData{})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+			t.FailNow()
+		}
+		if calls != 1 {
+			t.Errorf("run %d: expected 1 call, got %d", i, calls)
+			t.FailNow()
+		}
+	}
+}
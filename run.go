@@ -0,0 +1,127 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// RunFromConfig 每行输出的结果，Line 为输入中的行号，从1 开始
+type runResult struct {
+	Line  int         `json:"line"`
+	Data  interface{} `json:"data,omitempty"`
+	Node  string      `json:"node,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// 从配置文件加载流水线，把input 中的每一行（JSON）作为一条数据执行，结果逐行以JSON 写入output
+// format 为配置文件的格式 json 或 yaml，为空时按文件扩展名判断；opts 可以设置并发数等
+// 单条数据解析或执行失败时写入一行带 error 的结果，不影响其他数据；只有加载配置、读写失败时返回错误
+func RunFromConfig(ctx context.Context, configPath string, reg *Registry, input io.Reader, output io.Writer, format string, opts ...StreamOption) error {
+	m, err := loadConfigFile(configPath, reg, format)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	in := make(chan *rawData)
+	// 解析失败的行直接输出，不进入流水线
+	bad := make(chan runResult)
+	// 流式执行的序号 -> 行号
+	var mu sync.Mutex
+	var lines []int
+	var readErr error
+	go func() {
+		defer close(in)
+		defer close(bad)
+		scanner := bufio.NewScanner(input)
+		for n := 1; scanner.Scan(); n++ {
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+			var v interface{}
+			if err := json.Unmarshal([]byte(text), &v); err != nil {
+				select {
+				case bad <- runResult{Line: n, Error: fmt.Sprintf("decode input: %v", err)}:
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+			mu.Lock()
+			lines = append(lines, n)
+			mu.Unlock()
+			select {
+			case in <- &rawData{Data: v}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr = scanner.Err()
+	}()
+
+	lineOf := func(seq uint64) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return lines[seq]
+	}
+	enc := json.NewEncoder(output)
+	outs, errs := m.HandleStream(ctx, in, opts...)
+	for outs != nil || errs != nil || bad != nil {
+		var res runResult
+		select {
+		case out, ok := <-outs:
+			if !ok {
+				outs = nil
+				continue
+			}
+			res = runResult{Line: lineOf(out.Seq), Data: out.Out.Data}
+		case se, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			res = runResult{Line: lineOf(se.Seq), Node: se.Node, Error: se.Err.Error()}
+		case r, ok := <-bad:
+			if !ok {
+				bad = nil
+				continue
+			}
+			res = r
+		}
+		if err := enc.Encode(res); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+	}
+	if readErr != nil {
+		return fmt.Errorf("read input: %w", readErr)
+	}
+	return ctx.Err()
+}
+
+// 按格式加载配置文件
+func loadConfigFile(path string, reg *Registry, format string) (*Manager, error) {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch strings.ToLower(format) {
+	case "json":
+		return LoadJSON(f, reg, WithConfigSource(path))
+	case "yaml", "yml":
+		return LoadYAML(f, reg, WithConfigSource(path))
+	}
+	return nil, fmt.Errorf("unknown config format[%s]", format)
+}
@@ -0,0 +1,53 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 测试从配置文件执行逐行输入，失败的数据输出一行错误
+func TestRunFromConfig(t *testing.T) {
+	reg := NewRegistry()
+	if err := reg.RegisterWorker("double", func(ctx context.Context, in *rawData) (*rawData, error) {
+		n, ok := in.Data.(float64)
+		if !ok {
+			return nil, errors.New("not a number")
+		}
+		return &rawData{Data: n * 2}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "pipeline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "pipeline.json")
+	config := `{
+	"nodes": [{"name": "d1", "type": "worker", "action": "double"}],
+	"edges": [["head", "d1"], ["d1", "tail"]]
+}`
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	in := strings.NewReader("1\n2\n\"poison\"\n3\n")
+	if err := RunFromConfig(context.Background(), path, reg, in, &out, ""); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"line":1,"data":2}
+{"line":2,"data":4}
+{"line":3,"node":"d1","error":"not a number"}
+{"line":4,"data":6}
+`
+	if out.String() != want {
+		t.Errorf("output=\n%s\nwant=\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 执行任务的goroutine 池，例如调用方已有的带配额、监控的工作池
+// Go 在另一个goroutine 中执行task，无法执行（例如池已满）时返回错误，此时task 不会被调用
+type Runner interface {
+	Go(ctx context.Context, task func()) error
+}
+
+// 每个任务启动一个新的goroutine 的 Runner
+type GoRunner struct{}
+
+func (GoRunner) Go(ctx context.Context, task func()) error {
+	go task()
+	return nil
+}
+
+// 批量执行、流式执行中每条数据的执行都交给r，见 HandleBatchGrouped、HandleStream、HandlePull
+// 数据直接在r 的goroutine 中执行，同时交给r 的数据不超过并发数；开启 WithStageParallelism 时每个节点也在r 上执行；
+// 未设置时在流水线自己的goroutine 中执行；r 返回错误时对应的数据失败，错误为r 返回的错误，
+// 设置 WithRunnerRetry 时改为等待之后重试
+func WithRunner(r Runner) Option {
+	return func(m *Manager) {
+		m.runner = r
+	}
+}
+
+// Runner 返回错误时等待interval 之后重试，直到成功或ctx 结束，结束时数据的错误为ctx 的错误
+func WithRunnerRetry(interval time.Duration) Option {
+	return func(m *Manager) {
+		m.runnerRetry = interval
+	}
+}
+
+// 批量执行、流式执行交给 Runner 的一组数据，同时执行的数据不超过槽位数
+// 数据直接在 Runner 的goroutine 中执行，流水线不为每条数据再占用一个goroutine
+type runnerGroup struct {
+	m      *Manager
+	runner Runner
+	slots  chan struct{}
+	wg     sync.WaitGroup
+}
+
+// 没有设置 Runner 时每个task 启动一个新的goroutine
+func (m *Manager) newRunnerGroup(n int) *runnerGroup {
+	g := &runnerGroup{m: m, runner: m.runner, slots: make(chan struct{}, n)}
+	if g.runner == nil {
+		g.runner = GoRunner{}
+	}
+	return g
+}
+
+// 等待一个空闲的槽位，ctx 结束时返回ctx 的错误
+func (g *runnerGroup) acquire(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case g.slots <- struct{}{}:
+		g.wg.Add(1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 使用已经占用的槽位在 Runner 上执行task，不等待task 返回，task 返回后释放槽位
+// Runner 拒绝执行时释放槽位并返回错误，此时task 不会被调用
+func (g *runnerGroup) run(ctx context.Context, task func()) error {
+	f := func() {
+		defer g.release()
+		task()
+	}
+	for {
+		err := g.runner.Go(ctx, f)
+		if err == nil {
+			return nil
+		}
+		if g.m.runnerRetry <= 0 {
+			g.release()
+			return err
+		}
+		select {
+		case <-g.m.clock.After(g.m.runnerRetry):
+		case <-ctx.Done():
+			g.release()
+			return ctx.Err()
+		}
+	}
+}
+
+// 释放 acquire 占用的槽位
+func (g *runnerGroup) release() {
+	<-g.slots
+	g.wg.Done()
+}
+
+// 等待所有交给 Runner 的task 返回
+func (g *runnerGroup) wait() {
+	g.wg.Wait()
+}
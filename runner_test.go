@@ -0,0 +1,208 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errSaturated = errors.New("pool saturated")
+
+// 记录调用次数的 Runner，reject 返回true 时拒绝执行
+type countingRunner struct {
+	calls, running int64
+	reject         func(call int64) bool
+}
+
+func (r *countingRunner) Go(ctx context.Context, task func()) error {
+	call := atomic.AddInt64(&r.calls, 1)
+	if r.reject != nil && r.reject(call) {
+		return errSaturated
+	}
+	go func() {
+		atomic.AddInt64(&r.running, 1)
+		defer atomic.AddInt64(&r.running, -1)
+		task()
+	}()
+	return nil
+}
+
+// 只在 Runner 的任务中才能执行成功的工作节点
+func runnerWorker(r *countingRunner) WorkerFunc {
+	return func(ctx context.Context, in *rawData) (*rawData, error) {
+		if atomic.LoadInt64(&r.running) == 0 {
+			return nil, errors.New("not running on the runner")
+		}
+		return in, nil
+	}
+}
+
+// 测试批量执行的每条数据都交给 Runner，拒绝执行的数据结果为 Runner 的错误
+func TestManager_RunnerBatch(t *testing.T) {
+	r := &countingRunner{reject: func(call int64) bool { return call == 3 }}
+	m := newSingleWorkerManager(t, runnerWorker(r), WithRunner(r))
+	groups := map[string][]*rawData{"g": make([]*rawData, 10)}
+	for i := range groups["g"] {
+		groups["g"][i] = &rawData{Data: i}
+	}
+	results, err := m.HandleBatchGrouped(context.Background(), groups, WithBatchConcurrency(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.calls != 10 {
+		t.Errorf("runner called %d times, want 10", r.calls)
+	}
+	for i, res := range results["g"] {
+		if i == 2 {
+			if !errors.Is(res.Err, errSaturated) {
+				t.Errorf("result 2 err=%v, want saturation error", res.Err)
+			}
+			continue
+		}
+		if res.Err != nil {
+			t.Errorf("result %d err=%v", i, res.Err)
+		}
+	}
+}
+
+// 测试流式执行的每条数据都交给 Runner，拒绝执行的数据输出到错误channel
+func TestManager_RunnerStream(t *testing.T) {
+	r := &countingRunner{reject: func(call int64) bool { return call%5 == 0 }}
+	m := newSingleWorkerManager(t, runnerWorker(r), WithRunner(r))
+	in := make(chan *rawData)
+	go func() {
+		for i := 0; i < 20; i++ {
+			in <- &rawData{Data: i}
+		}
+		close(in)
+	}()
+	outs, errs := m.HandleStream(context.Background(), in, WithStreamConcurrency(3))
+	succeeded, rejected := 0, 0
+	for outs != nil || errs != nil {
+		select {
+		case _, ok := <-outs:
+			if !ok {
+				outs = nil
+				continue
+			}
+			succeeded++
+		case se, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if !errors.Is(se, errSaturated) || se.Node != "" {
+				t.Errorf("unexpected stream error %v", se)
+			}
+			rejected++
+		}
+	}
+	if r.calls != 20 || succeeded != 16 || rejected != 4 {
+		t.Errorf("calls=%d succeeded=%d rejected=%d, want 20/16/4", r.calls, succeeded, rejected)
+	}
+}
+
+// 测试 WithRunnerRetry 时 Runner 拒绝之后重试，直到成功
+func TestManager_RunnerRetry(t *testing.T) {
+	r := &countingRunner{reject: func(call int64) bool { return call <= 2 }}
+	m := newSingleWorkerManager(t, runnerWorker(r), WithRunner(r), WithRunnerRetry(time.Millisecond))
+	results, err := m.HandleBatchGrouped(context.Background(), map[string][]*rawData{"g": {{Data: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := results["g"][0]; res.Err != nil || r.calls != 3 {
+		t.Errorf("err=%v calls=%d, want success after 3 calls", res.Err, r.calls)
+	}
+}
+
+// 流水线自己的goroutine 数：栈中有本包的函数，但不是 Runner 执行任务的goroutine，也不是测试启动的goroutine
+func engineGoroutines() int {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	n := 0
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		if strings.Contains(g, "caigoumiao/pipeline.") && !strings.Contains(g, "countingRunner") &&
+			!strings.Contains(g, "caigoumiao/pipeline.Test") {
+			n++
+		}
+	}
+	return n
+}
+
+// 测试交给 Runner 的数据直接在 Runner 的goroutine 中执行，同时执行的数据不超过并发数，
+// 流水线不为每条数据再占用一个goroutine，自己的goroutine 数不随并发数增加
+func TestManager_RunnerGoroutines(t *testing.T) {
+	runs := map[string]func(m *Manager, n int, items []*rawData){
+		"batch": func(m *Manager, n int, items []*rawData) {
+			_, _ = m.HandleBatchGrouped(context.Background(), map[string][]*rawData{"g": items}, WithBatchConcurrency(n))
+		},
+		"stream": func(m *Manager, n int, items []*rawData) {
+			in := make(chan *rawData, len(items))
+			for _, item := range items {
+				in <- item
+			}
+			close(in)
+			outs, errs := m.HandleStream(context.Background(), in, WithStreamConcurrency(n))
+			go func() {
+				for range errs {
+				}
+			}()
+			for range outs {
+			}
+		},
+		"pull": func(m *Manager, n int, items []*rawData) {
+			i := 0
+			_ = m.HandlePull(context.Background(), func(ctx context.Context) (*rawData, bool, error) {
+				if i == len(items) {
+					return nil, false, nil
+				}
+				i++
+				return items[i-1], true, nil
+			}, func(ctx context.Context, out *rawData, err error) error {
+				return nil
+			}, WithStreamConcurrency(n))
+		},
+	}
+	for name, run := range runs {
+		var counts []int
+		for _, n := range []int{1, 4} {
+			r := &countingRunner{}
+			release := make(chan struct{})
+			var exceeded int32
+			m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+				if atomic.LoadInt64(&r.running) > int64(n) {
+					atomic.StoreInt32(&exceeded, 1)
+				}
+				<-release
+				return in, nil
+			}, WithRunner(r))
+			items := make([]*rawData, 2*n)
+			for i := range items {
+				items[i] = &rawData{Data: i}
+			}
+			done := make(chan struct{})
+			go func() {
+				run(m, n, items)
+				close(done)
+			}()
+			deadline := time.Now().Add(5 * time.Second)
+			for atomic.LoadInt64(&r.running) < int64(n) && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			counts = append(counts, engineGoroutines())
+			close(release)
+			<-done
+			if r.calls != int64(len(items)) || exceeded != 0 {
+				t.Errorf("%s concurrency %d: runner calls=%d exceeded=%v, want %d bounded calls", name, n, r.calls, exceeded != 0, len(items))
+			}
+		}
+		if counts[0] != counts[1] {
+			t.Errorf("%s: %d engine goroutines with concurrency 1, %d with concurrency 4, want items to run only on the runner",
+				name, counts[0], counts[1])
+		}
+	}
+}
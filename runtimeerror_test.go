@@ -0,0 +1,238 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 执行时错误的文本格式：pipeline: <节点类型> "<节点名>": <问题> (<细节>)
+var runtimeErrorFormat = regexp.MustCompile(`^pipeline: (worker|divider|merger|judger) "[^"]+": [^()]+( \(.+\))?$`)
+
+// 测试执行中每一种由流水线发现的错误都带有结构化的字段，并且文本符合统一的格式
+func TestManager_RuntimeErrors(t *testing.T) {
+	failing := func(ctx context.Context, in *rawData) (*rawData, error) {
+		return nil, errors.New("boom")
+	}
+	cases := []struct {
+		name    string
+		run     func(t *testing.T) error
+		node    string
+		typ     NodeTyp
+		problem string
+		is      error
+	}{
+		{"divider outputs", func(t *testing.T) error {
+			m := newDiamondManager(t)
+			m.actionMap[m.nodes["d1"].actionId] = DividerFunc(func(ctx context.Context, in *rawData) ([]*rawData, error) {
+				return []*rawData{in}, nil
+			})
+			_, err := m.Handle(&rawData{Data: 1})
+			return err
+		}, "d1", NodeTypDivider, "outputs do not match branches", ErrDividerOutputMismatch},
+		{"judger branch", func(t *testing.T) error {
+			_, err := newBudgetJudgerManager(t).HandleContext(context.Background(), &rawData{}, WithForcedDecisions(Decisions{"budget": 5}))
+			return err
+		}, "budget", NodeTypJudger, "branch index out of range", ErrJudgerBranchOutOfRange},
+		{"missing decision", func(t *testing.T) error {
+			_, err := newBudgetJudgerManager(t).HandleContext(context.Background(), &rawData{}, WithForcedDecisions(Decisions{}))
+			return err
+		}, "budget", NodeTypJudger, "no forced decision", nil},
+		{"next changed", func(t *testing.T) error {
+			m := newDiamondManager(t)
+			m.nodes["m1"].Next = nil
+			_, err := m.Handle(&rawData{Data: 1})
+			return err
+		}, "m1", NodeTypMerger, "next nodes changed after build", ErrTopologyCorrupted},
+		{"nil next", func(t *testing.T) error {
+			m := newDiamondManager(t)
+			m.nodes["a"].Next = []*Node{nil}
+			_, err := m.Handle(&rawData{Data: 1})
+			return err
+		}, "a", NodeTypWorker, "next node is nil", ErrTopologyCorrupted},
+		{"merger in-degree", func(t *testing.T) error {
+			m := newDiamondManager(t, WithRuntimeAssertions())
+			m.nodes["m1"].inEdges = 1
+			_, err := m.Handle(&rawData{Data: 1})
+			return err
+		}, "m1", NodeTypMerger, "in-degree invariant violated", errInvariant},
+		{"action type", func(t *testing.T) error {
+			m := newDiamondManager(t)
+			m.actionMap[m.nodes["a"].actionId] = JudgerFunc(routeJudger)
+			_, err := m.Handle(&rawData{Data: 1})
+			return err
+		}, "a", NodeTypWorker, "unexpected action type", errInvariant},
+		{"merge timeout", func(t *testing.T) error {
+			_, err := newMergeTimeoutManager(t, newFakeClock(), 2*time.Second, FailOnMergeTimeout).Handle(&rawData{})
+			return err
+		}, "m1", NodeTypMerger, "wait timeout", ErrMergeTimeout},
+		{"context ignored", func(t *testing.T) error {
+			clock := newFakeClock()
+			m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+				clock.Advance(time.Second)
+				return in, nil
+			}, WithClock(clock), WithDeadlineAssertions())
+			_, err := m.HandleContext(fakeDeadlineCtx{Context: context.Background(), deadline: clock.Now().Add(time.Millisecond)}, &rawData{})
+			return err
+		}, "w1", NodeTypWorker, "action ignored context deadline", ErrContextIgnored},
+		{"node timeout", func(t *testing.T) error {
+			m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}, WithDefaultNodeTimeout(10*time.Millisecond))
+			_, err := m.Handle(&rawData{})
+			return err
+		}, "w1", NodeTypWorker, "timed out", context.DeadlineExceeded},
+		{"input size", func(t *testing.T) error {
+			m := NewManager()
+			_ = m.AddWorkerNode("w1", passWorker, WithMaxInputSize(1, func(in *rawData) int { return 2 }, FailOnOversize))
+			if err := m.BuildPipeline([][]string{{Head, "w1"}, {"w1", Tail}}); err != nil {
+				t.Fatal(err)
+			}
+			_, err := m.Handle(&rawData{})
+			return err
+		}, "w1", NodeTypWorker, "input too large", ErrPayloadTooLarge},
+		{"stage budget", func(t *testing.T) error {
+			m := newStageManager(t, newFakeClock(), WithStageTimeout("enrich", 100*time.Millisecond))
+			_, err := m.Handle(&rawData{})
+			return err
+		}, "enrich2", NodeTypWorker, "stage budget exceeded", ErrStageTimeout},
+		{"nil output", func(t *testing.T) error {
+			m, err := Linear(NamedWorker{"w1", func(ctx context.Context, in *rawData) (*rawData, error) {
+				return nil, nil
+			}}, NamedWorker{"w2", passWorker})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = m.Handle(&rawData{})
+			return err
+		}, "w1", NodeTypWorker, "output is nil", ErrNilNodeOutput},
+		{"section acquire", func(t *testing.T) error {
+			m := NewManager()
+			_ = m.AddWorkerNode("reserve", passWorker)
+			_ = m.AddWorkerNode("confirm", passWorker)
+			m.DefineCriticalSection("booking", "reserve", "confirm", func(ctx context.Context, in *rawData) (func(error), error) {
+				return nil, ErrOverloaded
+			})
+			if err := m.BuildPipeline([][]string{{Head, "reserve"}, {"reserve", "confirm"}, {"confirm", Tail}}); err != nil {
+				t.Fatal(err)
+			}
+			_, err := m.Handle(&rawData{})
+			return err
+		}, "reserve", NodeTypWorker, "critical section acquire failed", ErrOverloaded},
+		{"variant", func(t *testing.T) error {
+			m := NewManager(WithVariantSelector(func(ctx context.Context, node string, versions []string) string {
+				return "v3"
+			}))
+			_ = m.AddWorkerNode("score", passWorker)
+			_ = m.AddWorkerVariant("score", "v2", passWorker)
+			if err := m.BuildPipeline([][]string{{Head, "score"}, {"score", Tail}}); err != nil {
+				t.Fatal(err)
+			}
+			_, err := m.Handle(&rawData{})
+			return err
+		}, "score", NodeTypWorker, "variant is not registered", ErrVariantNotRegistered},
+		{"backoff interrupted", func(t *testing.T) error {
+			m := NewManager()
+			_ = m.AddWorkerNode("w1", failing, WithRetry(3), WithBackoff(ConstantBackoff(time.Hour)))
+			if err := m.BuildPipeline([][]string{{Head, "w1"}, {"w1", Tail}}); err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			_, err := m.HandleContext(ctx, &rawData{})
+			return err
+		}, "w1", NodeTypWorker, "retry backoff interrupted", context.DeadlineExceeded},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.run(t)
+			var ne *NodeError
+			if !errors.As(err, &ne) {
+				t.Fatalf("err=%v, want NodeError", err)
+			}
+			if ne.Node != c.node || ne.Typ != c.typ || ne.Problem != c.problem {
+				t.Errorf("node=%s typ=%s problem=%q, want %s %s %q", ne.Node, ne.Typ, ne.Problem, c.node, c.typ, c.problem)
+			}
+			if c.is != nil && !errors.Is(err, c.is) {
+				t.Errorf("err=%v, want errors.Is %v", err, c.is)
+			}
+			text := ne.Error()
+			want := fmt.Sprintf("pipeline: %s %q: %s", c.typ, c.node, c.problem)
+			if ne.Details != "" {
+				want += " (" + ne.Details + ")"
+			}
+			if text != want || !runtimeErrorFormat.MatchString(text) {
+				t.Errorf("text=%q, want %q", text, want)
+			}
+		})
+	}
+}
+
+// 测试执行路径上（execution、mergerState 的方法以及下面列出的函数）的错误都通过 runtimeError 构造
+// 允许的例外只包装已有的错误，不是新的节点错误
+func TestRuntimeErrorsUseHelper(t *testing.T) {
+	funcs := map[string]bool{"checkTopology": true, "attemptError": true, "actionTypeError": true}
+	allowed := map[string]bool{
+		// 整个流水线重试失败
+		"runWithRetry": true,
+		// 在原来的错误上附加说明
+		"saveDeadLetter": true,
+		"diagnose":       true,
+	}
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range f.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || fd.Body == nil || allowed[fd.Name.Name] || !onHandlePath(fd, funcs) {
+				continue
+			}
+			ast.Inspect(fd.Body, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				sel, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				if pkg, ok := sel.X.(*ast.Ident); ok &&
+					(pkg.Name == "fmt" && sel.Sel.Name == "Errorf" || pkg.Name == "errors" && sel.Sel.Name == "New") {
+					t.Errorf("%s: %s builds an error with %s.%s, use runtimeError", fset.Position(call.Pos()), fd.Name.Name, pkg.Name, sel.Sel.Name)
+				}
+				return true
+			})
+		}
+	}
+}
+
+func onHandlePath(fd *ast.FuncDecl, funcs map[string]bool) bool {
+	if fd.Recv == nil {
+		return funcs[fd.Name.Name]
+	}
+	star, ok := fd.Recv.List[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	recv, ok := star.X.(*ast.Ident)
+	return ok && (recv.Name == "execution" || recv.Name == "mergerState")
+}
@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+var ErrUnknownStateKind = errors.New("unknown node state kind")
+
+// 节点运行时状态的种类，见 ResetNodeState，与 RuntimeState 中的状态一一对应
+type StateKind int
+
+const (
+	// 所有种类
+	StateAll StateKind = iota
+	// 最近一次执行的错误，见 Health
+	StateHealth
+	// 判断节点每个分支被选择的次数
+	StateDecisions
+)
+
+// 节点在多次执行之间累积的状态，包括健康检查记录的错误和判断节点的决策计数
+// 流水线本身没有熔断、限流和结果缓存，节点自己实现的这类状态不在其中，需要由节点自己提供查看和清空的方法
+type RuntimeState struct {
+	Node string
+	Typ  NodeTyp
+	// 最近一次执行失败的错误，之后执行成功时清空
+	LastError string
+	// 判断节点每个分支被选择的次数，key 为分支名，没有命名时为分支的索引；其他节点为nil
+	Decisions map[string]int64
+}
+
+// 构建时为判断节点分配决策计数
+func (m *Manager) initRuntimeState() {
+	for _, node := range m.nodes {
+		node.decisionCounts = nil
+		if node.Typ == NodeTypJudger {
+			node.decisionCounts = make([]int64, len(node.Next))
+		}
+	}
+}
+
+// 返回节点的运行时状态，可以在执行的同时调用
+func (m *Manager) NodeRuntimeState(name string) (RuntimeState, error) {
+	node, ok := m.nodes[name]
+	if !ok {
+		return RuntimeState{}, fmt.Errorf("node[%s] cannot be found in nodes", name)
+	}
+	s := RuntimeState{Node: name, Typ: node.Typ}
+	m.health.mu.RLock()
+	if err := m.health.nodeErr[node]; err != nil {
+		s.LastError = err.Error()
+	}
+	m.health.mu.RUnlock()
+	if node.decisionCounts != nil {
+		s.Decisions = make(map[string]int64, len(node.decisionCounts))
+		for i := range node.decisionCounts {
+			s.Decisions[node.branchName(i)] = atomic.LoadInt64(&node.decisionCounts[i])
+		}
+	}
+	return s, nil
+}
+
+// 清空节点what 种类的运行时状态，可以在执行的同时调用
+func (m *Manager) ResetNodeState(name string, what StateKind) error {
+	node, ok := m.nodes[name]
+	if !ok {
+		return fmt.Errorf("node[%s] cannot be found in nodes", name)
+	}
+	if what < StateAll || what > StateDecisions {
+		return fmt.Errorf("%w: %d", ErrUnknownStateKind, what)
+	}
+	if what == StateAll || what == StateHealth {
+		m.health.mu.Lock()
+		delete(m.health.nodeErr, node)
+		m.health.mu.Unlock()
+	}
+	if what == StateAll || what == StateDecisions {
+		for i := range node.decisionCounts {
+			atomic.StoreInt64(&node.decisionCounts[i], 0)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 测试查看和清空节点的运行时状态
+func TestManager_NodeRuntimeState(t *testing.T) {
+	m := NewManager()
+	_ = m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) int {
+		return in.Data.(int)
+	}, WithBranches("ok", "flaky"))
+	_ = m.AddWorkerNode("ok", passWorker)
+	_ = m.AddWorkerNode("flaky", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return nil, errors.New("dependency down")
+	})
+	if err := m.BuildPipeline([][]string{{Head, "j1"}, {"j1", "ok"}, {"j1", "flaky"}, {"ok", Tail}, {"flaky", Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, branch := range []int{0, 0, 1} {
+		_, _ = m.Handle(&rawData{Data: branch})
+	}
+	s, err := m.NodeRuntimeState("flaky")
+	if err != nil || s.LastError != "dependency down" || s.Decisions != nil {
+		t.Fatalf("unexpected state %+v %v", s, err)
+	}
+	if s, _ = m.NodeRuntimeState("j1"); !reflect.DeepEqual(s.Decisions, map[string]int64{"ok": 2, "flaky": 1}) {
+		t.Errorf("unexpected decisions %+v", s.Decisions)
+	}
+	if m.Health(context.Background()).Status != HealthDegraded {
+		t.Error("want degraded before reset")
+	}
+
+	// 依赖恢复后手动清空
+	if err = m.ResetNodeState("flaky", StateHealth); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ = m.NodeRuntimeState("flaky"); s.LastError != "" || m.Health(context.Background()).Status != HealthReady {
+		t.Errorf("health should be cleared: %+v", s)
+	}
+	if err = m.ResetNodeState("j1", StateAll); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ = m.NodeRuntimeState("j1"); !reflect.DeepEqual(s.Decisions, map[string]int64{"ok": 0, "flaky": 0}) {
+		t.Errorf("decisions should be cleared: %+v", s.Decisions)
+	}
+
+	if _, err = m.NodeRuntimeState("missing"); err == nil || !strings.Contains(err.Error(), "node[missing]") {
+		t.Errorf("want unknown node error, got %v", err)
+	}
+	if err = m.ResetNodeState("missing", StateAll); err == nil {
+		t.Error("want unknown node error")
+	}
+	if err = m.ResetNodeState("j1", StateKind(99)); !errors.Is(err, ErrUnknownStateKind) {
+		t.Errorf("want ErrUnknownStateKind, got %v", err)
+	}
+}
+
+// 测试执行的同时查看和清空状态
+func TestManager_NodeRuntimeStateConcurrent(t *testing.T) {
+	m := newBudgetJudgerManager(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _ = m.Handle(&rawData{})
+			}
+		}()
+	}
+	for j := 0; j < 100; j++ {
+		_ = m.ResetNodeState("budget", StateAll)
+		if _, err := m.NodeRuntimeState("budget"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,56 @@
+package pipeline
+
+// 轨迹和记录的采样比例，见 WithTraceSampling、WithRecordingSampling
+type samplingRates struct {
+	trace, recording float64
+}
+
+// 只对rate 比例（0 到1）的执行记录 WithTrace 传入的轨迹，未采样的执行跳过记录轨迹的全部代码，
+// 只在节点失败时记录失败节点的一条轨迹；默认全部采样
+func WithTraceSampling(rate float64) Option {
+	return func(m *Manager) {
+		m.samplingRates().trace = rate
+	}
+}
+
+// 只对rate 比例（0 到1）的执行记录数据血缘（WithLineage），未采样的执行不记录血缘；默认全部采样
+// 与 WithTraceSampling 使用同一个随机数，记录的比例不大于轨迹时，记录血缘的执行一定也记录了轨迹
+func WithRecordingSampling(rate float64) Option {
+	return func(m *Manager) {
+		m.samplingRates().recording = rate
+	}
+}
+
+func (m *Manager) samplingRates() *samplingRates {
+	if m.sampling == nil {
+		m.sampling = &samplingRates{trace: 1, recording: 1}
+	}
+	return m.sampling
+}
+
+// 本次执行不受采样限制，总是记录轨迹和血缘，用于定向调试
+func WithForceTrace() CallOption {
+	return func(o *callOptions) {
+		o.forceTrace = true
+	}
+}
+
+// 每次执行采样一次，使用执行的随机数来源（见 WithExecutionRand），没有时使用Manager 的随机数来源
+func (e *execution) sample(force bool) {
+	var u float64
+	if !force {
+		if e.rnd != nil {
+			u = e.rnd.Float64()
+		} else {
+			u = e.m.rand.Float64()
+		}
+	}
+	e.traceSampled = force || u < e.m.sampling.trace
+	e.recordingSampled = force || u < e.m.sampling.recording
+	if !e.traceSampled && e.trace != nil {
+		e.unsampledTrace, e.trace = e.trace, nil
+	}
+	if !e.recordingSampled {
+		e.lineage = false
+	}
+}
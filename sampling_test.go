@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+func TestManager_SamplingRates(t *testing.T) {
+	const n = 10000
+	m := newLinearManager(t, 2, 0)
+	for _, opt := range []Option{
+		WithTraceSampling(0.1),
+		WithRecordingSampling(0.02),
+		WithRandSource(rand.NewSource(1)),
+		WithExecutionHistory(n),
+	} {
+		opt(m)
+	}
+	for i := 0; i < n; i++ {
+		if _, err := m.Handle(&rawData{Data: 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var traced, recorded int
+	for _, s := range m.RecentExecutions() {
+		if s.TraceSampled {
+			traced++
+		}
+		if s.RecordingSampled {
+			recorded++
+			if !s.TraceSampled {
+				t.Errorf("execution %s recorded without trace", s.ID)
+			}
+		}
+	}
+	if traced < 900 || traced > 1100 {
+		t.Errorf("trace sampled %d of %d, want about 1000", traced, n)
+	}
+	if recorded < 150 || recorded > 250 {
+		t.Errorf("recording sampled %d of %d, want about 200", recorded, n)
+	}
+}
+
+func TestManager_UnsampledTrace(t *testing.T) {
+	m := newLinearManager(t, 3, 0)
+	WithTraceSampling(0)(m)
+	WithRecordingSampling(0)(m)
+	tr := &Trace{}
+	out, err := m.HandleContext(context.Background(), &rawData{Data: 0}, WithTrace(tr), WithLineage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries := tr.Entries(); len(entries) != 0 {
+		t.Errorf("unsampled execution should not be traced, got %d entries", len(entries))
+	}
+	if _, ok := ResultLineage(out); ok {
+		t.Error("unsampled execution should not record lineage")
+	}
+
+	tr = &Trace{}
+	out, err = m.HandleContext(context.Background(), &rawData{Data: 0}, WithTrace(tr), WithLineage(), WithForceTrace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries := tr.Entries(); len(entries) != 3 {
+		t.Errorf("forced execution should be traced, got %d entries", len(entries))
+	}
+	if _, ok := ResultLineage(out); !ok {
+		t.Error("forced execution should record lineage")
+	}
+}
+
+func TestManager_UnsampledTraceKeepsFailure(t *testing.T) {
+	m := newLinearManager(t, 3, 2)
+	WithTraceSampling(0)(m)
+	tr := &Trace{}
+	if _, err := m.HandleContext(context.Background(), &rawData{Data: 0}, WithTrace(tr)); err == nil {
+		t.Fatal("want error")
+	}
+	entries := tr.Entries()
+	if len(entries) != 1 || entries[0].Node != "w2" || entries[0].Err == nil {
+		t.Errorf("want only the failing node in the trace, got %+v", entries)
+	}
+}
+
+// 未采样的执行与不记录轨迹的执行分配次数相同
+func TestManager_UnsampledTraceAllocs(t *testing.T) {
+	m := newLinearManager(t, 8, 0)
+	WithTraceSampling(0)(m)
+	ctx, in := context.Background(), &rawData{}
+	traced, untraced := WithTrace(&Trace{}), WithTrace(nil)
+	allocs := func(opt CallOption) float64 {
+		return testing.AllocsPerRun(100, func() {
+			in.Data = 0
+			_, _ = m.HandleContext(ctx, in, opt)
+		})
+	}
+	if got, want := allocs(traced), allocs(untraced); got != want {
+		t.Errorf("unsampled trace allocs %v, want %v", got, want)
+	}
+}
+
+func BenchmarkHandle_UnsampledTrace(b *testing.B) {
+	m := newLinearManager(b, 8, 0)
+	WithTraceSampling(0)(m)
+	ctx, in := context.Background(), &rawData{}
+	opt := WithTrace(&Trace{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		in.Data = 0
+		if _, err := m.HandleContext(ctx, in, opt); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
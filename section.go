@@ -0,0 +1,153 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// 执行结束时没有经过临界区的结束节点，例如所在的分支被放弃
+var ErrCriticalSectionIncomplete = errors.New("critical section did not reach its last node")
+
+// 从fromNode 到toNode 的一段节点，执行期间持有同一把锁
+type criticalSection struct {
+	name     string
+	from, to string
+	acquire  func(ctx context.Context, in *rawData) (release func(error), err error)
+	// 构建时解析的首尾节点
+	first, last *Node
+}
+
+// 定义临界区：执行到fromNode 之前调用acquire，toNode 执行完成后调用返回的release(nil)；
+// 执行在临界区内失败或被取消时，以最终的错误调用release
+// fromNode 必须支配toNode（不经过fromNode 无法到达toNode），临界区内的节点只能从fromNode 进入、
+// 从toNode 离开，fromNode 不能是合并节点；临界区之间不能重叠或嵌套，以上在构建时检查
+// acquire 失败时fromNode 不会执行，执行返回包含该错误的 NodeError
+// 开启 WithStageParallelism 时acquire 等待期间其他分支继续执行，临界区内的分支也可以同时执行
+func (m *Manager) DefineCriticalSection(name string, fromNode, toNode string,
+	acquire func(ctx context.Context, in *rawData) (release func(error), err error)) {
+	m.sections = append(m.sections, &criticalSection{name: name, from: fromNode, to: toNode, acquire: acquire})
+}
+
+// 检查临界区的定义，并在首尾节点上记录所属的临界区
+func (m *Manager) validateCriticalSections() error {
+	for _, node := range m.nodes {
+		node.section = nil
+	}
+	preds := make(map[*Node][]*Node)
+	for _, edge := range m.edgeList {
+		preds[m.nodes[edge.to]] = append(preds[m.nodes[edge.to]], m.nodes[edge.from])
+	}
+	owner := make(map[*Node]*criticalSection)
+	names := make(map[string]bool)
+	for _, s := range m.sections {
+		if names[s.name] {
+			return fmt.Errorf("critical section[%s] is already defined", s.name)
+		}
+		names[s.name] = true
+		for _, n := range []string{s.from, s.to} {
+			node := m.nodes[n]
+			if node == nil || node.Typ == NodeTypHead || node.Typ == NodeTypTail {
+				return fmt.Errorf("critical section[%s] node[%s] cannot be found in nodes", s.name, n)
+			}
+		}
+		s.first, s.last = m.nodes[s.from], m.nodes[s.to]
+		if s.first.Typ == NodeTypMerger {
+			return fmt.Errorf("critical section[%s] cannot start at merger node[%s]", s.name, s.from)
+		}
+		if reachableWithout(m.nodes[m.headName], s.last, s.first) {
+			return fmt.Errorf("critical section[%s] node[%s] can be reached without passing node[%s]", s.name, s.to, s.from)
+		}
+		nodes := pathNodes(s.first, s.last)
+		if nodes == nil {
+			return fmt.Errorf("critical section[%s] node[%s] cannot reach node[%s]", s.name, s.from, s.to)
+		}
+		for n := range nodes {
+			if other := owner[n]; other != nil {
+				return fmt.Errorf("critical section[%s] overlaps critical section[%s] at node[%s]", s.name, other.name, n.nodeName)
+			}
+			owner[n] = s
+			if n != s.last {
+				for _, next := range n.Next {
+					if !nodes[next] {
+						return fmt.Errorf("critical section[%s] node[%s] leaves the section to node[%s] without passing node[%s]",
+							s.name, n.nodeName, next.nodeName, s.to)
+					}
+				}
+			}
+			if n != s.first {
+				for _, pred := range preds[n] {
+					if !nodes[pred] {
+						return fmt.Errorf("critical section[%s] node[%s] enters the section at node[%s] without passing node[%s]",
+							s.name, pred.nodeName, n.nodeName, s.from)
+					}
+				}
+			}
+		}
+		s.first.section, s.last.section = s, s
+	}
+	return nil
+}
+
+// 从src 出发不经过avoid 能否到达dst
+func reachableWithout(src, dst, avoid *Node) bool {
+	seen := map[*Node]bool{avoid: true}
+	stack := []*Node{src}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[n] {
+			continue
+		}
+		if n == dst {
+			return true
+		}
+		seen[n] = true
+		stack = append(stack, n.Next...)
+	}
+	return false
+}
+
+// 执行到临界区的第一个节点之前获取锁，并行执行时等待期间释放执行的锁
+func (e *execution) enterSection(ctx context.Context, node *Node, in *rawData) error {
+	s := node.section
+	e.current = node
+	var release func(error)
+	err := e.call(ctx, func(ctx context.Context) (err error) {
+		release, err = s.acquire(ctx, in)
+		return err
+	})
+	if err != nil {
+		return runtimeError(node, err, "critical section acquire failed", fmt.Sprintf("section[%s]: %v", s.name, err))
+	}
+	if e.sections == nil {
+		e.sections = make(map[*criticalSection]func(error))
+	}
+	e.sections[s] = release
+	return nil
+}
+
+// 临界区的最后一个节点完成后释放锁
+func (e *execution) leaveSection(s *criticalSection) {
+	release, ok := e.sections[s]
+	if !ok {
+		return
+	}
+	delete(e.sections, s)
+	if release != nil {
+		release(nil)
+	}
+}
+
+// 执行结束时释放仍然持有的锁，err 为nil 时说明没有到达临界区的最后一个节点
+func (e *execution) releaseSections(err error) {
+	if err == nil {
+		err = ErrCriticalSectionIncomplete
+	}
+	for s, release := range e.sections {
+		delete(e.sections, s)
+		if release != nil {
+			release(err)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 记录节点执行以及锁的获取、释放顺序
+type sectionRecorder struct {
+	events   []string
+	released []error
+}
+
+func (r *sectionRecorder) worker(name string, fail error) WorkerFunc {
+	return func(ctx context.Context, in *rawData) (*rawData, error) {
+		r.events = append(r.events, name)
+		return in, fail
+	}
+}
+
+func (r *sectionRecorder) acquire(ctx context.Context, in *rawData) (func(error), error) {
+	r.events = append(r.events, "acquire")
+	return func(err error) {
+		r.events = append(r.events, "release")
+		r.released = append(r.released, err)
+	}, nil
+}
+
+// w1 -> reserve -> confirm -> w2 的直线流程，reserve 到confirm 为临界区
+func newSectionManager(t *testing.T, r *sectionRecorder, confirmErr error) *Manager {
+	m := NewManager()
+	for _, n := range []struct {
+		name string
+		err  error
+	}{{"w1", nil}, {"reserve", nil}, {"confirm", confirmErr}, {"w2", nil}} {
+		if err := m.AddWorkerNode(n.name, r.worker(n.name, n.err)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.DefineCriticalSection("booking", "reserve", "confirm", r.acquire)
+	if err := m.BuildPipeline([][]string{
+		{Head, "w1"},
+		{"w1", "reserve"},
+		{"reserve", "confirm"},
+		{"confirm", "w2"},
+		{"w2", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestManager_CriticalSection(t *testing.T) {
+	for _, fastPath := range []bool{true, false} {
+		r := &sectionRecorder{}
+		m := newSectionManager(t, r, nil)
+		m.disableFastPath = !fastPath
+		if _, err := m.Handle(&rawData{}); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"w1", "acquire", "reserve", "confirm", "release", "w2"}
+		if !reflect.DeepEqual(r.events, want) {
+			t.Errorf("fastPath=%v events = %v, want %v", fastPath, r.events, want)
+		}
+		if !reflect.DeepEqual(r.released, []error{nil}) {
+			t.Errorf("fastPath=%v released with %v, want nil", fastPath, r.released)
+		}
+	}
+}
+
+func TestManager_CriticalSectionFailure(t *testing.T) {
+	r := &sectionRecorder{}
+	boom := errors.New("boom")
+	m := newSectionManager(t, r, boom)
+	_, err := m.Handle(&rawData{})
+	if !errors.Is(err, boom) {
+		t.Fatalf("want boom, got %v", err)
+	}
+	want := []string{"w1", "acquire", "reserve", "confirm", "release"}
+	if !reflect.DeepEqual(r.events, want) {
+		t.Errorf("events = %v, want %v", r.events, want)
+	}
+	var nodeErr *NodeError
+	if len(r.released) != 1 || !errors.As(r.released[0], &nodeErr) || nodeErr.Node != "confirm" {
+		t.Errorf("release should get the failure of confirm, got %v", r.released)
+	}
+}
+
+func TestManager_CriticalSectionAcquireFailure(t *testing.T) {
+	r := &sectionRecorder{}
+	m := NewManager()
+	_ = m.AddWorkerNode("reserve", r.worker("reserve", nil))
+	_ = m.AddWorkerNode("confirm", r.worker("confirm", nil))
+	busy := errors.New("lock is busy")
+	m.DefineCriticalSection("booking", "reserve", "confirm", func(ctx context.Context, in *rawData) (func(error), error) {
+		return nil, busy
+	})
+	if err := m.BuildPipeline([][]string{{Head, "reserve"}, {"reserve", "confirm"}, {"confirm", Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := m.Handle(&rawData{})
+	var nodeErr *NodeError
+	if !errors.Is(err, busy) || !errors.As(err, &nodeErr) || nodeErr.Node != "reserve" {
+		t.Errorf("want acquire error on reserve, got %v", err)
+	}
+	if len(r.events) != 0 {
+		t.Errorf("no node should run without the lock, got %v", r.events)
+	}
+}
+
+func TestManager_CriticalSectionCancelled(t *testing.T) {
+	r := &sectionRecorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewManager()
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{in, in}, nil
+	})
+	_ = m.AddWorkerNode("a", func(ctx context.Context, in *rawData) (*rawData, error) {
+		cancel()
+		return nil, ctx.Err()
+	})
+	_ = m.AddWorkerNode("b", passWorker)
+	_ = m.AddMergerNode("m1", func(ctx context.Context, ins []*rawData) (*rawData, error) {
+		return ins[0], nil
+	})
+	m.DefineCriticalSection("fanout", "d1", "m1", r.acquire)
+	if err := m.BuildPipeline([][]string{
+		{Head, "d1"},
+		{"d1", "a"},
+		{"d1", "b"},
+		{"a", "m1"},
+		{"b", "m1"},
+		{"m1", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.HandleContext(ctx, &rawData{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+	if len(r.released) != 1 || !errors.Is(r.released[0], context.Canceled) {
+		t.Errorf("release should get the cancellation, got %v", r.released)
+	}
+}
+
+// 测试并行执行时获取临界区期间其他分支继续执行，临界区结束后以nil 释放
+func TestManager_CriticalSectionParallel(t *testing.T) {
+	var r rendezvous
+	var released []error
+	m := NewManager(WithStageParallelism())
+	m.parallelism = 2
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{in, in}, nil
+	})
+	_ = m.AddWorkerNode("other", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return in, r.wait("other", "acquire")
+	})
+	_ = m.AddWorkerNode("reserve", passWorker)
+	_ = m.AddWorkerNode("confirm", passWorker)
+	_ = m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		return in[0], nil
+	})
+	// 获取临界区时等到other 开始执行，持有执行的锁时other 无法开始
+	m.DefineCriticalSection("booking", "reserve", "confirm", func(ctx context.Context, in *rawData) (func(error), error) {
+		if err := r.wait("acquire", "other"); err != nil {
+			return nil, err
+		}
+		return func(err error) { released = append(released, err) }, nil
+	})
+	if err := m.BuildPipeline([][]string{
+		{Head, "d1"}, {"d1", "other"}, {"d1", "reserve"}, {"reserve", "confirm"},
+		{"other", "m1"}, {"confirm", "m1"}, {"m1", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Handle(&rawData{}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(released, []error{nil}) {
+		t.Errorf("released=%v, want one release with nil", released)
+	}
+}
+
+func TestManager_CriticalSectionValidation(t *testing.T) {
+	noop := func(ctx context.Context, in *rawData) (func(error), error) {
+		return nil, nil
+	}
+	for _, c := range []struct {
+		name     string
+		sections [][2]string
+		want     string
+	}{
+		{"not dominated", [][2]string{{"a", "m1"}}, "node[m1] can be reached without passing node[a]"},
+		{"overlap", [][2]string{{"w1", "w2"}, {"w2", "w3"}}, "critical section[s1] overlaps critical section[s0] at node[w2]"},
+		{"nested", [][2]string{{"w1", "w3"}, {"w2", "w2"}}, "critical section[s1] overlaps critical section[s0] at node[w2]"},
+		{"leaves", [][2]string{{"j1", "x"}}, "node[j1] leaves the section to node[y] without passing node[x]"},
+		{"starts at merger", [][2]string{{"m1", "w1"}}, "cannot start at merger node[m1]"},
+		{"unknown", [][2]string{{"w1", "nope"}}, "node[nope] cannot be found"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			m := NewManager()
+			_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+				return []*rawData{in, in}, nil
+			})
+			_ = m.AddMergerNode("m1", func(ctx context.Context, ins []*rawData) (*rawData, error) {
+				return ins[0], nil
+			})
+			_ = m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) int { return 0 })
+			for _, n := range []string{"a", "b", "w1", "w2", "w3", "x", "y"} {
+				_ = m.AddWorkerNode(n, passWorker)
+			}
+			for i, s := range c.sections {
+				m.DefineCriticalSection("s"+string(rune('0'+i)), s[0], s[1], noop)
+			}
+			err := m.BuildPipeline([][]string{
+				{Head, "d1"},
+				{"d1", "a"},
+				{"d1", "b"},
+				{"a", "m1"},
+				{"b", "m1"},
+				{"m1", "w1"},
+				{"w1", "w2"},
+				{"w2", "w3"},
+				{"w3", "j1"},
+				{"j1", "x"},
+				{"j1", "y"},
+				{"x", Tail},
+				{"y", Tail},
+			})
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("want error containing %q, got %v", c.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 生成骨架时最多处理的路径数
+const skeletonPathLimit = maxCostCombinations
+
+// 一条执行路径以及路径上判断节点的决策
+type skeletonPath struct {
+	nodes     []*Node
+	decisions map[string]int
+}
+
+// 为构建好的流水线生成测试骨架，写入w，pkg 为生成文件的包名
+// 骨架包含：每条从头到尾的执行路径一个表格驱动的测试（见 Paths，判断节点的决策已经填好）、
+// 每个节点一个测试（按 ToDOT 的节点顺序，经过包含该节点的第一条路径执行，检查执行轨迹中该节点的记录），
+// 以及检查 Lint 的结果和路径数没有变化的测试；需要填写期望值的地方标有TODO
+// 节点的处理方法使用包内的 rawData，生成的文件需要放在构建流水线的包中，并实现其中的 newSkeletonManager
+// 同一个流水线每次生成的内容相同
+func (m *Manager) WriteTestSkeleton(w io.Writer, pkg string) error {
+	if !m.built {
+		return ErrorsPipelineNotBuilt
+	}
+	var paths []skeletonPath
+	err := m.eachDecisionPath(map[string]int{}, skeletonPathLimit, func(nodes []*Node, decisions map[string]int) {
+		paths = append(paths, skeletonPath{nodes: nodes, decisions: decisions})
+	})
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// 由 WriteTestSkeleton 生成的测试骨架，流水线指纹 %s\n", m.Fingerprint())
+	fmt.Fprintf(&b, "// 实现 newSkeletonManager 并填写TODO 处的期望值\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	fmt.Fprintf(&b, "import (\n\"context\"\n\"reflect\"\n\"testing\"\n)\n\n")
+	fmt.Fprintf(&b, "// TODO: 返回被测的流水线，和生成骨架时的流水线相同\n")
+	fmt.Fprintf(&b, "func newSkeletonManager(t *testing.T) *Manager {\nt.Helper()\nt.Skip(\"TODO: build the pipeline under test\")\nreturn nil\n}\n\n")
+	for i, p := range paths {
+		writeSkeletonPath(&b, i+1, p)
+	}
+	used := make(map[string]bool)
+	for _, node := range m.exportOrder() {
+		if node.Typ == NodeTypHead || node.Typ == NodeTypTail {
+			continue
+		}
+		for i, p := range paths {
+			if p.contains(node) {
+				writeSkeletonNode(&b, skeletonIdent(node.nodeName, used), node, i+1, p)
+				break
+			}
+		}
+	}
+	fmt.Fprintf(&b, "// 构建没有发现问题，执行路径和生成骨架时相同\n")
+	fmt.Fprintf(&b, "func TestSkeleton_Lint(t *testing.T) {\nm := newSkeletonManager(t)\n")
+	fmt.Fprintf(&b, "// TODO: 跳过已知并接受的问题\nfor _, f := range m.Lint() {\nt.Errorf(\"lint: %%s\", f)\n}\n")
+	fmt.Fprintf(&b, "paths, err := m.Paths(%d)\nif err != nil {\nt.Fatal(err)\n}\n", skeletonPathLimit)
+	fmt.Fprintf(&b, "if len(paths) != %d {\nt.Errorf(\"%%d paths, the skeleton covers %d\", len(paths))\n}\n}\n", len(paths), len(paths))
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("format test skeleton: %w", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+func (p skeletonPath) contains(node *Node) bool {
+	for _, n := range p.nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+// 路径上判断节点的决策，按节点名排序
+func (p skeletonPath) decisionsLiteral() string {
+	names := make([]string, 0, len(p.decisions))
+	for name := range p.decisions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := make([]string, len(names))
+	for i, name := range names {
+		items[i] = fmt.Sprintf("%s: %d", strconv.Quote(name), p.decisions[name])
+	}
+	return "Decisions{" + strings.Join(items, ", ") + "}"
+}
+
+// 路径的说明，形如 "d1 -> a -> m1"，判断节点后面注明选择的分支
+func (p skeletonPath) describe() string {
+	names := make([]string, len(p.nodes))
+	for i, node := range p.nodes {
+		names[i] = node.nodeName
+		if d, ok := p.decisions[node.nodeName]; ok {
+			names[i] += "[" + node.branchName(d) + "]"
+		}
+	}
+	return strings.Join(names, " -> ")
+}
+
+func writeSkeletonPath(b *bytes.Buffer, n int, p skeletonPath) {
+	names := make([]string, len(p.nodes))
+	for i, node := range p.nodes {
+		names[i] = strconv.Quote(node.nodeName)
+	}
+	fmt.Fprintf(b, "// 路径 %d: %s\n", n, p.describe())
+	fmt.Fprintf(b, "func TestSkeleton_Path%d(t *testing.T) {\n", n)
+	fmt.Fprintf(b, "path := []string{%s}\n", strings.Join(names, ", "))
+	fmt.Fprintf(b, "cases := []struct {\nname string\nin *rawData\nwant interface{}\n}{\n")
+	fmt.Fprintf(b, "// TODO: 走这条路径的输入以及期望的输出\n{name: \"todo\", in: &rawData{}, want: nil},\n}\n")
+	fmt.Fprintf(b, "for _, c := range cases {\nt.Run(c.name, func(t *testing.T) {\nm := newSkeletonManager(t)\ntrace := &Trace{}\n")
+	fmt.Fprintf(b, "out, err := m.HandleContext(context.Background(), c.in, WithForcedDecisions(%s), WithTrace(trace))\n", p.decisionsLiteral())
+	fmt.Fprintf(b, "if err != nil {\nt.Fatal(err)\n}\n")
+	fmt.Fprintf(b, "ran := make(map[string]bool)\nfor _, entry := range trace.Entries() {\nran[entry.Node] = true\n}\n")
+	fmt.Fprintf(b, "for _, node := range path {\nif !ran[node] {\nt.Errorf(\"node %%s did not run\", node)\n}\n}\n")
+	fmt.Fprintf(b, "if !reflect.DeepEqual(out.Data, c.want) {\nt.Errorf(\"out=%%v, want %%v\", out.Data, c.want)\n}\n")
+	fmt.Fprintf(b, "})\n}\n}\n\n")
+}
+
+func writeSkeletonNode(b *bytes.Buffer, ident string, node *Node, n int, p skeletonPath) {
+	name := strconv.Quote(node.nodeName)
+	fmt.Fprintf(b, "// 节点 %s（%s），经过路径 %d 执行\n", node.nodeName, node.Typ, n)
+	fmt.Fprintf(b, "func TestSkeleton_Node_%s(t *testing.T) {\nm := newSkeletonManager(t)\n", ident)
+	fmt.Fprintf(b, "// TODO: 能执行到该节点的输入\nin := &rawData{}\ntrace := &Trace{}\n")
+	fmt.Fprintf(b, "if _, err := m.HandleContext(context.Background(), in, WithForcedDecisions(%s), WithTrace(trace)); err != nil {\nt.Fatal(err)\n}\n", p.decisionsLiteral())
+	fmt.Fprintf(b, "for _, entry := range trace.Entries() {\nif entry.Node != %s {\ncontinue\n}\n", name)
+	fmt.Fprintf(b, "// TODO: 检查节点的执行结果\nif entry.Err != nil {\nt.Errorf(\"err=%%v\", entry.Err)\n}\nreturn\n}\n")
+	fmt.Fprintf(b, "t.Errorf(\"node %%s did not run\", %s)\n}\n\n", name)
+}
+
+// 节点名转换成测试函数名的一部分，不能用在标识符中的字符换成下划线，重复时加上序号
+func skeletonIdent(name string, used map[string]bool) string {
+	ident := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+	base := ident
+	for i := 2; used[ident]; i++ {
+		ident = fmt.Sprintf("%s_%d", base, i)
+	}
+	used[ident] = true
+	return ident
+}
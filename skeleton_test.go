@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 判断节点的一个分支经过菱形的分裂、合并节点
+func newSkeletonDiamond(t *testing.T) *Manager {
+	m := NewManager()
+	_ = m.AddJudgerNode("route", func(ctx context.Context, in *rawData) int { return 0 }, WithBranches("plain", "split"))
+	_ = m.AddWorkerNode("plain", passWorker)
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{in, in}, nil
+	})
+	_ = m.AddWorkerNode("a", passWorker)
+	_ = m.AddWorkerNode("b-2", passWorker)
+	_ = m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		return in[0], nil
+	})
+	if err := m.BuildPipeline([][]string{
+		{Head, "route"},
+		{"route", "plain"},
+		{"route", "d1"},
+		{"plain", Tail},
+		{"d1", "a"},
+		{"d1", "b-2"},
+		{"a", "m1"},
+		{"b-2", "m1"},
+		{"m1", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// 测试生成的骨架和testdata 中的一致，并且多次生成的结果相同
+func TestManager_WriteTestSkeleton(t *testing.T) {
+	var first, second bytes.Buffer
+	if err := newSkeletonDiamond(t).WriteTestSkeleton(&first, "pipeline"); err != nil {
+		t.Fatal(err)
+	}
+	if err := newSkeletonDiamond(t).WriteTestSkeleton(&second, "pipeline"); err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != second.String() {
+		t.Fatal("skeleton is not deterministic")
+	}
+	want, err := ioutil.ReadFile("testdata/skeleton.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != string(want) {
+		t.Errorf("skeleton mismatch, got:\n%s\nwant:\n%s", first.String(), want)
+	}
+	if err := NewManager().WriteTestSkeleton(&first, "pipeline"); err != ErrorsPipelineNotBuilt {
+		t.Errorf("err=%v, want ErrorsPipelineNotBuilt", err)
+	}
+}
+
+// 测试生成的骨架放进包的副本后能通过 go vet
+func TestManager_WriteTestSkeletonCompiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go vet on a copy of the package")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	dir, err := ioutil.TempDir("", "skeleton")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range append(files, "go.mod", "go.sum") {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := newSkeletonDiamond(t).WriteTestSkeleton(&buf, "pipeline"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "skeleton_test.go"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(goBin, "vet", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go vet: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 只报告截止时间、不会真正过期的ctx，配合fakeClock 使用
+type fakeDeadlineCtx struct {
+	context.Context
+	deadline time.Time
+}
+
+func (c fakeDeadlineCtx) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+// 测试剩余时间在阈值两侧时可选节点被跳过或执行
+func TestManager_SkipIfRemaining(t *testing.T) {
+	clock := newFakeClock()
+	m := NewManager(WithClock(clock))
+	_ = m.AddWorkerNode("w1", func(ctx context.Context, in *rawData) (*rawData, error) {
+		in.Data = in.Data.(int) + 1
+		return in, nil
+	})
+	_ = m.AddWorkerNode("enrich", func(ctx context.Context, in *rawData) (*rawData, error) {
+		in.Data = in.Data.(int) * 10
+		return in, nil
+	}, WithSkipIfRemaining(100*time.Millisecond))
+	if err := m.BuildPipeline([][]string{
+		{"head000", "w1"},
+		{"w1", "enrich"},
+		{"enrich", "tail111"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		remaining time.Duration
+		expected  int
+		outcome   Outcome
+	}{
+		{99 * time.Millisecond, 2, OutcomeSkipped},
+		{100 * time.Millisecond, 20, OutcomeExecuted},
+		{time.Second, 20, OutcomeExecuted},
+	}
+	for _, c := range cases {
+		ctx := fakeDeadlineCtx{Context: context.Background(), deadline: clock.Now().Add(c.remaining)}
+		trace := &Trace{}
+		out, err := m.HandleContext(ctx, &rawData{Data: 1}, WithTrace(trace))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Data.(int) != c.expected {
+			t.Errorf("remaining %v: expected %d, got %v", c.remaining, c.expected, out.Data)
+		}
+		entries := trace.Entries()
+		if len(entries) != 2 || entries[1].Node != "enrich" || entries[1].Outcome != c.outcome {
+			t.Errorf("remaining %v: unexpected trace %+v", c.remaining, entries)
+		}
+	}
+
+	// 没有截止时间时总是执行
+	out, err := m.Handle(&rawData{Data: 1})
+	if err != nil || out.Data.(int) != 20 {
+		t.Errorf("expected 20 without deadline, got %v, %v", out, err)
+	}
+}
+
+// 测试WithSkipIfRemaining 用在非工作节点上时构建失败
+func TestManager_SkipIfRemainingRejected(t *testing.T) {
+	m := NewManager()
+	_ = m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) int {
+		return 0
+	}, WithSkipIfRemaining(time.Second))
+	_ = m.AddWorkerNode("w1", passWorker)
+	_ = m.AddWorkerNode("w2", passWorker)
+	err := m.BuildPipeline([][]string{
+		{"head000", "j1"},
+		{"j1", "w1"},
+		{"j1", "w2"},
+		{"w1", "tail111"},
+		{"w2", "tail111"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "node[j1]") {
+		t.Fatalf("expected option error for j1, got %v", err)
+	}
+}
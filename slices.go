@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// 内置的处理方法：在分裂、合并节点处拆分和拼接切片类型的数据
+// 拆分出的每份数据带有原数据Meta 的拷贝；合并节点收到的输入按到达的顺序排列，
+// 各分支节点数相同时与分支顺序一致
+
+// 返回in.Data 对应的切片
+func sliceOf(node string, d *rawData) (reflect.Value, error) {
+	if d == nil {
+		return reflect.Value{}, fmt.Errorf("%s: data is nil", node)
+	}
+	v := reflect.ValueOf(d.Data)
+	if v.Kind() != reflect.Slice {
+		return reflect.Value{}, fmt.Errorf("%s: data is %T, not a slice", node, d.Data)
+	}
+	return v, nil
+}
+
+// 分裂节点：把切片按顺序拆成n 段连续的子切片，前面的段比后面的段最多多一个元素
+// n 大于元素个数时后面的段为空切片；n 不是正数或数据不是切片时报错
+func SplitSlice(n int) DividerFunc {
+	return func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		if n <= 0 {
+			return nil, fmt.Errorf("SplitSlice: n=%d should be positive", n)
+		}
+		v, err := sliceOf("SplitSlice", in)
+		if err != nil {
+			return nil, err
+		}
+		outs := make([]*rawData, n)
+		size, rest := v.Len()/n, v.Len()%n
+		start := 0
+		for i := range outs {
+			end := start + size
+			if i < rest {
+				end++
+			}
+			outs[i] = cloneData(in)
+			outs[i].Data = v.Slice3(start, end, end).Interface()
+			start = end
+		}
+		return outs, nil
+	}
+}
+
+// 分裂节点：每个元素一份数据，元素个数需要与分支数相同；空切片或数据不是切片时报错
+func SplitEach() DividerFunc {
+	return func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		v, err := sliceOf("SplitEach", in)
+		if err != nil {
+			return nil, err
+		}
+		if v.Len() == 0 {
+			return nil, fmt.Errorf("SplitEach: slice is empty")
+		}
+		outs := make([]*rawData, v.Len())
+		for i := range outs {
+			outs[i] = cloneData(in)
+			outs[i].Data = v.Index(i).Interface()
+		}
+		return outs, nil
+	}
+}
+
+// 合并节点：把每份输入中的切片依次拼接成一个切片，所有切片的类型必须相同
+// Data 为nil 的输入会被跳过；没有任何切片时输出的Data 为nil
+func ConcatSlices() MergerFunc {
+	return func(ctx context.Context, in []*rawData) (*rawData, error) {
+		var out reflect.Value
+		for i, d := range in {
+			if d == nil || d.Data == nil {
+				continue
+			}
+			v, err := sliceOf(fmt.Sprintf("ConcatSlices input[%d]", i), d)
+			if err != nil {
+				return nil, err
+			}
+			if !out.IsValid() {
+				out = reflect.MakeSlice(v.Type(), 0, v.Len())
+			} else if v.Type() != out.Type() {
+				return nil, fmt.Errorf("ConcatSlices input[%d]: %s does not match %s", i, v.Type(), out.Type())
+			}
+			out = reflect.AppendSlice(out, v)
+		}
+		if !out.IsValid() {
+			return &rawData{}, nil
+		}
+		return &rawData{Data: out.Interface()}, nil
+	}
+}
+
+// 合并节点：把每份输入的Data 依次收集成 []interface{}
+func CollectSlice() MergerFunc {
+	return func(ctx context.Context, in []*rawData) (*rawData, error) {
+		out := make([]interface{}, len(in))
+		for i, d := range in {
+			if d != nil {
+				out[i] = d.Data
+			}
+		}
+		return &rawData{Data: out}, nil
+	}
+}
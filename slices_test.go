@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func datas(outs []*rawData) []interface{} {
+	res := make([]interface{}, len(outs))
+	for i, out := range outs {
+		res[i] = out.Data
+	}
+	return res
+}
+
+func TestSplitSlice(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		in   interface{}
+		n    int
+		want []interface{}
+	}{
+		{[]int{1, 2, 3, 4, 5}, 2, []interface{}{[]int{1, 2, 3}, []int{4, 5}}},
+		{[]int{1, 2}, 3, []interface{}{[]int{1}, []int{2}, []int{}}},
+		{[]string{}, 2, []interface{}{[]string{}, []string{}}},
+	}
+	for _, c := range cases {
+		outs, err := SplitSlice(c.n)(ctx, &rawData{Data: c.in, Meta: map[string]interface{}{"k": "v"}})
+		if err != nil {
+			t.Fatalf("%v/%d: %v", c.in, c.n, err)
+		}
+		if got := datas(outs); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%v/%d: got %v, want %v", c.in, c.n, got, c.want)
+		}
+		if outs[0].Meta["k"] != "v" {
+			t.Errorf("meta should be copied to every chunk")
+		}
+	}
+	// 拆出的子切片追加元素不会覆盖后面的段
+	outs, _ := SplitSlice(2)(ctx, &rawData{Data: []int{1, 2, 3, 4}})
+	_ = append(outs[0].Data.([]int), 100)
+	if outs[1].Data.([]int)[0] != 3 {
+		t.Errorf("chunks should not share capacity")
+	}
+	for _, bad := range []struct {
+		in interface{}
+		n  int
+	}{{[]int{1}, 0}, {"not a slice", 2}, {nil, 2}} {
+		if _, err := SplitSlice(bad.n)(ctx, &rawData{Data: bad.in}); err == nil {
+			t.Errorf("%v/%d: expected error", bad.in, bad.n)
+		}
+	}
+}
+
+func TestSplitEach(t *testing.T) {
+	ctx := context.Background()
+	outs, err := SplitEach()(ctx, &rawData{Data: []string{"a", "b"}})
+	if err != nil || !reflect.DeepEqual(datas(outs), []interface{}{"a", "b"}) {
+		t.Errorf("outs=%v err=%v", outs, err)
+	}
+	for _, bad := range []interface{}{[]int{}, 3} {
+		if _, err := SplitEach()(ctx, &rawData{Data: bad}); err == nil {
+			t.Errorf("%v: expected error", bad)
+		}
+	}
+}
+
+func TestConcatSlicesAndCollectSlice(t *testing.T) {
+	ctx := context.Background()
+	out, err := ConcatSlices()(ctx, []*rawData{{Data: []int{1, 2}}, {}, {Data: []int{}}, {Data: []int{3}}})
+	if err != nil || !reflect.DeepEqual(out.Data, []int{1, 2, 3}) {
+		t.Errorf("out=%v err=%v", out, err)
+	}
+	if out, err = ConcatSlices()(ctx, nil); err != nil || out.Data != nil {
+		t.Errorf("no inputs: out=%v err=%v", out, err)
+	}
+	if _, err = ConcatSlices()(ctx, []*rawData{{Data: []int{1}}, {Data: []string{"a"}}}); err == nil {
+		t.Errorf("mismatched slice types should fail")
+	}
+	if _, err = ConcatSlices()(ctx, []*rawData{{Data: 1}}); err == nil {
+		t.Errorf("non-slice input should fail")
+	}
+	out, err = CollectSlice()(ctx, []*rawData{{Data: 1}, {Data: "a"}})
+	if err != nil || !reflect.DeepEqual(out.Data, []interface{}{1, "a"}) {
+		t.Errorf("out=%v err=%v", out, err)
+	}
+}
+
+// 测试在流水线中拆分、分别处理后再拼接
+func TestManager_SplitConcat(t *testing.T) {
+	m := NewManager()
+	_ = m.AddDividerNode("split", SplitSlice(2))
+	_ = m.AddMergerNode("concat", ConcatSlices())
+	double := func(ctx context.Context, in *rawData) (*rawData, error) {
+		s := in.Data.([]int)
+		res := make([]int, len(s))
+		for i, v := range s {
+			res[i] = v * 2
+		}
+		return &rawData{Data: res}, nil
+	}
+	_ = m.AddWorkerNode("w1", double)
+	_ = m.AddWorkerNode("w2", double)
+	if err := m.BuildPipeline([][]string{
+		{Head, "split"},
+		{"split", "w1"}, {"split", "w2"},
+		{"w1", "concat"}, {"w2", "concat"},
+		{"concat", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := m.Handle(&rawData{Data: []int{1, 2, 3}})
+	if err != nil || !reflect.DeepEqual(out.Data, []int{2, 4, 6}) {
+		t.Errorf("out=%v err=%v", out, err)
+	}
+}
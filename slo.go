@@ -0,0 +1,165 @@
+package pipeline
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 计算滚动分位数使用的最近执行次数，以及归因使用的最近慢执行次数
+const (
+	sloWindow     = 100
+	sloSlowWindow = 20
+	// 违反SLO 时给出的节点数
+	sloTopNodes = 3
+)
+
+// 执行耗时的目标：最近执行的耗时的percentile 分位数（例如0.99）不超过target
+type SLOStatus struct {
+	Target     time.Duration `json:"target"`
+	Percentile float64       `json:"percentile"`
+	// 最近执行耗时的分位数，以及参与计算的执行次数
+	Current time.Duration `json:"current"`
+	Samples int           `json:"samples"`
+	// 当前是否违反目标
+	Violated bool `json:"violated"`
+}
+
+// 节点在最近的慢执行（耗时超过目标的执行）中的累计耗时
+type NodeContribution struct {
+	Node     string
+	Duration time.Duration
+}
+
+// 违反SLO 的事件，TopNodes 为最近的慢执行中累计耗时最多的节点，最多3 个
+type SLOViolation struct {
+	SLOStatus
+	TopNodes []NodeContribution
+}
+
+// 统计每次执行（不含嵌套调用和预热）的耗时，维护最近100 次执行耗时的percentile 分位数，
+// 分位数从不超过target 变为超过target 时调用 OnSLOViolation 设置的回调，恢复之后再次超过时会再次调用
+// 状态通过 Health、Capabilities 查看；耗时由 WithClock 的时间来源测量
+func WithSLO(target time.Duration, percentile float64) Option {
+	return func(m *Manager) {
+		m.slo = &sloTracker{target: target, percentile: percentile}
+	}
+}
+
+// 设置违反SLO 时的回调，见 WithSLO；回调在执行结束的goroutine 中同步调用
+func OnSLOViolation(f func(v SLOViolation)) Option {
+	return func(m *Manager) {
+		m.onSLOViolation = f
+	}
+}
+
+type sloTracker struct {
+	mu         sync.Mutex
+	target     time.Duration
+	percentile float64
+	// 最近执行的耗时，环形缓冲区
+	latencies []time.Duration
+	next      int
+	// 最近的慢执行中每个节点的耗时
+	slow     []map[string]time.Duration
+	nextSlow int
+	current  time.Duration
+	violated bool
+}
+
+// 记录节点在本次执行中的耗时，开启 WithSLO 时调用
+func (e *execution) chargeSLO(node *Node, d time.Duration) {
+	if e.nodeTimes == nil {
+		e.nodeTimes = make(map[string]time.Duration)
+	}
+	e.nodeTimes[node.nodeName] += d
+}
+
+// 记录一次执行的耗时，分位数开始超过目标时返回违反的事件
+func (t *sloTracker) observe(latency time.Duration, nodeTimes map[string]time.Duration) (SLOViolation, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.latencies) < sloWindow {
+		t.latencies = append(t.latencies, latency)
+	} else {
+		t.latencies[t.next] = latency
+		t.next = (t.next + 1) % sloWindow
+	}
+	if latency > t.target {
+		if len(t.slow) < sloSlowWindow {
+			t.slow = append(t.slow, nodeTimes)
+		} else {
+			t.slow[t.nextSlow] = nodeTimes
+			t.nextSlow = (t.nextSlow + 1) % sloSlowWindow
+		}
+	}
+	sorted := append([]time.Duration(nil), t.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	// 最近秩法
+	rank := int(math.Ceil(t.percentile*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	} else if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	t.current = sorted[rank]
+	was := t.violated
+	t.violated = t.current > t.target
+	if !t.violated || was {
+		return SLOViolation{}, false
+	}
+	return SLOViolation{SLOStatus: t.statusLocked(), TopNodes: t.topNodes()}, true
+}
+
+// 最近的慢执行中累计耗时最多的节点，耗时相同时按节点名排序
+func (t *sloTracker) topNodes() []NodeContribution {
+	total := make(map[string]time.Duration)
+	for _, times := range t.slow {
+		for node, d := range times {
+			total[node] += d
+		}
+	}
+	top := make([]NodeContribution, 0, len(total))
+	for node, d := range total {
+		top = append(top, NodeContribution{Node: node, Duration: d})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Duration != top[j].Duration {
+			return top[i].Duration > top[j].Duration
+		}
+		return top[i].Node < top[j].Node
+	})
+	if len(top) > sloTopNodes {
+		top = top[:sloTopNodes]
+	}
+	return top
+}
+
+func (t *sloTracker) status() *SLOStatus {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.statusLocked()
+	return &s
+}
+
+func (t *sloTracker) statusLocked() SLOStatus {
+	return SLOStatus{
+		Target:     t.target,
+		Percentile: t.percentile,
+		Current:    t.current,
+		Samples:    len(t.latencies),
+		Violated:   t.violated,
+	}
+}
+
+// 执行结束时记录耗时，开启 WithSLO 时调用
+func (e *execution) observeSLO() {
+	v, violated := e.m.slo.observe(e.m.clock.Now().Sub(e.start), e.nodeTimes)
+	if violated && e.m.onSLOViolation != nil {
+		e.m.onSLOViolation(v)
+	}
+}
@@ -0,0 +1,67 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 测试分位数超过目标时触发一次违反事件，并指出慢执行中耗时最多的节点
+func TestManager_SLO(t *testing.T) {
+	clock := newFakeClock()
+	// 每个节点执行时让时间前进的长度
+	costs := map[string]time.Duration{"parse": 10 * time.Millisecond, "db": 50 * time.Millisecond, "render": 20 * time.Millisecond}
+	spend := func(name string) WorkerFunc {
+		return func(ctx context.Context, in *rawData) (*rawData, error) {
+			clock.Advance(costs[name])
+			return in, nil
+		}
+	}
+	var violations []SLOViolation
+	m := NewManager(WithClock(clock), WithSLO(500*time.Millisecond, 0.9), OnSLOViolation(func(v SLOViolation) {
+		violations = append(violations, v)
+	}))
+	for _, name := range []string{"parse", "db", "render"} {
+		_ = m.AddWorkerNode(name, spend(name))
+	}
+	if err := m.BuildPipeline([][]string{{Head, "parse"}, {"parse", "db"}, {"db", "render"}, {"render", Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	run := func(n int) {
+		for i := 0; i < n; i++ {
+			if _, err := m.HandleContext(context.Background(), &rawData{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	run(20)
+	if len(violations) != 0 || m.Health(context.Background()).SLO.Violated {
+		t.Fatalf("fast executions should not violate, got %+v", violations)
+	}
+
+	// db 变慢，超过10% 的执行超过目标后触发
+	costs["db"] = 600 * time.Millisecond
+	run(2)
+	if len(violations) != 0 {
+		t.Fatalf("p90 is still within target, got %+v", violations)
+	}
+	run(1)
+	if len(violations) != 1 {
+		t.Fatalf("want one violation, got %d", len(violations))
+	}
+	v := violations[0]
+	want := []NodeContribution{{"db", 3 * 600 * time.Millisecond}, {"render", 3 * 20 * time.Millisecond}, {"parse", 3 * 10 * time.Millisecond}}
+	if !v.Violated || v.Current != 630*time.Millisecond || v.Samples != 23 || !reflect.DeepEqual(v.TopNodes, want) {
+		t.Errorf("unexpected violation %+v", v)
+	}
+	// 持续违反时不重复触发
+	run(5)
+	if len(violations) != 1 {
+		t.Errorf("want no repeated violation, got %d", len(violations))
+	}
+	status := m.Capabilities().SLO
+	if status == nil || !status.Violated || status.Target != 500*time.Millisecond || m.Health(context.Background()).Status != HealthReady {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
@@ -0,0 +1,104 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+)
+
+// 检查点在存储中的key 前缀，完整的key 为 snapshot/<ExecID>/<序号>
+const snapshotPrefix = "snapshot/"
+
+// 执行在节点边界上的检查点，以JSON 保存在 snapshot/<ExecID>/<Seq> 下
+// Outputs 为节点成功执行后的输出，分裂节点为各分支的输出；数据经过JSON 编解码，
+// 设置了 WithPayloadRedactor 时保存的是脱敏后的数据
+type Checkpoint struct {
+	ExecID string `json:"exec_id"`
+	// 本次执行中的第几个检查点，从1 开始
+	Seq     int        `json:"seq"`
+	Node    string     `json:"node"`
+	Time    time.Time  `json:"time"`
+	Outputs []*rawData `json:"outputs"`
+	// 到这个节点为止判断节点的决策
+	Decisions Decisions `json:"decisions,omitempty"`
+}
+
+// 设置了 WithCheckpoint 的节点成功执行之后把检查点保存到s 中，通过 Checkpoints 读出
+// 保存失败时节点失败，错误满足 errors.Is(err, 存储返回的错误)
+func WithSnapshotStore(s Store) Option {
+	return func(m *Manager) {
+		m.snapshots = s
+	}
+}
+
+// 节点成功执行之后保存一个检查点，需要设置 WithSnapshotStore，否则构建报错
+// 只对工作节点、分裂节点、合并节点生效
+func WithCheckpoint() NodeOption {
+	return func(o *nodeOptions) {
+		o.checkpoint = true
+		o.use("WithCheckpoint", NodeTypWorker, NodeTypDivider, NodeTypMerger)
+	}
+}
+
+// 设置了检查点的节点需要有保存的地方
+func (m *Manager) validateCheckpoints() error {
+	if m.snapshots != nil {
+		return nil
+	}
+	for _, edge := range m.edgeList {
+		if node := m.nodes[edge.to]; node != nil && node.opts.checkpoint {
+			return invalidNode(CodeBadOption, node.nodeName, fmt.Errorf("node[%s] WithCheckpoint needs WithSnapshotStore", node.nodeName))
+		}
+	}
+	return nil
+}
+
+// 保存节点的检查点，并行执行时保存期间释放执行的锁
+func (e *execution) checkpoint(node *Node, outs ...*rawData) error {
+	e.checkpoints++
+	cp := Checkpoint{ExecID: e.id(), Seq: e.checkpoints, Node: node.nodeName, Time: e.m.clock.Now()}
+	cp.Outputs = make([]*rawData, len(outs))
+	for i, out := range outs {
+		cp.Outputs[i] = e.m.redact(out)
+	}
+	if len(e.decisions) > 0 {
+		cp.Decisions = make(Decisions, len(e.decisions))
+		for name, index := range e.decisions {
+			cp.Decisions[name] = index
+		}
+	}
+	data, err := json.Marshal(cp)
+	if err == nil {
+		key := fmt.Sprintf("%s%s/%06d", snapshotPrefix, cp.ExecID, cp.Seq)
+		err = e.call(e.ctx, func(ctx context.Context) error {
+			return e.m.snapshots.Put(ctx, key, data)
+		})
+	}
+	if err != nil {
+		return runtimeError(node, err, "checkpoint not saved", fmt.Sprintf("checkpoint %d: %v", cp.Seq, err))
+	}
+	return nil
+}
+
+// 读出s 中执行execID 保存的全部检查点，按保存的顺序排列
+func Checkpoints(ctx context.Context, s Store, execID string) ([]Checkpoint, error) {
+	keys, err := s.List(ctx, snapshotPrefix+execID+"/")
+	if err != nil {
+		return nil, err
+	}
+	cps := make([]Checkpoint, 0, len(keys))
+	for _, key := range keys {
+		data, err := s.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		var cp Checkpoint
+		if err = json.Unmarshal(data, &cp); err != nil {
+			return nil, fmt.Errorf("checkpoint[%s/%s]: %w", execID, path.Base(key), err)
+		}
+		cps = append(cps, cp)
+	}
+	return cps, nil
+}
@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/caigoumiao/pipeline/store"
+)
+
+// 写入总是失败的存储
+type failingStore struct {
+	Store
+	err error
+}
+
+func (s failingStore) Put(ctx context.Context, key string, value []byte) error {
+	return s.err
+}
+
+// 构建 w1 -> j1 -> (d1 -> a,b -> m1 | w2) 的流水线，w1、d1、m1 保存检查点，id 记录执行的标识
+func newCheckpointManager(id *string, opts ...Option) (*Manager, error) {
+	m := NewManager(append(opts, WithContextValues())...)
+	_ = m.AddWorkerNode("w1", func(ctx context.Context, in *rawData) (*rawData, error) {
+		*id, _ = ExecutionIDFrom(ctx)
+		return &rawData{Data: in.Data.(string) + "/w1"}, nil
+	}, WithCheckpoint())
+	_ = m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) int { return 0 })
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{{Data: in.Data.(string) + "/a"}, {Data: in.Data.(string) + "/b"}}, nil
+	}, WithCheckpoint())
+	_ = m.AddWorkerNode("a", passWorker)
+	_ = m.AddWorkerNode("b", passWorker)
+	_ = m.AddWorkerNode("w2", passWorker)
+	_ = m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		return &rawData{Data: in[0].Data.(string) + "+" + in[1].Data.(string)}, nil
+	}, WithCheckpoint())
+	return m, m.BuildPipeline([][]string{
+		{Head, "w1"}, {"w1", "j1"}, {"j1", "d1"}, {"j1", "w2"},
+		{"d1", "a"}, {"d1", "b"}, {"a", "m1"}, {"b", "m1"}, {"m1", Tail}, {"w2", Tail},
+	})
+}
+
+// 测试设置了 WithCheckpoint 的节点成功执行之后按顺序保存检查点，包括节点的输出和之前的决策
+func TestManager_Checkpoints(t *testing.T) {
+	s := store.NewMemory()
+	var id string
+	m, err := newCheckpointManager(&id, WithSnapshotStore(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := m.Handle(&rawData{Data: "in"})
+	if err != nil || out.Data != "in/w1/a+in/w1/b" {
+		t.Fatalf("out=%v err=%v", out, err)
+	}
+	cps, err := Checkpoints(context.Background(), s, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type summary struct {
+		Seq       int
+		Node      string
+		Outputs   []interface{}
+		Decisions Decisions
+	}
+	var got []summary
+	for _, cp := range cps {
+		if cp.ExecID != id || cp.Time.IsZero() {
+			t.Errorf("checkpoint %+v, want execution %s", cp, id)
+		}
+		sm := summary{Seq: cp.Seq, Node: cp.Node, Decisions: cp.Decisions}
+		for _, o := range cp.Outputs {
+			sm.Outputs = append(sm.Outputs, o.Data)
+		}
+		got = append(got, sm)
+	}
+	want := []summary{
+		{1, "w1", []interface{}{"in/w1"}, nil},
+		{2, "d1", []interface{}{"in/w1/a", "in/w1/b"}, Decisions{"j1": 0}},
+		{3, "m1", []interface{}{"in/w1/a+in/w1/b"}, Decisions{"j1": 0}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("checkpoints %+v, want %+v", got, want)
+	}
+}
+
+// 测试检查点保存失败时节点失败，没有设置 WithSnapshotStore 时构建报错
+func TestManager_CheckpointErrors(t *testing.T) {
+	errPut := errors.New("disk full")
+	var id string
+	m, err := newCheckpointManager(&id, WithSnapshotStore(failingStore{Store: store.NewMemory(), err: errPut}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Handle(&rawData{Data: "in"})
+	var ne *NodeError
+	if !errors.Is(err, errPut) || !errors.As(err, &ne) || ne.Node != "w1" || !strings.Contains(err.Error(), "checkpoint not saved") {
+		t.Errorf("err=%v, want w1 failing with the store's error", err)
+	}
+	_, err = newCheckpointManager(&id)
+	var ve *ValidationError
+	if !errors.As(err, &ve) || ve.Code != CodeBadOption || !strings.Contains(err.Error(), "WithSnapshotStore") {
+		t.Errorf("err=%v, want a bad option error", err)
+	}
+}
@@ -0,0 +1,53 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+)
+
+// 执行超过软截止时间时的回调，execID 为执行的标识，currentNode 为正在执行的节点
+type SoftDeadlineFunc func(execID string, elapsed time.Duration, currentNode string)
+
+type softDeadline struct {
+	d        time.Duration
+	onExceed SoftDeadlineFunc
+}
+
+// 单次执行超过d 还没有结束时调用onExceed，用于发现忘记设置截止时间的调用方
+// 只是告警，不会取消执行；每次执行最多调用一次，执行结束时定时器立即取消
+func WithSoftDeadline(d time.Duration, onExceed SoftDeadlineFunc) Option {
+	return func(m *Manager) {
+		m.softDeadline = &softDeadline{d: d, onExceed: onExceed}
+	}
+}
+
+// 执行的看门狗，记录当前正在执行的节点
+type watchdog struct {
+	mu   sync.Mutex
+	node string
+}
+
+// 为本次执行启动软截止时间的定时器，执行结束时需要调用返回的 Timer 的Stop
+func (e *execution) startWatchdog() Timer {
+	m, sd := e.m, e.m.softDeadline
+	w := &watchdog{}
+	e.watch = w
+	id := e.id()
+	start := m.clock.Now()
+	return m.clock.AfterFunc(sd.d, func() {
+		w.mu.Lock()
+		node := w.node
+		w.mu.Unlock()
+		sd.onExceed(id, m.clock.Now().Sub(start), node)
+	})
+}
+
+// 记录正在执行的节点
+func (e *execution) enter(node *Node) {
+	if e.watch == nil {
+		return
+	}
+	e.watch.mu.Lock()
+	e.watch.node = node.nodeName
+	e.watch.mu.Unlock()
+}
@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type softDeadlineCall struct {
+	id      string
+	elapsed time.Duration
+	node    string
+}
+
+// 测试慢的执行触发一次告警并报告正在执行的节点，快的执行不触发且不遗留定时器
+func TestManager_SoftDeadline(t *testing.T) {
+	clock := newFakeClock()
+	var calls []softDeadlineCall
+	m := NewManager(WithClock(clock), WithSoftDeadline(time.Second, func(id string, elapsed time.Duration, node string) {
+		calls = append(calls, softDeadlineCall{id: id, elapsed: elapsed, node: node})
+	}))
+	_ = m.AddWorkerNode("fetch", passWorker)
+	_ = m.AddWorkerNode("score", func(ctx context.Context, in *rawData) (*rawData, error) {
+		if in.Data == "slow" {
+			clock.Advance(1500 * time.Millisecond)
+			clock.Advance(time.Second)
+		}
+		return in, nil
+	})
+	if err := m.BuildPipeline([][]string{
+		{Head, "fetch"},
+		{"fetch", "score"},
+		{"score", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Handle(&rawData{Data: "slow"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0].node != "score" || calls[0].elapsed != 1500*time.Millisecond || calls[0].id == "" {
+		t.Errorf("expected one call for score, got %+v", calls)
+	}
+	if n := clock.pending(); n != 0 {
+		t.Errorf("expected no pending timers, got %d", n)
+	}
+
+	calls = nil
+	for i := 0; i < 10000; i++ {
+		if _, err := m.Handle(&rawData{Data: "fast"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(calls) != 0 {
+		t.Errorf("expected no calls for fast executions, got %d", len(calls))
+	}
+	if n := clock.pending(); n != 0 {
+		t.Errorf("expected no pending timers, got %d", n)
+	}
+}
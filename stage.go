@@ -0,0 +1,113 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 阶段内节点的累计耗时超过 WithStageTimeout 设置的上限
+var ErrStageTimeout = errors.New("stage timeout")
+
+// 将节点划分到名为name 的阶段，用于按阶段统计以及限制耗时
+// 节点必须已经添加，且每个节点最多属于一个阶段
+func (m *Manager) DefineStage(name string, nodes []string) error {
+	if name == "" {
+		return errors.New("stage name is empty")
+	}
+	if _, ok := m.stages[name]; ok {
+		return fmt.Errorf("stage[%s] is already defined", name)
+	}
+	for _, n := range nodes {
+		node, ok := m.nodes[n]
+		if !ok || node.Typ == NodeTypHead || node.Typ == NodeTypTail {
+			return fmt.Errorf("stage[%s] node[%s] cannot be found in nodes", name, n)
+		}
+		if node.stage != "" {
+			return fmt.Errorf("stage[%s] node[%s] already belongs to stage[%s]", name, n, node.stage)
+		}
+	}
+	for _, n := range nodes {
+		m.nodes[n].stage = name
+	}
+	if m.stages == nil {
+		m.stages = make(map[string][]string)
+	}
+	m.stages[name] = append([]string(nil), nodes...)
+	return nil
+}
+
+// 限制一次执行中阶段内所有节点的累计耗时，超过后执行失败并返回ErrStageTimeout
+func WithStageTimeout(stage string, d time.Duration) Option {
+	return func(m *Manager) {
+		if m.stageTimeouts == nil {
+			m.stageTimeouts = make(map[string]time.Duration)
+		}
+		m.stageTimeouts[stage] = d
+	}
+}
+
+// 检查设置了超时的阶段都已经定义
+func (m *Manager) validateStages() error {
+	names := make([]string, 0, len(m.stageTimeouts))
+	for name := range m.stageTimeouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := m.stages[name]; !ok {
+			return fmt.Errorf("stage[%s] has timeout but is not defined", name)
+		}
+	}
+	return nil
+}
+
+// 累加节点所在阶段的耗时
+func (e *execution) chargeStage(node *Node, d time.Duration) error {
+	budget, ok := e.m.stageTimeouts[node.stage]
+	if !ok {
+		return nil
+	}
+	if e.stageUsed == nil {
+		e.stageUsed = make(map[string]time.Duration)
+	}
+	used := e.stageUsed[node.stage] + d
+	e.stageUsed[node.stage] = used
+	if used > budget {
+		return runtimeError(node, ErrStageTimeout, "stage budget exceeded",
+			fmt.Sprintf("stage[%s] used %v, budget %v", node.stage, used, budget))
+	}
+	return nil
+}
+
+// 阶段的汇总统计
+type StageStats struct {
+	// 执行的节点数
+	Nodes    int
+	Duration time.Duration
+	// 阶段内节点在队列中等待的总时间，不计入Duration
+	QueueWait time.Duration
+	Errors    int
+}
+
+// 按阶段汇总执行轨迹，不属于任何阶段的节点不统计
+func (t *Trace) StageStats() map[string]StageStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := make(map[string]StageStats)
+	for _, entry := range t.entries {
+		if entry.Stage == "" {
+			continue
+		}
+		s := stats[entry.Stage]
+		s.Nodes++
+		s.Duration += entry.Duration
+		s.QueueWait += entry.QueueWait
+		if entry.Err != nil {
+			s.Errors++
+		}
+		stats[entry.Stage] = s
+	}
+	return stats
+}
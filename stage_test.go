@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 构建 ingest -> enrich1 -> enrich2 -> publish 的直线流程，enrich 阶段的每个节点让时间前进60ms
+func newStageManager(t *testing.T, clock *fakeClock, opts ...Option) *Manager {
+	m := NewManager(append([]Option{WithClock(clock)}, opts...)...)
+	slow := func(ctx context.Context, in *rawData) (*rawData, error) {
+		clock.Advance(60 * time.Millisecond)
+		return in, nil
+	}
+	_ = m.AddWorkerNode("ingest", passWorker)
+	_ = m.AddWorkerNode("enrich1", slow)
+	_ = m.AddWorkerNode("enrich2", slow)
+	_ = m.AddWorkerNode("publish", passWorker)
+	if err := m.DefineStage("ingest", []string{"ingest"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DefineStage("enrich", []string{"enrich1", "enrich2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.BuildPipeline([][]string{
+		{Head, "ingest"},
+		{"ingest", "enrich1"},
+		{"enrich1", "enrich2"},
+		{"enrich2", "publish"},
+		{"publish", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// 测试阶段内的累计耗时超过上限后执行失败
+func TestManager_StageTimeout(t *testing.T) {
+	m := newStageManager(t, newFakeClock(), WithStageTimeout("enrich", 100*time.Millisecond))
+	trace := &Trace{}
+	_, err := m.HandleContext(context.Background(), &rawData{}, WithTrace(trace))
+	if !errors.Is(err, ErrStageTimeout) {
+		t.Fatalf("expected ErrStageTimeout, got %v", err)
+	}
+	entries := trace.Entries()
+	if last := entries[len(entries)-1]; last.Node != "enrich2" || len(entries) != 3 {
+		t.Errorf("expected execution to stop at enrich2, got %+v", entries)
+	}
+	if info, _ := m.NodeInfo("enrich2"); info.Stage != "enrich" {
+		t.Errorf("unexpected node info %+v", info)
+	}
+}
+
+// 测试按阶段汇总执行轨迹
+func TestManager_StageStats(t *testing.T) {
+	m := newStageManager(t, newFakeClock())
+	trace := &Trace{}
+	if _, err := m.HandleContext(context.Background(), &rawData{}, WithTrace(trace)); err != nil {
+		t.Fatal(err)
+	}
+	stats := trace.StageStats()
+	if s := stats["enrich"]; s.Nodes != 2 || s.Duration != 120*time.Millisecond || s.Errors != 0 {
+		t.Errorf("unexpected enrich stats %+v", s)
+	}
+	if s := stats["ingest"]; s.Nodes != 1 || s.Duration != 0 {
+		t.Errorf("unexpected ingest stats %+v", s)
+	}
+	if len(stats) != 2 {
+		t.Errorf("expected 2 stages, got %v", stats)
+	}
+}
+
+// 测试阶段定义的检查
+func TestManager_DefineStageInvalid(t *testing.T) {
+	m := NewManager(WithStageTimeout("missing", time.Second))
+	_ = m.AddWorkerNode("w1", passWorker)
+	if err := m.DefineStage("a", []string{"w2"}); err == nil {
+		t.Error("expected unknown node error")
+	}
+	if err := m.DefineStage("a", []string{"w1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DefineStage("b", []string{"w1"}); err == nil {
+		t.Error("expected node already in stage error")
+	}
+	if err := m.BuildPipeline([][]string{{Head, "w1"}, {"w1", Tail}}); err == nil {
+		t.Error("expected undefined stage timeout error")
+	}
+}
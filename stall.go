@@ -0,0 +1,240 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 开启 WithStallDetection 并设置 AbortOnStall 时，停滞的执行返回的错误满足 errors.Is(err, ErrStalled)
+var ErrStalled = errors.New("execution stalled")
+
+// 执行停滞时的状态，作为 OutcomeStalled 事件的Err 发给监听者
+// 设置 AbortOnStall 时也是执行返回的错误，Err 为执行被取消后原来的错误
+type StallError struct {
+	ExecID string
+	// 最近一次有节点完成之后经过的时间
+	Idle time.Duration
+	// 正在执行、没有完成的节点
+	Running []string
+	// 已经加入队列、还没有开始执行的节点
+	QueueDepth int
+	Queued     []string
+	// 还在等待输入的合并节点
+	Mergers []MergerWait
+	Err     error
+}
+
+func (e *StallError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "execution[%s] stalled: no node completed for %v", e.ExecID, e.Idle)
+	if len(e.Running) > 0 {
+		fmt.Fprintf(&b, ", running [%s]", strings.Join(e.Running, ", "))
+	}
+	fmt.Fprintf(&b, ", queue depth %d", e.QueueDepth)
+	if len(e.Queued) > 0 {
+		fmt.Fprintf(&b, " [%s]", strings.Join(e.Queued, ", "))
+	}
+	for _, w := range e.Mergers {
+		fmt.Fprintf(&b, ", merger[%s] %d/%d", w.Node, w.Received, w.Expected)
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&b, ": %v", e.Err)
+	}
+	return b.String()
+}
+
+func (e *StallError) Unwrap() error {
+	return e.Err
+}
+
+func (e *StallError) Is(target error) bool {
+	return target == ErrStalled
+}
+
+// WithStallDetection 的可选配置
+type StallOption func(p *stallPolicy)
+
+type stallPolicy struct {
+	d     time.Duration
+	abort bool
+	// 正在执行的节点长时间没有完成也算作停滞
+	running bool
+}
+
+// 发现停滞后取消本次执行的ctx，执行返回 ErrStalled；节点需要响应ctx 的取消才能结束
+func AbortOnStall() StallOption {
+	return func(p *stallPolicy) {
+		p.abort = true
+	}
+}
+
+// 正在执行的节点长时间没有完成时也算作停滞，不论队列中是否还有节点；默认认为是正常的耗时较长的节点
+func StallOnLongRunningNode() StallOption {
+	return func(p *stallPolicy) {
+		p.running = true
+	}
+}
+
+// 执行的存活检查：d 时间内没有任何节点完成、没有节点正在执行，而队列中还有等待执行的节点时
+// （例如卡在获取临界区上），给监听者发送一个 OutcomeStalled 事件，Err 为 *StallError；
+// 分支在同一个goroutine 上依次执行，耗时较长的节点执行时队列中通常还有其他分支，默认不算作停滞，
+// 设置 StallOnLongRunningNode 后才算，此时事件的Node 为正在执行的节点；
+// 开启 WithStageParallelism 时 Running 为所有正在执行的节点，事件的Node 为其中最早开始的节点
+// 有节点完成之后再次停滞会再次发送；时间由 WithClock 的时间来源测量，不开启时没有额外开销
+func WithStallDetection(d time.Duration, opts ...StallOption) Option {
+	p := &stallPolicy{d: d}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return func(m *Manager) {
+		m.stall = p
+	}
+}
+
+// 执行的存活状态，由执行的goroutine 更新，由定时器的goroutine 检查
+type stallWatch struct {
+	mu     sync.Mutex
+	m      *Manager
+	policy *stallPolicy
+	id     string
+	// 最近一次有节点完成的时间
+	progress time.Time
+	// 正在执行的节点以及开始的时间，按开始的顺序排列；并行执行时可能有多个
+	running []stallEntry
+	// 最近一次从队列中取出节点时队列的状态
+	queue    *cancelSnapshot
+	depth    int
+	timer    Timer
+	reported bool
+	stopped  bool
+	// 取消执行的方法，以及取消时的状态
+	cancel  context.CancelFunc
+	aborted *StallError
+}
+
+// 为本次执行启动存活检查，开启 AbortOnStall 时执行的ctx 替换为可以取消的ctx
+func (e *execution) startStallWatch() {
+	m := e.m
+	w := &stallWatch{m: m, policy: m.stall, id: e.id(), progress: m.clock.Now()}
+	if w.policy.abort {
+		e.ctx, w.cancel = context.WithCancel(e.ctx)
+	}
+	e.stall = w
+	w.mu.Lock()
+	w.timer = m.clock.AfterFunc(w.policy.d, w.check)
+	w.mu.Unlock()
+}
+
+// 定时器到期时检查是否停滞，然后按最近一次完成的时间重新设置定时器
+func (w *stallWatch) check() {
+	m := w.m
+	now := m.clock.Now()
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return
+	}
+	idle := now.Sub(w.progress)
+	next := w.policy.d - idle
+	var stalled *StallError
+	if next <= 0 {
+		next = w.policy.d
+		if !w.reported && (len(w.running) == 0 && w.depth > 0 || w.policy.running && len(w.running) > 0) {
+			w.reported = true
+			stalled = w.report(idle)
+		}
+	}
+	// 事件的Node 为执行时间最长的节点
+	var node *Node
+	var began time.Time
+	if len(w.running) > 0 {
+		node, began = w.running[0].node, w.running[0].began
+	}
+	w.timer = m.clock.AfterFunc(next, w.check)
+	if stalled != nil && w.cancel != nil {
+		w.aborted = stalled
+	}
+	w.mu.Unlock()
+	if stalled == nil {
+		return
+	}
+	if m.listener != nil {
+		ev := NodeEvent{Outcome: OutcomeStalled, Err: stalled}
+		if node != nil {
+			ev.Node, ev.Info, ev.Stage, ev.Typ = node.nodeName, node.info(), node.stage, node.Typ
+			ev.Duration = now.Sub(began)
+		}
+		m.listener(ev)
+	}
+	if w.cancel != nil {
+		w.cancel()
+	}
+}
+
+func (w *stallWatch) report(idle time.Duration) *StallError {
+	s := &StallError{ExecID: w.id, Idle: idle, QueueDepth: w.depth}
+	for _, r := range w.running {
+		s.Running = append(s.Running, r.node.nodeName)
+	}
+	if w.queue != nil {
+		s.Queued, s.Mergers = w.queue.queued, w.queue.mergers
+	}
+	return s
+}
+
+type stallEntry struct {
+	node  *Node
+	began time.Time
+}
+
+// 记录开始执行的节点
+func (w *stallWatch) enter(node *Node, now time.Time) {
+	w.mu.Lock()
+	w.running = append(w.running, stallEntry{node: node, began: now})
+	w.mu.Unlock()
+}
+
+// 记录节点完成
+func (w *stallWatch) done(node *Node, now time.Time) {
+	w.mu.Lock()
+	for i, r := range w.running {
+		if r.node == node {
+			w.running = append(w.running[:i], w.running[i+1:]...)
+			break
+		}
+	}
+	w.progress = now
+	w.reported = false
+	w.mu.Unlock()
+}
+
+// 从队列中取出节点后记录队列的状态
+func (w *stallWatch) observe(queue []*nodeDataWrapper, mergers map[*Node]*mergerState) {
+	s := queueState(queue, mergers)
+	w.mu.Lock()
+	w.queue, w.depth = s, len(queue)
+	w.mu.Unlock()
+}
+
+// 停止存活检查，执行因为停滞被取消时将错误转换为 StallError
+func (w *stallWatch) finish(err error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.stopped {
+		w.stopped = true
+		w.timer.Stop()
+		if w.cancel != nil {
+			w.cancel()
+		}
+	}
+	if err == nil || w.aborted == nil {
+		return err
+	}
+	s := *w.aborted
+	s.Err = err
+	return &s
+}
@@ -0,0 +1,290 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 已经被占用的信号量，acquire 一直等到 release 或者ctx 结束
+type fakeSemaphore struct {
+	held    chan struct{}
+	waiting chan struct{}
+}
+
+func newFakeSemaphore() *fakeSemaphore {
+	s := &fakeSemaphore{held: make(chan struct{}, 1), waiting: make(chan struct{}, 1)}
+	s.held <- struct{}{}
+	return s
+}
+
+func (s *fakeSemaphore) acquire(ctx context.Context) error {
+	s.waiting <- struct{}{}
+	select {
+	case s.held <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *fakeSemaphore) release() {
+	<-s.held
+}
+
+// 记录 OutcomeStalled 事件的监听者
+type stallEvents struct {
+	mu     sync.Mutex
+	events []NodeEvent
+}
+
+func (r *stallEvents) listen(ev NodeEvent) {
+	if ev.Outcome != OutcomeStalled {
+		return
+	}
+	r.mu.Lock()
+	r.events = append(r.events, ev)
+	r.mu.Unlock()
+}
+
+func (r *stallEvents) get() []NodeEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]NodeEvent(nil), r.events...)
+}
+
+// d1 分出fast、stuck、later 三个分支后在m1 合并，stuck 在信号量上等待
+// d1 -> fast, stuck, later -> m1，stuck 卡在信号量上：inSection 为true 时卡在获取临界区上（没有节点在执行），
+// 否则卡在节点的处理方法中
+func newStallManager(t *testing.T, sem *fakeSemaphore, inSection bool, opts ...Option) *Manager {
+	m := NewManager(opts...)
+	_ = m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{{Data: 1}, {Data: 2}, {Data: 3}}, nil
+	})
+	_ = m.AddWorkerNode("fast", passWorker)
+	_ = m.AddWorkerNode("stuck", func(ctx context.Context, in *rawData) (*rawData, error) {
+		if inSection {
+			return in, nil
+		}
+		if err := sem.acquire(ctx); err != nil {
+			return nil, err
+		}
+		return in, nil
+	})
+	if inSection {
+		m.DefineCriticalSection("lock", "stuck", "stuck", func(ctx context.Context, in *rawData) (func(error), error) {
+			if err := sem.acquire(ctx); err != nil {
+				return nil, err
+			}
+			return func(error) {}, nil
+		})
+	}
+	_ = m.AddWorkerNode("later", passWorker)
+	_ = m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		return &rawData{Data: len(in)}, nil
+	})
+	if err := m.BuildPipeline([][]string{
+		{Head, "d1"},
+		{"d1", "fast"},
+		{"d1", "stuck"},
+		{"d1", "later"},
+		{"fast", "m1"},
+		{"stuck", "m1"},
+		{"later", "m1"},
+		{"m1", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func handleAsync(m *Manager) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Handle(&rawData{})
+		done <- err
+	}()
+	return done
+}
+
+// 测试没有节点在执行、队列中还有节点而没有节点完成时发出停滞事件，事件中有队列和合并节点的状态
+func TestManager_StallDetection(t *testing.T) {
+	clock := newFakeClock()
+	sem := newFakeSemaphore()
+	var rec stallEvents
+	m := newStallManager(t, sem, true, WithClock(clock), WithListener(rec.listen), WithStallDetection(time.Second))
+	done := handleAsync(m)
+	<-sem.waiting
+
+	clock.Advance(500 * time.Millisecond)
+	if evs := rec.get(); len(evs) != 0 {
+		t.Fatalf("stalled before the threshold: %v", evs)
+	}
+	clock.Advance(500 * time.Millisecond)
+	evs := rec.get()
+	if len(evs) != 1 {
+		t.Fatalf("got %d stall events, want 1", len(evs))
+	}
+	ev := evs[0]
+	if ev.Node != "" {
+		t.Errorf("node=%s, want no running node", ev.Node)
+	}
+	var se *StallError
+	if !errors.As(ev.Err, &se) {
+		t.Fatalf("err=%v, want StallError", ev.Err)
+	}
+	if se.Idle != time.Second || se.QueueDepth != 2 || strings.Join(se.Queued, ",") != "later" || len(se.Running) != 0 {
+		t.Errorf("idle=%v depth=%d queued=%v running=%v", se.Idle, se.QueueDepth, se.Queued, se.Running)
+	}
+	if len(se.Mergers) != 1 || se.Mergers[0] != (MergerWait{Node: "m1", Received: 1, Expected: 3}) {
+		t.Errorf("mergers=%v, want m1 1/3", se.Mergers)
+	}
+
+	// 没有新的进展时不会重复发出
+	clock.Advance(3 * time.Second)
+	if n := len(rec.get()); n != 1 {
+		t.Errorf("got %d stall events, want 1", n)
+	}
+	// 不取消执行，释放信号量后正常完成
+	sem.release()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 测试 AbortOnStall 时取消执行并返回 ErrStalled
+func TestManager_StallAbort(t *testing.T) {
+	clock := newFakeClock()
+	sem := newFakeSemaphore()
+	m := newStallManager(t, sem, true, WithClock(clock), WithStallDetection(time.Second, AbortOnStall()))
+	done := handleAsync(m)
+	<-sem.waiting
+	clock.Advance(time.Second)
+	err := <-done
+	if !errors.Is(err, ErrStalled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("err=%v, want ErrStalled", err)
+	}
+	if !strings.Contains(err.Error(), "merger[m1] 1/3") {
+		t.Errorf("err=%v, want merger state", err)
+	}
+}
+
+// 测试分支中耗时较长的节点执行时，即使队列中还有其他分支也默认不算作停滞，
+// 设置 StallOnLongRunningNode 后才发出事件，事件中有正在执行的节点以及队列的状态
+func TestManager_StallSlowBranch(t *testing.T) {
+	for _, running := range []bool{false, true} {
+		clock := newFakeClock()
+		sem := newFakeSemaphore()
+		var rec stallEvents
+		opts := []StallOption{AbortOnStall()}
+		if running {
+			opts = append(opts, StallOnLongRunningNode())
+		}
+		m := newStallManager(t, sem, false, WithClock(clock), WithListener(rec.listen), WithStallDetection(10*time.Millisecond, opts...))
+		done := handleAsync(m)
+		<-sem.waiting
+		clock.Advance(10 * time.Millisecond)
+		if !running {
+			clock.Advance(40 * time.Millisecond)
+			if evs := rec.get(); len(evs) != 0 {
+				t.Errorf("slow branch reported as stalled: %v", evs[0].Err)
+			}
+			sem.release()
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		evs := rec.get()
+		if len(evs) != 1 || evs[0].Node != "stuck" || evs[0].Duration != 10*time.Millisecond {
+			t.Fatalf("events=%v, want one for stuck after 10ms", evs)
+		}
+		if err := evs[0].Err.Error(); !strings.Contains(err, "running [stuck], queue depth 2 [later]") {
+			t.Errorf("error=%q", err)
+		}
+		if err := <-done; !errors.Is(err, ErrStalled) {
+			t.Errorf("err=%v, want ErrStalled", err)
+		}
+	}
+}
+
+// 测试只有一个节点在执行时默认不算作停滞，设置 StallOnLongRunningNode 后才发出事件
+func TestManager_StallLongRunningNode(t *testing.T) {
+	for _, running := range []bool{false, true} {
+		clock := newFakeClock()
+		sem := newFakeSemaphore()
+		var rec stallEvents
+		opts := []StallOption{AbortOnStall()}
+		if running {
+			opts = append(opts, StallOnLongRunningNode())
+		}
+		m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+			if err := sem.acquire(ctx); err != nil {
+				return nil, err
+			}
+			return in, nil
+		}, WithClock(clock), WithListener(rec.listen), WithStallDetection(time.Second, opts...))
+		done := handleAsync(m)
+		<-sem.waiting
+		clock.Advance(5 * time.Second)
+		if !running {
+			if n := len(rec.get()); n != 0 {
+				t.Errorf("got %d stall events for a long running node", n)
+			}
+			sem.release()
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if evs := rec.get(); len(evs) != 1 || evs[0].Node != "w1" {
+			t.Errorf("events=%v, want one for w1", evs)
+		}
+		if err := <-done; !errors.Is(err, ErrStalled) {
+			t.Errorf("err=%v, want ErrStalled", err)
+		}
+	}
+}
+
+// 测试并行执行时停滞事件列出所有正在执行的节点，AbortOnStall 取消执行
+func TestManager_StallDetectionParallel(t *testing.T) {
+	clock := newFakeClock()
+	sem := newFakeSemaphore()
+	var rec stallEvents
+	var finished sync.WaitGroup
+	finished.Add(2)
+	listen := func(ev NodeEvent) {
+		if ev.Outcome == OutcomeExecuted && ev.Err == nil && (ev.Node == "fast" || ev.Node == "later") {
+			finished.Done()
+		}
+		rec.listen(ev)
+	}
+	m := newStallManager(t, sem, false, WithStageParallelism(), WithClock(clock), WithListener(listen),
+		WithStallDetection(time.Second, StallOnLongRunningNode(), AbortOnStall()))
+	m.parallelism = 3
+	done := handleAsync(m)
+	<-sem.waiting
+	finished.Wait()
+
+	clock.Advance(time.Second)
+	evs := rec.get()
+	if len(evs) != 1 {
+		t.Fatalf("got %d stall events, want 1", len(evs))
+	}
+	if evs[0].Node != "stuck" {
+		t.Errorf("node=%s, want stuck", evs[0].Node)
+	}
+	var se *StallError
+	if !errors.As(evs[0].Err, &se) {
+		t.Fatalf("err=%v, want StallError", evs[0].Err)
+	}
+	if strings.Join(se.Running, ",") != "stuck" {
+		t.Errorf("running=%v, want [stuck]", se.Running)
+	}
+	if err := <-done; !errors.Is(err, ErrStalled) {
+		t.Errorf("err=%v, want ErrStalled", err)
+	}
+}
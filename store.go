@@ -0,0 +1,173 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// 持久化存储，key 用/ 分隔层级
+// Get 在key 不存在时返回的错误需要满足 errors.Is(err, os.ErrNotExist)
+// 子包 store 提供了内存和文件系统的实现
+type Store interface {
+	Put(ctx context.Context, key string, value []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	// 返回以prefix 开头的所有key，按字典序排列
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// 死信在存储中的key 前缀
+const deadLetterPrefix = "dlq/"
+
+// 执行失败的数据，以JSON 保存在死信存储的 dlq/<ExecID> 下
+// Input 是失败时的输入对象，节点原地修改过输入时保存的是修改后的数据
+// 数据经过JSON 编解码，Data 中的数字重新读出后为float64
+type DeadLetter struct {
+	ExecID string    `json:"exec_id"`
+	Node   string    `json:"node,omitempty"`
+	Err    string    `json:"error"`
+	Time   time.Time `json:"time"`
+	Input  *rawData  `json:"input"`
+	// 错误上附加的元数据，见 WithErrorMetadata
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// 这条数据第几次执行失败，从1 开始，每次 Requeue 再次失败时加1
+	Attempt int `json:"attempt,omitempty"`
+}
+
+// 执行失败（包括错误处理子图也失败）时把输入保存到s 中，之后可以用 Requeue 重新执行
+// 设置了 WithPayloadRedactor 时保存的是脱敏后的数据，需要重新执行的字段应该加密而不是删除
+func WithDeadLetterStore(s Store) Option {
+	return func(m *Manager) {
+		m.deadLetters = s
+	}
+}
+
+// 执行的标识，第一次使用时生成，重启之后也不会重复
+func (e *execution) id() string {
+	if e.execID == "" {
+		if e.seq == 0 {
+			e.seq = atomic.AddUint64(&e.m.execSeq, 1)
+		}
+		e.execID = formatExecID(e.start, e.seq)
+	}
+	return e.execID
+}
+
+// 由执行开始的时间和序号生成执行的标识
+func formatExecID(start time.Time, seq uint64) string {
+	return strconv.FormatInt(start.UnixNano(), 10) + "-" + strconv.FormatUint(seq, 10)
+}
+
+// 保存死信，保存失败时在原来的错误上附加说明
+func (e *execution) saveDeadLetter(in *rawData, err error) error {
+	dl := DeadLetter{ExecID: e.id(), Input: e.m.redact(in), Attempt: 1}
+	if e.dataAttempt > 1 {
+		dl.Attempt = e.dataAttempt
+	}
+	dl.setFailure(e.m.redactError(err, in, nil), e.m.clock.Now())
+	if merr := putDeadLetter(e.ctx, e.m.deadLetters, dl); merr != nil {
+		return fmt.Errorf("%w (dead letter not saved: %v)", err, merr)
+	}
+	return err
+}
+
+// 记录失败的错误、节点和时间
+func (dl *DeadLetter) setFailure(err error, now time.Time) {
+	dl.Err, dl.Time, dl.Metadata, dl.Node = err.Error(), now, ErrorMetadata(err), ""
+	var nodeErr *NodeError
+	if errors.As(err, &nodeErr) {
+		dl.Node = nodeErr.Node
+	}
+}
+
+func putDeadLetter(ctx context.Context, s Store, dl DeadLetter) error {
+	data, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, deadLetterPrefix+dl.ExecID, data)
+}
+
+// 读出s 中保存的全部死信
+func DeadLetters(ctx context.Context, s Store) ([]DeadLetter, error) {
+	keys, err := s.List(ctx, deadLetterPrefix)
+	if err != nil {
+		return nil, err
+	}
+	dls := make([]DeadLetter, 0, len(keys))
+	for _, key := range keys {
+		data, err := s.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		var dl DeadLetter
+		if err = json.Unmarshal(data, &dl); err != nil {
+			return nil, fmt.Errorf("dead letter[%s]: %w", path.Base(key), err)
+		}
+		dls = append(dls, dl)
+	}
+	return dls, nil
+}
+
+// 用s 中保存的死信重新执行，成功的死信从s 中删除；再次失败的不会产生新的死信，
+// 原来的记录中更新错误、失败的节点和执行次数，保留原来的ExecID 和输入
+// 重新执行使用 WithParentExecution(ExecID) 和 WithAttempt(Attempt+1)，执行摘要、轨迹和监听事件中可以找到原来的执行
+// 返回成功重新执行的条数，只有读写存储失败时返回错误
+func (m *Manager) Requeue(ctx context.Context, s Store) (int, error) {
+	dls, err := DeadLetters(ctx, s)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, dl := range dls {
+		attempt := dl.Attempt + 1
+		if attempt < 2 {
+			attempt = 2
+		}
+		_, err := m.HandleContext(ctx, dl.Input, withoutDeadLetter(), WithParentExecution(dl.ExecID), WithAttempt(attempt))
+		if err != nil {
+			if ctx.Err() != nil {
+				return n, ctx.Err()
+			}
+			dl.Attempt = attempt
+			dl.setFailure(err, m.clock.Now())
+			if err := putDeadLetter(ctx, s, dl); err != nil {
+				return n, err
+			}
+			continue
+		}
+		if err := s.Delete(ctx, deadLetterPrefix+dl.ExecID); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// 本次执行是id 失败之后的重新执行，id 出现在执行摘要（ParentID）、轨迹（Trace.Parent）和监听事件中
+// Requeue 会自动设置，从其他地方重新执行失败的数据时可以使用
+func WithParentExecution(id string) CallOption {
+	return func(o *callOptions) {
+		o.parent = id
+	}
+}
+
+// 本次执行是这条数据的第n 次执行，和 WithParentExecution 一起使用；保存死信时记录在 DeadLetter.Attempt 中
+func WithAttempt(n int) CallOption {
+	return func(o *callOptions) {
+		o.attempt = n
+	}
+}
+
+// 重新执行死信时不再保存新的死信
+func withoutDeadLetter() CallOption {
+	return func(o *callOptions) {
+		o.noDeadLetter = true
+	}
+}
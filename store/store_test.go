@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// 测试两种实现的基本读写
+func TestStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file, err := NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for name, s := range map[string]interface {
+		Put(ctx context.Context, key string, value []byte) error
+		Get(ctx context.Context, key string) ([]byte, error)
+		List(ctx context.Context, prefix string) ([]string, error)
+		Delete(ctx context.Context, key string) error
+	}{"memory": NewMemory(), "file": file} {
+		for _, key := range []string{"dlq/b", "dlq/a", "snap/a"} {
+			if err := s.Put(ctx, key, []byte(key)); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+		if v, err := s.Get(ctx, "dlq/a"); err != nil || string(v) != "dlq/a" {
+			t.Errorf("%s: get=%q err=%v", name, v, err)
+		}
+		if keys, err := s.List(ctx, "dlq/"); err != nil || !reflect.DeepEqual(keys, []string{"dlq/a", "dlq/b"}) {
+			t.Errorf("%s: list=%v err=%v", name, keys, err)
+		}
+		if err := s.Delete(ctx, "dlq/a"); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if _, err := s.Get(ctx, "dlq/a"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s: err=%v, want os.ErrNotExist", name, err)
+		}
+	}
+	if err := file.Put(ctx, "../escape", nil); err == nil {
+		t.Errorf("key outside the directory should be rejected")
+	}
+}
@@ -0,0 +1,148 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/caigoumiao/pipeline/store"
+)
+
+var _ Store = (*store.File)(nil)
+var _ Store = (*store.Memory)(nil)
+
+// 测试死信在重启（新的Manager，同一个文件存储）之后仍然存在，并且可以重新执行
+func TestManager_DeadLetterStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dlq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	errDown := errors.New("downstream unavailable")
+	worker := func(down bool) WorkerFunc {
+		return func(ctx context.Context, in *rawData) (*rawData, error) {
+			if down {
+				return nil, errDown
+			}
+			return &rawData{Data: in.Data.(string) + "!"}, nil
+		}
+	}
+	ctx := context.Background()
+
+	s, err := store.NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newSingleWorkerManager(t, worker(true), WithDeadLetterStore(s))
+	for _, data := range []string{"a", "b"} {
+		if _, err := m.HandleContext(ctx, &rawData{Data: data}); !errors.Is(err, errDown) {
+			t.Fatalf("err=%v, want errDown", err)
+		}
+	}
+
+	// 模拟重启
+	s, err = store.NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dls, err := DeadLetters(ctx, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dls) != 2 || dls[0].Node != "w1" || dls[0].Err != "downstream unavailable" || dls[0].Input.Data != "a" {
+		t.Fatalf("unexpected dead letters %+v", dls)
+	}
+	// 下游仍然不可用时，死信保留且不会重复
+	m = newSingleWorkerManager(t, worker(true), WithDeadLetterStore(s))
+	if n, err := m.Requeue(ctx, s); n != 0 || err != nil {
+		t.Errorf("requeued=%d err=%v", n, err)
+	}
+	if dls, _ = DeadLetters(ctx, s); len(dls) != 2 {
+		t.Errorf("%d dead letters, want 2", len(dls))
+	}
+	m = newSingleWorkerManager(t, worker(false), WithDeadLetterStore(s))
+	if n, err := m.Requeue(ctx, s); n != 2 || err != nil {
+		t.Errorf("requeued=%d err=%v", n, err)
+	}
+	if dls, _ = DeadLetters(ctx, s); len(dls) != 0 {
+		t.Errorf("%d dead letters left, want 0", len(dls))
+	}
+}
+
+// 测试重新执行的摘要、轨迹和监听事件中带有原来失败的执行以及执行次数，再次失败时死信中的次数增加
+func TestManager_RequeueLineage(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	down := true
+	var events []NodeEvent
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		if down {
+			return nil, errors.New("downstream unavailable")
+		}
+		return &rawData{Data: in.Data}, nil
+	}, WithDeadLetterStore(s), WithExecutionHistory(8), WithListener(func(ev NodeEvent) {
+		events = append(events, ev)
+	}))
+	if _, err := m.HandleContext(ctx, &rawData{Data: "a"}); err == nil {
+		t.Fatal("want the first execution to fail")
+	}
+	first := m.RecentExecutions()[0]
+	dls, err := DeadLetters(ctx, s)
+	if err != nil || len(dls) != 1 || dls[0].ExecID != first.ID || dls[0].Attempt != 1 || dls[0].Node != "w1" {
+		t.Fatalf("dead letters %+v err=%v", dls, err)
+	}
+
+	if n, err := m.Requeue(ctx, s); n != 0 || err != nil {
+		t.Fatalf("requeued=%d err=%v", n, err)
+	}
+	second := m.RecentExecutions()[0]
+	if second.ParentID != first.ID || second.Attempt != 2 || first.ParentID != "" || first.Attempt != 0 {
+		t.Errorf("first %+v second %+v, want the second to reference the first as attempt 2", first, second)
+	}
+	if dls, _ = DeadLetters(ctx, s); len(dls) != 1 || dls[0].ExecID != first.ID || dls[0].Attempt != 2 {
+		t.Errorf("dead letters %+v, want the original record with attempt 2", dls)
+	}
+
+	down = false
+	events = nil
+	trace := &Trace{}
+	if _, err := m.HandleContext(ctx, dls[0].Input, WithParentExecution(first.ID), WithAttempt(3), WithTrace(trace)); err != nil {
+		t.Fatal(err)
+	}
+	if id, attempt := trace.Parent(); id != first.ID || attempt != 3 {
+		t.Errorf("trace parent %s attempt %d", id, attempt)
+	}
+	if len(events) != 1 || events[0].ParentExecution != first.ID || events[0].Attempt != 3 {
+		t.Errorf("events %+v, want lineage on node events", events)
+	}
+	if n, err := m.Requeue(ctx, s); n != 1 || err != nil {
+		t.Errorf("requeued=%d err=%v", n, err)
+	}
+	if last := m.RecentExecutions()[0]; last.ParentID != first.ID || last.Attempt != 3 {
+		t.Errorf("last %+v, want attempt 3 of %s", last, first.ID)
+	}
+}
+
+// 测试死信保存的是还没有被回收的原始输入，重新执行时使用原来的数据
+func TestManager_DeadLetterReleasable(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	fail := true
+	m := newReleaseFailManager(t, &fail, WithDeadLetterStore(s))
+	if err := m.BuildPipeline([][]string{{Head, "w1"}, {"w1", "w2"}, {"w2", Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	in := &reusedPayload{Val: "in"}
+	if _, err := m.Handle(&rawData{Data: in}); !errors.Is(err, errBranch) {
+		t.Fatalf("err=%v, want errBranch", err)
+	}
+	dls, err := DeadLetters(ctx, s)
+	if err != nil || len(dls) != 1 {
+		t.Fatalf("dead letters %+v err=%v", dls, err)
+	}
+	if data, _ := dls[0].Input.Data.(map[string]interface{}); data["Val"] != "in" || data["Released"] != float64(0) || in.Released != 0 {
+		t.Errorf("stored input %v, input released %d times, want the original payload", dls[0].Input.Data, in.Released)
+	}
+}
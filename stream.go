@@ -0,0 +1,324 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// 流式执行的可选配置
+type StreamOption func(o *streamOptions)
+
+type streamOptions struct {
+	concurrency int
+	buffer      int
+	priority    func(*rawData) int
+	guard       int
+	stats       *StreamStats
+	saturation  *saturationNotify
+	// 按读入的顺序输出，以及失败的数据是否以占位的输出代替错误
+	ordered      bool
+	placeholders bool
+}
+
+// 默认最多预先读入的数据条数
+const defaultStreamBuffer = 64
+
+// 设置流式执行同时处理的数据条数，默认为1
+func WithStreamConcurrency(n int) StreamOption {
+	return func(o *streamOptions) {
+		o.concurrency = n
+	}
+}
+
+// 设置最多预先从输入中读出、等待执行的数据条数，默认为64
+func WithStreamBuffer(n int) StreamOption {
+	return func(o *streamOptions) {
+		o.buffer = n
+	}
+}
+
+// 按数据的优先级调度：读出的数据按f 返回的优先级分组，空闲时总是先执行优先级最高的数据
+func WithPriorityFunc(f func(*rawData) int) StreamOption {
+	return func(o *streamOptions) {
+		o.priority = f
+	}
+}
+
+// 防止低优先级的数据饿死：连续执行n 条高优先级数据后，如果有更低优先级的数据在等待，
+// 执行一条等待的数据中优先级最低的；0 表示不限制
+func WithStarvationGuard(n int) StreamOption {
+	return func(o *streamOptions) {
+		o.guard = n
+	}
+}
+
+// 将流式执行的统计记录到s 中
+func WithStreamStats(s *StreamStats) StreamOption {
+	return func(o *streamOptions) {
+		o.stats = s
+	}
+}
+
+// 饱和度（见 StreamStats.Saturation）升到high 以上时调用f，之后降到low 以下时再调用f，
+// 中间的波动不会重复通知；f 按顺序调用，参数为当时的饱和度
+func WithSaturationChange(high, low float64, f func(level float64)) StreamOption {
+	return func(o *streamOptions) {
+		o.saturation = &saturationNotify{high: high, low: low, f: f}
+	}
+}
+
+// 按数据读入的顺序输出：执行完的数据等待序号更小的数据都输出之后才输出，慢的数据会拖慢后面的数据
+// 等待输出的数据最多为并发数（WithStreamConcurrency）条；开启后 WithPriorityFunc 不生效
+// 失败的数据在自己的位置上输出到错误channel，见 WithFailurePlaceholders
+func WithOrderedOutput() StreamOption {
+	return func(o *streamOptions) {
+		o.ordered = true
+	}
+}
+
+// 失败的数据不输出到错误channel，而是在输出channel 中输出一个Out 为空、带有Err 的占位输出，
+// 和 WithOrderedOutput 一起使用时只读取输出channel 就可以得到连续的序号
+func WithFailurePlaceholders() StreamOption {
+	return func(o *streamOptions) {
+		o.placeholders = true
+	}
+}
+
+// 流式执行中单条数据的输出，Seq 为数据从输入中读出的序号，从0 开始
+// 开启 WithFailurePlaceholders 时失败的数据Out 为空，Err 为失败的原因
+type StreamOutput struct {
+	Seq uint64
+	Out *rawData
+	Err *StreamError
+}
+
+// 流式执行中单条数据的错误
+// Seq 与 StreamOutput 的序号相同，Node 为出错的节点，不是节点报错时为空
+// 设置了 WithPayloadRedactor 时 Input 为脱敏后的数据
+type StreamError struct {
+	Seq   uint64
+	Input *rawData
+	Node  string
+	Err   error
+}
+
+func (e StreamError) Error() string {
+	if e.Node == "" {
+		return fmt.Sprintf("stream item[%d]: %v", e.Seq, e.Err)
+	}
+	return fmt.Sprintf("stream item[%d] node[%s]: %v", e.Seq, e.Node, e.Err)
+}
+
+func (e StreamError) Unwrap() error {
+	return e.Err
+}
+
+type streamItem struct {
+	seq      uint64
+	in       *rawData
+	priority int
+}
+
+// 流式执行流水线：从in 中逐条读取数据执行，成功的结果写入第一个channel，失败写入第二个channel
+// in 关闭或ctx 结束后，处理完已读出的数据再关闭两个channel；调用方需要同时读取两个channel 直到关闭
+// 设置了 WithRunner 时每条数据在 Runner 上执行，Runner 拒绝执行的数据输出到错误channel
+func (m *Manager) HandleStream(ctx context.Context, in <-chan *rawData, opts ...StreamOption) (<-chan StreamOutput, <-chan StreamError) {
+	o := streamOptions{concurrency: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.concurrency <= 0 {
+		o.concurrency = 1
+	}
+	if o.buffer <= 0 {
+		o.buffer = defaultStreamBuffer
+	}
+	if o.saturation != nil && o.stats == nil {
+		o.stats = &StreamStats{}
+	}
+	if o.stats != nil {
+		o.stats.notify = o.saturation
+		atomic.StoreInt64(&o.stats.capacity, int64(o.concurrency+o.buffer))
+	}
+	var order *streamOrder
+	if o.ordered {
+		// 按优先级调度会打乱序号，等待输出的数据可能永远等不到更小的序号
+		o.priority = nil
+		order = newStreamOrder()
+	}
+	outs := make(chan StreamOutput)
+	errs := make(chan StreamError)
+	queue := newStreamQueue(o.buffer, o.guard, o.stats)
+
+	go func() {
+		defer queue.close()
+		var seq uint64
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case data, ok := <-in:
+				if !ok {
+					return
+				}
+				item := streamItem{seq: seq, in: data}
+				if o.priority != nil {
+					item.priority = o.priority(data)
+				}
+				if !queue.push(item) {
+					return
+				}
+				seq++
+			}
+		}
+	}()
+	go func() {
+		// ctx 结束时唤醒阻塞在队列上的读入和执行
+		select {
+		case <-ctx.Done():
+			queue.close()
+			order.cancel()
+		case <-queue.done:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	if m.runner != nil {
+		// 每条数据直接交给 Runner 执行，由一个goroutine 按空闲的槽位从队列中取出数据
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g := m.newRunnerGroup(o.concurrency)
+			defer g.wait()
+			// 执行中的数据总会结束并释放槽位，已经读入队列的数据在ctx 结束后仍然需要取出
+			for g.acquire(context.Background()) == nil {
+				item, ok := queue.pop()
+				if !ok {
+					g.release()
+					return
+				}
+				if err := g.run(ctx, func() {
+					m.handleStreamItem(ctx, item, outs, errs, &o, order)
+					o.stats.processed(item.priority)
+				}); err != nil {
+					m.emitStreamItem(ctx, item, nil, err, outs, errs, &o, order)
+					o.stats.processed(item.priority)
+				}
+			}
+		}()
+	} else {
+		for i := 0; i < o.concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					item, ok := queue.pop()
+					if !ok {
+						return
+					}
+					m.handleStreamItem(ctx, item, outs, errs, &o, order)
+					o.stats.processed(item.priority)
+				}
+			}()
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(outs)
+		close(errs)
+	}()
+	return outs, errs
+}
+
+func (m *Manager) handleStreamItem(ctx context.Context, item streamItem, outs chan<- StreamOutput, errs chan<- StreamError,
+	o *streamOptions, order *streamOrder) {
+	out, err := m.handleAdaptive(ctx, item.in)
+	m.emitStreamItem(ctx, item, out, err, outs, errs, o, order)
+}
+
+// 输出一条数据的结果，开启 WithOrderedOutput 时等待轮到该数据
+func (m *Manager) emitStreamItem(ctx context.Context, item streamItem, out *rawData, err error, outs chan<- StreamOutput,
+	errs chan<- StreamError, o *streamOptions, order *streamOrder) {
+	if order != nil {
+		if !order.wait(item.seq) {
+			return
+		}
+		defer order.done()
+	}
+	if err == nil {
+		select {
+		case outs <- StreamOutput{Seq: item.seq, Out: out}:
+		case <-ctx.Done():
+		}
+		return
+	}
+	se := m.streamError(item, err)
+	if o.placeholders {
+		select {
+		case outs <- StreamOutput{Seq: item.seq, Err: &se}:
+		case <-ctx.Done():
+		}
+		return
+	}
+	select {
+	case errs <- se:
+	case <-ctx.Done():
+	}
+}
+
+// 单条数据执行失败的错误，节点的错误拆分为Node 和Err
+func (m *Manager) streamError(item streamItem, err error) StreamError {
+	se := StreamError{Seq: item.seq, Input: m.redact(item.in), Err: err}
+	var nodeErr *NodeError
+	if errors.As(err, &nodeErr) {
+		se.Node = nodeErr.Node
+		se.Err = nodeErr.Err
+	}
+	se.Err = m.redactError(se.Err, item.in, nil)
+	return se
+}
+
+// 按序号依次输出，等待输出的数据阻塞在 wait 中，最多为并发数条
+type streamOrder struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	next      uint64
+	cancelled bool
+}
+
+func newStreamOrder() *streamOrder {
+	o := &streamOrder{}
+	o.cond = sync.NewCond(&o.mu)
+	return o
+}
+
+// 等待轮到序号为seq 的数据输出，ctx 结束时返回false
+func (o *streamOrder) wait(seq uint64) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for o.next != seq && !o.cancelled {
+		o.cond.Wait()
+	}
+	return !o.cancelled
+}
+
+// 当前数据输出完成，轮到下一个序号
+func (o *streamOrder) done() {
+	o.mu.Lock()
+	o.next++
+	o.mu.Unlock()
+	o.cond.Broadcast()
+}
+
+func (o *streamOrder) cancel() {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	o.cancelled = true
+	o.mu.Unlock()
+	o.cond.Broadcast()
+}
@@ -0,0 +1,203 @@
+package pipeline
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// 流式执行中等待执行的数据，按优先级分组，同一优先级内先进先出
+type streamQueue struct {
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	buckets  map[int][]streamItem
+	// 非空分组的优先级，从高到低
+	priorities []int
+	size       int
+	limit      int
+	closed     bool
+	// 关闭后被close
+	done chan struct{}
+	// 饥饿保护：连续执行guard 条高优先级数据后让出一次
+	guard  int
+	streak int
+	stats  *StreamStats
+}
+
+func newStreamQueue(limit, guard int, stats *StreamStats) *streamQueue {
+	q := &streamQueue{
+		buckets: make(map[int][]streamItem),
+		limit:   limit,
+		done:    make(chan struct{}),
+		guard:   guard,
+		stats:   stats,
+	}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+	return q
+}
+
+// 队列满时阻塞，队列已关闭时返回false
+func (q *streamQueue) push(item streamItem) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.size >= q.limit && !q.closed {
+		q.notFull.Wait()
+	}
+	if q.closed {
+		return false
+	}
+	p := item.priority
+	if len(q.buckets[p]) == 0 {
+		i := sort.Search(len(q.priorities), func(i int) bool { return q.priorities[i] < p })
+		q.priorities = append(q.priorities, 0)
+		copy(q.priorities[i+1:], q.priorities[i:])
+		q.priorities[i] = p
+	}
+	q.buckets[p] = append(q.buckets[p], item)
+	q.size++
+	q.stats.setDepth(p, len(q.buckets[p]))
+	q.stats.addLoad(1)
+	q.notEmpty.Signal()
+	return true
+}
+
+// 队列为空时阻塞，队列关闭且为空时返回false
+func (q *streamQueue) pop() (streamItem, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.size == 0 && !q.closed {
+		q.notEmpty.Wait()
+	}
+	if q.size == 0 {
+		return streamItem{}, false
+	}
+	i := 0
+	if len(q.priorities) > 1 {
+		if q.guard > 0 && q.streak >= q.guard {
+			i = len(q.priorities) - 1
+			q.streak = 0
+		} else {
+			q.streak++
+		}
+	} else {
+		q.streak = 0
+	}
+	p := q.priorities[i]
+	bucket := q.buckets[p]
+	item := bucket[0]
+	bucket[0] = streamItem{}
+	q.buckets[p] = bucket[1:]
+	if len(bucket) == 1 {
+		delete(q.buckets, p)
+		q.priorities = append(q.priorities[:i], q.priorities[i+1:]...)
+	}
+	q.size--
+	q.stats.setDepth(p, len(bucket)-1)
+	q.notFull.Signal()
+	return item, true
+}
+
+// 关闭队列，已在队列中的数据仍然可以取出
+func (q *streamQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	close(q.done)
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+}
+
+// 流式执行的统计，按优先级记录，可以在执行过程中并发读取
+type StreamStats struct {
+	// 已经读入但还没有执行完的数据条数，以及执行槽位和等待队列的总容量，原子读写
+	load     int64
+	capacity int64
+	mu       sync.Mutex
+	done     map[int]uint64
+	depths   map[int]int
+	// 饱和度变化的通知
+	notifyMu  sync.Mutex
+	notify    *saturationNotify
+	saturated bool
+}
+
+// 饱和度：已经读入但还没有执行完的数据占执行槽位和等待队列总容量的比例，0 到1
+// 开销很小，可以在其他goroutine 中频繁查询，例如上游据此暂停拉取数据
+func (s *StreamStats) Saturation() float64 {
+	c := atomic.LoadInt64(&s.capacity)
+	if c <= 0 {
+		return 0
+	}
+	level := float64(atomic.LoadInt64(&s.load)) / float64(c)
+	if level > 1 {
+		level = 1
+	}
+	return level
+}
+
+// 优先级为p 的数据已经执行完的条数
+func (s *StreamStats) Processed(p int) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done[p]
+}
+
+// 优先级为p 的数据当前等待执行的条数
+func (s *StreamStats) QueueDepth(p int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.depths[p]
+}
+
+func (s *StreamStats) processed(p int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.done == nil {
+		s.done = make(map[int]uint64)
+	}
+	s.done[p]++
+	s.mu.Unlock()
+	s.addLoad(-1)
+}
+
+// 饱和度越过阈值时的通知，带有滞后：升到high 以上通知一次，之后降到low 以下才再通知
+type saturationNotify struct {
+	high, low float64
+	f         func(level float64)
+}
+
+func (s *StreamStats) addLoad(delta int64) {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.load, delta)
+	if s.notify == nil {
+		return
+	}
+	s.notifyMu.Lock()
+	defer s.notifyMu.Unlock()
+	level := s.Saturation()
+	if !s.saturated && level >= s.notify.high || s.saturated && level <= s.notify.low {
+		s.saturated = !s.saturated
+		s.notify.f(level)
+	}
+}
+
+func (s *StreamStats) setDepth(p int, depth int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.depths == nil {
+		s.depths = make(map[int]int)
+	}
+	s.depths[p] = depth
+	s.mu.Unlock()
+}
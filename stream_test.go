@@ -0,0 +1,270 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试通过序号找出流式执行中失败的数据
+func TestManager_HandleStreamErrorSeq(t *testing.T) {
+	errOdd := errors.New("bad item")
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		if in.Data.(int)%10 == 3 {
+			return nil, errOdd
+		}
+		return &rawData{Data: in.Data.(int) * 2}, nil
+	})
+
+	in := make(chan *rawData)
+	go func() {
+		for i := 0; i < 50; i++ {
+			in <- &rawData{Data: i}
+		}
+		close(in)
+	}()
+	outs, errs := m.HandleStream(context.Background(), in, WithStreamConcurrency(4))
+
+	var failed []uint64
+	succeeded := 0
+	for outs != nil || errs != nil {
+		select {
+		case out, ok := <-outs:
+			if !ok {
+				outs = nil
+				continue
+			}
+			if out.Out.Data.(int) != int(out.Seq)*2 {
+				t.Errorf("seq %d: unexpected output %v", out.Seq, out.Out.Data)
+			}
+			succeeded++
+		case se, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if se.Node != "w1" || !errors.Is(se, errOdd) || se.Input.Data.(int) != int(se.Seq) {
+				t.Errorf("unexpected stream error %+v", se)
+			}
+			failed = append(failed, se.Seq)
+		}
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i] < failed[j] })
+	if expected := []uint64{3, 13, 23, 33, 43}; !reflect.DeepEqual(failed, expected) {
+		t.Errorf("expected failed seqs %v, got %v", expected, failed)
+	}
+	if succeeded != 45 {
+		t.Errorf("expected 45 outputs, got %d", succeeded)
+	}
+}
+
+// 单个执行槽位时，按优先级执行等待的数据，并检查饥饿保护
+func TestManager_HandleStreamPriority(t *testing.T) {
+	cases := []struct {
+		guard    int
+		expected []int
+	}{
+		{0, []int{0, 1, 3, 5, 7, 9, 2, 4, 6, 8, 10}},
+		{2, []int{0, 1, 3, 2, 5, 7, 4, 9, 6, 8, 10}},
+	}
+	for _, c := range cases {
+		started := make(chan struct{}, 1)
+		release := make(chan struct{})
+		var order []int
+		m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+			if in.Data.(int) == 0 {
+				started <- struct{}{}
+				<-release
+			}
+			order = append(order, in.Data.(int))
+			return in, nil
+		})
+
+		stats := &StreamStats{}
+		in := make(chan *rawData)
+		outs, errs := m.HandleStream(context.Background(), in,
+			WithPriorityFunc(func(data *rawData) int { return data.Data.(int) % 2 }),
+			WithStarvationGuard(c.guard), WithStreamStats(stats))
+		go func() {
+			for range errs {
+			}
+		}()
+		in <- &rawData{Data: 0}
+		<-started
+		for i := 1; i <= 10; i++ {
+			in <- &rawData{Data: i}
+		}
+		close(in)
+		for stats.QueueDepth(0)+stats.QueueDepth(1) != 10 {
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+		for range outs {
+		}
+		if !reflect.DeepEqual(order, c.expected) {
+			t.Errorf("guard %d: expected order %v, got %v", c.guard, c.expected, order)
+		}
+		if stats.Processed(1) != 5 || stats.Processed(0) != 6 {
+			t.Errorf("guard %d: unexpected throughput high=%d low=%d", c.guard, stats.Processed(1), stats.Processed(0))
+		}
+	}
+}
+
+// 测试慢节点导致饱和度上升，输入暂停、积压处理完之后饱和度下降
+func TestManager_HandleStreamSaturation(t *testing.T) {
+	release := make(chan struct{})
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		<-release
+		return in, nil
+	})
+	levels := make(chan float64, 10)
+	stats := &StreamStats{}
+	in := make(chan *rawData)
+	outs, errs := m.HandleStream(context.Background(), in, WithStreamBuffer(4), WithStreamStats(stats),
+		WithSaturationChange(0.8, 0.2, func(level float64) { levels <- level }))
+	// 1 个执行槽位加4 个等待位置，读入5 条数据后饱和
+	for i := 0; i < 5; i++ {
+		in <- &rawData{Data: i}
+	}
+	if level := <-levels; level < 0.8 {
+		t.Errorf("saturation notified at %v, want >= 0.8", level)
+	}
+	if s := stats.Saturation(); s < 0.8 {
+		t.Errorf("saturation=%v, want >= 0.8", s)
+	}
+	close(release)
+	for i := 0; i < 5; i++ {
+		<-outs
+	}
+	if level := <-levels; level > 0.2 {
+		t.Errorf("saturation notified at %v, want <= 0.2", level)
+	}
+	if s := stats.Saturation(); s > 0.2 {
+		t.Errorf("saturation=%v, want <= 0.2", s)
+	}
+	close(in)
+	for range errs {
+	}
+	select {
+	case level := <-levels:
+		t.Errorf("unexpected notification %v", level)
+	default:
+	}
+}
+
+// 每条数据的延迟随机，第0 条最慢；序号除以7 余3 的数据失败
+func newRandomLatencyStream(t *testing.T, n int) (*Manager, <-chan *rawData, *int64) {
+	rnd := rand.New(rand.NewSource(1))
+	delays := make([]time.Duration, n)
+	for i := range delays {
+		delays[i] = time.Duration(rnd.Intn(3000)) * time.Microsecond
+	}
+	delays[0] = 20 * time.Millisecond
+	started := new(int64)
+	m := newSingleWorkerManager(t, func(ctx context.Context, in *rawData) (*rawData, error) {
+		atomic.AddInt64(started, 1)
+		i := in.Data.(int)
+		time.Sleep(delays[i])
+		if i%7 == 3 {
+			return nil, errors.New("bad item")
+		}
+		return in, nil
+	})
+	in := make(chan *rawData)
+	go func() {
+		for i := 0; i < n; i++ {
+			in <- &rawData{Data: i}
+		}
+		close(in)
+	}()
+	return m, in, started
+}
+
+// 同时读取两个channel，按收到的顺序返回序号
+func collectStreamSeqs(outs <-chan StreamOutput, errs <-chan StreamError, received func()) []uint64 {
+	var seqs []uint64
+	for outs != nil || errs != nil {
+		select {
+		case out, ok := <-outs:
+			if !ok {
+				outs = nil
+				continue
+			}
+			seqs = append(seqs, out.Seq)
+		case se, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			seqs = append(seqs, se.Seq)
+		}
+		received()
+	}
+	return seqs
+}
+
+func TestManager_HandleStreamOrderedOutput(t *testing.T) {
+	const n, concurrency = 100, 8
+	m, in, started := newRandomLatencyStream(t, n)
+	outs, errs := m.HandleStream(context.Background(), in, WithStreamConcurrency(concurrency), WithOrderedOutput())
+	var emitted, maxPending int64
+	seqs := collectStreamSeqs(outs, errs, func() {
+		emitted++
+		if pending := atomic.LoadInt64(started) - emitted; pending > maxPending {
+			maxPending = pending
+		}
+	})
+	if len(seqs) != n {
+		t.Fatalf("got %d results, want %d", len(seqs), n)
+	}
+	for i, seq := range seqs {
+		if seq != uint64(i) {
+			t.Fatalf("result %d has seq %d, want input order: %v", i, seq, seqs)
+		}
+	}
+	// 已经开始但还没有输出的数据受并发数限制
+	if maxPending > concurrency+1 {
+		t.Errorf("%d items pending output, want <= %d", maxPending, concurrency+1)
+	}
+}
+
+func TestManager_HandleStreamUnorderedReorders(t *testing.T) {
+	const n = 100
+	m, in, _ := newRandomLatencyStream(t, n)
+	outs, errs := m.HandleStream(context.Background(), in, WithStreamConcurrency(8))
+	seqs := collectStreamSeqs(outs, errs, func() {})
+	if len(seqs) != n {
+		t.Fatalf("got %d results, want %d", len(seqs), n)
+	}
+	if sort.SliceIsSorted(seqs, func(i, j int) bool { return seqs[i] < seqs[j] }) {
+		t.Errorf("unordered mode should reorder outputs")
+	}
+}
+
+// 测试占位输出：只读取输出channel 就能得到连续的序号
+func TestManager_HandleStreamFailurePlaceholders(t *testing.T) {
+	const n = 30
+	m, in, _ := newRandomLatencyStream(t, n)
+	outs, errs := m.HandleStream(context.Background(), in, WithStreamConcurrency(4), WithOrderedOutput(), WithFailurePlaceholders())
+	var i uint64
+	for out := range outs {
+		if out.Seq != i {
+			t.Fatalf("got seq %d, want %d", out.Seq, i)
+		}
+		if failed := i%7 == 3; failed != (out.Err != nil) || failed == (out.Out != nil) {
+			t.Errorf("seq %d: out=%v err=%v", i, out.Out, out.Err)
+		}
+		i++
+	}
+	if i != n {
+		t.Errorf("got %d outputs, want %d", i, n)
+	}
+	if _, ok := <-errs; ok {
+		t.Errorf("errors should be placeholders")
+	}
+}
@@ -0,0 +1,169 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 子图中的合并节点需要子图以外的分支输入
+var ErrSubgraphNotSelfContained = errors.New("subgraph merger needs inputs from outside")
+
+// 只执行from 到to 之间的节点，用于调试：in 作为from 的输入，返回to 的输出
+// from 不能是合并节点，to 不能是分裂节点；子图中的合并节点的所有输入都必须来自子图，
+// 否则返回ErrSubgraphNotSelfContained 并列出缺少输入的合并节点
+// 判断节点选择了子图以外的分支时返回错误
+// 和 HandleContext 一样受 WithMaxInflightExecutions、WithMaxRecursionDepth 的限制，支持 WithStallDetection，
+// 开启 WithExecutionHistory 时记录摘要（Subgraph 为 "from->to"）；子图的输出不是流水线的结果，
+// 所以不调用 WithFinalizer 的回调、不计入 WithSLO，失败时不经过错误处理子图、不保存死信，WithPipelineRetry 不生效
+func (m *Manager) HandleSubgraph(ctx context.Context, from, to string, in *rawData, opts ...CallOption) (out *rawData, err error) {
+	if !m.built {
+		return nil, ErrorsPipelineNotBuilt
+	}
+	nodes, err := m.subgraph(from, to)
+	if err != nil {
+		return nil, err
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	start := m.clock.Now()
+	depth := 1
+	if m.tracksRecursion() {
+		if ctx, depth, err = m.enterRecursion(ctx); err != nil {
+			return nil, err
+		}
+	}
+	var seq uint64
+	if depth == 1 {
+		if seq, err = m.track(start); err != nil {
+			return nil, err
+		}
+		defer m.untrack(seq)
+		if err = m.admit(ctx); err != nil {
+			return nil, err
+		}
+		defer m.release()
+	}
+	e := m.newExecution(ctx, opts, seq, start)
+	e.subgraph, e.stopAt, e.span = nodes, m.nodes[to], from+"->"+to
+	if m.softDeadline != nil {
+		defer e.startWatchdog().Stop()
+	}
+	// 分支超时和合并等待都会登记需要取消的ctx 和计时器
+	defer e.cancelBranches()
+	if m.stall != nil {
+		e.startStallWatch()
+		defer e.stall.finish(nil)
+	}
+	out, err = e.runFrom(m.nodes[from], nil, in, e.start)
+	if errors.Is(err, ErrorsCannotReachTail) {
+		err = fmt.Errorf("subgraph[%s->%s] finished without reaching node[%s]", from, to, to)
+	} else if err != nil {
+		err = e.cancelled(err)
+	}
+	if e.stall != nil {
+		err = e.stall.finish(err)
+	}
+	if e.sections != nil {
+		e.releaseSections(err)
+	}
+	if m.history != nil {
+		e.remember(in, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// 计算from 到to 之间所有路径上的节点，并检查合并节点的输入
+func (m *Manager) subgraph(from, to string) (map[*Node]bool, error) {
+	src, dst := m.nodes[from], m.nodes[to]
+	for _, n := range []struct {
+		name string
+		node *Node
+	}{{from, src}, {to, dst}} {
+		if n.node == nil || n.node.Typ == NodeTypHead || n.node.Typ == NodeTypTail {
+			return nil, fmt.Errorf("subgraph node[%s] is not a node of the pipeline", n.name)
+		}
+	}
+	if src.Typ == NodeTypMerger {
+		return nil, fmt.Errorf("subgraph cannot start at merger node[%s]", from)
+	}
+	if dst.Typ == NodeTypDivider {
+		return nil, fmt.Errorf("subgraph cannot end at divider node[%s]", to)
+	}
+	nodes := pathNodes(src, dst)
+	if nodes == nil {
+		return nil, fmt.Errorf("subgraph node[%s] cannot reach node[%s]", from, to)
+	}
+	// 按edges 中的顺序检查合并节点，报错顺序稳定
+	var unfed []string
+	seen := make(map[*Node]bool)
+	for _, edge := range m.edgeList {
+		n := m.nodes[edge.to]
+		if n == nil || n.Typ != NodeTypMerger || !nodes[n] || seen[n] {
+			continue
+		}
+		seen[n] = true
+		var outside []string
+		for _, pred := range m.predsOfMerger[n] {
+			if !nodes[pred] {
+				outside = append(outside, pred.nodeName)
+			}
+		}
+		if len(outside) > 0 {
+			unfed = append(unfed, fmt.Sprintf("merger[%s] from [%s]", n.nodeName, strings.Join(outside, ", ")))
+		}
+	}
+	if len(unfed) > 0 {
+		return nil, fmt.Errorf("%w: subgraph[%s->%s] %s", ErrSubgraphNotSelfContained, from, to, strings.Join(unfed, "; "))
+	}
+	return nodes, nil
+}
+
+// 从src 到dst 的所有路径上的节点，包括src 和dst；src 无法到达dst 时返回nil
+func pathNodes(src, dst *Node) map[*Node]bool {
+	// 从src 出发能到达的节点中，能到达dst 的节点
+	forward := make(map[*Node]bool)
+	stack := []*Node{src}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if forward[n] {
+			continue
+		}
+		forward[n] = true
+		if n != dst {
+			stack = append(stack, n.Next...)
+		}
+	}
+	if !forward[dst] {
+		return nil
+	}
+	nodes := make(map[*Node]bool)
+	var reaches func(n *Node) bool
+	reaches = func(n *Node) bool {
+		if ok, seen := nodes[n]; seen {
+			return ok
+		}
+		nodes[n] = n == dst
+		if n != dst {
+			for _, next := range n.Next {
+				if forward[next] && reaches(next) {
+					nodes[n] = true
+				}
+			}
+		}
+		return nodes[n]
+	}
+	reaches(src)
+	for n, ok := range nodes {
+		if !ok {
+			delete(nodes, n)
+		}
+	}
+	return nodes
+}
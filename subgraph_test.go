@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestManager_HandleSubgraphLinear(t *testing.T) {
+	m := newLinearManager(t, 5, 0)
+	trace := &Trace{}
+	out, err := m.HandleSubgraph(context.Background(), "w2", "w4", &rawData{Data: 10}, WithTrace(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Data.(int) != 13 {
+		t.Errorf("out=%v, want 13", out.Data)
+	}
+	var nodes []string
+	for _, entry := range trace.Entries() {
+		nodes = append(nodes, entry.Node)
+	}
+	if got := strings.Join(nodes, ","); got != "w2,w3,w4" {
+		t.Errorf("trace=%s, want w2,w3,w4", got)
+	}
+}
+
+func TestManager_HandleSubgraphDiamond(t *testing.T) {
+	var mu sync.Mutex
+	var nodes []string
+	m := NewManager(WithListener(func(ev NodeEvent) {
+		mu.Lock()
+		nodes = append(nodes, ev.Node)
+		mu.Unlock()
+	}))
+	for _, name := range []string{"pre", "a", "b", "post"} {
+		if err := m.AddWorkerNode(name, passWorker); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+		return []*rawData{in, {Data: in.Data}}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (*rawData, error) {
+		return &rawData{Data: len(in)}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.BuildPipeline([][]string{
+		{"head", "pre"},
+		{"pre", "d1"},
+		{"d1", "a"},
+		{"d1", "b"},
+		{"a", "m1"},
+		{"b", "m1"},
+		{"m1", "post"},
+		{"post", "tail"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := m.HandleSubgraph(context.Background(), "d1", "m1", &rawData{Data: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Data.(int) != 2 {
+		t.Errorf("out=%v, want 2", out.Data)
+	}
+	if got := strings.Join(nodes, ","); got != "d1,a,b,m1" {
+		t.Errorf("executed %s, want d1,a,b,m1", got)
+	}
+}
+
+func TestManager_HandleSubgraphUnfedMerger(t *testing.T) {
+	m := newDiamondManager(t)
+	_, err := m.HandleSubgraph(context.Background(), "a", "m1", &rawData{Data: 1})
+	if !errors.Is(err, ErrSubgraphNotSelfContained) {
+		t.Fatalf("want ErrSubgraphNotSelfContained, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "merger[m1] from [b]") {
+		t.Errorf("error should list the unfed merger: %v", err)
+	}
+	if _, err = m.HandleSubgraph(context.Background(), "a", "b", &rawData{Data: 1}); err == nil {
+		t.Errorf("want error for unreachable segment")
+	}
+}
+
+// 测试子图的执行占用 WithMaxInflightExecutions 的名额，并记录到执行历史中
+func TestManager_HandleSubgraphInflight(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	m := NewManager(WithMaxInflightExecutions(1, OverflowReject), WithExecutionHistory(4))
+	_ = m.AddWorkerNode("w1", func(ctx context.Context, in *rawData) (*rawData, error) {
+		if in.Data == "block" {
+			close(started)
+			<-release
+		}
+		return in, nil
+	})
+	_ = m.AddWorkerNode("w2", passWorker)
+	if err := m.BuildPipeline([][]string{{Head, "w1"}, {"w1", "w2"}, {"w2", Tail}}); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.HandleSubgraph(context.Background(), "w1", "w2", &rawData{Data: "block"})
+		done <- err
+	}()
+	<-started
+	if _, err := m.Handle(&rawData{}); err != ErrOverloaded {
+		t.Errorf("err=%v, want ErrOverloaded while the subgraph holds the only slot", err)
+	}
+	if _, err := m.HandleSubgraph(context.Background(), "w1", "w2", &rawData{}); err != ErrOverloaded {
+		t.Errorf("err=%v, want ErrOverloaded for a second subgraph run", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.HandleSubgraph(context.Background(), "w1", "w2", &rawData{}); err != nil {
+		t.Fatal(err)
+	}
+	if recent := m.RecentExecutions(); len(recent) != 2 || recent[0].Subgraph != "w1->w2" || recent[0].Status != ExecutionSucceeded {
+		t.Errorf("recent executions %+v, want the two subgraph runs", recent)
+	}
+}
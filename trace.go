@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+)
+
+// 一次执行的轨迹，通过 WithTrace 传给 HandleContext 后按执行顺序记录每个节点
+type Trace struct {
+	mu      sync.Mutex
+	entries []TraceEntry
+	// 执行使用的随机数种子，见 WithSeed
+	seed   int64
+	seeded bool
+	// 执行的功能开关，见 WithFlags
+	flags map[string]bool
+	// 重新执行时原来的执行，以及这条数据第几次执行，见 WithParentExecution
+	parent  string
+	attempt int
+}
+
+// 单个节点的执行记录
+type TraceEntry struct {
+	Node  string
+	Typ   NodeTyp
+	Start time.Time
+	// 节点的执行时间，不包括在队列中等待的时间
+	Duration time.Duration
+	// 开始执行前在队列中等待的时间，流程中的第一个节点还包括等待执行槽位（WithMaxInflightExecutions）的时间
+	QueueWait time.Duration
+	Err       error
+	// 判断节点选择的分支，其他节点BranchIndex 为-1
+	BranchIndex int
+	Branch      string
+	// 执行次数（包括重试），以及每次重试前等待的时间
+	Attempts int
+	Backoffs []time.Duration
+	Outcome  Outcome
+	// 节点所属的阶段
+	Stage string
+	// 工作节点选择的版本，没有注册其他版本时为空
+	Variant string
+	// 开启 WithPipelineRetry 时为整个流水线的第几次执行，从1 开始；否则为0
+	PipelineAttempt int
+	// 是否为预热的执行，见 Warmup
+	Warmup bool
+	// 节点的信息
+	Info NodeInfo
+}
+
+// 返回执行记录的副本
+func (t *Trace) Entries() []TraceEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entries := make([]TraceEntry, len(t.entries))
+	for i, entry := range t.entries {
+		entry.Info = entry.Info.clone()
+		entries[i] = entry
+	}
+	return entries
+}
+
+// 返回执行使用的随机数种子，执行没有独立的随机数来源时ok 为false
+func (t *Trace) Seed() (seed int64, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.seed, t.seeded
+}
+
+func (t *Trace) setSeed(seed int64) {
+	t.mu.Lock()
+	t.seed, t.seeded = seed, true
+	t.mu.Unlock()
+}
+
+// 返回重新执行时原来的执行，以及这条数据第几次执行；不是重新执行时为空和0
+func (t *Trace) Parent() (id string, attempt int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.parent, t.attempt
+}
+
+func (t *Trace) setParent(id string, attempt int) {
+	t.mu.Lock()
+	t.parent, t.attempt = id, attempt
+	t.mu.Unlock()
+}
+
+func (t *Trace) add(entry TraceEntry) {
+	t.mu.Lock()
+	t.entries = append(t.entries, entry)
+	t.mu.Unlock()
+}
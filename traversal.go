@@ -0,0 +1,43 @@
+package pipeline
+
+// 通用执行流程中待执行节点的顺序
+type Traversal int
+
+const (
+	// 广度优先：先进先出，各分支交替推进（默认）
+	BFS Traversal = iota
+	// 深度优先：后进先出，一个分支执行到合并节点之后才开始下一个分支
+	DFS
+)
+
+// 设置通用执行流程的遍历顺序，工作节点链总是连续执行，不受影响
+// 只对依次执行有效：开启 WithStageParallelism 时输入已经到达的节点同时执行，执行顺序和轨迹的顺序取决于完成的顺序，
+// 遍历顺序没有意义，调度总是先进先出
+func WithTraversal(t Traversal) Option {
+	return func(m *Manager) {
+		m.traversal = t
+	}
+}
+
+// 取出下一个待执行的节点
+func (m *Manager) popNode(queue []*nodeDataWrapper) (*nodeDataWrapper, []*nodeDataWrapper) {
+	if m.depthFirst() {
+		return queue[len(queue)-1], queue[:len(queue)-1]
+	}
+	return queue[0], queue[1:]
+}
+
+// 分裂节点的分支加入队列后调用，深度优先时反转顺序，保证第一个分支先执行
+func (m *Manager) orderBranches(branches []*nodeDataWrapper) {
+	if !m.depthFirst() {
+		return
+	}
+	for i, j := 0, len(branches)-1; i < j; i, j = i+1, j-1 {
+		branches[i], branches[j] = branches[j], branches[i]
+	}
+}
+
+// 依次执行并且设置了深度优先，见 WithTraversal
+func (m *Manager) depthFirst() bool {
+	return m.traversal == DFS && m.parallelism == 0
+}
@@ -0,0 +1,73 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// 测试两个分支（每个分支内再分裂成两个工作节点后合并）在广度优先和深度优先下的执行顺序
+// 并行执行时忽略遍历顺序，只有一个执行槽位时按先进先出的顺序执行
+func TestManager_Traversal(t *testing.T) {
+	cases := []struct {
+		traversal Traversal
+		parallel  bool
+		want      []string
+	}{
+		{BFS, false, []string{"d1", "da", "db", "a1", "a2", "b1", "b2", "ma", "mb", "m1"}},
+		{DFS, false, []string{"d1", "da", "a1", "a2", "ma", "db", "b1", "b2", "mb", "m1"}},
+		{DFS, true, []string{"d1", "da", "db", "a1", "a2", "b1", "b2", "ma", "mb", "m1"}},
+	}
+	for _, c := range cases {
+		m := NewManager(WithTraversal(c.traversal))
+		if c.parallel {
+			WithStageParallelism()(m)
+			m.parallelism = 1
+		}
+		if err := m.AddDividerNode("d1", func(ctx context.Context, in *rawData) (out []*rawData, err error) {
+			return []*rawData{{Data: 1}, {Data: 2}}, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.AddMergerNode("m1", func(ctx context.Context, in []*rawData) (out *rawData, err error) {
+			return &rawData{Data: in[0].Data.(int)*10 + in[1].Data.(int)}, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		edges := [][]string{{Head, "d1"}, {"m1", Tail}}
+		for _, b := range []string{"a", "b"} {
+			_ = m.AddDividerNode("d"+b, func(ctx context.Context, in *rawData) (out []*rawData, err error) {
+				return []*rawData{in, {Data: in.Data}}, nil
+			})
+			_ = m.AddMergerNode("m"+b, func(ctx context.Context, in []*rawData) (out *rawData, err error) {
+				return in[0], nil
+			})
+			_ = m.AddWorkerNode(b+"1", passWorker)
+			_ = m.AddWorkerNode(b+"2", passWorker)
+			edges = append(edges,
+				[]string{"d1", "d" + b},
+				[]string{"d" + b, b + "1"}, []string{"d" + b, b + "2"},
+				[]string{b + "1", "m" + b}, []string{b + "2", "m" + b},
+				[]string{"m" + b, "m1"})
+		}
+		if err := m.BuildPipeline(edges); err != nil {
+			t.Fatal(err)
+		}
+		var tr Trace
+		out, err := m.HandleContext(context.Background(), &rawData{}, WithTrace(&tr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 合并节点的输入仍然按分支顺序
+		if out.Data.(int) != 12 {
+			t.Errorf("traversal=%d parallel=%v: out=%v, want 12", c.traversal, c.parallel, out.Data)
+		}
+		var order []string
+		for _, entry := range tr.Entries() {
+			order = append(order, entry.Node)
+		}
+		if !reflect.DeepEqual(order, c.want) {
+			t.Errorf("traversal=%d parallel=%v: order=%v, want=%v", c.traversal, c.parallel, order, c.want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// 只有一个节点的流水线
+var trivialShapes = []struct {
+	name  string
+	node  string
+	build func(m *Manager) error
+	// 执行的结果以及 Paths 返回的路径数
+	out   int
+	paths int
+}{
+	{
+		name: "single worker",
+		node: "w1",
+		build: func(m *Manager) error {
+			if err := m.AddWorkerNode("w1", passWorker, WithCost(1)); err != nil {
+				return err
+			}
+			return m.BuildPipeline([][]string{{Head, "w1"}, {"w1", Tail}})
+		},
+		out:   1,
+		paths: 1,
+	},
+	{
+		name: "single judger",
+		node: "j1",
+		build: func(m *Manager) error {
+			if err := m.AddJudgerNode("j1", func(ctx context.Context, in *rawData) int {
+				return 1
+			}, WithBranches("yes", "no")); err != nil {
+				return err
+			}
+			return m.BuildPipeline([][]string{{Head, "j1"}, {"j1", Tail}, {"j1", Tail}})
+		},
+		out:   1,
+		paths: 2,
+	},
+	{
+		name: "single divider",
+		node: "d1",
+		build: func(m *Manager) error {
+			if err := m.AddDividerNode("d1", func(ctx context.Context, in *rawData) ([]*rawData, error) {
+				return []*rawData{in, {Data: 2}}, nil
+			}); err != nil {
+				return err
+			}
+			return m.BuildPipeline([][]string{{Head, "d1"}, {"d1", Tail}, {"d1", Tail}})
+		},
+		// 第一个到达尾节点的分支是执行的结果
+		out:   1,
+		paths: 1,
+	},
+}
+
+// 测试只有一个节点的流水线可以正常使用所有公开的方法
+func TestManager_TrivialShapes(t *testing.T) {
+	for _, shape := range trivialShapes {
+		t.Run(shape.name, func(t *testing.T) {
+			m := NewManager()
+			if err := shape.build(m); err != nil {
+				t.Fatal(err)
+			}
+			trace := &Trace{}
+			out, err := m.HandleContext(context.Background(), &rawData{Data: 1}, WithTrace(trace))
+			if err != nil || out.Data != shape.out {
+				t.Fatalf("out=%v err=%v, want %d", out, err, shape.out)
+			}
+			if entries := trace.Entries(); len(entries) != 1 || entries[0].Node != shape.node {
+				t.Errorf("trace=%v, want only %s", entries, shape.node)
+			}
+			if n, path, err := m.LongestPath(); err != nil || n != 1 || path[0] != shape.node {
+				t.Errorf("LongestPath=%d %v %v", n, path, err)
+			}
+			if _, _, err = m.MaxCostPath(); err != nil {
+				t.Errorf("MaxCostPath: %v", err)
+			}
+			paths, err := m.Paths(10, WithVirtualNodes())
+			if err != nil || len(paths) != shape.paths {
+				t.Fatalf("Paths=%v %v, want %d paths", paths, err, shape.paths)
+			}
+			for _, p := range paths {
+				if strings.Join(p, ",") != Head+","+shape.node+","+Tail {
+					t.Errorf("path %v", p)
+				}
+			}
+			quoted := `"` + shape.node + `"`
+			if !strings.Contains(m.ToDOT(), quoted) || !strings.Contains(m.ToMermaid(), quoted) {
+				t.Errorf("export misses %s:\n%s\n%s", shape.node, m.ToDOT(), m.ToMermaid())
+			}
+			if _, err = m.ExportJSON(); err != nil {
+				t.Error(err)
+			}
+			if err = m.AssertFingerprint(m.Fingerprint()); err != nil {
+				t.Error(err)
+			}
+			if !Diff(m, m).Empty() || len(m.Lint()) != 0 {
+				t.Errorf("diff=%v lint=%v", Diff(m, m), m.Lint())
+			}
+			if report := m.Health(context.Background()); report.Status != HealthReady {
+				t.Errorf("health=%+v", report)
+			}
+			in := make(chan *rawData, 1)
+			in <- &rawData{Data: 1}
+			close(in)
+			outs, errs := m.HandleStream(context.Background(), in)
+			if seqs := collectStreamSeqs(outs, errs, func() {}); len(seqs) != 1 {
+				t.Errorf("stream results %v", seqs)
+			}
+		})
+	}
+}
+
+// 测试空的流水线报错而不是panic
+func TestManager_EmptyPipeline(t *testing.T) {
+	m := NewManager()
+	if err := m.BuildPipeline(nil); !errors.Is(err, ErrorsNodesOrEdgesEmpty) {
+		t.Errorf("err=%v, want ErrorsNodesOrEdgesEmpty", err)
+	}
+	if err := m.BuildPipeline([][]string{{Head, Tail}}); !errors.Is(err, ErrorsNodesOrEdgesEmpty) {
+		t.Errorf("err=%v, want ErrorsNodesOrEdgesEmpty", err)
+	}
+	if _, err := m.Handle(&rawData{}); !errors.Is(err, ErrorsPipelineNotBuilt) {
+		t.Errorf("Handle err=%v, want ErrorsPipelineNotBuilt", err)
+	}
+	if _, _, err := m.LongestPath(); !errors.Is(err, ErrorsPipelineNotBuilt) {
+		t.Errorf("LongestPath err=%v", err)
+	}
+	if _, err := m.Paths(10); !errors.Is(err, ErrorsPipelineNotBuilt) {
+		t.Errorf("Paths err=%v", err)
+	}
+	if report := m.Health(context.Background()); report.Status == HealthReady {
+		t.Errorf("empty pipeline should not be ready")
+	}
+	_ = m.ToDOT()
+	_ = m.ToMermaid()
+	in := make(chan *rawData, 1)
+	in <- &rawData{}
+	close(in)
+	outs, errs := m.HandleStream(context.Background(), in)
+	for outs != nil || errs != nil {
+		select {
+		case _, ok := <-outs:
+			if ok {
+				t.Errorf("empty pipeline should not produce output")
+			} else {
+				outs = nil
+			}
+		case se, ok := <-errs:
+			if !ok {
+				errs = nil
+			} else if !errors.Is(se, ErrorsPipelineNotBuilt) {
+				t.Errorf("stream err=%v", se)
+			}
+		}
+	}
+}
+
+// 测试没有节点、没有边以及边都没有用到节点三种情况的报错，前两种仍然兼容 ErrorsNodesOrEdgesEmpty
+func TestManager_EmptyPipelineErrors(t *testing.T) {
+	edges := [][]string{{Head, "a"}, {"a", "b"}, {"b", "c"}, {"c", Tail}}
+	m := NewManager()
+	err := m.BuildPipeline(edges)
+	if !errors.Is(err, ErrorsNoNodesRegistered) || !errors.Is(err, ErrorsNodesOrEdgesEmpty) ||
+		err.Error() != "no nodes registered (0 nodes registered, 4 edges provided)" {
+		t.Errorf("no nodes: err=%v", err)
+	}
+
+	m = NewManager()
+	_ = m.AddWorkerNode("w1", passWorker)
+	err = m.BuildPipeline(nil)
+	if !errors.Is(err, ErrorsNoEdgesProvided) || !errors.Is(err, ErrorsNodesOrEdgesEmpty) || errors.Is(err, ErrorsNoNodesRegistered) ||
+		err.Error() != "no edges provided (1 nodes registered, 0 edges provided)" {
+		t.Errorf("no edges: err=%v", err)
+	}
+
+	for _, name := range []string{"w1", "w2", "w3"} {
+		_ = m.AddWorkerNode("team/"+name, passWorker)
+	}
+	err = m.BuildPipeline(edges)
+	var ve *ValidationError
+	if !errors.Is(err, ErrorsEdgesMatchNoNodes) || errors.Is(err, ErrorsNodesOrEdgesEmpty) || !errors.As(err, &ve) || ve.Code != CodeUnknownNode ||
+		err.Error() != "no edge references a registered node (4 nodes registered, 4 edges provided), first edge is head->a" {
+		t.Errorf("unknown nodes: err=%v", err)
+	}
+}
+
+// 测试没有后继的节点报错中带有节点名
+func TestManager_BuildNodeWithoutNext(t *testing.T) {
+	m := NewManager()
+	_ = m.AddWorkerNode("w1", passWorker)
+	// 没有到达尾节点的边
+	err := m.BuildPipeline([][]string{{Head, "w1"}})
+	if !errors.Is(err, ErrorsTailEdgeMissing) || !strings.Contains(err.Error(), "node[w1]") {
+		t.Errorf("err=%v", err)
+	}
+	m = NewManager()
+	for _, name := range []string{"w1", "w2", "w3"} {
+		_ = m.AddWorkerNode(name, passWorker)
+	}
+	err = m.BuildPipeline([][]string{{Head, "w1"}, {"w1", "w2"}, {"w3", Tail}})
+	if !errors.Is(err, ErrorsNodeNil) || !strings.Contains(err.Error(), "node[w2]") {
+		t.Errorf("err=%v", err)
+	}
+}
+
+// 测试没有、有一条、有两条从头节点出发的边
+func TestManager_BuildHeadEdges(t *testing.T) {
+	for _, c := range []struct {
+		edges [][]string
+		want  error
+		msg   string
+	}{
+		{[][]string{{"w1", "w2"}, {"w2", Tail}}, ErrorsHeadEdgeMissing, `exactly one edge must start from "head" (or "head000")`},
+		{[][]string{{"head000", "w1"}, {"w1", "w2"}, {"w2", Tail}}, nil, ""},
+		{[][]string{{Head, "w1"}, {Head, "w2"}, {"w1", Tail}, {"w2", Tail}}, ErrorsHeadNodeNotUnique, "found 2 head edges: [head->w1 head->w2]"},
+	} {
+		m := NewManager()
+		_ = m.AddWorkerNode("w1", passWorker)
+		_ = m.AddWorkerNode("w2", passWorker)
+		err := m.BuildPipeline(c.edges)
+		if c.want == nil {
+			if err != nil {
+				t.Errorf("%v: unexpected error %v", c.edges, err)
+			}
+			continue
+		}
+		if !errors.Is(err, c.want) || !strings.Contains(err.Error(), c.msg) {
+			t.Errorf("%v: want %v containing %q, got %v", c.edges, c.want, c.msg, err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package pipeline
+
+import "errors"
+
+// 构建失败的原因，Code 是稳定的，可以用于在编辑器等工具中定位出错的节点、边
+// 原来的错误（例如 ErrorsPipelineHasCycle）仍然可以用 errors.Is 判断
+type ValidationError struct {
+	Code string
+	// 出错的节点，没有具体节点时为空
+	Node string
+	// 出错的边，没有具体的边时为空
+	Edge    [2]string
+	Message string
+	err     error
+}
+
+// 校验错误的代码，已经发布的代码不会修改含义，只会增加新的代码
+const (
+	// 节点重名
+	CodeDuplicateNode = "DUP_NODE"
+	// 节点使用了虚拟头、尾节点的名字
+	CodeReservedName = "RESERVED_NAME"
+	// 没有节点或者没有边
+	CodeEmpty = "EMPTY"
+	// 边的格式不对，缺少前后节点
+	CodeBadEdge = "BAD_EDGE"
+	// 边中的节点没有添加
+	CodeUnknownNode = "UNKNOWN_NODE"
+	// 没有从虚拟头节点出发的边
+	CodeMissingHeadEdge = "MISSING_HEAD_EDGE"
+	// 从虚拟头节点出发的边多于一条
+	CodeMultipleHeadEdges = "MULTIPLE_HEAD_EDGES"
+	// 没有到达虚拟尾节点的边
+	CodeMissingTailEdge = "MISSING_TAIL_EDGE"
+	// 节点的入度、出度不符合节点类型
+	CodeBadInDegree  = "BAD_IN_DEGREE"
+	CodeBadOutDegree = "BAD_OUT_DEGREE"
+	// 节点到达不了虚拟尾节点
+	CodeUnreachable = "UNREACHABLE"
+	// 图中有环
+	CodeCycle = "CYCLE"
+	// 最长路径超过 WithMaxDepth 的限制
+	CodeTooDeep = "TOO_DEEP"
+	// 分裂节点的分支内的判断节点直接连到了虚拟尾节点
+	CodeJudgerTailInBranch = "JUDGER_TAIL_IN_BRANCH"
+	// 节点配置不适用于节点，或者与流水线的结构不符
+	CodeBadOption = "BAD_OPTION"
+	// 阶段、错误处理子图、临界区、分支超时的配置不对
+	CodeBadStage           = "BAD_STAGE"
+	CodeBadErrorHandler    = "BAD_ERROR_HANDLER"
+	CodeBadCriticalSection = "BAD_CRITICAL_SECTION"
+	CodeBadBranchTimeout   = "BAD_BRANCH_TIMEOUT"
+)
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.err
+}
+
+// 节点node 的校验错误
+func invalidNode(code, node string, err error) *ValidationError {
+	return &ValidationError{Code: code, Node: node, Message: err.Error(), err: err}
+}
+
+// 边e 的校验错误，node 为边中出错的节点
+func invalidEdge(code string, e *edge, node string, err error) *ValidationError {
+	return &ValidationError{Code: code, Node: node, Edge: [2]string{e.from, e.to}, Message: err.Error(), err: err}
+}
+
+// 没有具体位置的错误按code 包装，已经是 ValidationError 时原样返回
+func invalid(code string, err error) error {
+	var ve *ValidationError
+	if err == nil || errors.As(err, &ve) {
+		return err
+	}
+	return &ValidationError{Code: code, Message: err.Error(), err: err}
+}
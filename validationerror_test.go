@@ -0,0 +1,155 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// 测试构建失败时的错误代码以及出错的节点、边
+func TestManager_ValidationError(t *testing.T) {
+	judge := func(ctx context.Context, in *rawData) int { return 0 }
+	merge := func(ctx context.Context, in []*rawData) (*rawData, error) { return in[0], nil }
+	cases := []struct {
+		name     string
+		build    func(m *Manager) error
+		code     string
+		node     string
+		edge     [2]string
+		sentinel error
+	}{
+		{
+			name: "duplicate node",
+			build: func(m *Manager) error {
+				_ = m.AddWorkerNode("w1", passWorker)
+				return m.AddWorkerNode("w1", passWorker)
+			},
+			code: CodeDuplicateNode, node: "w1", sentinel: ErrorsNodeNameDuplicate,
+		},
+		{
+			name:  "reserved name",
+			build: func(m *Manager) error { return m.AddWorkerNode("tail111", passWorker) },
+			code:  CodeReservedName, node: "tail111",
+		},
+		{
+			name: "empty",
+			build: func(m *Manager) error {
+				return m.BuildPipeline(nil)
+			},
+			code: CodeEmpty, sentinel: ErrorsNodesOrEdgesEmpty,
+		},
+		{
+			name: "bad edge",
+			build: func(m *Manager) error {
+				_ = m.AddWorkerNode("w1", passWorker)
+				return m.BuildPipeline([][]string{{Head, "w1"}, {"w1"}})
+			},
+			code: CodeBadEdge,
+		},
+		{
+			name: "unknown node",
+			build: func(m *Manager) error {
+				_ = m.AddWorkerNode("w1", passWorker)
+				return m.BuildPipeline([][]string{{Head, "w1"}, {"w1", "w2"}, {"w2", Tail}})
+			},
+			code: CodeUnknownNode, node: "w2", edge: [2]string{"w1", "w2"},
+		},
+		{
+			name: "missing head edge",
+			build: func(m *Manager) error {
+				_ = m.AddWorkerNode("w1", passWorker)
+				return m.BuildPipeline([][]string{{"w1", Tail}})
+			},
+			code: CodeMissingHeadEdge, sentinel: ErrorsHeadEdgeMissing,
+		},
+		{
+			name: "multiple head edges",
+			build: func(m *Manager) error {
+				_ = m.AddWorkerNode("w1", passWorker)
+				_ = m.AddWorkerNode("w2", passWorker)
+				return m.BuildPipeline([][]string{{Head, "w1"}, {Head, "w2"}, {"w1", Tail}, {"w2", Tail}})
+			},
+			code: CodeMultipleHeadEdges, node: Head, edge: [2]string{Head, "w2"}, sentinel: ErrorsHeadNodeNotUnique,
+		},
+		{
+			name: "missing tail edge",
+			build: func(m *Manager) error {
+				_ = m.AddWorkerNode("w1", passWorker)
+				return m.BuildPipeline([][]string{{Head, "w1"}})
+			},
+			code: CodeMissingTailEdge, node: "w1", sentinel: ErrorsTailEdgeMissing,
+		},
+		{
+			name: "bad in degree",
+			build: func(m *Manager) error {
+				_ = m.AddMergerNode("m1", merge)
+				return m.BuildPipeline([][]string{{Head, "m1"}, {"m1", Tail}})
+			},
+			code: CodeBadInDegree, node: "m1",
+		},
+		{
+			name: "bad out degree",
+			build: func(m *Manager) error {
+				_ = m.AddWorkerNode("w1", passWorker)
+				_ = m.AddWorkerNode("w2", passWorker)
+				return m.BuildPipeline([][]string{{Head, "w1"}, {"w1", "w2"}, {"w1", Tail}, {"w2", Tail}})
+			},
+			code: CodeBadOutDegree, node: "w1",
+		},
+		{
+			name: "tail out edge",
+			build: func(m *Manager) error {
+				_ = m.AddWorkerNode("w1", passWorker)
+				return m.BuildPipeline([][]string{{Head, "w1"}, {"w1", Tail}, {Tail, "w1"}})
+			},
+			code: CodeBadOutDegree, node: Tail, edge: [2]string{Tail, "w1"},
+		},
+		{
+			name: "unreachable",
+			build: func(m *Manager) error {
+				_ = m.AddWorkerNode("w1", passWorker)
+				_ = m.AddWorkerNode("w2", passWorker)
+				return m.BuildPipeline([][]string{{Head, "w1"}, {"w2", Tail}})
+			},
+			code: CodeUnreachable, node: "w1", sentinel: ErrorsNodeNil,
+		},
+		{
+			name: "cycle",
+			build: func(m *Manager) error {
+				_ = m.AddWorkerNode("w1", passWorker)
+				_ = m.AddMergerNode("m1", merge)
+				_ = m.AddJudgerNode("j1", judge)
+				return m.BuildPipeline([][]string{{Head, "w1"}, {"w1", "m1"}, {"m1", "j1"}, {"j1", Tail}, {"j1", "m1"}})
+			},
+			code: CodeCycle, node: "m1", sentinel: ErrorsPipelineHasCycle,
+		},
+		{
+			name: "bad option",
+			build: func(m *Manager) error {
+				_ = m.AddJudgerNode("j1", judge, WithBranches("only"))
+				_ = m.AddWorkerNode("a", passWorker)
+				_ = m.AddWorkerNode("b", passWorker)
+				return m.BuildPipeline([][]string{{Head, "j1"}, {"j1", "a"}, {"j1", "b"}, {"a", Tail}, {"b", Tail}})
+			},
+			code: CodeBadOption, node: "j1",
+		},
+	}
+	for _, c := range cases {
+		err := c.build(NewManager())
+		var ve *ValidationError
+		if !errors.As(err, &ve) {
+			t.Errorf("%s: want ValidationError, got %v", c.name, err)
+			continue
+		}
+		if ve.Code != c.code || ve.Node != c.node || ve.Edge != c.edge {
+			t.Errorf("%s: got code=%s node=%q edge=%v, want code=%s node=%q edge=%v",
+				c.name, ve.Code, ve.Node, ve.Edge, c.code, c.node, c.edge)
+		}
+		if ve.Message != err.Error() || ve.Message == "" {
+			t.Errorf("%s: message %q differs from error %q", c.name, ve.Message, err)
+		}
+		if c.sentinel != nil && !errors.Is(err, c.sentinel) {
+			t.Errorf("%s: want errors.Is %v, got %v", c.name, c.sentinel, err)
+		}
+	}
+}
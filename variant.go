@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// 选择的版本没有注册
+var ErrVariantNotRegistered = errors.New("worker variant is not registered")
+
+// 工作节点通过 AddWorkerNode 添加的原始实现的版本名
+const DefaultVariant = "default"
+
+// 为节点选择本次执行使用的版本，versions 为节点所有的版本，第一个为 DefaultVariant
+// 返回空字符串时使用 DefaultVariant
+type VariantSelector func(ctx context.Context, node string, versions []string) string
+
+type workerVariant struct {
+	version  string
+	actionId string
+}
+
+// 为已经添加的工作节点注册另一个版本的实现，执行时由 WithVariantSelector 选择
+func (m *Manager) AddWorkerVariant(name string, version string, f WorkerFunc) error {
+	node, ok := m.nodes[name]
+	if !ok || node.Typ != NodeTypWorker {
+		return fmt.Errorf("worker node[%s] cannot be found in nodes", name)
+	}
+	if version == "" || version == DefaultVariant {
+		return fmt.Errorf("worker node[%s] variant version[%s] is reserved", name, version)
+	}
+	for _, v := range node.variants {
+		if v.version == version {
+			return fmt.Errorf("worker node[%s] variant version[%s] is duplicate", name, version)
+		}
+	}
+	actionId := fmt.Sprintf("%s-%d", NodeTypWorker, len(m.actionMap)+1)
+	m.actionMap[actionId] = f
+	node.variants = append(node.variants, workerVariant{version: version, actionId: actionId})
+	return nil
+}
+
+// 设置选择工作节点版本的方法，每次执行中每个节点只选择一次，选择的版本记录在执行轨迹中
+func WithVariantSelector(f VariantSelector) Option {
+	return func(m *Manager) {
+		m.variantSelector = f
+	}
+}
+
+// 选择节点本次执行使用的版本
+func (e *execution) selectVariant(ctx context.Context, node *Node) (WorkerFunc, string, error) {
+	if version, ok := e.variants[node]; ok {
+		action, err := e.variantAction(node, version)
+		return action, version, err
+	}
+	version := DefaultVariant
+	if e.m.variantSelector != nil {
+		versions := make([]string, 0, len(node.variants)+1)
+		versions = append(versions, DefaultVariant)
+		for _, v := range node.variants {
+			versions = append(versions, v.version)
+		}
+		if v := e.m.variantSelector(ctx, node.nodeName, versions); v != "" {
+			version = v
+		}
+	}
+	if e.variants == nil {
+		e.variants = make(map[*Node]string)
+	}
+	e.variants[node] = version
+	action, err := e.variantAction(node, version)
+	return action, version, err
+}
+
+func (e *execution) variantAction(node *Node, version string) (WorkerFunc, error) {
+	if version == DefaultVariant {
+		return e.m.actionMap[node.actionId].(WorkerFunc), nil
+	}
+	for _, v := range node.variants {
+		if v.version == version {
+			return e.m.actionMap[v.actionId].(WorkerFunc), nil
+		}
+	}
+	return nil, runtimeError(node, ErrVariantNotRegistered, "variant is not registered", fmt.Sprintf("version[%s]", version))
+}
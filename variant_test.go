@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// 测试按选择器的结果执行不同版本，并记录在执行轨迹中
+func TestManager_WorkerVariant(t *testing.T) {
+	var selected []string
+	choice := ""
+	m := NewManager(WithVariantSelector(func(ctx context.Context, node string, versions []string) string {
+		selected = append(selected, node)
+		if !reflect.DeepEqual(versions, []string{DefaultVariant, "v2"}) {
+			t.Errorf("unexpected versions %v", versions)
+		}
+		return choice
+	}))
+	_ = m.AddWorkerNode("score", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: "v1"}, nil
+	})
+	if err := m.AddWorkerVariant("score", "v2", func(ctx context.Context, in *rawData) (*rawData, error) {
+		return &rawData{Data: "v2"}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddWorkerVariant("score", "v2", passWorker); err == nil {
+		t.Error("expected duplicate variant error")
+	}
+	if err := m.BuildPipeline([][]string{{Head, "score"}, {"score", Tail}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct{ choice, expected, variant string }{
+		{"", "v1", DefaultVariant},
+		{"v2", "v2", "v2"},
+		{DefaultVariant, "v1", DefaultVariant},
+	} {
+		choice = c.choice
+		trace := &Trace{}
+		out, err := m.HandleContext(context.Background(), &rawData{}, WithTrace(trace))
+		if err != nil || out.Data != c.expected {
+			t.Errorf("choice %q: expected %s, got %v, %v", c.choice, c.expected, out, err)
+			continue
+		}
+		if v := trace.Entries()[0].Variant; v != c.variant {
+			t.Errorf("choice %q: expected variant %s in trace, got %s", c.choice, c.variant, v)
+		}
+	}
+	if len(selected) != 3 {
+		t.Errorf("expected one selection per execution, got %v", selected)
+	}
+
+	choice = "v3"
+	if _, err := m.Handle(&rawData{}); err == nil || !errors.Is(err, ErrVariantNotRegistered) {
+		t.Errorf("expected unregistered variant error, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 序列化格式的版本，形如 major.minor
+// minor 升级保持兼容，旧的minor 版本在导入时会被迁移；major 升级不兼容
+//
+// 1.0：没有 version 字段，节点的处理方法写在 action 字段或旧的 func 字段中
+// 1.1：增加 version 字段，处理方法只写在 action 字段中
+const FormatVersion = "1.1"
+
+var ErrUnsupportedFormatVersion = errors.New("unsupported format version")
+
+// 解析版本号，空版本视为1.0
+func parseFormatVersion(v string) (major, minor int, err error) {
+	if v == "" {
+		return 1, 0, nil
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("%w: %q", ErrUnsupportedFormatVersion, v)
+	}
+	if major, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, fmt.Errorf("%w: %q", ErrUnsupportedFormatVersion, v)
+	}
+	if minor, err = strconv.Atoi(parts[1]); err != nil {
+		return 0, 0, fmt.Errorf("%w: %q", ErrUnsupportedFormatVersion, v)
+	}
+	return major, minor, nil
+}
+
+// 检查版本是否兼容：major 比当前新的拒绝
+func checkFormatVersion(v string) (major, minor int, err error) {
+	if major, minor, err = parseFormatVersion(v); err != nil {
+		return
+	}
+	curMajor, _, _ := parseFormatVersion(FormatVersion)
+	if major > curMajor {
+		err = fmt.Errorf("%w: version %s is newer than the supported %s, please upgrade the pipeline package",
+			ErrUnsupportedFormatVersion, v, FormatVersion)
+	} else if major < curMajor {
+		err = fmt.Errorf("%w: version %s is too old to migrate to %s", ErrUnsupportedFormatVersion, v, FormatVersion)
+	}
+	return
+}
+
+// 1.0 版本的节点配置
+type nodeConfigV10 struct {
+	Name   string  `json:"name" yaml:"name"`
+	Typ    NodeTyp `json:"type" yaml:"type"`
+	Action string  `json:"action" yaml:"action"`
+	Func   string  `json:"func" yaml:"func"`
+}
+
+type pipelineConfigV10 struct {
+	Nodes []nodeConfigV10 `json:"nodes" yaml:"nodes"`
+	Edges [][]string      `json:"edges" yaml:"edges"`
+}
+
+// 按版本解码配置，旧的minor 版本迁移到当前版本
+func decodeConfig(data []byte, unmarshal func([]byte, interface{}) error) (*PipelineConfig, error) {
+	var head struct {
+		Version string `json:"version" yaml:"version"`
+	}
+	if err := unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+	_, minor, err := checkFormatVersion(head.Version)
+	if err != nil {
+		return nil, err
+	}
+	if minor == 0 {
+		var old pipelineConfigV10
+		if err = unmarshal(data, &old); err != nil {
+			return nil, err
+		}
+		cfg := &PipelineConfig{Version: FormatVersion, Edges: old.Edges}
+		for _, n := range old.Nodes {
+			action := n.Action
+			if action == "" {
+				action = n.Func
+			}
+			cfg.Nodes = append(cfg.Nodes, NodeConfig{Name: n.Name, Typ: n.Typ, Action: action})
+		}
+		return cfg, nil
+	}
+	var cfg PipelineConfig
+	if err = unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
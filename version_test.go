@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// 测试当前版本导出后再导入，结果不变
+func TestExportJSON_RoundTrip(t *testing.T) {
+	reg := newTeamRegistry(t)
+	m, err := LoadJSON(strings.NewReader(fetchConfig), reg, WithNamespace("team-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := m.ExportJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"version": "`+FormatVersion+`"`)) {
+		t.Errorf("export missing version:\n%s", data)
+	}
+	m2, err := LoadJSON(bytes.NewReader(data), reg, WithNamespace("team-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data2, err := m2.ExportJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, data2) {
+		t.Errorf("round trip changed export:\n%s\n%s", data, data2)
+	}
+}
+
+// 测试旧的minor 版本被迁移
+func TestLoadJSON_MigrateOldVersion(t *testing.T) {
+	f, err := os.Open("testdata/pipeline_v1.0.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m, err := LoadJSON(f, newTeamRegistry(t), WithNamespace("team-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := m.Handle(&rawData{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Data != "a" {
+		t.Errorf("out=%v, want a", out.Data)
+	}
+	data, err := m.ExportJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"action": "fetch"`)) {
+		t.Errorf("migrated export should use action:\n%s", data)
+	}
+}
+
+// 测试更新的major 版本被拒绝
+func TestLoadJSON_RejectNewerMajor(t *testing.T) {
+	f, err := os.Open("testdata/pipeline_v2.0.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, err = LoadJSON(f, newTeamRegistry(t), WithNamespace("team-a"))
+	if !errors.Is(err, ErrUnsupportedFormatVersion) || !strings.Contains(err.Error(), "newer than the supported") {
+		t.Errorf("err=%v, want newer version rejected", err)
+	}
+}
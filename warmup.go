@@ -0,0 +1,202 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 预热中一次执行的失败
+type WarmupFailure struct {
+	// 第几次执行，从1 开始
+	Iteration int
+	// 本次执行强制使用的判断节点决策，没有强制时为nil
+	Decisions Decisions
+	Err       error
+}
+
+// 预热中所有失败的执行
+type WarmupError struct {
+	Failures []WarmupFailure
+}
+
+func (e *WarmupError) Error() string {
+	msgs := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		msgs[i] = fmt.Sprintf("iteration %d", f.Iteration)
+		if f.Decisions != nil {
+			msgs[i] += " decisions " + f.Decisions.String()
+		}
+		msgs[i] += ": " + f.Err.Error()
+	}
+	return fmt.Sprintf("warmup: %d executions failed: %s", len(e.Failures), strings.Join(msgs, "; "))
+}
+
+// 返回第一次失败的错误
+func (e *WarmupError) Unwrap() error {
+	return e.Failures[0].Err
+}
+
+// 按判断节点名排序的决策，形如 {j1:0 j2:1}
+func (d Decisions) String() string {
+	names := make([]string, 0, len(d))
+	for name := range d {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%s:%d", name, d[name])
+	}
+	return "{" + strings.Join(names, " ") + "}"
+}
+
+// 预热执行的标记，以及执行中判断节点的决策
+type warmupRun struct {
+	decisions Decisions
+}
+
+func withWarmup(w *warmupRun) CallOption {
+	return func(o *callOptions) {
+		o.warmup = w
+		o.noDeadLetter = true
+	}
+}
+
+// 用synthetic 的副本执行iterations 次流水线并丢弃输出，用于在接收流量之前预热节点中的连接池、缓存等
+// 强制判断节点的决策，直到执行到的判断节点的每个分支都至少执行过一次，因此执行次数可能多于iterations；
+// 之后的执行不再强制决策。预热的执行不保存死信，NodeEvent、TraceEntry 和执行历史中 Warmup 为true
+// 所有执行失败都会记录，返回 WarmupError，其中包括失败的执行序号和强制使用的决策
+func (m *Manager) Warmup(ctx context.Context, synthetic *rawData, iterations int, opts ...CallOption) error {
+	if !m.built {
+		return ErrorsPipelineNotBuilt
+	}
+	// 执行到过的判断节点已经执行过的分支
+	covered := make(map[*Node][]bool)
+	forcing := m.hasJudger()
+	var failures []WarmupFailure
+	for i := 1; ; i++ {
+		var decisions Decisions
+		if forcing {
+			decisions = m.warmupDecisions(covered, i == 1)
+			forcing = decisions != nil
+		}
+		if !forcing && i > iterations {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		run := &warmupRun{decisions: make(Decisions)}
+		callOpts := append(opts[:len(opts):len(opts)], withWarmup(run))
+		if forcing {
+			callOpts = append(callOpts, WithForcedDecisions(decisions))
+		}
+		if _, err := m.HandleContext(ctx, cloneData(synthetic), callOpts...); err != nil {
+			failures = append(failures, WarmupFailure{Iteration: i, Decisions: decisions, Err: err})
+		}
+		progress := false
+		for name, branch := range run.decisions {
+			node := m.nodes[name]
+			if covered[node] == nil {
+				covered[node] = make([]bool, len(node.Next))
+			}
+			if !covered[node][branch] {
+				covered[node][branch], progress = true, true
+			}
+		}
+		if forcing && !progress {
+			// 没有执行到新的分支，剩下的分支执行不到（例如前面的节点失败），不再强制决策
+			forcing = false
+		}
+	}
+	if len(failures) > 0 {
+		return &WarmupError{Failures: failures}
+	}
+	return nil
+}
+
+// 每个判断节点选择第一个还没有执行过的分支；分支都执行过的判断节点选择能到达还有分支没有执行过
+// （或者从未执行到过）的判断节点的分支。没有需要执行的分支时返回nil
+// 第一次执行时还不知道会执行到哪些判断节点，都选择第0 个分支
+func (m *Manager) warmupDecisions(covered map[*Node][]bool, first bool) Decisions {
+	pending := make(map[*Node]bool)
+	for _, node := range m.nodes {
+		if node.Typ != NodeTypJudger {
+			continue
+		}
+		branches, reached := covered[node]
+		for _, done := range branches {
+			if !done {
+				pending[node] = true
+			}
+		}
+		if !reached {
+			pending[node] = true
+		}
+	}
+	if !first && !reachedPending(covered, pending) {
+		return nil
+	}
+	d := make(Decisions)
+	for _, node := range m.nodes {
+		if node.Typ != NodeTypJudger {
+			continue
+		}
+		d[node.nodeName] = 0
+		if branches := covered[node]; branches != nil {
+			d[node.nodeName] = warmupBranch(node, branches, pending)
+		}
+	}
+	return d
+}
+
+// 执行到过的判断节点中是否还有没有执行过的分支
+func reachedPending(covered map[*Node][]bool, pending map[*Node]bool) bool {
+	for node := range covered {
+		if pending[node] {
+			return true
+		}
+	}
+	return false
+}
+
+func warmupBranch(node *Node, branches []bool, pending map[*Node]bool) int {
+	for i, done := range branches {
+		if !done {
+			return i
+		}
+	}
+	for i, next := range node.Next {
+		if reachesAny(next, pending, make(map[*Node]bool)) {
+			return i
+		}
+	}
+	return 0
+}
+
+// 从n 出发能否到达targets 中的节点
+func reachesAny(n *Node, targets, seen map[*Node]bool) bool {
+	if targets[n] {
+		return true
+	}
+	if seen[n] {
+		return false
+	}
+	seen[n] = true
+	for _, next := range n.Next {
+		if reachesAny(next, targets, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) hasJudger() bool {
+	for _, node := range m.nodes {
+		if node.Typ == NodeTypJudger {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// j1 -> a 或 j2，j2 -> b 或 c；判断节点不强制时总是选择第0 个分支
+func newWarmupManager(t *testing.T, calls map[string]int, failing string, opts ...Option) *Manager {
+	m := NewManager(opts...)
+	for _, j := range []string{"j1", "j2"} {
+		if err := m.AddJudgerNode(j, func(ctx context.Context, in *rawData) int { return 0 }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, w := range []string{"a", "b", "c"} {
+		w := w
+		if err := m.AddWorkerNode(w, func(ctx context.Context, in *rawData) (*rawData, error) {
+			calls[w]++
+			in.Meta["touched"] = true
+			if w == failing {
+				return nil, errors.New("cold")
+			}
+			return in, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.BuildPipeline([][]string{
+		{Head, "j1"},
+		{"j1", "a"},
+		{"j1", "j2"},
+		{"j2", "b"},
+		{"j2", "c"},
+		{"a", Tail},
+		{"b", Tail},
+		{"c", Tail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestManager_WarmupCoversBranches(t *testing.T) {
+	calls := make(map[string]int)
+	var events []NodeEvent
+	m := newWarmupManager(t, calls, "", WithListener(func(ev NodeEvent) { events = append(events, ev) }), WithExecutionHistory(10))
+	synthetic := &rawData{Meta: map[string]interface{}{}}
+	if err := m.Warmup(context.Background(), synthetic, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range []string{"a", "b", "c"} {
+		if calls[w] != 1 {
+			t.Errorf("node %s called %d times, want 1", w, calls[w])
+		}
+	}
+	if _, ok := synthetic.Meta["touched"]; ok {
+		t.Error("warmup should not modify the synthetic input")
+	}
+	for _, ev := range events {
+		if !ev.Warmup {
+			t.Errorf("event of node %s should be marked as warmup", ev.Node)
+		}
+	}
+	recent := m.RecentExecutions()
+	if len(recent) != 3 {
+		t.Fatalf("want 3 warmup executions, got %d", len(recent))
+	}
+	for _, s := range recent {
+		if !s.Warmup {
+			t.Errorf("execution %s should be marked as warmup", s.ID)
+		}
+	}
+
+	// 分支都执行过之后不再强制决策，剩下的执行按判断节点的结果
+	if err := m.Warmup(context.Background(), synthetic, 5); err != nil {
+		t.Fatal(err)
+	}
+	if calls["a"] != 1+3 || calls["b"] != 2 || calls["c"] != 2 {
+		t.Errorf("unexpected calls after second warmup: %v", calls)
+	}
+}
+
+func TestManager_WarmupErrors(t *testing.T) {
+	calls := make(map[string]int)
+	m := newWarmupManager(t, calls, "b")
+	err := m.Warmup(context.Background(), &rawData{Meta: map[string]interface{}{}}, 1)
+	var werr *WarmupError
+	if !errors.As(err, &werr) {
+		t.Fatalf("want WarmupError, got %v", err)
+	}
+	if len(werr.Failures) != 1 || werr.Failures[0].Iteration != 2 {
+		t.Fatalf("unexpected failures: %+v", werr.Failures)
+	}
+	if !strings.Contains(err.Error(), "iteration 2 decisions {j1:1 j2:0}: cold") {
+		t.Errorf("error should name the iteration and decisions: %v", err)
+	}
+	var nodeErr *NodeError
+	if !errors.As(err, &nodeErr) || nodeErr.Node != "b" {
+		t.Errorf("error should unwrap to the node error, got %v", err)
+	}
+	if calls["c"] != 1 {
+		t.Errorf("warmup should continue after a failure, c called %d times", calls["c"])
+	}
+}